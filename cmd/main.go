@@ -9,12 +9,16 @@ import (
 
 	"github.com/msniranjan18/chit-chat/config"
 
-	"github.com/msniranjan18/common/jwt"
 	"github.com/msniranjan18/common/middleware/logging"
 
 	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/moderation"
+	"github.com/msniranjan18/chit-chat/pkg/push"
 	"github.com/msniranjan18/chit-chat/pkg/routes"
 	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/token"
+	"github.com/msniranjan18/chit-chat/pkg/webhook"
 
 	_ "github.com/msniranjan18/chit-chat/docs"
 )
@@ -42,11 +46,12 @@ func main() {
 
 	// 1. Initialize Storage
 	slog.Info("Initializing storage...")
-	storage, err := store.NewStore(ctx, cfg.Database.URL, cfg.Redis.URL, logger)
+	storage, err := store.NewStore(ctx, cfg.Database.URL, cfg.Redis.URL, cfg.Redis.Optional, cfg.Database.SlowQueryTime, logger)
 	if err != nil {
 		slog.Error("Failed to connect to storage", "error", err)
 		os.Exit(1)
 	}
+	storage.MembershipActivity = cfg.Chat.MembershipActivity
 	defer func() {
 		if err := storage.Close(); err != nil {
 			slog.Error("Failed to close storage", "error", err)
@@ -60,24 +65,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Start cleanup worker
-	go storage.StartCleanupWorker(1*time.Hour, 24*time.Hour*30)
-	slog.Debug("Cleanup worker started", "interval", "1h", "max_age", "30d")
-
 	// 2. Initialize JWT authentication
 	slog.Info("Initializing authentication...")
-	jwt.InitJWT(cfg.JWT.Secret)
+	token.InitJWT(cfg.JWT.Secret, cfg.JWT.Expiration)
 
 	// 3. Initialize WebSocket Hub
 	slog.Info("Initializing WebSocket hub...")
-	wsHub := hub.NewHub(storage, logger)
+	webhooks := webhook.NewDispatcher(storage, cfg.Webhook, logger)
+	moderator, err := moderation.New(cfg.Moderation)
+	if err != nil {
+		slog.Error("Failed to configure content moderation", "error", err)
+		os.Exit(1)
+	}
+	notifiers, err := push.NewNotifiers(cfg.Push)
+	if err != nil {
+		slog.Error("Failed to configure push notifications", "error", err)
+		os.Exit(1)
+	}
+	pushes := push.NewDispatcher(storage, notifiers, cfg.Push, logger)
+	slog.Info("Push notifications configured", "platforms", len(notifiers))
+	wsHub := hub.NewHub(storage, webhooks, pushes, moderator, cfg.WebSocket, cfg.Chat, logger)
 	go wsHub.Run()
 	go wsHub.ListenToRedis()
 	slog.Debug("WebSocket hub initialized and running")
 
+	// Start cleanup worker. It runs after the hub is up so members can be told when
+	// disappearing messages are purged.
+	retention := map[models.ChatType]time.Duration{
+		models.ChatTypeDirect:  cfg.Retention.Direct,
+		models.ChatTypeGroup:   cfg.Retention.Group,
+		models.ChatTypeChannel: cfg.Retention.Channel,
+	}
+	go storage.StartCleanupWorker(1*time.Hour, 24*time.Hour*30, retention, func(chatID string, messageIDs []string) {
+		wsHub.BroadcastChatUpdate(chatID, models.ChatUpdateEvent{
+			Event:      models.ChatUpdateEventMessagesExpired,
+			ChatID:     chatID,
+			MessageIDs: messageIDs,
+		})
+	})
+	slog.Debug("Cleanup worker started", "interval", "1h", "max_age", "30d")
+
 	// 4. Initialize HTTP router
 	slog.Info("Setting up routes...")
-	router := routes.NewRouter(wsHub, storage, logger)
+	router := routes.NewRouter(cfg, wsHub, storage, logger)
 
 	// Apply middleware
 	handler := logging.LoggingMiddleware(router, logger)
@@ -15,6 +15,107 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/admin/chats/{id}/rebuild-cache": {
+            "post": {
+                "description": "Operator-only. Drops the chat's cached messages and members and its members' unread counters, then re-warms them from the database. Use it when the caches have drifted, for example after editing the database by hand. Requires X-Admin-Token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rebuild a chat's caches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin token (ADMIN_TOKEN)",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.CacheRebuildResult"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Cache is not available",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/connections": {
+            "get": {
+                "description": "Operator-only. Lists the clients connected to the instance that serves the request, per user, with each client's session, joined chat rooms and last ping round trip. Other instances' clients are not included. Requires X-Admin-Token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List connected WebSocket clients",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin token (ADMIN_TOKEN)",
+                        "name": "X-Admin-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ConnectionsSnapshot"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/api/auth/login": {
             "post": {
                 "description": "Authenticates a user by phone number and returns a new session token.",
@@ -69,7 +170,7 @@ const docTemplate = `{
         },
         "/api/auth/logout": {
             "post": {
-                "description": "Deletes the current user session and invalidates the session ID.",
+                "description": "Deletes the current user session. Its access token is refused from then on and its WebSocket connections are closed.",
                 "tags": [
                     "auth"
                 ],
@@ -98,7 +199,10 @@ const docTemplate = `{
         },
         "/api/auth/refresh": {
             "post": {
-                "description": "Takes an existing valid JWT and returns a new token with an extended expiration time.",
+                "description": "Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; reusing one revokes its session, refusing its access tokens and closing its WebSocket connections.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
@@ -108,24 +212,33 @@ const docTemplate = `{
                 "summary": "Refresh session token",
                 "parameters": [
                     {
-                        "type": "string",
-                        "default": "Bearer \u003ctoken\u003e",
-                        "description": "Insert your Bearer token",
-                        "name": "Authorization",
-                        "in": "header",
-                        "required": true
+                        "description": "Refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.RefreshRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Returns new token and expires_at",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": true
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "401": {
-                        "description": "Invalid or missing token",
+                        "description": "Invalid, expired or reused refresh token",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -223,45 +336,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/chats": {
-            "get": {
-                "description": "Retrieve a list of all chats (Direct and Group) that the current user is a member of",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "chats"
-                ],
-                "summary": "Get user chats",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatListResponse"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    }
-                }
-            },
+        "/api/bots": {
             "post": {
-                "description": "Create a new direct chat (1-on-1) or group chat",
+                "description": "Create a service account owned by the current user. Bots have no phone login; they send messages with API keys, into chats an admin has added them to.",
                 "consumes": [
                     "application/json"
                 ],
@@ -269,35 +346,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "chats"
+                    "bots"
                 ],
-                "summary": "Create a new chat",
+                "summary": "Create a bot",
                 "parameters": [
                     {
-                        "description": "Chat Creation Request",
-                        "name": "chat",
+                        "description": "Bot name",
+                        "name": "bot",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatRequest"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BotRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Returned if direct chat already exists",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
-                        }
-                    },
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Bot"
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid name",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -317,64 +388,20 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/chats/search": {
-            "get": {
-                "description": "Search for chats by name or description",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "chats"
-                ],
-                "summary": "Search user chats",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by type (direct/group)",
-                        "name": "type",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Limit results",
-                        "name": "limit",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
-                            }
-                        }
-                    }
-                }
-            }
-        },
-        "/api/chats/{id}": {
-            "get": {
-                "description": "Retrieve detailed information about a specific chat, including member list and user details",
+        "/api/bots/{id}": {
+            "delete": {
+                "description": "Delete one of the current user's bots, removing it from all chats and revoking its API keys",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "chats"
+                    "bots"
                 ],
-                "summary": "Get chat details",
+                "summary": "Delete a bot",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Chat ID",
+                        "description": "Bot ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -384,7 +411,10 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "401": {
@@ -397,7 +427,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Chat not found",
+                        "description": "Bot not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -406,9 +436,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update name, description, or settings for a specific chat (Admins only)",
+            }
+        },
+        "/api/bots/{id}/keys": {
+            "post": {
+                "description": "Issue an API key for one of the current user's bots. The key is sent in the X-API-Key header, may only be used to send messages, and only to the listed chats the bot is a member of. It is only returned here; just its hash is stored.",
                 "consumes": [
                     "application/json"
                 ],
@@ -416,36 +448,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "chats"
+                    "bots"
                 ],
-                "summary": "Update chat details",
+                "summary": "Create a bot API key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Chat ID",
+                        "description": "Bot ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Chat Update Fields",
-                        "name": "updates",
+                        "description": "Chats the key may send to",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatUpdateRequest"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.APIKeyRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.APIKey"
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid chat list",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -454,7 +495,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Chat not found",
+                        "description": "Bot not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -463,66 +504,55 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/bots/{id}/keys/{keyId}": {
             "delete": {
-                "description": "Permanently delete a chat and all its messages (Creator only)",
+                "description": "Revoke one of the API keys of the current user's bot",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "chats"
+                    "bots"
                 ],
-                "summary": "Delete a chat",
+                "summary": "Revoke a bot API key",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Chat ID",
+                        "description": "Bot ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "keyId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "403": {
-                        "description": "Forbidden - Only creator can delete",
-                        "schema": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
                     },
-                    "404": {
-                        "description": "Chat not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        },
-        "/api/chats/{id}/leave": {
-            "post": {
-                "description": "Remove yourself from a group chat",
-                "tags": [
-                    "chats"
-                ],
-                "summary": "Leave a chat",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Chat ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Left chat successfully",
+                    },
+                    "404": {
+                        "description": "Bot or key not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -533,35 +563,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/chats/{id}/members": {
+        "/api/chats": {
             "get": {
-                "description": "Retrieve a list of all members in a specific chat, including their roles and join dates. The requester must be a member of the chat.",
+                "description": "Retrieve a list of all chats (Direct and Group) that the current user is a member of",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "chats"
                 ],
-                "summary": "Get members of a chat",
+                "summary": "Get user chats",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Chat ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
-                            }
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatListResponse"
                         }
                     },
+                    "304": {
+                        "description": "Not modified since the given ETag"
+                    },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
@@ -571,15 +600,6 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Chat not found or access denied",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -592,35 +612,61 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Add a new user to an existing group chat",
+                "description": "Create a new direct chat (1-on-1) or group chat. A direct chat with the requester's own user ID is their private \"Saved Messages\" chat for notes; like other direct chats, asking again returns the existing one.",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "chats"
                 ],
-                "summary": "Add member to chat",
+                "summary": "Create a new chat",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Chat ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Member Details",
-                        "name": "member",
+                        "description": "Chat Creation Request",
+                        "name": "chat",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRequest"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatRequest"
                         }
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "Returned if direct chat already exists",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
+                        }
+                    },
                     "201": {
-                        "description": "Member added successfully",
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, name or description",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "User is not accepting messages from you",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -631,43 +677,69 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/chats/{id}/members/{memberId}": {
-            "delete": {
-                "description": "Remove a specific user from a group chat",
+        "/api/chats/search": {
+            "get": {
+                "description": "Search for chats by name or description",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "chats"
                 ],
-                "summary": "Remove member from chat",
+                "summary": "Search user chats",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Chat ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "User ID to remove",
-                        "name": "memberId",
-                        "in": "path",
-                        "required": true
+                        "description": "Filter by type (direct/group)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to 'array' for a bare array without paging metadata",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatSearchResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/chats/{id}/read": {
-            "post": {
-                "description": "Mark all messages in a chat as read for the current user",
+        "/api/chats/{id}": {
+            "get": {
+                "description": "Retrieve detailed information about a specific chat, including member list and user details",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "chats"
                 ],
-                "summary": "Mark chat as read",
+                "summary": "Get chat details",
                 "parameters": [
                     {
                         "type": "string",
@@ -679,39 +751,22 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Chat marked as read",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        },
-        "/api/contacts": {
-            "get": {
-                "description": "Retrieve the contact list for the current user",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "contacts"
-                ],
-                "summary": "Get user contacts",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
-                            }
-                        }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Chat not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -721,8 +776,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "Add another user to the current user's contact list",
+            "put": {
+                "description": "Update name, description, or settings for a specific chat (Admins only)",
                 "consumes": [
                     "application/json"
                 ],
@@ -730,23 +785,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "contacts"
+                    "chats"
                 ],
-                "summary": "Add a contact",
+                "summary": "Update chat details",
                 "parameters": [
                     {
-                        "description": "Contact Request (user_id required)",
-                        "name": "contact",
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Chat Update Fields",
+                        "name": "updates",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatUpdateRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Contact added",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, name or description, or pinned chat limit reached",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -754,8 +822,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "404": {
+                        "description": "Chat not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -763,8 +831,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "409": {
+                        "description": "Chat changed since expected_updated_at",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -773,19 +841,17 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/contacts/{id}": {
+            },
             "delete": {
-                "description": "Delete a user from the current user's contact list",
+                "description": "Permanently delete a chat and all its messages (Creator only). Deleting a direct chat instead removes it for the requester only, as leaving it does: the other participant keeps it closed until they delete it too.",
                 "tags": [
-                    "contacts"
+                    "chats"
                 ],
-                "summary": "Remove a contact",
+                "summary": "Delete a chat",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Contact User ID",
+                        "description": "Chat ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -795,8 +861,8 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
-                    "400": {
-                        "description": "ID required",
+                    "403": {
+                        "description": "Forbidden - Only creator can delete",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -804,8 +870,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Chat not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -816,34 +882,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/messages": {
+        "/api/chats/{id}/audit": {
             "get": {
-                "description": "Retrieve a paginated list of messages for a specific chat. Requires chat_id and supports pagination via 'before' (message_id) and 'limit'.",
+                "description": "Retrieve the moderation history of a chat (members added or removed, history cleared, chat deleted), newest first. Only owners and admins can view it.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Get messages from a chat",
+                "summary": "Get a chat's audit log",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "Chat ID",
-                        "name": "chat_id",
-                        "in": "query",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Message ID to get messages before (for pagination)",
-                        "name": "before",
+                        "type": "integer",
+                        "description": "Maximum number of events to return (default 20, max 100)",
+                        "name": "limit",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Number of messages to return (default 20, max 50)",
-                        "name": "limit",
+                        "description": "Number of events to skip",
+                        "name": "offset",
                         "in": "query"
                     }
                 ],
@@ -851,14 +917,11 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
-                            }
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.AuditLogResponse"
                         }
                     },
-                    "400": {
-                        "description": "Chat ID required",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -866,8 +929,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Only owners and admins can view the audit log",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -885,9 +948,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/chats/{id}/clear": {
             "post": {
-                "description": "Send a new message to a specific chat (Direct or Group).",
+                "description": "Clear existing messages without deleting the chat. Scope \"me\" (default) hides them for the requester only. Scope \"everyone\" deletes them for all members and is allowed for group admins and for either participant of a direct chat.",
                 "consumes": [
                     "application/json"
                 ],
@@ -895,29 +960,56 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Send a message",
+                "summary": "Clear chat history",
                 "parameters": [
                     {
-                        "description": "Message Details",
-                        "name": "message",
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Clear scope",
+                        "name": "scope",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageRequest"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ClearHistoryRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "Chat history cleared",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid scope",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Only admins can clear history for everyone",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -926,7 +1018,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Chat not found",
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -937,35 +1029,29 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/messages/search": {
+        "/api/chats/{id}/export": {
             "get": {
-                "description": "Search for text within messages of a specific chat.",
+                "description": "Stream all non-deleted messages of a chat (with sender names resolved) as a downloadable JSON array, or as newline-delimited JSON when format=ndjson. The requester must be a member of the chat.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/x-ndjson"
                 ],
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Search messages",
+                "summary": "Export chat history",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "Chat ID",
-                        "name": "chat_id",
-                        "in": "query",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Limit results",
-                        "name": "limit",
+                        "description": "Export format (json/ndjson, default json)",
+                        "name": "format",
                         "in": "query"
                     }
                 ],
@@ -980,7 +1066,25 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Query required",
+                        "description": "Invalid format",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -991,46 +1095,43 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/messages/{id}": {
-            "put": {
-                "description": "Update the text content of a previously sent message. Only the sender can edit their own message.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/chats/{id}/group": {
+            "get": {
+                "description": "Retrieve a group chat together with its members, settings and activity stats in one call. Stats and the join link are only included for owners and admins.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Edit a message",
+                "summary": "Get group details",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Message ID",
+                        "description": "Chat ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "New Content",
-                        "name": "updates",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageUpdateRequest"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.GroupResponse"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden - Not the sender",
+                    "400": {
+                        "description": "Chat is not a group",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1039,7 +1140,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Message not found",
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1048,25 +1149,54 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Permanently delete a message for all participants.",
+            }
+        },
+        "/api/chats/{id}/group/settings": {
+            "patch": {
+                "description": "Change a group's settings. Only fields present in the body are changed. Owners can always edit settings; admins only while admins_can_edit is on. Setting disappearing_ttl (seconds, up to 90 days) makes messages sent from then on disappear that long after they are sent; 0 turns it off. Members are notified with a settings_updated chat update.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Delete a message",
+                "summary": "Update group settings",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Message ID",
+                        "description": "Chat ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Settings to change",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.GroupSettingsRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.GroupSettings"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid settings or chat is not a group",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     },
                     "401": {
                         "description": "Unauthorized",
@@ -1077,8 +1207,17 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Not allowed to edit settings",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "404": {
-                        "description": "Message not found",
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1089,17 +1228,17 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/messages/{id}/read": {
+        "/api/chats/{id}/leave": {
             "post": {
-                "description": "Updates the status of a specific message to 'read'.",
+                "description": "Remove yourself from a chat. Leaving a direct chat closes it: the other participant keeps its history but can no longer send to it, and starting a new direct chat with them creates a fresh one. The chat is deleted once both have left. Leaving a chat you are not in succeeds without changes.",
                 "tags": [
-                    "messages"
+                    "chats"
                 ],
-                "summary": "Mark message as read",
+                "summary": "Leave a chat",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Message ID",
+                        "description": "Chat ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -1107,7 +1246,16 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Marked as read",
+                        "description": "Left chat successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "User is the group's last owner",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1118,21 +1266,30 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/users/me": {
+        "/api/chats/{id}/me": {
             "get": {
-                "description": "Retrieve the profile details of the currently authenticated user",
+                "description": "Return the requester's membership in a chat (role, display name, mute and ban state) with the permissions that follow from their role and the group's settings, so clients know which controls to show.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
+                ],
+                "summary": "Get my membership in a chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get current user profile",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MyMembership"
                         }
                     },
                     "401": {
@@ -1145,7 +1302,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1154,39 +1311,54 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update name or status for the current user",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/chats/{id}/media": {
+            "get": {
+                "description": "List the photos, videos and other attachments shared in a chat, newest first, with each one's thumbnail, sender and send time. Messages the requester cleared from their history are left out.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
                 ],
-                "summary": "Update user profile",
+                "summary": "Get a chat's media gallery",
                 "parameters": [
                     {
-                        "description": "User Update Request",
-                        "name": "updates",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserUpdateRequest"
-                        }
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this media type (image, video, audio, document, sticker); all when omitted",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MediaResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid media type",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1202,27 +1374,76 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        },
-        "/api/users/online": {
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/members": {
             "get": {
-                "description": "Retrieve a list of users currently marked as online",
+                "description": "Retrieve the members of a specific chat, including their roles and join dates. The requester must be a member of the chat. Members are ordered by join time, or by role (owners first) and then join time with sort=role. Without limit or offset every member is returned as a plain array; with either, a ChatMembersResponse holding one page and the total member count is returned.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
+                ],
+                "summary": "Get members of a chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "joined",
+                            "role"
+                        ],
+                        "type": "string",
+                        "description": "Member order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20, max 100); switches to a paged response",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of members to skip; switches to a paged response",
+                        "name": "offset",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get online users",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid sort",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
@@ -1234,47 +1455,66 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
-            }
-        },
-        "/api/users/search": {
-            "get": {
-                "description": "Find users by phone number or name",
-                "produces": [
+            },
+            "post": {
+                "description": "Add a new user to an existing group chat. Only admins and owners can add members, and only owners can add them as admins or owners.",
+                "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
                 ],
-                "summary": "Search for users",
+                "summary": "Add member to chat",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Limit results (default 20)",
-                        "name": "limit",
-                        "in": "query"
+                        "description": "Member Details",
+                        "name": "member",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Member added successfully",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
                     "400": {
-                        "description": "Query required",
+                        "description": "Cannot add members to a direct chat",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1282,8 +1522,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Not allowed to add members or grant this role, or the group has reached the member limit",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1294,23 +1543,50 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/users/sessions": {
-            "get": {
-                "description": "Retrieve the active login sessions for the current user",
+        "/api/chats/{id}/members/me": {
+            "patch": {
+                "description": "Set or clear the requesting member's per-chat nickname. An empty display_name clears it.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
+                ],
+                "summary": "Set my display name in a chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Display name",
+                        "name": "member",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberUpdateRequest"
+                        }
+                    }
                 ],
-                "summary": "Get user sessions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserSession"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
@@ -1322,38 +1598,69 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
-        "/api/users/{id}": {
+        "/api/chats/{id}/members/search": {
             "get": {
-                "description": "Retrieve profile details for a specific user ID",
+                "description": "Find members of a chat whose name or per-chat display name contains the query, case-insensitively, ordered by the name they are shown under, so clients can locate someone in a large group without loading the whole member list. Banned members are left out.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "chats"
                 ],
-                "summary": "Get user by ID",
+                "summary": "Search a chat's members",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User ID",
+                        "description": "Chat ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 50)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberSearchResponse"
                         }
                     },
                     "400": {
-                        "description": "ID required",
+                        "description": "Search query required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1362,7 +1669,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1373,31 +1680,43 @@ const docTemplate = `{
                 }
             }
         },
-        "/ws": {
+        "/api/chats/{id}/members/summary": {
             "get": {
-                "description": "Upgrades the HTTP connection to a WebSocket for real-time messaging. Requires a valid JWT token as a query parameter.",
+                "description": "Return the number of members in a chat, in total and by role, so clients can show a header such as \"256 members\" without loading the member list. Banned members are not counted.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "websocket"
+                    "chats"
                 ],
-                "summary": "Establish WebSocket connection",
+                "summary": "Count a chat's members by role",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Valid JWT Token",
-                        "name": "token",
-                        "in": "query",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "101": {
-                        "description": "Switching Protocols",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MemberRoleCounts"
                         }
                     },
                     "401": {
-                        "description": "Invalid or missing token",
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -1407,330 +1726,3771 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "github_com_msniranjan18_chit-chat_pkg_models.AuthRequest": {
-            "type": "object",
-            "properties": {
-                "device_id": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "phone": {
-                    "type": "string"
-                }
-            }
-        },
-        "github_com_msniranjan18_chit-chat_pkg_models.AuthResponse": {
-            "type": "object",
-            "properties": {
-                "expires_at": {
-                    "type": "string"
-                },
-                "token": {
-                    "type": "string"
-                },
-                "user": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
-                }
-            }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.Chat": {
+        "/api/chats/{id}/members/{memberId}": {
+            "delete": {
+                "description": "Remove a specific user from a group chat",
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Remove member from chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID to remove",
+                        "name": "memberId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Member is the group's last owner",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Set or clear another member's per-chat nickname (Admins only). An empty display_name clears it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Set a member's display name in a chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Member user ID",
+                        "name": "memberId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Display name",
+                        "name": "member",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Only admins can update other members",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat or member not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/members/{memberId}/role": {
+            "patch": {
+                "description": "Promote or demote a member of a group or channel. Owners may assign any role; admins may only move non-owners between admin, member and viewer. The last owner cannot be demoted. Members are notified with a role_changed chat update.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Change a member's role",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Member user ID",
+                        "name": "memberId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid role, direct chat, or last owner",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not allowed to assign this role",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat or member not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/messages": {
+            "get": {
+                "description": "Diagnostic listing of a chat's messages with the given overall status (e.g. failed), newest first, for finding stuck messages. Only owners and admins can use it; clients should use /api/messages.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Get a chat's messages by status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message status (sent, delivered, read, failed)",
+                        "name": "status",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of messages to return (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessagesByStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners and admins can list messages by status",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/messages/unread": {
+            "get": {
+                "description": "List the messages from others the requester hasn't read yet in a chat, oldest first, so a client can open the chat at its \"new messages\" divider. The first page carries the divider's message ID in first_unread_id. Deleted messages and ones the requester cleared from their history are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Get a chat's unread messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UnreadMessagesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/read": {
+            "post": {
+                "description": "Mark all messages in a chat as read for the current user",
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Mark chat as read",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chat marked as read",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/typing": {
+            "post": {
+                "description": "Show or clear the requester's typing indicator in a chat, for clients without a WebSocket. Members receive the same typing event as when it is sent over a WebSocket, and an indicator that is not refreshed is cleared after a few seconds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Send a typing indicator",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Typing state",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.TypingRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chats/{id}/unread": {
+            "post": {
+                "description": "Flag a chat as unread again as a reminder, by moving the requester's read marker back to just before the given message, or before the latest message from someone else. Read receipts already sent are not withdrawn.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Mark chat as unread",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message to mark unread from",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MarkUnreadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Chat marked as unread",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat or message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/contacts": {
+            "get": {
+                "description": "Retrieve the contact list for the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "contacts"
+                ],
+                "summary": "Get user contacts",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add another user to the current user's contact list. Set mutual to also add the current user to the target's contacts. Adding an existing contact is idempotent and reports created=false.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "contacts"
+                ],
+                "summary": "Add a contact",
+                "parameters": [
+                    {
+                        "description": "Contact Request",
+                        "name": "contact",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ContactRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Contact already existed",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ContactResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Contact added",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ContactResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Cannot add this user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/contacts/bulk": {
+            "post": {
+                "description": "Add up to 500 contacts at once, each named by user_id or by phone number. Phone numbers are resolved the same way as /api/users/lookup. Every entry gets a result in request order: added, skipped (yourself, a duplicate, an existing contact or a blocked user) or not_found.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "contacts"
+                ],
+                "summary": "Add contacts in bulk",
+                "parameters": [
+                    {
+                        "description": "Contacts to add (max 500)",
+                        "name": "contacts",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BulkContactRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BulkContactResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or too many contacts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/contacts/{id}": {
+            "delete": {
+                "description": "Delete a user from the current user's contact list",
+                "tags": [
+                    "contacts"
+                ],
+                "summary": "Remove a contact",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Contact User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "ID required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages": {
+            "get": {
+                "description": "Retrieve a paginated list of messages for a specific chat. Requires chat_id and supports pagination via 'before' (message_id) and 'limit'.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get messages from a chat",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chat_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID to get messages before (for pagination)",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of messages to return (default 50, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Chat ID required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found or access denied",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Send a new message to a specific chat (Direct or Group).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Send a message",
+                "parameters": [
+                    {
+                        "description": "Message Details",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, reply_to, forward_from or media",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "User is not accepting messages from you, or group settings restrict sending",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many messages sent to this chat",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/around": {
+            "get": {
+                "description": "Retrieve up to 'radius' messages before and after a target message in chronological order, for jumping to a search result or reply. Cursors can be passed back as message_id to keep paging in either direction.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get messages around a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chat_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target message ID",
+                        "name": "message_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Messages on each side of the target (default 20, max 50)",
+                        "name": "radius",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessagesAround"
+                        }
+                    },
+                    "400": {
+                        "description": "Chat ID and message ID required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Chat or message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/mentions": {
+            "get": {
+                "description": "List messages in which the current user was mentioned with @{userID}, newest first, across all their chats.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List mentions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of messages to return (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of messages to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/search": {
+            "get": {
+                "description": "Search for text within messages of a specific chat, optionally only those sent within a date range.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Search messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chat_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages sent at or after this RFC 3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages sent at or before this RFC 3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to 'array' for a bare array without paging metadata",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageSearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Query required or invalid date range",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}": {
+            "put": {
+                "description": "Update the text of a previously sent message, or the caption of a media message. The attached media is kept and the content type cannot be changed. Only the sender can edit their own message.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Edit a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New Content",
+                        "name": "updates",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing content, content type change, media message without valid media or content rejected by moderation",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - Not the sender",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a message for all participants. Senders may only do so within DELETE_FOR_EVERYONE_WINDOW of sending it, unless they own or administer the group; after that the message is deleted for them only and a 200 response explains why.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Delete a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Too old to delete for everyone; deleted for the requester only",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageDeleteResponse"
+                        }
+                    },
+                    "204": {
+                        "description": "Deleted for everyone"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Only message sender can delete",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/forward": {
+            "post": {
+                "description": "Forward a message to one or more chats the user belongs to. The number of destination chats per request is capped, and each forward increments the source message's forward_count. An optional comment (up to 1000 characters) is sent in each chat as a text message replying to the forwarded copy.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Forward a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Destination chats and optional comment",
+                        "name": "forward",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ForwardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ForwardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, comment too long or rejected, or too many destination chats",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Sending to a destination chat is not allowed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message or destination chat not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too many messages sent to a destination chat",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/read": {
+            "post": {
+                "description": "Updates the status of a specific message to 'read'.",
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Mark message as read",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Marked as read",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/status": {
+            "get": {
+                "description": "List which members a message has been delivered to and read by. Only available to the sender and chat admins.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get message status summary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageStatusSummary"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - Not the sender or an admin",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/lookup": {
+            "post": {
+                "description": "Contact discovery: returns the users registered with any of the given phone numbers. Numbers are normalized to 10 digits. Only matches are returned, so the response never reveals anything about non-matching numbers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Find registered users by phone number",
+                "parameters": [
+                    {
+                        "description": "Phone numbers (max 500)",
+                        "name": "lookup",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserLookupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or too many phone numbers",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me": {
+            "get": {
+                "description": "Retrieve the profile details of the currently authenticated user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get current user profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the given ETag"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update name, status, or message privacy (everyone/contacts) for the current user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update user profile",
+                "parameters": [
+                    {
+                        "description": "User Update Request",
+                        "name": "updates",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Profile changed since expected_updated_at",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/blocked": {
+            "get": {
+                "description": "Retrieve the users the current user has blocked, most recently blocked first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List blocked users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/notifications": {
+            "get": {
+                "description": "Get the current user's notification preferences, shared by all of their devices. Users who never changed them get the defaults.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get notification settings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.NotificationSettings"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Change the current user's notification preferences. Omitted fields keep their value. Quiet hours are HH:MM times in the given IANA timezone, set together and possibly wrapping past midnight; send both as \"\" to clear them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Update notification settings",
+                "parameters": [
+                    {
+                        "description": "Settings to change",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.NotificationSettingsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.NotificationSettings"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid settings",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/push-tokens": {
+            "post": {
+                "description": "Register the current device's push token so the user is notified of new messages while not connected. android and web tokens come from Firebase Cloud Messaging, ios tokens from APNs. Registering a token again refreshes it; tokens the push service rejects are removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Register a push token",
+                "parameters": [
+                    {
+                        "description": "Device token and platform",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.PushTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.PushToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing token or unknown platform",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/unread": {
+            "get": {
+                "description": "Count unread messages across all of the current user's chats, e.g. for an app icon badge",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get total unread count",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UnreadSummary"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/online": {
+            "get": {
+                "description": "Retrieve a list of users currently marked as online",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get online users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/search": {
+            "get": {
+                "description": "Find users by phone number or name. Excludes the caller and blocked users; the total match count is returned in the X-Total-Count header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Search for users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to 'array' for a bare array without paging metadata",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserSearchResponse"
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Total number of matching users"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Query required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/sessions": {
+            "get": {
+                "description": "Retrieve the active login sessions for the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get user sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserSession"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/sessions/{id}": {
+            "patch": {
+                "description": "Set or clear a custom label for one of the current user's sessions, shown in place of the detected device name.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Rename a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Session label",
+                        "name": "session",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.SessionUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or label too long",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}": {
+            "get": {
+                "description": "Retrieve profile details for a specific user ID. privacy_messages is only included for the requester's own profile.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get user by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the given ETag"
+                    },
+                    "400": {
+                        "description": "ID required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}/block": {
+            "post": {
+                "description": "Block another user. Neither of you can then message the other, start a direct chat, add the other as a contact or see the other in search or shared media. Blocking someone already blocked is a no-op.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Block a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "ID required or own user ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Lift a block you placed on another user. A block they placed on you stays. Unblocking someone who isn't blocked is a no-op.",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Unblock a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "ID required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}/shared-media": {
+            "get": {
+                "description": "List the photos, videos and other attachments the requester and another user have sent each other in the chats they are both in, mainly their direct chat, newest first. Messages the requester cleared from their history are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get media shared with a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only this media type (image, video, audio, document, sticker); all when omitted",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit results (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of results to skip (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MediaResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid media type or own user ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Users have blocked each other",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks": {
+            "post": {
+                "description": "Subscribe an HTTP endpoint to message events (message.sent, message.delivered, message.read) in every chat the user belongs to. Deliveries are signed with HMAC-SHA256 using the webhook's secret, sent in X-ChitChat-Signature, and retried with backoff until a 2xx response. The secret is generated when omitted and is only returned here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Register a webhook",
+                "parameters": [
+                    {
+                        "description": "Endpoint URL, events and optional secret",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid URL, non-public or unresolvable host, events or secret",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}": {
+            "delete": {
+                "description": "Stop delivering events to one of the user's webhooks",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrades the HTTP connection to a WebSocket for real-time messaging. Requires a valid JWT token as a query parameter.",
+                "tags": [
+                    "websocket"
+                ],
+                "summary": "Establish WebSocket connection",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Valid JWT Token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated protocol versions the client supports (default 1)",
+                        "name": "versions",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "No supported protocol version",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or missing token, or the token's session has ended",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to verify session",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "github_com_msniranjan18_chit-chat_pkg_models.APIKey": {
+            "type": "object",
+            "properties": {
+                "bot_id": {
+                    "type": "string"
+                },
+                "chat_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Only returned when the key is created",
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.APIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "chat_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.AuditAction": {
+            "type": "string",
+            "enum": [
+                "add",
+                "remove",
+                "ban",
+                "promote",
+                "demote",
+                "delete",
+                "clear_history"
+            ],
+            "x-enum-varnames": [
+                "AuditActionAdd",
+                "AuditActionRemove",
+                "AuditActionBan",
+                "AuditActionPromote",
+                "AuditActionDemote",
+                "AuditActionDelete",
+                "AuditActionClearHistory"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.AuditAction"
+                },
+                "actor_id": {
+                    "type": "string"
+                },
+                "chat_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "description": "User the action applied to, if any",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.AuditLogResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.AuditEvent"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.AuthRequest": {
+            "type": "object",
+            "properties": {
+                "device_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.AuthResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "refresh_expires_at": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "user": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.Bot": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "The bot's user ID",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BotRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BulkContactEntry": {
+            "type": "object",
+            "properties": {
+                "display_name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Used when user_id is empty",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BulkContactRequest": {
+            "type": "object",
+            "properties": {
+                "contacts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BulkContactEntry"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BulkContactResponse": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BulkContactResult"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BulkContactResult": {
+            "type": "object",
+            "properties": {
+                "phone": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Why the entry was skipped",
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.BulkContactStatus"
+                },
+                "user_id": {
+                    "description": "The resolved user, when there is one",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.BulkContactStatus": {
+            "type": "string",
+            "enum": [
+                "added",
+                "skipped",
+                "not_found"
+            ],
+            "x-enum-varnames": [
+                "BulkContactAdded",
+                "BulkContactSkipped",
+                "BulkContactNotFound"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.CacheRebuildResult": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "members": {
+                    "description": "Members cached, each with rebuilt unread counters",
+                    "type": "integer"
+                },
+                "messages": {
+                    "description": "Messages in the re-warmed first page",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.Chat": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "closed_at": {
+                    "description": "When the other participant left a direct chat, which is read-only from then on",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_archived": {
+                    "type": "boolean"
+                },
+                "is_muted": {
+                    "description": "Muted by the requesting member, not for everyone",
+                    "type": "boolean"
+                },
+                "is_pinned": {
+                    "description": "Pinned by the requesting member, not for everyone",
+                    "type": "boolean"
+                },
+                "is_self": {
+                    "description": "A direct chat with only the user in it, for saving notes and links",
+                    "type": "boolean"
+                },
+                "last_activity": {
+                    "type": "string"
+                },
+                "last_message": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pin_order": {
+                    "description": "Position among the member's pinned chats, lowest first",
+                    "type": "integer"
+                },
+                "type": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatType"
+                },
+                "unread_count": {
+                    "type": "integer"
+                },
+                "unread_mentions": {
+                    "description": "Unread messages mentioning the user, counted even when the chat is muted",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatListResponse": {
+            "type": "object",
+            "properties": {
+                "chats": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMember": {
+            "type": "object",
+            "properties": {
+                "banned_until": {
+                    "type": "string"
+                },
+                "chat_id": {
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "is_admin": {
+                    "type": "boolean"
+                },
+                "is_banned": {
+                    "type": "boolean"
+                },
+                "joined_at": {
+                    "type": "string"
+                },
+                "last_read_at": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "description": "Last role, ban or display name change",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberMatch": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "banned_until": {
+                    "type": "string"
+                },
+                "chat_id": {
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "is_admin": {
+                    "type": "boolean"
+                },
+                "is_banned": {
+                    "type": "boolean"
+                },
+                "joined_at": {
+                    "type": "string"
+                },
+                "last_read_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "description": "Last role, ban or display name change",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRequest": {
+            "type": "object",
+            "properties": {
+                "display_name": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRole": {
+            "type": "string",
+            "enum": [
+                "owner",
+                "admin",
+                "member",
+                "viewer"
+            ],
+            "x-enum-varnames": [
+                "ChatMemberRoleOwner",
+                "ChatMemberRoleAdmin",
+                "ChatMemberRoleMember",
+                "ChatMemberRoleViewer"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRoleRequest": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "description": "owner, admin, member or viewer",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRole"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberSearchResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "description": "More members match; refine the query to find them",
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMemberMatch"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "display_name": {
+                    "description": "Empty or null clears the nickname",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatPermissions": {
+            "type": "object",
+            "properties": {
+                "can_add_members": {
+                    "type": "boolean"
+                },
+                "can_edit_settings": {
+                    "type": "boolean"
+                },
+                "can_post": {
+                    "type": "boolean"
+                },
+                "can_post_media": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatRequest": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatType"
+                },
+                "user_ids": {
+                    "description": "For direct chat: [other_user_id], For group: all members",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatResponse": {
+            "type": "object",
+            "properties": {
+                "chat": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                },
+                "last_read_message": {
+                    "description": "Direct chats only: the latest of the requester's messages the other\nparticipant has read, where clients show the \"seen\" marker",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                        }
+                    ]
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                    }
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatSearchResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_offset": {
+                    "description": "Offset of the next page; absent on the last page",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatType": {
+            "type": "string",
+            "enum": [
+                "direct",
+                "group",
+                "channel"
+            ],
+            "x-enum-varnames": [
+                "ChatTypeDirect",
+                "ChatTypeGroup",
+                "ChatTypeChannel"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ChatUpdateRequest": {
             "type": "object",
             "properties": {
                 "avatar_url": {
                     "type": "string"
                 },
+                "description": {
+                    "type": "string"
+                },
+                "expected_updated_at": {
+                    "description": "If set, the update only applies when the chat's updated_at still matches",
+                    "type": "string"
+                },
+                "is_archived": {
+                    "type": "boolean"
+                },
+                "is_muted": {
+                    "type": "boolean"
+                },
+                "is_pinned": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "pin_order": {
+                    "description": "Ignored unless the chat is or becomes pinned",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ClearHistoryRequest": {
+            "type": "object",
+            "properties": {
+                "scope": {
+                    "description": "me (default) or everyone",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ClearHistoryScope"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ClearHistoryScope": {
+            "type": "string",
+            "enum": [
+                "me",
+                "everyone"
+            ],
+            "x-enum-varnames": [
+                "ClearHistoryScopeMe",
+                "ClearHistoryScopeEveryone"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ClientConnection": {
+            "type": "object",
+            "properties": {
+                "chat_ids": {
+                    "description": "Chat rooms the client has joined",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "last_rtt_ms": {
+                    "description": "Round trip of the last ping; zero before the first pong",
+                    "type": "integer"
+                },
+                "session_id": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Negotiated protocol version",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ConnectionsSnapshot": {
+            "type": "object",
+            "properties": {
+                "client_count": {
+                    "type": "integer"
+                },
+                "node_id": {
+                    "type": "string"
+                },
+                "slow_client_drops": {
+                    "description": "Clients dropped since startup for not draining their send buffer",
+                    "type": "integer"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.UserConnections"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ContactRequest": {
+            "type": "object",
+            "properties": {
+                "display_name": {
+                    "type": "string"
+                },
+                "mutual": {
+                    "description": "Also add the requester to the target's contacts",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ContactResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "False if the contact already existed",
+                    "type": "boolean"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "mutual": {
+                    "description": "True if both users have each other as contacts",
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.DeleteScope": {
+            "type": "string",
+            "enum": [
+                "everyone",
+                "me"
+            ],
+            "x-enum-comments": {
+                "DeleteScopeMe": "Hidden for the requester; everyone else still sees it"
+            },
+            "x-enum-descriptions": [
+                "",
+                "Hidden for the requester; everyone else still sees it"
+            ],
+            "x-enum-varnames": [
+                "DeleteScopeEveryone",
+                "DeleteScopeMe"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ForwardRequest": {
+            "type": "object",
+            "properties": {
+                "chat_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "comment": {
+                    "description": "Sent in each chat as a text reply to the forwarded copy",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.ForwardResponse": {
+            "type": "object",
+            "properties": {
+                "comments": {
+                    "description": "The comment sent with each copy, in the same order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                    }
+                },
+                "forward_count": {
+                    "description": "Source message's forward count after this request",
+                    "type": "integer"
+                },
+                "frequently_forwarded": {
+                    "type": "boolean"
+                },
+                "messages": {
+                    "description": "The new copies, one per destination chat",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.GroupResponse": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                    }
+                },
+                "settings": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.GroupSettings"
+                },
+                "stats": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.GroupStats"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.GroupSettings": {
+            "type": "object",
+            "properties": {
+                "admins_can_edit": {
+                    "type": "boolean"
+                },
+                "chat_id": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
-                "created_by": {
+                "disappearing_ttl": {
+                    "description": "seconds new messages last; 0 keeps them",
+                    "type": "integer"
+                },
+                "is_public": {
+                    "type": "boolean"
+                },
+                "join_link": {
                     "type": "string"
                 },
-                "description": {
+                "join_link_expires_at": {
+                    "type": "string"
+                },
+                "members_can_invite": {
+                    "type": "boolean"
+                },
+                "send_media_allowed": {
+                    "type": "boolean"
+                },
+                "send_messages_allowed": {
+                    "type": "boolean"
+                },
+                "slow_mode_delay": {
+                    "description": "seconds",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.GroupSettingsRequest": {
+            "type": "object",
+            "properties": {
+                "admins_can_edit": {
+                    "type": "boolean"
+                },
+                "disappearing_ttl": {
+                    "description": "seconds; 0 turns disappearing messages off",
+                    "type": "integer"
+                },
+                "is_public": {
+                    "type": "boolean"
+                },
+                "members_can_invite": {
+                    "type": "boolean"
+                },
+                "send_media_allowed": {
+                    "type": "boolean"
+                },
+                "send_messages_allowed": {
+                    "type": "boolean"
+                },
+                "slow_mode_delay": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.GroupStats": {
+            "type": "object",
+            "properties": {
+                "active_members": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "group_id": {
+                    "type": "string"
+                },
+                "messages_month": {
+                    "type": "integer"
+                },
+                "messages_today": {
+                    "type": "integer"
+                },
+                "messages_week": {
+                    "type": "integer"
+                },
+                "total_members": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MarkUnreadRequest": {
+            "type": "object",
+            "properties": {
+                "message_id": {
+                    "description": "First message to show as unread; the latest from others when omitted",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MediaItem": {
+            "type": "object",
+            "properties": {
+                "caption": {
+                    "type": "string"
+                },
+                "chat_id": {
                     "type": "string"
                 },
-                "id": {
+                "content_type": {
                     "type": "string"
                 },
-                "is_archived": {
-                    "type": "boolean"
-                },
-                "is_muted": {
-                    "type": "boolean"
+                "duration": {
+                    "type": "integer"
                 },
-                "is_pinned": {
-                    "type": "boolean"
+                "file_size": {
+                    "type": "integer"
                 },
-                "last_activity": {
+                "media_url": {
                     "type": "string"
                 },
-                "last_message": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
-                },
-                "name": {
+                "message_id": {
                     "type": "string"
                 },
-                "type": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatType"
+                "sender_id": {
+                    "type": "string"
                 },
-                "unread_count": {
-                    "type": "integer"
+                "sent_at": {
+                    "type": "string"
                 },
-                "updated_at": {
+                "thumbnail_url": {
                     "type": "string"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatListResponse": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MediaResponse": {
             "type": "object",
             "properties": {
-                "chats": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MediaItem"
                     }
                 },
                 "limit": {
                     "type": "integer"
                 },
-                "page": {
+                "next_offset": {
+                    "description": "Offset of the next page; absent on the last page",
                     "type": "integer"
                 },
-                "total": {
+                "offset": {
                     "type": "integer"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatMember": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MemberRoleCounts": {
             "type": "object",
             "properties": {
-                "banned_until": {
+                "admins": {
+                    "type": "integer"
+                },
+                "chat_id": {
                     "type": "string"
                 },
+                "members": {
+                    "type": "integer"
+                },
+                "owners": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "viewers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.Message": {
+            "type": "object",
+            "properties": {
                 "chat_id": {
                     "type": "string"
                 },
-                "display_name": {
+                "content": {
                     "type": "string"
                 },
-                "is_admin": {
+                "content_type": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "For audio/video",
+                    "type": "integer"
+                },
+                "edited_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Disappearing messages are purged after this",
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "forward_count": {
+                    "description": "Times this message was forwarded",
+                    "type": "integer"
+                },
+                "forward_from": {
+                    "type": "string"
+                },
+                "forward_from_name": {
+                    "description": "Display name of the original sender",
+                    "type": "string"
+                },
+                "forwarded": {
                     "type": "boolean"
                 },
-                "is_banned": {
+                "frequently_forwarded": {
+                    "description": "Forwarded past the configured threshold",
                     "type": "boolean"
                 },
-                "joined_at": {
+                "id": {
                     "type": "string"
                 },
-                "last_read_at": {
+                "is_deleted": {
+                    "type": "boolean"
+                },
+                "is_edited": {
+                    "type": "boolean"
+                },
+                "media_url": {
                     "type": "string"
                 },
-                "role": {
+                "mentions": {
+                    "description": "Members mentioned with @{userID}; set when the message is sent",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "read_at": {
                     "type": "string"
                 },
-                "user_id": {
+                "read_count": {
+                    "description": "Members other than the sender who have read it",
+                    "type": "integer"
+                },
+                "reply_message": {
+                    "description": "Quoted parent, one level deep only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                        }
+                    ]
+                },
+                "reply_to": {
+                    "type": "string"
+                },
+                "sender_avatar": {
+                    "type": "string"
+                },
+                "sender_id": {
+                    "type": "string"
+                },
+                "sender_name": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "thumbnail_url": {
                     "type": "string"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatMemberRequest": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageDeleteResponse": {
             "type": "object",
             "properties": {
-                "display_name": {
+                "message": {
                     "type": "string"
                 },
-                "role": {
+                "message_id": {
                     "type": "string"
                 },
-                "user_id": {
-                    "type": "string"
+                "scope": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.DeleteScope"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatRequest": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageRequest": {
             "type": "object",
             "properties": {
-                "avatar_url": {
+                "chat_id": {
                     "type": "string"
                 },
-                "description": {
+                "content": {
                     "type": "string"
                 },
-                "name": {
+                "content_type": {
                     "type": "string"
                 },
-                "type": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatType"
+                "duration": {
+                    "description": "Seconds; required for audio and video",
+                    "type": "integer"
                 },
-                "user_ids": {
-                    "description": "For direct chat: [other_user_id], For group: all members",
+                "file_size": {
+                    "description": "Bytes",
+                    "type": "integer"
+                },
+                "forward_from": {
+                    "type": "string"
+                },
+                "forwarded": {
+                    "type": "boolean"
+                },
+                "media_url": {
+                    "type": "string"
+                },
+                "reply_to": {
+                    "type": "string"
+                },
+                "thumbnail_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageSearchResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_offset": {
+                    "description": "Offset of the next page; absent on the last page",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "results": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
                     }
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatResponse": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageStatus": {
+            "type": "string",
+            "enum": [
+                "sent",
+                "delivered",
+                "read",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "MessageStatusSent",
+                "MessageStatusDelivered",
+                "MessageStatusRead",
+                "MessageStatusFailed"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageStatusEntry": {
             "type": "object",
             "properties": {
-                "chat": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Chat"
+                "status": {
+                    "type": "string"
                 },
-                "members": {
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageStatusSummary": {
+            "type": "object",
+            "properties": {
+                "delivered_to": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatMember"
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageStatusEntry"
                     }
                 },
-                "users": {
+                "message_id": {
+                    "type": "string"
+                },
+                "read_by": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageStatusEntry"
                     }
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatType": {
-            "type": "string",
-            "enum": [
-                "direct",
-                "group",
-                "channel"
-            ],
-            "x-enum-varnames": [
-                "ChatTypeDirect",
-                "ChatTypeGroup",
-                "ChatTypeChannel"
-            ]
+        "github_com_msniranjan18_chit-chat_pkg_models.MessageUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "description": "New text, or caption for media messages; media captions may be cleared",
+                    "type": "string"
+                },
+                "content_type": {
+                    "description": "Optional; must match the message's current type",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MessagesAround": {
+            "type": "object",
+            "properties": {
+                "after_cursor": {
+                    "description": "Newest returned message ID, set when newer messages exist",
+                    "type": "string"
+                },
+                "before_cursor": {
+                    "description": "Oldest returned message ID, set when older messages exist",
+                    "type": "string"
+                },
+                "has_more_after": {
+                    "type": "boolean"
+                },
+                "has_more_before": {
+                    "type": "boolean"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                    }
+                },
+                "target_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.MessagesByStatusResponse": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.MessageStatus"
+                }
+            }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.ChatUpdateRequest": {
+        "github_com_msniranjan18_chit-chat_pkg_models.MyMembership": {
             "type": "object",
             "properties": {
-                "avatar_url": {
+                "banned_until": {
                     "type": "string"
                 },
-                "description": {
+                "chat_id": {
                     "type": "string"
                 },
-                "is_archived": {
+                "display_name": {
+                    "type": "string"
+                },
+                "is_admin": {
                     "type": "boolean"
                 },
-                "is_muted": {
+                "is_banned": {
                     "type": "boolean"
                 },
-                "is_pinned": {
+                "is_muted": {
                     "type": "boolean"
                 },
-                "name": {
-                    "type": "string"
-                }
-            }
-        },
-        "github_com_msniranjan18_chit-chat_pkg_models.Message": {
-            "type": "object",
-            "properties": {
-                "chat_id": {
+                "joined_at": {
                     "type": "string"
                 },
-                "content": {
+                "last_read_at": {
                     "type": "string"
                 },
-                "content_type": {
+                "permissions": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ChatPermissions"
+                },
+                "role": {
                     "type": "string"
                 },
-                "deleted_at": {
+                "updated_at": {
+                    "description": "Last role, ban or display name change",
                     "type": "string"
                 },
-                "delivered_at": {
+                "user_id": {
                     "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.NotificationSettings": {
+            "type": "object",
+            "properties": {
+                "mute_all": {
+                    "type": "boolean"
                 },
-                "duration": {
-                    "description": "For audio/video",
-                    "type": "integer"
+                "preview_enabled": {
+                    "description": "Show message content in notifications",
+                    "type": "boolean"
                 },
-                "edited_at": {
+                "quiet_hours_end": {
                     "type": "string"
                 },
-                "file_size": {
-                    "type": "integer"
-                },
-                "forward_from": {
+                "quiet_hours_start": {
                     "type": "string"
                 },
-                "forwarded": {
+                "sound": {
                     "type": "boolean"
                 },
-                "id": {
+                "timezone": {
+                    "description": "IANA name the quiet hours are in",
                     "type": "string"
                 },
-                "is_deleted": {
+                "updated_at": {
+                    "description": "Absent while the defaults apply",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.NotificationSettingsRequest": {
+            "type": "object",
+            "properties": {
+                "mute_all": {
                     "type": "boolean"
                 },
-                "is_edited": {
+                "preview_enabled": {
                     "type": "boolean"
                 },
-                "media_url": {
+                "quiet_hours_end": {
                     "type": "string"
                 },
-                "read_at": {
+                "quiet_hours_start": {
                     "type": "string"
                 },
-                "reply_message": {
-                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                "sound": {
+                    "type": "boolean"
                 },
-                "reply_to": {
+                "timezone": {
                     "type": "string"
-                },
-                "sender_id": {
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.PushPlatform": {
+            "type": "string",
+            "enum": [
+                "android",
+                "web",
+                "ios"
+            ],
+            "x-enum-comments": {
+                "PushPlatformAndroid": "Firebase Cloud Messaging",
+                "PushPlatformIOS": "Apple Push Notification service",
+                "PushPlatformWeb": "Firebase Cloud Messaging"
+            },
+            "x-enum-descriptions": [
+                "Firebase Cloud Messaging",
+                "Firebase Cloud Messaging",
+                "Apple Push Notification service"
+            ],
+            "x-enum-varnames": [
+                "PushPlatformAndroid",
+                "PushPlatformWeb",
+                "PushPlatformIOS"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.PushToken": {
+            "type": "object",
+            "properties": {
+                "created_at": {
                     "type": "string"
                 },
-                "sender_name": {
+                "platform": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.PushPlatform"
+                },
+                "token": {
                     "type": "string"
                 },
-                "sent_at": {
+                "updated_at": {
+                    "description": "Last time the device registered it",
                     "type": "string"
                 },
-                "status": {
+                "user_id": {
                     "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.PushTokenRequest": {
+            "type": "object",
+            "properties": {
+                "platform": {
+                    "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.PushPlatform"
                 },
-                "thumbnail_url": {
+                "token": {
                     "type": "string"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.MessageRequest": {
+        "github_com_msniranjan18_chit-chat_pkg_models.RefreshRequest": {
             "type": "object",
             "properties": {
-                "chat_id": {
+                "refresh_token": {
                     "type": "string"
-                },
-                "content": {
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.SessionUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "description": "Empty or null clears the label",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
                     "type": "string"
                 },
-                "content_type": {
+                "refresh_expires_at": {
                     "type": "string"
                 },
-                "forward_from": {
+                "refresh_token": {
+                    "description": "Replaces the one sent; the old token can't be used again",
                     "type": "string"
                 },
-                "forwarded": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.TypingRequest": {
+            "type": "object",
+            "properties": {
+                "is_typing": {
                     "type": "boolean"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.UnreadMessagesResponse": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
                 },
-                "reply_to": {
+                "first_unread_id": {
+                    "description": "Where the \"new messages\" divider goes; only on the first page",
                     "type": "string"
+                },
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.Message"
+                    }
+                },
+                "next_offset": {
+                    "description": "Offset of the next page; absent on the last page",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
                 }
             }
         },
-        "github_com_msniranjan18_chit-chat_pkg_models.MessageUpdateRequest": {
+        "github_com_msniranjan18_chit-chat_pkg_models.UnreadSummary": {
             "type": "object",
             "properties": {
-                "content": {
-                    "type": "string"
+                "total_unread": {
+                    "type": "integer"
+                },
+                "unread_chats": {
+                    "description": "Chats with at least one unread message",
+                    "type": "integer"
                 }
             }
         },
@@ -1743,6 +5503,10 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "display_name": {
+                    "description": "Name the requester saved this user under in their contacts, if any",
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -1758,6 +5522,10 @@ const docTemplate = `{
                 "phone": {
                     "type": "string"
                 },
+                "privacy_messages": {
+                    "description": "Only returned to the user themselves",
+                    "type": "string"
+                },
                 "status": {
                     "type": "string"
                 },
@@ -1766,24 +5534,99 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_msniranjan18_chit-chat_pkg_models.UserConnections": {
+            "type": "object",
+            "properties": {
+                "client_count": {
+                    "type": "integer"
+                },
+                "clients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.ClientConnection"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.UserLookupRequest": {
+            "type": "object",
+            "properties": {
+                "phones": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.UserSearchResponse": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "type": "boolean"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_offset": {
+                    "description": "Offset of the next page; absent on the last page",
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.User"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
         "github_com_msniranjan18_chit-chat_pkg_models.UserSession": {
             "type": "object",
             "properties": {
+                "browser": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
                 "device_info": {
+                    "description": "Raw User-Agent",
+                    "type": "string"
+                },
+                "device_name": {
+                    "description": "e.g. \"Chrome on Windows\"",
                     "type": "string"
                 },
                 "ip_address": {
+                    "description": "Address of the latest login or token refresh",
                     "type": "string"
                 },
                 "is_active": {
                     "type": "boolean"
                 },
+                "is_current": {
+                    "description": "Session the request was made with",
+                    "type": "boolean"
+                },
+                "label": {
+                    "description": "Name chosen by the user",
+                    "type": "string"
+                },
                 "last_active": {
                     "type": "string"
                 },
+                "os": {
+                    "type": "string"
+                },
                 "session_id": {
                     "type": "string"
                 },
@@ -1795,13 +5638,79 @@ const docTemplate = `{
         "github_com_msniranjan18_chit-chat_pkg_models.UserUpdateRequest": {
             "type": "object",
             "properties": {
+                "expected_updated_at": {
+                    "description": "If set, the update only applies when the user's updated_at still matches",
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
+                "privacy_messages": {
+                    "description": "everyone or contacts",
+                    "type": "string"
+                },
                 "status": {
                     "type": "string"
                 }
             }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.Webhook": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.WebhookEventType"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Only returned when the webhook is created",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.WebhookEventType": {
+            "type": "string",
+            "enum": [
+                "message.sent",
+                "message.delivered",
+                "message.read"
+            ],
+            "x-enum-varnames": [
+                "WebhookEventMessageSent",
+                "WebhookEventMessageDelivered",
+                "WebhookEventMessageRead"
+            ]
+        },
+        "github_com_msniranjan18_chit-chat_pkg_models.WebhookRequest": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_msniranjan18_chit-chat_pkg_models.WebhookEventType"
+                    }
+                },
+                "secret": {
+                    "description": "Generated when omitted",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
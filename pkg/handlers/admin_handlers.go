@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// AdminHandler serves the operator endpoints under /api/admin/. Requests reach it
+// only through the admin token middleware, never with a user's JWT.
+type AdminHandler struct {
+	store  *store.Store
+	hub    *hub.Hub
+	logger *slog.Logger
+}
+
+func NewAdminHandler(store *store.Store, hub *hub.Hub, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{store: store, hub: hub, logger: logger}
+}
+
+// GetConnections godoc
+// @Summary      List connected WebSocket clients
+// @Description  Operator-only. Lists the clients connected to the instance that serves the request, per user, with each client's session, joined chat rooms and last ping round trip. Other instances' clients are not included. Requires X-Admin-Token.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token  header    string  true  "Admin token (ADMIN_TOKEN)"
+// @Success      200            {object}  models.ConnectionsSnapshot
+// @Failure      401            {object}  map[string]string "Invalid admin token"
+// @Router       /api/admin/connections [get]
+func (h *AdminHandler) GetConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var snapshot models.ConnectionsSnapshot = h.hub.Snapshot()
+
+	h.logger.Debug("GetConnections: snapshot taken",
+		"user_count", len(snapshot.Users), "client_count", snapshot.ClientCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// RebuildChatCache godoc
+// @Summary      Rebuild a chat's caches
+// @Description  Operator-only. Drops the chat's cached messages and members and its members' unread counters, then re-warms them from the database. Use it when the caches have drifted, for example after editing the database by hand. Requires X-Admin-Token.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Token  header    string  true  "Admin token (ADMIN_TOKEN)"
+// @Param        id             path      string  true  "Chat ID"
+// @Success      200            {object}  models.CacheRebuildResult
+// @Failure      401            {object}  map[string]string "Invalid admin token"
+// @Failure      404            {object}  map[string]string "Chat not found"
+// @Failure      503            {object}  map[string]string "Cache is not available"
+// @Router       /api/admin/chats/{id}/rebuild-cache [post]
+func (h *AdminHandler) RebuildChatCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if _, err := uuid.Parse(chatID); err != nil {
+		h.logger.Warn("RebuildChatCache: invalid chat ID", "chat_id", chatID)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	if !h.store.RedisAvailable() {
+		h.logger.Warn("RebuildChatCache: Redis is unavailable, nothing to rebuild", "chat_id", chatID)
+		http.Error(w, "Cache is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	result, err := h.store.RebuildChatCaches(chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("RebuildChatCache: chat not found", "chat_id", chatID)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("RebuildChatCache: failed to rebuild caches", "error", err, "chat_id", chatID)
+		http.Error(w, "Failed to rebuild caches", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -10,20 +10,24 @@ import (
 
 	"github.com/google/uuid"
 
-	"github.com/msniranjan18/common/jwt"
 	"github.com/msniranjan18/common/middleware/auth"
 
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/hub"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/token"
 )
 
 type AuthHandler struct {
 	store  *store.Store
+	hub    *hub.Hub
+	cfg    config.JWTConfig
 	logger *slog.Logger
 }
 
-func NewAuthHandler(store *store.Store, logger *slog.Logger) *AuthHandler {
-	return &AuthHandler{store: store, logger: logger}
+func NewAuthHandler(store *store.Store, hub *hub.Hub, cfg config.JWTConfig, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{store: store, hub: hub, cfg: cfg, logger: logger}
 }
 
 // Register godoc
@@ -72,7 +76,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	// Check if user exists
 	existingUser, err := h.store.GetUserByPhone(req.Phone)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
 		h.logger.Error("Register: failed to check existing user", "error", err, "phone", req.Phone)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -108,7 +112,22 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Register: creating user session",
 		"user_id", user.ID, "session_id", sessionID, "device", deviceInfo, "ip", ipAddress)
 
-	if err := h.store.CreateUserSession(user.ID, sessionID, deviceInfo, ipAddress); err != nil {
+	browser, osName := parseUserAgent(deviceInfo)
+	session := &models.UserSession{
+		UserID:     user.ID,
+		SessionID:  sessionID,
+		DeviceInfo: deviceInfo,
+		Browser:    browser,
+		OS:         osName,
+		DeviceName: deviceName(browser, osName),
+		IPAddress:  ipAddress,
+	}
+	if err := h.evictExcessSessions(user.ID); err != nil {
+		h.logger.Error("Register: failed to enforce session limit", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.CreateUserSession(session); err != nil {
 		h.logger.Error("Register: failed to create session",
 			"error", err, "user_id", user.ID, "session_id", sessionID)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
@@ -116,21 +135,30 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate JWT token
-	token, expiresAt, err := jwt.GenerateJWT(user.ID, sessionID)
+	accessToken, expiresAt, err := token.GenerateJWT(user.ID, sessionID)
 	if err != nil {
 		h.logger.Error("Register: failed to generate JWT", "error", err, "user_id", user.ID)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
+	refreshToken, refreshExpiresAt, err := h.issueRefreshToken(user.ID, sessionID)
+	if err != nil {
+		h.logger.Error("Register: failed to issue refresh token", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
 	h.logger.Info("Register: successful",
 		"user_id", user.ID, "session_id", sessionID, "expires_at", expiresAt)
 
 	// Prepare response
 	response := models.AuthResponse{
-		Token:     token,
-		User:      *user,
-		ExpiresAt: expiresAt,
+		Token:            accessToken,
+		User:             *user,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -167,7 +195,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Get user by phone
 	user, err := h.store.GetUserByPhone(req.Phone)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			h.logger.Warn("Login: user not found", "phone", req.Phone)
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
@@ -179,6 +207,19 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debug("Login: user found", "user_id", user.ID, "name", user.Name)
 
+	// Bots authenticate with API keys only
+	isBot, err := h.store.IsBot(user.ID)
+	if err != nil {
+		h.logger.Error("Login: failed to check for bot", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if isBot {
+		h.logger.Warn("Login: rejected bot account", "user_id", user.ID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
 	// Update last seen
 	h.store.UpdateUserLastSeen(user.ID, time.Now())
 
@@ -190,7 +231,22 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Login: creating user session",
 		"user_id", user.ID, "session_id", sessionID, "device", deviceInfo, "ip", ipAddress)
 
-	if err := h.store.CreateUserSession(user.ID, sessionID, deviceInfo, ipAddress); err != nil {
+	browser, osName := parseUserAgent(deviceInfo)
+	session := &models.UserSession{
+		UserID:     user.ID,
+		SessionID:  sessionID,
+		DeviceInfo: deviceInfo,
+		Browser:    browser,
+		OS:         osName,
+		DeviceName: deviceName(browser, osName),
+		IPAddress:  ipAddress,
+	}
+	if err := h.evictExcessSessions(user.ID); err != nil {
+		h.logger.Error("Login: failed to enforce session limit", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.CreateUserSession(session); err != nil {
 		h.logger.Error("Login: failed to create session",
 			"error", err, "user_id", user.ID, "session_id", sessionID)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
@@ -198,21 +254,30 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate JWT token
-	token, expiresAt, err := jwt.GenerateJWT(user.ID, sessionID)
+	accessToken, expiresAt, err := token.GenerateJWT(user.ID, sessionID)
 	if err != nil {
 		h.logger.Error("Login: failed to generate JWT", "error", err, "user_id", user.ID)
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
+	refreshToken, refreshExpiresAt, err := h.issueRefreshToken(user.ID, sessionID)
+	if err != nil {
+		h.logger.Error("Login: failed to issue refresh token", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
 	h.logger.Info("Login: successful",
 		"user_id", user.ID, "session_id", sessionID, "expires_at", expiresAt)
 
 	// Prepare response
 	response := models.AuthResponse{
-		Token:     token,
-		User:      *user,
-		ExpiresAt: expiresAt,
+		Token:            accessToken,
+		User:             *user,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -221,7 +286,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 // Logout godoc
 // @Summary      Logout user
-// @Description  Deletes the current user session and invalidates the session ID.
+// @Description  Deletes the current user session. Its access token is refused from then on and its WebSocket connections are closed.
 // @Tags         auth
 // @Success      200     {object}  map[string]string "Logged out successfully"
 // @Failure      401     {object}  map[string]string "Not authenticated"
@@ -244,6 +309,8 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.hub.EndSession(auth.GetUserID(r.Context()), sessionID)
+
 	h.logger.Info("Logout: successful", "session_id", sessionID)
 
 	w.WriteHeader(http.StatusOK)
@@ -273,9 +340,14 @@ func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
 
 	// Get user
 	user, err := h.store.GetUserByID(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("Verify: user not found", "user_id", userID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.logger.Error("Verify: failed to get user", "error", err, "user_id", userID)
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -287,49 +359,98 @@ func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
 
 // RefreshToken godoc
 // @Summary      Refresh session token
-// @Description  Takes an existing valid JWT and returns a new token with an extended expiration time.
+// @Description  Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; reusing one revokes its session, refusing its access tokens and closing its WebSocket connections.
 // @Tags         auth
+// @Accept       json
 // @Produce      json
-// @Param        Authorization  header    string  true  "Insert your Bearer token" default(Bearer <token>)
-// @Success      200            {object}  map[string]interface{} "Returns new token and expires_at"
-// @Failure      401            {object}  map[string]string "Invalid or missing token"
+// @Param        request  body      models.RefreshRequest  true  "Refresh token"
+// @Success      200      {object}  models.TokenResponse
+// @Failure      400      {object}  map[string]string "Invalid request body"
+// @Failure      401      {object}  map[string]string "Invalid, expired or reused refresh token"
 // @Router       /api/auth/refresh [post]
 func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		h.logger.Warn("RefreshToken: missing authorization header")
-		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+	if r.Method != http.MethodPost {
+		h.logger.Warn("RefreshToken: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == "" {
-		h.logger.Warn("RefreshToken: empty token")
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("RefreshToken: invalid request body", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	h.logger.Debug("RefreshToken: refreshing token")
+	if req.RefreshToken == "" {
+		h.logger.Warn("RefreshToken: missing refresh token")
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Debug("RefreshToken: rotating refresh token")
+
+	newRefreshToken, newHash, err := token.NewRefreshToken()
+	if err != nil {
+		h.logger.Error("RefreshToken: failed to generate refresh token", "error", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	refreshExpiresAt := time.Now().Add(h.cfg.RefreshExpiration)
+
+	userID, sessionID, err := h.store.RotateRefreshToken(token.HashRefreshToken(req.RefreshToken), newHash, refreshExpiresAt, getIPAddress(r))
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		h.logger.Warn("RefreshToken: reused refresh token, session revoked",
+			"user_id", userID, "session_id", sessionID, "ip", getIPAddress(r))
+		h.hub.EndSession(userID, sessionID)
+		http.Error(w, "Refresh token was already used; please log in again", http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, store.ErrRefreshTokenInvalid) {
+		h.logger.Warn("RefreshToken: invalid or expired refresh token")
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		h.logger.Error("RefreshToken: failed to rotate refresh token", "error", err)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
 
-	// Refresh token
-	newToken, expiresAt, err := jwt.RefreshJWT(token)
+	accessToken, expiresAt, err := token.GenerateJWT(userID, sessionID)
 	if err != nil {
-		h.logger.Error("RefreshToken: failed to refresh token", "error", err)
-		http.Error(w, "Failed to refresh token", http.StatusUnauthorized)
+		h.logger.Error("RefreshToken: failed to generate JWT", "error", err, "user_id", userID)
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
 		return
 	}
 
-	h.logger.Info("RefreshToken: token refreshed successfully", "expires_at", expiresAt)
+	h.logger.Info("RefreshToken: token refreshed successfully",
+		"user_id", userID, "session_id", sessionID, "expires_at", expiresAt)
 
-	response := map[string]interface{}{
-		"token":      newToken,
-		"expires_at": expiresAt,
+	response := models.TokenResponse{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     newRefreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// issueRefreshToken creates the first refresh token of a new session
+func (h *AuthHandler) issueRefreshToken(userID, sessionID string) (string, time.Time, error) {
+	raw, hash, err := token.NewRefreshToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(h.cfg.RefreshExpiration)
+	if err := h.store.CreateRefreshToken(userID, sessionID, hash, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return raw, expiresAt, nil
+}
+
 // Helper function to get IP address
 func getIPAddress(r *http.Request) string {
 	ip := r.Header.Get("X-Forwarded-For")
@@ -345,3 +466,82 @@ func getIPAddress(r *http.Request) string {
 	}
 	return ip
 }
+
+// parseUserAgent picks out the browser and operating system from a User-Agent
+// header. Either is empty when not recognized. Checks are ordered because most
+// browsers also claim to be Safari or Chrome, and Android claims to be Linux.
+func parseUserAgent(ua string) (browser, osName string) {
+	switch {
+	case strings.Contains(ua, "Edg/"), strings.Contains(ua, "EdgA/"), strings.Contains(ua, "EdgiOS/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "SamsungBrowser/"):
+		browser = "Samsung Internet"
+	case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		osName = "iOS"
+	case strings.Contains(ua, "Android"):
+		osName = "Android"
+	case strings.Contains(ua, "Windows"):
+		osName = "Windows"
+	case strings.Contains(ua, "CrOS"):
+		osName = "ChromeOS"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		osName = "macOS"
+	case strings.Contains(ua, "Linux"):
+		osName = "Linux"
+	}
+	return browser, osName
+}
+
+// deviceName builds the friendly session name shown in the devices list
+func deviceName(browser, osName string) string {
+	switch {
+	case browser != "" && osName != "":
+		return browser + " on " + osName
+	case browser != "":
+		return browser
+	case osName != "":
+		return osName + " device"
+	}
+	return "Unknown device"
+}
+
+// evictExcessSessions makes room for a new session when the user is at
+// MaxSessionsPerUser, ending their least recently used sessions. Like logging out,
+// this deletes the session and its refresh tokens, so its access token is refused
+// from then on and its WebSocket connections are closed.
+func (h *AuthHandler) evictExcessSessions(userID string) error {
+	if h.cfg.MaxSessionsPerUser <= 0 {
+		return nil
+	}
+
+	count, err := h.store.GetActiveSessionCount(userID)
+	if err != nil {
+		return err
+	}
+	for ; count >= h.cfg.MaxSessionsPerUser; count-- {
+		evicted, err := h.store.DeleteOldestSession(userID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		h.logger.Warn("Session evicted: user reached the session limit",
+			"user_id", userID, "session_id", evicted.SessionID, "device_name", evicted.DeviceName,
+			"ip_address", evicted.IPAddress, "last_active", evicted.LastActive,
+			"max_sessions", h.cfg.MaxSessionsPerUser)
+		h.hub.EndSession(userID, evicted.SessionID)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/token"
+)
+
+func TestLoginMissingUser(t *testing.T) {
+	s := newTestStore(t)
+	h := NewAuthHandler(s, newTestHub(s), config.JWTConfig{}, testLogger)
+
+	w := httptest.NewRecorder()
+	h.Login(w, newRequest(http.MethodPost, "/api/auth/login", "", models.AuthRequest{Phone: testPhone()}))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Login for unknown phone = %d %q, want %d", w.Code, w.Body.String(), http.StatusNotFound)
+	}
+}
+
+func TestLoginStoreError(t *testing.T) {
+	s := newTestStore(t)
+	h := NewAuthHandler(s, newTestHub(s), config.JWTConfig{}, testLogger)
+	// Every query now fails, which must not be mistaken for a missing user
+	s.DB.Close()
+
+	w := httptest.NewRecorder()
+	h.Login(w, newRequest(http.MethodPost, "/api/auth/login", "", models.AuthRequest{Phone: testPhone()}))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Login with a failing store = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestRegisterMissingUser(t *testing.T) {
+	s := newTestStore(t)
+	token.InitJWT("test-secret", 0)
+	h := NewAuthHandler(s, newTestHub(s), config.JWTConfig{}, testLogger)
+	phone := testPhone()
+
+	w := httptest.NewRecorder()
+	h.Register(w, newRequest(http.MethodPost, "/api/auth/register", "", models.AuthRequest{Phone: phone, Name: "New User"}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Register for unknown phone = %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+	var response models.AuthResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if response.User.ID == "" || response.User.Phone != phone || response.Token == "" {
+		t.Fatalf("Register response = %+v, want a new user with phone %s and a token", response, phone)
+	}
+
+	user, err := s.GetUserByPhone(phone)
+	if err != nil {
+		t.Fatalf("GetUserByPhone after Register: %v", err)
+	}
+	if user.ID != response.User.ID {
+		t.Errorf("stored user %s, want %s", user.ID, response.User.ID)
+	}
+}
+
+func TestRegisterStoreError(t *testing.T) {
+	s := newTestStore(t)
+	h := NewAuthHandler(s, newTestHub(s), config.JWTConfig{}, testLogger)
+	s.DB.Close()
+
+	w := httptest.NewRecorder()
+	h.Register(w, newRequest(http.MethodPost, "/api/auth/register", "", models.AuthRequest{Phone: testPhone(), Name: "New User"}))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Register with a failing store = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
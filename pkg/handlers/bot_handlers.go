@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/token"
+)
+
+const (
+	maxBotNameLength = 100 // Matches users.name
+	maxAPIKeyChats   = 100
+)
+
+type BotHandler struct {
+	store  *store.Store
+	logger *slog.Logger
+}
+
+func NewBotHandler(store *store.Store, logger *slog.Logger) *BotHandler {
+	return &BotHandler{store: store, logger: logger}
+}
+
+// CreateBot godoc
+// @Summary      Create a bot
+// @Description  Create a service account owned by the current user. Bots have no phone login; they send messages with API keys, into chats an admin has added them to.
+// @Tags         bots
+// @Accept       json
+// @Produce      json
+// @Param        bot  body      models.BotRequest  true  "Bot name"
+// @Success      201  {object}  models.Bot
+// @Failure      400  {object}  map[string]string "Invalid name"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /api/bots [post]
+func (h *BotHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("CreateBot: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("CreateBot: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("CreateBot: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxBotNameLength {
+		h.logger.Warn("CreateBot: invalid name", "user_id", userID, "length", len(name))
+		http.Error(w, "Name must be 1-100 characters", http.StatusBadRequest)
+		return
+	}
+
+	bot, err := h.store.CreateBot(userID, name)
+	if err != nil {
+		h.logger.Error("CreateBot: failed to create bot", "error", err, "user_id", userID)
+		http.Error(w, "Failed to create bot", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("CreateBot: bot created", "user_id", userID, "bot_id", bot.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bot)
+}
+
+// DeleteBot godoc
+// @Summary      Delete a bot
+// @Description  Delete one of the current user's bots, removing it from all chats and revoking its API keys
+// @Tags         bots
+// @Produce      json
+// @Param        id   path      string  true  "Bot ID"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Bot not found"
+// @Router       /api/bots/{id} [delete]
+func (h *BotHandler) DeleteBot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.logger.Warn("DeleteBot: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("DeleteBot: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bot, ok := h.ownedBot(w, r, "DeleteBot", userID)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteBot(bot.ID); err != nil {
+		h.logger.Error("DeleteBot: failed to delete bot", "error", err, "user_id", userID, "bot_id", bot.ID)
+		http.Error(w, "Failed to delete bot", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("DeleteBot: bot deleted", "user_id", userID, "bot_id", bot.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Bot deleted",
+	})
+}
+
+// CreateAPIKey godoc
+// @Summary      Create a bot API key
+// @Description  Issue an API key for one of the current user's bots. The key is sent in the X-API-Key header, may only be used to send messages, and only to the listed chats the bot is a member of. It is only returned here; just its hash is stored.
+// @Tags         bots
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string                true  "Bot ID"
+// @Param        key  body      models.APIKeyRequest  true  "Chats the key may send to"
+// @Success      201  {object}  models.APIKey
+// @Failure      400  {object}  map[string]string "Invalid chat list"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Bot not found"
+// @Router       /api/bots/{id}/keys [post]
+func (h *BotHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("CreateAPIKey: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("CreateAPIKey: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bot, ok := h.ownedBot(w, r, "CreateAPIKey", userID)
+	if !ok {
+		return
+	}
+
+	var req models.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("CreateAPIKey: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.ChatIDs) == 0 || len(req.ChatIDs) > maxAPIKeyChats {
+		h.logger.Warn("CreateAPIKey: invalid chat count",
+			"user_id", userID, "bot_id", bot.ID, "count", len(req.ChatIDs))
+		http.Error(w, "Between 1 and 100 chat IDs are required", http.StatusBadRequest)
+		return
+	}
+	for _, chatID := range req.ChatIDs {
+		if _, err := uuid.Parse(chatID); err != nil {
+			h.logger.Warn("CreateAPIKey: invalid chat ID",
+				"user_id", userID, "bot_id", bot.ID, "chat_id", chatID)
+			http.Error(w, "Invalid chat ID: "+chatID, http.StatusBadRequest)
+			return
+		}
+	}
+
+	raw, hash, err := token.NewAPIKey()
+	if err != nil {
+		h.logger.Error("CreateAPIKey: failed to generate key", "error", err, "user_id", userID)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	key, err := h.store.CreateAPIKey(bot.ID, hash, req.ChatIDs)
+	if err != nil {
+		h.logger.Error("CreateAPIKey: failed to create key", "error", err, "user_id", userID, "bot_id", bot.ID)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	key.Key = raw
+
+	h.logger.Info("CreateAPIKey: key created",
+		"user_id", userID, "bot_id", bot.ID, "key_id", key.ID, "chat_count", len(req.ChatIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// DeleteAPIKey godoc
+// @Summary      Revoke a bot API key
+// @Description  Revoke one of the API keys of the current user's bot
+// @Tags         bots
+// @Produce      json
+// @Param        id     path      string  true  "Bot ID"
+// @Param        keyId  path      string  true  "API key ID"
+// @Success      200    {object}  map[string]string
+// @Failure      401    {object}  map[string]string "Unauthorized"
+// @Failure      404    {object}  map[string]string "Bot or key not found"
+// @Router       /api/bots/{id}/keys/{keyId} [delete]
+func (h *BotHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.logger.Warn("DeleteAPIKey: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("DeleteAPIKey: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bot, ok := h.ownedBot(w, r, "DeleteAPIKey", userID)
+	if !ok {
+		return
+	}
+
+	keyID := r.PathValue("keyId")
+	if _, err := uuid.Parse(keyID); err != nil {
+		h.logger.Warn("DeleteAPIKey: invalid key ID", "user_id", userID, "key_id", keyID)
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := h.store.DeleteAPIKey(bot.ID, keyID)
+	if err != nil {
+		h.logger.Error("DeleteAPIKey: failed to delete key",
+			"error", err, "user_id", userID, "bot_id", bot.ID, "key_id", keyID)
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		h.logger.Warn("DeleteAPIKey: key not found", "user_id", userID, "bot_id", bot.ID, "key_id", keyID)
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("DeleteAPIKey: key revoked", "user_id", userID, "bot_id", bot.ID, "key_id", keyID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "API key revoked",
+	})
+}
+
+// ownedBot loads the bot named by the id path value and checks the requester owns
+// it. Otherwise it writes a 404, so other users' bots aren't revealed, and returns
+// false.
+func (h *BotHandler) ownedBot(w http.ResponseWriter, r *http.Request, op, userID string) (*models.Bot, bool) {
+	botID := r.PathValue("id")
+	if _, err := uuid.Parse(botID); err != nil {
+		h.logger.Warn(op+": invalid bot ID", "user_id", userID, "bot_id", botID)
+		http.Error(w, "Bot not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	bot, err := h.store.GetBot(botID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && bot.OwnerID != userID) {
+		h.logger.Warn(op+": bot not found or not owned", "user_id", userID, "bot_id", botID)
+		http.Error(w, "Bot not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error(op+": failed to get bot", "error", err, "user_id", userID, "bot_id", botID)
+		http.Error(w, "Failed to get bot", http.StatusInternalServerError)
+		return nil, false
+	}
+	return bot, true
+}
@@ -2,23 +2,50 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
 
 	"github.com/msniranjan18/common/middleware/auth"
 
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/hub"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 	"github.com/msniranjan18/chit-chat/pkg/store"
 )
 
+// exportBatchSize is the number of messages loaded per page while streaming a chat export
+const exportBatchSize = 500
+
+// Limits on chat names and descriptions, in characters; names match the column width
+const (
+	maxChatNameLength        = 100
+	maxChatDescriptionLength = 500
+)
+
+// maxDisappearingTTL is the longest a group can keep disappearing messages
+const maxDisappearingTTL = 90 * 24 * time.Hour
+
+// lastOwnerMessage explains why a change that would leave a group without an owner
+// was refused
+const lastOwnerMessage = "A group must keep at least one owner; make another member owner first"
+
 type ChatHandler struct {
 	store  *store.Store
+	hub    *hub.Hub
+	cfg    config.ChatConfig
 	logger *slog.Logger
 }
 
-func NewChatHandler(store *store.Store, logger *slog.Logger) *ChatHandler {
-	return &ChatHandler{store: store, logger: logger}
+func NewChatHandler(store *store.Store, hub *hub.Hub, cfg config.ChatConfig, logger *slog.Logger) *ChatHandler {
+	return &ChatHandler{store: store, hub: hub, cfg: cfg, logger: logger}
 }
 
 // GetChats godoc
@@ -26,7 +53,9 @@ func NewChatHandler(store *store.Store, logger *slog.Logger) *ChatHandler {
 // @Description  Retrieve a list of all chats (Direct and Group) that the current user is a member of
 // @Tags         chats
 // @Produce      json
+// @Param        If-None-Match  header  string  false  "ETag from a previous response"
 // @Success      200  {object}  models.ChatListResponse
+// @Success      304  "Not modified since the given ETag"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      500  {object}  map[string]string "Internal Server Error"
 // @Router       /api/chats [get]
@@ -55,6 +84,10 @@ func (h *ChatHandler) GetChats(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debug("GetChats: retrieved chats", "user_id", userID, "chat_count", len(chats))
 
+	if err := h.applyLastSenderNames(userID, chats); err != nil {
+		h.logger.Warn("GetChats: failed to apply contact names", "error", err, "user_id", userID)
+	}
+
 	response := models.ChatListResponse{
 		Chats: chats,
 		Total: len(chats),
@@ -62,8 +95,9 @@ func (h *ChatHandler) GetChats(w http.ResponseWriter, r *http.Request) {
 		Limit: len(chats),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if err := writeJSONWithETag(w, r, response); err != nil {
+		h.logger.Error("GetChats: failed to write response", "error", err, "user_id", userID)
+	}
 }
 
 // GetChat godoc
@@ -99,29 +133,20 @@ func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Info("GetChat: fetching chat details", "user_id", userID, "chat_id", chatID)
 
-	// Verify user is a member of the chat
-	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	// Get chat details, verifying membership in the same query
+	chat, _, err := h.store.GetChatForMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("GetChat: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
-
-	// Get chat details
-	chat, err := h.store.GetChat(chatID)
 	if err != nil {
 		h.logger.Error("GetChat: failed to get chat", "error", err, "chat_id", chatID, "user_id", userID)
 		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
 		return
 	}
 
-	if chat == nil {
-		h.logger.Warn("GetChat: chat not found", "chat_id", chatID, "user_id", userID)
-		http.Error(w, "Chat not found", http.StatusNotFound)
-		return
-	}
-
 	// Get chat members
 	members, err := h.store.GetChatMembers(chatID)
 	if err != nil {
@@ -145,6 +170,11 @@ func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyContactNames(h.store, userID, users); err != nil {
+		h.logger.Warn("GetChat: failed to apply contact names",
+			"error", err, "chat_id", chatID, "user_id", userID)
+	}
+
 	h.logger.Debug("GetChat: retrieved chat details",
 		"chat_id", chatID, "user_id", userID, "member_count", len(members))
 
@@ -154,21 +184,41 @@ func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
 		Users:   users,
 	}
 
+	if chat.Type == models.ChatTypeDirect {
+		for _, member := range members {
+			if member.UserID == userID {
+				continue
+			}
+			lastRead, err := h.store.GetLastReadMessage(chatID, member.UserID)
+			if errors.Is(err, store.ErrNotFound) {
+				// Nothing read yet
+				continue
+			}
+			if err != nil {
+				h.logger.Warn("GetChat: failed to get last read message",
+					"error", err, "chat_id", chatID, "user_id", userID)
+				continue
+			}
+			response.LastReadMessage = lastRead
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
 // CreateChat godoc
 // @Summary      Create a new chat
-// @Description  Create a new direct chat (1-on-1) or group chat
+// @Description  Create a new direct chat (1-on-1) or group chat. A direct chat with the requester's own user ID is their private "Saved Messages" chat for notes; like other direct chats, asking again returns the existing one.
 // @Tags         chats
 // @Accept       json
 // @Produce      json
 // @Param        chat  body      models.ChatRequest  true  "Chat Creation Request"
 // @Success      201   {object}  models.ChatResponse
 // @Success      200   {object}  models.ChatResponse "Returned if direct chat already exists"
-// @Failure      400   {object}  map[string]string "Invalid request"
+// @Failure      400   {object}  map[string]string "Invalid request, name or description"
 // @Failure      401   {object}  map[string]string "Unauthorized"
+// @Failure      403   {object}  map[string]string "User is not accepting messages from you"
 // @Router       /api/chats [post]
 func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -200,19 +250,40 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Type == models.ChatTypeGroup && (req.Name == nil || *req.Name == "") {
+	if req.Type == models.ChatTypeGroup && (req.Name == nil || strings.TrimSpace(*req.Name) == "") {
 		h.logger.Warn("CreateChat: missing group name", "user_id", userID)
 		http.Error(w, "Group name is required", http.StatusBadRequest)
 		return
 	}
 
+	if invalid := normalizeChatDetails(req.Name, req.Description); invalid != "" {
+		h.logger.Warn("CreateChat: invalid chat details", "user_id", userID, "reason", invalid)
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+
 	if len(req.UserIDs) == 0 && req.Type != models.ChatTypeDirect {
 		h.logger.Warn("CreateChat: no users specified", "user_id", userID, "type", req.Type)
 		http.Error(w, "At least one user is required", http.StatusBadRequest)
 		return
 	}
 
-	// For direct chat, ensure exactly 2 users (creator + one other)
+	if req.Type == models.ChatTypeGroup && h.cfg.MaxGroupMembers > 0 {
+		// Creator plus each distinct invited user
+		distinct := map[string]bool{userID: true}
+		for _, id := range req.UserIDs {
+			distinct[id] = true
+		}
+		if len(distinct) > h.cfg.MaxGroupMembers {
+			h.logger.Warn("CreateChat: group exceeds member limit",
+				"user_id", userID, "member_count", len(distinct), "limit", h.cfg.MaxGroupMembers)
+			http.Error(w, fmt.Sprintf("Groups can have at most %d members", h.cfg.MaxGroupMembers), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// For direct chat, ensure exactly 2 users (creator + one other). Naming the
+	// creator instead gives them their own chat for notes, with just them in it.
 	if req.Type == models.ChatTypeDirect {
 		if len(req.UserIDs) != 1 {
 			h.logger.Warn("CreateChat: direct chat requires exactly one other user",
@@ -221,9 +292,25 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if req.UserIDs[0] != userID {
+			allowed, err := h.store.CanMessageUser(userID, req.UserIDs[0])
+			if err != nil {
+				h.logger.Error("CreateChat: failed to check recipient privacy",
+					"error", err, "user_id", userID, "other_user_id", req.UserIDs[0])
+				http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				h.logger.Warn("CreateChat: recipient does not accept messages from user",
+					"user_id", userID, "other_user_id", req.UserIDs[0])
+				http.Error(w, "This user is not accepting messages from you", http.StatusForbidden)
+				return
+			}
+		}
+
 		// Check if direct chat already exists
 		existingChat, err := h.store.GetDirectChat(userID, req.UserIDs[0])
-		if err != nil {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
 			h.logger.Error("CreateChat: failed to check existing direct chat",
 				"error", err, "user_id", userID, "other_user_id", req.UserIDs[0])
 			http.Error(w, "Failed to check existing chat", http.StatusInternalServerError)
@@ -245,6 +332,22 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 
 	// Create chat
 	chat, err := h.store.CreateChat(&req, userID)
+	if errors.Is(err, store.ErrDirectChatExists) {
+		// A concurrent request created the pair's chat first; return that one
+		existingChat, err := h.store.GetDirectChat(userID, req.UserIDs[0])
+		if err != nil {
+			h.logger.Error("CreateChat: failed to load concurrently created direct chat",
+				"error", err, "user_id", userID, "other_user_id", req.UserIDs[0])
+			http.Error(w, "Failed to create chat", http.StatusInternalServerError)
+			return
+		}
+
+		h.logger.Info("CreateChat: returning concurrently created direct chat",
+			"user_id", userID, "other_user_id", req.UserIDs[0], "chat_id", existingChat.ID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.ChatResponse{Chat: *existingChat})
+		return
+	}
 	if err != nil {
 		h.logger.Error("CreateChat: failed to create chat",
 			"error", err, "user_id", userID, "type", req.Type)
@@ -283,8 +386,9 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 // @Param        id      path      string                    true  "Chat ID"
 // @Param        updates body      models.ChatUpdateRequest  true  "Chat Update Fields"
 // @Success      200     {object}  models.Chat
-// @Failure      400     {object}  map[string]string "Invalid request"
+// @Failure      400     {object}  map[string]string "Invalid request, name or description, or pinned chat limit reached"
 // @Failure      404     {object}  map[string]string "Chat not found"
+// @Failure      409     {object}  map[string]string "Chat changed since expected_updated_at"
 // @Router       /api/chats/{id} [put]
 func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
@@ -310,9 +414,15 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 
 	// Verify user is an admin of the chat
 	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	if err != nil {
+		h.logger.Error("UpdateChat: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to update chat", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
 		h.logger.Warn("UpdateChat: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
@@ -327,19 +437,54 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if invalid := normalizeChatDetails(req.Name, req.Description); invalid != "" {
+		h.logger.Warn("UpdateChat: invalid chat details",
+			"user_id", userID, "chat_id", chatID, "reason", invalid)
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+
+	if req.PinOrder != nil && *req.PinOrder < 0 {
+		h.logger.Warn("UpdateChat: negative pin order",
+			"user_id", userID, "chat_id", chatID, "pin_order", *req.PinOrder)
+		http.Error(w, "Pin order must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.IsPinned != nil && *req.IsPinned && h.cfg.MaxPinnedChats > 0 {
+		pinned, err := h.store.CountPinnedChats(userID, chatID)
+		if err != nil {
+			h.logger.Error("UpdateChat: failed to count pinned chats",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to update chat", http.StatusInternalServerError)
+			return
+		}
+		if pinned >= h.cfg.MaxPinnedChats {
+			h.logger.Warn("UpdateChat: pinned chat limit reached",
+				"user_id", userID, "chat_id", chatID, "pinned", pinned, "limit", h.cfg.MaxPinnedChats)
+			http.Error(w, fmt.Sprintf("You can pin up to %d chats; unpin one before pinning another", h.cfg.MaxPinnedChats),
+				http.StatusBadRequest)
+			return
+		}
+	}
+
 	h.logger.Debug("UpdateChat: update request",
 		"user_id", userID, "chat_id", chatID, "update_fields", req)
 
 	// Update chat
-	if err := h.store.UpdateChat(chatID, &req); err != nil {
+	if err := h.store.UpdateChat(chatID, userID, &req); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Chat was modified by another request", http.StatusConflict)
+			return
+		}
 		h.logger.Error("UpdateChat: failed to update chat",
 			"error", err, "user_id", userID, "chat_id", chatID)
 		http.Error(w, "Failed to update chat", http.StatusInternalServerError)
 		return
 	}
 
-	// Get updated chat
-	chat, err := h.store.GetChat(chatID)
+	// Get updated chat, with the requester's own pin
+	chat, _, err := h.store.GetChatForMember(chatID, userID)
 	if err != nil {
 		h.logger.Error("UpdateChat: failed to get updated chat",
 			"error", err, "user_id", userID, "chat_id", chatID)
@@ -356,7 +501,7 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 
 // DeleteChat godoc
 // @Summary      Delete a chat
-// @Description  Permanently delete a chat and all its messages (Creator only)
+// @Description  Permanently delete a chat and all its messages (Creator only). Deleting a direct chat instead removes it for the requester only, as leaving it does: the other participant keeps it closed until they delete it too.
 // @Tags         chats
 // @Param        id   path      string  true  "Chat ID"
 // @Success      204  "No Content"
@@ -387,12 +532,46 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 
 	// Verify user is the creator or admin
 	chat, err := h.store.GetChat(chatID)
-	if err != nil || chat == nil {
+	if errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("DeleteChat: chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		h.logger.Error("DeleteChat: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+		return
+	}
+
+	// Either participant may delete a direct chat, but only their own side of it;
+	// the other keeps the history until they delete it as well
+	if chat.Type == models.ChatTypeDirect {
+		isMember, err := h.store.IsChatMember(chatID, userID)
+		if err != nil {
+			h.logger.Error("DeleteChat: failed to check membership",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to delete chat", http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			h.logger.Warn("DeleteChat: user is not a member of the direct chat",
+				"user_id", userID, "chat_id", chatID)
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		if err := h.store.RemoveChatMember(chatID, userID); err != nil {
+			h.logger.Error("DeleteChat: failed to leave direct chat",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to delete chat", http.StatusInternalServerError)
+			return
+		}
+
+		h.logger.Info("DeleteChat: left direct chat", "user_id", userID, "chat_id", chatID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
 	// Only creator can delete (or admin in future)
 	if chat.CreatedBy != userID {
@@ -410,6 +589,8 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.recordAudit(chatID, userID, models.AuditActionDelete, "")
+
 	h.logger.Info("DeleteChat: chat deleted successfully", "user_id", userID, "chat_id", chatID)
 
 	w.WriteHeader(http.StatusNoContent)
@@ -417,11 +598,15 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 
 // GetChatMembers godoc
 // @Summary      Get members of a chat
-// @Description  Retrieve a list of all members in a specific chat, including their roles and join dates. The requester must be a member of the chat.
+// @Description  Retrieve the members of a specific chat, including their roles and join dates. The requester must be a member of the chat. Members are ordered by join time, or by role (owners first) and then join time with sort=role. Without limit or offset every member is returned as a plain array; with either, a ChatMembersResponse holding one page and the total member count is returned.
 // @Tags         chats
 // @Produce      json
-// @Param        id   path      string  true  "Chat ID"
+// @Param        id      path      string  true   "Chat ID"
+// @Param        sort    query     string  false  "Member order" Enums(joined, role)
+// @Param        limit   query     int     false  "Page size (default 20, max 100); switches to a paged response"
+// @Param        offset  query     int     false  "Number of members to skip; switches to a paged response"
 // @Success      200  {array}   models.ChatMember
+// @Failure      400  {object}  map[string]string "Invalid sort"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      404  {object}  map[string]string "Chat not found or access denied"
 // @Failure      500  {object}  map[string]string "Internal Server Error"
@@ -446,17 +631,36 @@ func (h *ChatHandler) GetChatMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sort := r.URL.Query().Get("sort")
+	if sort != "" && sort != memberSortJoined && sort != memberSortRole {
+		h.logger.Warn("GetChatMembers: invalid sort", "user_id", userID, "chat_id", chatID, "sort", sort)
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+
 	h.logger.Debug("GetChatMembers: fetching members", "user_id", userID, "chat_id", chatID)
 
 	// Verify user is a member
 	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	if err != nil {
+		h.logger.Error("GetChatMembers: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat members", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
 		h.logger.Warn("GetChatMembers: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
 
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("offset") {
+		h.getChatMembersPage(w, r, userID, chatID, sort == memberSortRole)
+		return
+	}
+
 	// Get members
 	members, err := h.store.GetChatMembers(chatID)
 	if err != nil {
@@ -466,6 +670,13 @@ func (h *ChatHandler) GetChatMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sort == memberSortRole {
+		// Members come back in join order, which the stable sort keeps within a role
+		slices.SortStableFunc(members, func(a, b models.ChatMember) int {
+			return roleRank(models.ChatMemberRole(b.Role)) - roleRank(models.ChatMemberRole(a.Role))
+		})
+	}
+
 	h.logger.Debug("GetChatMembers: retrieved members",
 		"chat_id", chatID, "user_id", userID, "member_count", len(members))
 
@@ -473,195 +684,1422 @@ func (h *ChatHandler) GetChatMembers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(members)
 }
 
-// AddChatMember godoc
-// @Summary      Add member to chat
-// @Description  Add a new user to an existing group chat
+// getChatMembersPage writes one page of GetChatMembers, for large groups where
+// loading everyone at once is too heavy
+func (h *ChatHandler) getChatMembersPage(w http.ResponseWriter, r *http.Request, userID, chatID string, byRole bool) {
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	counts, err := h.store.GetMemberRoleCounts(chatID)
+	if err != nil {
+		h.logger.Error("GetChatMembers: failed to count chat members",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat members", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows
+	members, err := h.store.GetChatMembersPaged(chatID, byRole, limit+1, offset)
+	if err != nil {
+		h.logger.Error("GetChatMembers: failed to get chat members page",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat members", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(members) > limit
+	if hasMore {
+		members = members[:limit]
+	}
+
+	h.logger.Debug("GetChatMembers: retrieved members page",
+		"chat_id", chatID, "user_id", userID, "member_count", len(members), "total", counts.Total, "offset", offset)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ChatMembersResponse{
+		Members:    members,
+		Total:      counts.Total,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(members), hasMore),
+		HasMore:    hasMore,
+	})
+}
+
+// GetMemberSummary godoc
+// @Summary      Count a chat's members by role
+// @Description  Return the number of members in a chat, in total and by role, so clients can show a header such as "256 members" without loading the member list. Banned members are not counted.
 // @Tags         chats
-// @Accept       json
-// @Param        id      path      string                    true  "Chat ID"
-// @Param        member  body      models.ChatMemberRequest  true  "Member Details"
-// @Success      201     {object}  map[string]string "Member added successfully"
-// @Router       /api/chats/{id}/members [post]
-func (h *ChatHandler) AddChatMember(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// @Produce      json
+// @Param        id   path      string  true  "Chat ID"
+// @Success      200  {object}  models.MemberRoleCounts
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/members/summary [get]
+func (h *ChatHandler) GetMemberSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	userID := auth.GetUserID(r.Context())
 	if userID == "" {
-		h.logger.Warn("AddChatMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		h.logger.Warn("GetMemberSummary: unauthorized request", "method", r.Method, "path", r.URL.Path)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	chatID := r.PathValue("id")
 	if chatID == "" {
-		h.logger.Warn("AddChatMember: missing chat ID", "user_id", userID)
+		h.logger.Warn("GetMemberSummary: missing chat ID", "user_id", userID)
 		http.Error(w, "Chat ID required", http.StatusBadRequest)
 		return
 	}
 
-	h.logger.Info("AddChatMember: adding member to chat", "requester_id", userID, "chat_id", chatID)
-
-	// Verify user has permission to add members
-	// TODO: Check if user is admin/owner
-
-	var req models.ChatMemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Warn("AddChatMember: invalid request body",
-			"user_id", userID, "chat_id", chatID, "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("GetMemberSummary: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get member summary", http.StatusInternalServerError)
 		return
 	}
-
-	if req.UserID == "" {
-		h.logger.Warn("AddChatMember: missing user ID", "requester_id", userID, "chat_id", chatID)
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	if !isMember {
+		h.logger.Warn("GetMemberSummary: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
 
-	h.logger.Debug("AddChatMember: adding user to chat",
-		"requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID, "role", req.Role)
-
-	// Add member
-	role := models.ChatMemberRoleMember
-	if req.Role != nil {
-		role = models.ChatMemberRole(*req.Role)
-	}
-
-	displayName := ""
-	if req.DisplayName != nil {
-		displayName = *req.DisplayName
-	}
-
-	if err := h.store.AddChatMember(chatID, req.UserID, role, displayName); err != nil {
-		h.logger.Error("AddChatMember: failed to add chat member",
-			"error", err, "requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID)
-		http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+	counts, err := h.store.GetMemberRoleCounts(chatID)
+	if err != nil {
+		h.logger.Error("GetMemberSummary: failed to count chat members",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get member summary", http.StatusInternalServerError)
 		return
 	}
 
-	h.logger.Info("AddChatMember: member added successfully",
-		"requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID, "role", role)
+	h.logger.Debug("GetMemberSummary: counted members",
+		"user_id", userID, "chat_id", chatID, "total", counts.Total)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Member added successfully",
-	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
 }
 
-// RemoveChatMember godoc
-// @Summary      Remove member from chat
-// @Description  Remove a specific user from a group chat
+// SearchChatMembers godoc
+// @Summary      Search a chat's members
+// @Description  Find members of a chat whose name or per-chat display name contains the query, case-insensitively, ordered by the name they are shown under, so clients can locate someone in a large group without loading the whole member list. Banned members are left out.
 // @Tags         chats
-// @Param        id        path      string  true  "Chat ID"
-// @Param        memberId  path      string  true  "User ID to remove"
-// @Success      204       "No Content"
-// @Router       /api/chats/{id}/members/{memberId} [delete]
-func (h *ChatHandler) RemoveChatMember(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+// @Produce      json
+// @Param        id     path      string  true   "Chat ID"
+// @Param        q      query     string  true   "Search query"
+// @Param        limit  query     int     false  "Limit results (default 20, max 50)"
+// @Success      200    {object}  models.ChatMemberSearchResponse
+// @Failure      400    {object}  map[string]string "Search query required"
+// @Failure      401    {object}  map[string]string "Unauthorized"
+// @Failure      404    {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/members/search [get]
+func (h *ChatHandler) SearchChatMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	userID := auth.GetUserID(r.Context())
 	if userID == "" {
-		h.logger.Warn("RemoveChatMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		h.logger.Warn("SearchChatMembers: unauthorized request", "method", r.Method, "path", r.URL.Path)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	chatID := r.PathValue("id")
 	if chatID == "" {
-		h.logger.Warn("RemoveChatMember: missing chat ID", "user_id", userID)
+		h.logger.Warn("SearchChatMembers: missing chat ID", "user_id", userID)
 		http.Error(w, "Chat ID required", http.StatusBadRequest)
 		return
 	}
 
-	memberID := r.PathValue("memberId")
-	if memberID == "" {
-		h.logger.Warn("RemoveChatMember: missing member ID", "user_id", userID, "chat_id", chatID)
-		http.Error(w, "Member ID required", http.StatusBadRequest)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		h.logger.Warn("SearchChatMembers: missing search query", "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Search query required", http.StatusBadRequest)
 		return
 	}
 
-	h.logger.Info("RemoveChatMember: removing member from chat",
-		"requester_id", userID, "chat_id", chatID, "member_id", memberID)
-
-	// Verify user has permission to remove members
-	// TODO: Check if user is admin/owner
-
-	// Cannot remove yourself (use leave chat instead)
-	if memberID == userID {
-		h.logger.Warn("RemoveChatMember: cannot remove self",
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("SearchChatMembers: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to search members", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("SearchChatMembers: user is not a member or chat not found",
 			"user_id", userID, "chat_id", chatID)
-		http.Error(w, "Use leave endpoint to remove yourself", http.StatusBadRequest)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
 
-	// Remove member
-	if err := h.store.RemoveChatMember(chatID, memberID); err != nil {
-		h.logger.Error("RemoveChatMember: failed to remove chat member",
-			"error", err, "requester_id", userID, "chat_id", chatID, "member_id", memberID)
-		http.Error(w, "Failed to remove chat member", http.StatusInternalServerError)
+	limit := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+
+	// Fetch one extra to tell whether more members match
+	matches, err := h.store.SearchChatMembers(chatID, query, limit+1)
+	if err != nil {
+		h.logger.Error("SearchChatMembers: failed to search members",
+			"error", err, "user_id", userID, "chat_id", chatID, "query", query)
+		http.Error(w, "Failed to search members", http.StatusInternalServerError)
 		return
 	}
 
-	h.logger.Info("RemoveChatMember: member removed successfully",
-		"requester_id", userID, "chat_id", chatID, "member_id", memberID)
+	hasMore := len(matches) > limit
+	if hasMore {
+		matches = matches[:limit]
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	h.logger.Debug("SearchChatMembers: search completed",
+		"user_id", userID, "chat_id", chatID, "query", query, "result_count", len(matches), "has_more", hasMore)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ChatMemberSearchResponse{
+		Results: matches,
+		Limit:   limit,
+		HasMore: hasMore,
+	})
 }
 
-// LeaveChat godoc
-// @Summary      Leave a chat
-// @Description  Remove yourself from a group chat
+// GetMyMembership godoc
+// @Summary      Get my membership in a chat
+// @Description  Return the requester's membership in a chat (role, display name, mute and ban state) with the permissions that follow from their role and the group's settings, so clients know which controls to show.
 // @Tags         chats
+// @Produce      json
 // @Param        id   path      string  true  "Chat ID"
-// @Success      200  {object}  map[string]string "Left chat successfully"
-// @Router       /api/chats/{id}/leave [post]
-func (h *ChatHandler) LeaveChat(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// @Success      200  {object}  models.MyMembership
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/me [get]
+func (h *ChatHandler) GetMyMembership(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	userID := auth.GetUserID(r.Context())
 	if userID == "" {
-		h.logger.Warn("LeaveChat: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		h.logger.Warn("GetMyMembership: unauthorized request", "method", r.Method, "path", r.URL.Path)
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	chatID := r.PathValue("id")
-	if chatID == "" {
-		h.logger.Warn("LeaveChat: missing chat ID", "user_id", userID)
-		http.Error(w, "Chat ID required", http.StatusBadRequest)
+	if _, err := uuid.Parse(chatID); err != nil {
+		h.logger.Warn("GetMyMembership: invalid chat ID", "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
 
-	h.logger.Info("LeaveChat: user attempting to leave chat", "user_id", userID, "chat_id", chatID)
-
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetMyMembership: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetMyMembership: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get membership", http.StatusInternalServerError)
+		return
+	}
+
+	// The member's own view of the chat, which carries their mute
+	chat, _, err := h.store.GetChatForMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("GetMyMembership: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get membership", http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := h.store.GetGroupSettings(chatID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.Error("GetMyMembership: failed to get group settings",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get membership", http.StatusInternalServerError)
+		return
+	}
+
+	permissions := member.Permissions(chat, settings)
+
+	h.logger.Debug("GetMyMembership: retrieved membership",
+		"user_id", userID, "chat_id", chatID, "role", member.Role, "permissions", permissions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MyMembership{
+		ChatMember:  *member,
+		IsMuted:     chat.IsMuted,
+		Permissions: permissions,
+	})
+}
+
+// GetGroup godoc
+// @Summary      Get group details
+// @Description  Retrieve a group chat together with its members, settings and activity stats in one call. Stats and the join link are only included for owners and admins.
+// @Tags         chats
+// @Produce      json
+// @Param        id   path      string  true  "Chat ID"
+// @Success      200  {object}  models.GroupResponse
+// @Failure      400  {object}  map[string]string "Chat is not a group"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/group [get]
+func (h *ChatHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetGroup: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("GetGroup: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Debug("GetGroup: fetching group details", "user_id", userID, "chat_id", chatID)
+
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetGroup: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetGroup: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	chat, err := h.store.GetChat(chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetGroup: chat not found", "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetGroup: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+		return
+	}
+
+	if chat.Type != models.ChatTypeGroup {
+		h.logger.Warn("GetGroup: chat is not a group", "user_id", userID, "chat_id", chatID, "type", chat.Type)
+		http.Error(w, "Chat is not a group", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.store.GetChatMembers(chatID)
+	if err != nil {
+		h.logger.Error("GetGroup: failed to get chat members",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := h.store.GetGroupSettings(chatID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.Error("GetGroup: failed to get group settings",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get group", http.StatusInternalServerError)
+		return
+	}
+	if settings == nil {
+		// Same values as the group_settings column defaults
+		settings = &models.GroupSettings{
+			ChatID:              chatID,
+			AdminsCanEdit:       true,
+			MembersCanInvite:    true,
+			SendMediaAllowed:    true,
+			SendMessagesAllowed: true,
+			CreatedAt:           chat.CreatedAt,
+			UpdatedAt:           chat.UpdatedAt,
+		}
+	}
+
+	response := models.GroupResponse{
+		Group:    *chat,
+		Members:  members,
+		Settings: *settings,
+	}
+
+	if isChatAdmin(member) {
+		stats, err := h.store.GetGroupStats(chatID)
+		if err != nil {
+			h.logger.Error("GetGroup: failed to get group stats",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to get group", http.StatusInternalServerError)
+			return
+		}
+		response.Stats = stats
+	} else {
+		// The join link lets anyone in, so only admins get to see it
+		response.Settings.JoinLink = nil
+		response.Settings.JoinLinkExpiresAt = nil
+	}
+
+	h.logger.Debug("GetGroup: retrieved group details",
+		"user_id", userID, "chat_id", chatID, "member_count", len(members), "with_stats", response.Stats != nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateGroupSettings godoc
+// @Summary      Update group settings
+// @Description  Change a group's settings. Only fields present in the body are changed. Owners can always edit settings; admins only while admins_can_edit is on. Setting disappearing_ttl (seconds, up to 90 days) makes messages sent from then on disappear that long after they are sent; 0 turns it off. Members are notified with a settings_updated chat update.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                       true  "Chat ID"
+// @Param        settings  body      models.GroupSettingsRequest  true  "Settings to change"
+// @Success      200       {object}  models.GroupSettings
+// @Failure      400       {object}  map[string]string "Invalid settings or chat is not a group"
+// @Failure      401       {object}  map[string]string "Unauthorized"
+// @Failure      403       {object}  map[string]string "Not allowed to edit settings"
+// @Failure      404       {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/group/settings [patch]
+func (h *ChatHandler) UpdateGroupSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateGroupSettings: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("UpdateGroupSettings: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.GroupSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("UpdateGroupSettings: invalid request body",
+			"user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.SlowModeDelay != nil && *req.SlowModeDelay < 0 {
+		h.logger.Warn("UpdateGroupSettings: negative slow mode delay",
+			"user_id", userID, "chat_id", chatID, "slow_mode_delay", *req.SlowModeDelay)
+		http.Error(w, "slow_mode_delay cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.DisappearingTTL != nil &&
+		(*req.DisappearingTTL < 0 || time.Duration(*req.DisappearingTTL)*time.Second > maxDisappearingTTL) {
+		h.logger.Warn("UpdateGroupSettings: invalid disappearing TTL",
+			"user_id", userID, "chat_id", chatID, "disappearing_ttl", *req.DisappearingTTL)
+		http.Error(w, fmt.Sprintf("disappearing_ttl must be between 0 and %d seconds", int(maxDisappearingTTL.Seconds())),
+			http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateGroupSettings: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateGroupSettings: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to update group settings", http.StatusInternalServerError)
+		return
+	}
+
+	chat, err := h.store.GetChat(chatID)
+	if err != nil {
+		h.logger.Error("UpdateGroupSettings: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to update group settings", http.StatusInternalServerError)
+		return
+	}
+	if chat.Type != models.ChatTypeGroup {
+		h.logger.Warn("UpdateGroupSettings: chat is not a group", "user_id", userID, "chat_id", chatID, "type", chat.Type)
+		http.Error(w, "Chat is not a group", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.store.GetGroupSettings(chatID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		h.logger.Error("UpdateGroupSettings: failed to get group settings",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to update group settings", http.StatusInternalServerError)
+		return
+	}
+
+	if !member.Permissions(chat, settings).CanEditSettings {
+		h.logger.Warn("UpdateGroupSettings: not allowed to edit settings",
+			"user_id", userID, "chat_id", chatID, "role", member.Role)
+		http.Error(w, "You are not allowed to edit this group's settings", http.StatusForbidden)
+		return
+	}
+
+	settings, err = h.store.UpdateGroupSettings(chatID, &req)
+	if err != nil {
+		h.logger.Error("UpdateGroupSettings: failed to update group settings",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to update group settings", http.StatusInternalServerError)
+		return
+	}
+
+	// The join link lets anyone in, so keep it out of what every member receives
+	broadcast := *settings
+	broadcast.JoinLink = nil
+	broadcast.JoinLinkExpiresAt = nil
+	h.hub.BroadcastChatUpdate(chatID, models.ChatUpdateEvent{
+		Event:    models.ChatUpdateEventSettingsUpdated,
+		ChatID:   chatID,
+		UserID:   userID,
+		Settings: &broadcast,
+	})
+
+	h.logger.Info("UpdateGroupSettings: group settings updated",
+		"user_id", userID, "chat_id", chatID, "disappearing_ttl", settings.DisappearingTTL)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetAuditLog godoc
+// @Summary      Get a chat's audit log
+// @Description  Retrieve the moderation history of a chat (members added or removed, history cleared, chat deleted), newest first. Only owners and admins can view it.
+// @Tags         chats
+// @Produce      json
+// @Param        id      path      string  true   "Chat ID"
+// @Param        limit   query     int     false  "Maximum number of events to return (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of events to skip"
+// @Success      200     {object}  models.AuditLogResponse
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      403     {object}  map[string]string "Only owners and admins can view the audit log"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/audit [get]
+func (h *ChatHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetAuditLog: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("GetAuditLog: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetAuditLog: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetAuditLog: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	if !isChatAdmin(member) {
+		h.logger.Warn("GetAuditLog: non-admin tried to view audit log", "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Only owners and admins can view the audit log", http.StatusForbidden)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	events, err := h.store.GetAuditLog(chatID, limit, offset)
+	if err != nil {
+		h.logger.Error("GetAuditLog: failed to get audit log",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetAuditLog: retrieved audit log",
+		"user_id", userID, "chat_id", chatID, "count", len(events))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.AuditLogResponse{
+		Events: events,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetMessagesByStatus godoc
+// @Summary      Get a chat's messages by status
+// @Description  Diagnostic listing of a chat's messages with the given overall status (e.g. failed), newest first, for finding stuck messages. Only owners and admins can use it; clients should use /api/messages.
+// @Tags         chats
+// @Produce      json
+// @Param        id      path      string  true   "Chat ID"
+// @Param        status  query     string  true   "Message status (sent, delivered, read, failed)"
+// @Param        limit   query     int     false  "Maximum number of messages to return (default 20, max 100)"
+// @Success      200     {object}  models.MessagesByStatusResponse
+// @Failure      400     {object}  map[string]string "Invalid status"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      403     {object}  map[string]string "Only owners and admins can list messages by status"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/messages [get]
+func (h *ChatHandler) GetMessagesByStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetMessagesByStatus: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("GetMessagesByStatus: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	status := models.MessageStatus(r.URL.Query().Get("status"))
+	if !status.Valid() {
+		h.logger.Warn("GetMessagesByStatus: invalid status", "user_id", userID, "chat_id", chatID, "status", status)
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetMessagesByStatus: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetMessagesByStatus: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	if !isChatAdmin(member) {
+		h.logger.Warn("GetMessagesByStatus: non-admin tried to list messages by status",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Only owners and admins can list messages by status", http.StatusForbidden)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+
+	messages, err := h.store.GetMessagesByStatus(chatID, status, limit)
+	if err != nil {
+		h.logger.Error("GetMessagesByStatus: failed to get messages",
+			"error", err, "user_id", userID, "chat_id", chatID, "status", status)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetMessagesByStatus: retrieved messages",
+		"user_id", userID, "chat_id", chatID, "status", status, "count", len(messages))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MessagesByStatusResponse{
+		Status:   status,
+		Messages: messages,
+		Limit:    limit,
+	})
+}
+
+// GetUnreadMessages godoc
+// @Summary      Get a chat's unread messages
+// @Description  List the messages from others the requester hasn't read yet in a chat, oldest first, so a client can open the chat at its "new messages" divider. The first page carries the divider's message ID in first_unread_id. Deleted messages and ones the requester cleared from their history are left out.
+// @Tags         chats
+// @Produce      json
+// @Param        id      path      string  true   "Chat ID"
+// @Param        limit   query     int     false  "Limit results (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of results to skip (default 0)"
+// @Success      200     {object}  models.UnreadMessagesResponse
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/messages/unread [get]
+func (h *ChatHandler) GetUnreadMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetUnreadMessages: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("GetUnreadMessages: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("GetUnreadMessages: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get unread messages", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("GetUnreadMessages: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	// Fetch one extra to tell whether another page follows
+	messages, err := h.store.GetUnreadMessages(chatID, userID, limit+1, offset)
+	if err != nil {
+		h.logger.Error("GetUnreadMessages: failed to get unread messages",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get unread messages", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	response := models.UnreadMessagesResponse{
+		ChatID:     chatID,
+		Messages:   messages,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(messages), hasMore),
+		HasMore:    hasMore,
+	}
+	if offset == 0 && len(messages) > 0 {
+		response.FirstUnreadID = &messages[0].ID
+	}
+
+	h.logger.Debug("GetUnreadMessages: retrieved unread messages",
+		"user_id", userID, "chat_id", chatID, "count", len(messages), "has_more", hasMore)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetChatMedia godoc
+// @Summary      Get a chat's media gallery
+// @Description  List the photos, videos and other attachments shared in a chat, newest first, with each one's thumbnail, sender and send time. Messages the requester cleared from their history are left out.
+// @Tags         chats
+// @Produce      json
+// @Param        id      path      string  true   "Chat ID"
+// @Param        type    query     string  false  "Only this media type (image, video, audio, document, sticker); all when omitted"
+// @Param        limit   query     int     false  "Limit results (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of results to skip (default 0)"
+// @Success      200     {object}  models.MediaResponse
+// @Failure      400     {object}  map[string]string "Invalid media type"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/media [get]
+func (h *ChatHandler) GetChatMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetChatMedia: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("GetChatMedia: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	contentType := models.ContentType(r.URL.Query().Get("type"))
+	if contentType != "" && !contentType.IsMedia() {
+		h.logger.Warn("GetChatMedia: invalid media type", "user_id", userID, "chat_id", chatID, "type", contentType)
+		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("GetChatMedia: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get media", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("GetChatMedia: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	// Fetch one extra to tell whether another page follows
+	items, err := h.store.GetChatMedia(chatID, userID, contentType, limit+1, offset)
+	if err != nil {
+		h.logger.Error("GetChatMedia: failed to get media",
+			"error", err, "user_id", userID, "chat_id", chatID, "type", contentType)
+		http.Error(w, "Failed to get media", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	h.logger.Debug("GetChatMedia: retrieved media",
+		"user_id", userID, "chat_id", chatID, "type", contentType, "count", len(items), "has_more", hasMore)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MediaResponse{
+		Items:      items,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(items), hasMore),
+		HasMore:    hasMore,
+	})
+}
+
+// AddChatMember godoc
+// @Summary      Add member to chat
+// @Description  Add a new user to an existing group chat. Only admins and owners can add members, and only owners can add them as admins or owners.
+// @Tags         chats
+// @Accept       json
+// @Param        id      path      string                    true  "Chat ID"
+// @Param        member  body      models.ChatMemberRequest  true  "Member Details"
+// @Success      201     {object}  map[string]string "Member added successfully"
+// @Failure      400     {object}  map[string]string "Cannot add members to a direct chat"
+// @Failure      403     {object}  map[string]string "Not allowed to add members or grant this role, or the group has reached the member limit"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/members [post]
+func (h *ChatHandler) AddChatMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("AddChatMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("AddChatMember: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("AddChatMember: adding member to chat", "requester_id", userID, "chat_id", chatID)
+
+	requester, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("AddChatMember: user is not a member or chat not found",
+			"requester_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("AddChatMember: failed to get chat member",
+			"error", err, "requester_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+		return
+	}
+
+	chat, err := h.store.GetChat(chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("AddChatMember: chat not found", "requester_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("AddChatMember: failed to get chat", "error", err, "chat_id", chatID)
+		http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+		return
+	}
+
+	// Direct chats always have exactly two members
+	if chat.Type == models.ChatTypeDirect {
+		h.logger.Warn("AddChatMember: cannot add members to direct chat",
+			"requester_id", userID, "chat_id", chatID)
+		http.Error(w, "Cannot add members to a direct chat", http.StatusBadRequest)
+		return
+	}
+
+	if !isChatAdmin(requester) {
+		h.logger.Warn("AddChatMember: non-admin tried to add a member",
+			"requester_id", userID, "chat_id", chatID)
+		http.Error(w, "Only admins can add members", http.StatusForbidden)
+		return
+	}
+
+	var req models.ChatMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("AddChatMember: invalid request body",
+			"user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		h.logger.Warn("AddChatMember: missing user ID", "requester_id", userID, "chat_id", chatID)
+		http.Error(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Debug("AddChatMember: adding user to chat",
+		"requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID, "role", req.Role)
+
+	// Re-adding an existing member doesn't grow the group
+	if h.cfg.MaxGroupMembers > 0 {
+		alreadyMember, err := h.store.IsChatMember(chatID, req.UserID)
+		if err != nil {
+			h.logger.Error("AddChatMember: failed to check membership",
+				"error", err, "chat_id", chatID, "target_user_id", req.UserID)
+			http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+			return
+		}
+		if !alreadyMember {
+			count, err := h.store.CountChatMembers(chatID)
+			if err != nil {
+				h.logger.Error("AddChatMember: failed to count members",
+					"error", err, "chat_id", chatID)
+				http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+				return
+			}
+			if count >= h.cfg.MaxGroupMembers {
+				h.logger.Warn("AddChatMember: group is full",
+					"requester_id", userID, "chat_id", chatID, "member_count", count, "limit", h.cfg.MaxGroupMembers)
+				http.Error(w, fmt.Sprintf("Group has reached the limit of %d members", h.cfg.MaxGroupMembers), http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	// Add member
+	role := models.ChatMemberRoleMember
+	if req.Role != nil {
+		role = models.ChatMemberRole(*req.Role)
+	}
+	if !role.Valid() {
+		h.logger.Warn("AddChatMember: invalid role",
+			"requester_id", userID, "chat_id", chatID, "role", role)
+		http.Error(w, "Role must be owner, admin, member or viewer", http.StatusBadRequest)
+		return
+	}
+	// Admins add ordinary members; only owners bring in other admins or owners
+	if (role == models.ChatMemberRoleOwner || role == models.ChatMemberRoleAdmin) &&
+		requester.Role != string(models.ChatMemberRoleOwner) {
+		h.logger.Warn("AddChatMember: non-owner tried to add an admin or owner",
+			"requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID, "role", role)
+		http.Error(w, "Only owners can grant the admin or owner role", http.StatusForbidden)
+		return
+	}
+
+	displayName := ""
+	if req.DisplayName != nil {
+		displayName = *req.DisplayName
+	}
+
+	if err := h.store.AddChatMember(chatID, req.UserID, role, displayName); err != nil {
+		if errors.Is(err, store.ErrDirectChatMembers) {
+			http.Error(w, "Cannot add members to a direct chat", http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("AddChatMember: failed to add chat member",
+			"error", err, "requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID)
+		http.Error(w, "Failed to add chat member", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(chatID, userID, models.AuditActionAdd, req.UserID)
+
+	h.logger.Info("AddChatMember: member added successfully",
+		"requester_id", userID, "chat_id", chatID, "target_user_id", req.UserID, "role", role)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Member added successfully",
+	})
+}
+
+// RemoveChatMember godoc
+// @Summary      Remove member from chat
+// @Description  Remove a specific user from a group chat
+// @Tags         chats
+// @Param        id        path      string  true  "Chat ID"
+// @Param        memberId  path      string  true  "User ID to remove"
+// @Success      204       "No Content"
+// @Failure      400       {object}  map[string]string "Member is the group's last owner"
+// @Router       /api/chats/{id}/members/{memberId} [delete]
+func (h *ChatHandler) RemoveChatMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("RemoveChatMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("RemoveChatMember: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	memberID := r.PathValue("memberId")
+	if memberID == "" {
+		h.logger.Warn("RemoveChatMember: missing member ID", "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Member ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("RemoveChatMember: removing member from chat",
+		"requester_id", userID, "chat_id", chatID, "member_id", memberID)
+
+	// Verify user has permission to remove members
+	// TODO: Check if user is admin/owner
+
+	// Cannot remove yourself (use leave chat instead)
+	if memberID == userID {
+		h.logger.Warn("RemoveChatMember: cannot remove self",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Use leave endpoint to remove yourself", http.StatusBadRequest)
+		return
+	}
+
+	// Remove member
+	if err := h.store.RemoveChatMember(chatID, memberID); err != nil {
+		if errors.Is(err, store.ErrLastOwner) {
+			http.Error(w, lastOwnerMessage, http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("RemoveChatMember: failed to remove chat member",
+			"error", err, "requester_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Failed to remove chat member", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(chatID, userID, models.AuditActionRemove, memberID)
+
+	h.logger.Info("RemoveChatMember: member removed successfully",
+		"requester_id", userID, "chat_id", chatID, "member_id", memberID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateMyMember godoc
+// @Summary      Set my display name in a chat
+// @Description  Set or clear the requesting member's per-chat nickname. An empty display_name clears it.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string                          true  "Chat ID"
+// @Param        member  body      models.ChatMemberUpdateRequest  true  "Display name"
+// @Success      200     {object}  models.ChatMember
+// @Failure      400     {object}  map[string]string "Invalid request"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/members/me [patch]
+func (h *ChatHandler) UpdateMyMember(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateMyMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.updateMemberDisplayName(w, r, userID, userID)
+}
+
+// UpdateChatMember godoc
+// @Summary      Set a member's display name in a chat
+// @Description  Set or clear another member's per-chat nickname (Admins only). An empty display_name clears it.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                          true  "Chat ID"
+// @Param        memberId  path      string                          true  "Member user ID"
+// @Param        member    body      models.ChatMemberUpdateRequest  true  "Display name"
+// @Success      200       {object}  models.ChatMember
+// @Failure      400       {object}  map[string]string "Invalid request"
+// @Failure      401       {object}  map[string]string "Unauthorized"
+// @Failure      403       {object}  map[string]string "Only admins can update other members"
+// @Failure      404       {object}  map[string]string "Chat or member not found"
+// @Router       /api/chats/{id}/members/{memberId} [patch]
+func (h *ChatHandler) UpdateChatMember(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateChatMember: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	memberID := r.PathValue("memberId")
+	if memberID == "" {
+		h.logger.Warn("UpdateChatMember: missing member ID", "user_id", userID)
+		http.Error(w, "Member ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.updateMemberDisplayName(w, r, userID, memberID)
+}
+
+// updateMemberDisplayName applies a display name change requested by userID to memberID
+func (h *ChatHandler) updateMemberDisplayName(w http.ResponseWriter, r *http.Request, userID, memberID string) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("UpdateChatMember: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	requester, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateChatMember: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateChatMember: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	// Members may rename themselves; renaming others requires admin rights
+	if memberID != userID && !isChatAdmin(requester) {
+		h.logger.Warn("UpdateChatMember: non-admin tried to rename another member",
+			"user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Only admins can update other members", http.StatusForbidden)
+		return
+	}
+
+	var req models.ChatMemberUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("UpdateChatMember: invalid request body",
+			"user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.DisplayName != nil && len([]rune(*req.DisplayName)) > 100 {
+		h.logger.Warn("UpdateChatMember: display name too long",
+			"user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Display name must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UpdateChatMemberDisplayName(chatID, memberID, req.DisplayName); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.logger.Warn("UpdateChatMember: member not found",
+				"user_id", userID, "chat_id", chatID, "member_id", memberID)
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("UpdateChatMember: failed to update display name",
+			"error", err, "user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, memberID)
+	if err != nil {
+		h.logger.Error("UpdateChatMember: failed to get updated member",
+			"error", err, "user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Failed to get updated member", http.StatusInternalServerError)
+		return
+	}
+
+	// Let other clients refresh their labels
+	h.hub.BroadcastChatUpdate(chatID, models.ChatUpdateEvent{
+		Event:  models.ChatUpdateEventMemberUpdated,
+		ChatID: chatID,
+		UserID: userID,
+		Member: member,
+	})
+
+	h.logger.Info("UpdateChatMember: display name updated",
+		"user_id", userID, "chat_id", chatID, "member_id", memberID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// UpdateChatMemberRole godoc
+// @Summary      Change a member's role
+// @Description  Promote or demote a member of a group or channel. Owners may assign any role; admins may only move non-owners between admin, member and viewer. The last owner cannot be demoted. Members are notified with a role_changed chat update.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string                        true  "Chat ID"
+// @Param        memberId  path      string                        true  "Member user ID"
+// @Param        role      body      models.ChatMemberRoleRequest  true  "New role"
+// @Success      200       {object}  models.ChatMember
+// @Failure      400       {object}  map[string]string "Invalid role, direct chat, or last owner"
+// @Failure      401       {object}  map[string]string "Unauthorized"
+// @Failure      403       {object}  map[string]string "Not allowed to assign this role"
+// @Failure      404       {object}  map[string]string "Chat or member not found"
+// @Router       /api/chats/{id}/members/{memberId}/role [patch]
+func (h *ChatHandler) UpdateChatMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateChatMemberRole: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	memberID := r.PathValue("memberId")
+	if chatID == "" || memberID == "" {
+		h.logger.Warn("UpdateChatMemberRole: missing chat or member ID", "user_id", userID)
+		http.Error(w, "Chat ID and member ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ChatMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("UpdateChatMemberRole: invalid request body",
+			"user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Role.Valid() {
+		h.logger.Warn("UpdateChatMemberRole: invalid role",
+			"user_id", userID, "chat_id", chatID, "role", req.Role)
+		http.Error(w, "Role must be owner, admin, member or viewer", http.StatusBadRequest)
+		return
+	}
+
+	requester, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateChatMemberRole: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateChatMemberRole: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	chat, err := h.store.GetChat(chatID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateChatMemberRole: chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateChatMemberRole: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+		return
+	}
+	if chat.Type == models.ChatTypeDirect {
+		http.Error(w, "Direct chats have no roles", http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.store.GetChatMember(chatID, memberID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateChatMemberRole: member not found",
+			"user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateChatMemberRole: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	// Only owners can make owners or change an owner's role; admins manage the rest
+	isOwner := requester.Role == string(models.ChatMemberRoleOwner)
+	if !isOwner {
+		if !isChatAdmin(requester) {
+			h.logger.Warn("UpdateChatMemberRole: non-admin tried to change a role",
+				"user_id", userID, "chat_id", chatID, "member_id", memberID)
+			http.Error(w, "Only admins can change member roles", http.StatusForbidden)
+			return
+		}
+		if req.Role == models.ChatMemberRoleOwner || target.Role == string(models.ChatMemberRoleOwner) {
+			h.logger.Warn("UpdateChatMemberRole: admin tried to change ownership",
+				"user_id", userID, "chat_id", chatID, "member_id", memberID, "role", req.Role)
+			http.Error(w, "Only owners can grant or change the owner role", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.store.UpdateChatMemberRole(chatID, memberID, req.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrLastOwner):
+			http.Error(w, lastOwnerMessage, http.StatusBadRequest)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "Member not found", http.StatusNotFound)
+		default:
+			h.logger.Error("UpdateChatMemberRole: failed to update role",
+				"error", err, "user_id", userID, "chat_id", chatID, "member_id", memberID)
+			http.Error(w, "Failed to update member role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, memberID)
+	if err != nil {
+		h.logger.Error("UpdateChatMemberRole: failed to get updated member",
+			"error", err, "user_id", userID, "chat_id", chatID, "member_id", memberID)
+		http.Error(w, "Failed to get updated member", http.StatusInternalServerError)
+		return
+	}
+
+	if roleRank(req.Role) > roleRank(models.ChatMemberRole(target.Role)) {
+		h.recordAudit(chatID, userID, models.AuditActionPromote, memberID)
+	} else if roleRank(req.Role) < roleRank(models.ChatMemberRole(target.Role)) {
+		h.recordAudit(chatID, userID, models.AuditActionDemote, memberID)
+	}
+
+	// Clients gate admin controls on roles, so let them refresh
+	h.hub.BroadcastChatUpdate(chatID, models.ChatUpdateEvent{
+		Event:  models.ChatUpdateEventRoleChanged,
+		ChatID: chatID,
+		UserID: userID,
+		Member: member,
+	})
+
+	h.logger.Info("UpdateChatMemberRole: role updated",
+		"user_id", userID, "chat_id", chatID, "member_id", memberID, "from", target.Role, "to", req.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// LeaveChat godoc
+// @Summary      Leave a chat
+// @Description  Remove yourself from a chat. Leaving a direct chat closes it: the other participant keeps its history but can no longer send to it, and starting a new direct chat with them creates a fresh one. The chat is deleted once both have left. Leaving a chat you are not in succeeds without changes.
+// @Tags         chats
+// @Param        id   path      string  true  "Chat ID"
+// @Success      200  {object}  map[string]string "Left chat successfully"
+// @Failure      400  {object}  map[string]string "User is the group's last owner"
+// @Router       /api/chats/{id}/leave [post]
+func (h *ChatHandler) LeaveChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("LeaveChat: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("LeaveChat: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("LeaveChat: user attempting to leave chat", "user_id", userID, "chat_id", chatID)
+
 	// Get chat to check if user is the creator
 	chat, err := h.store.GetChat(chatID)
-	if err != nil || chat == nil {
+	if errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("LeaveChat: chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found", http.StatusNotFound)
 		return
 	}
-
-	// If user is the creator of a group, they can't leave (must delete or transfer ownership)
-	if chat.Type == models.ChatTypeGroup && chat.CreatedBy == userID {
-		h.logger.Warn("LeaveChat: group creator cannot leave",
-			"user_id", userID, "chat_id", chatID, "chat_type", chat.Type)
-		http.Error(w, "Group creator must transfer ownership or delete group", http.StatusBadRequest)
+	if err != nil {
+		h.logger.Error("LeaveChat: failed to get chat",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
 		return
 	}
 
-	// Remove user from chat
+	// Remove user from chat; the last owner of a group must hand it over first
 	if err := h.store.RemoveChatMember(chatID, userID); err != nil {
+		if errors.Is(err, store.ErrLastOwner) {
+			h.logger.Warn("LeaveChat: last owner cannot leave",
+				"user_id", userID, "chat_id", chatID, "chat_type", chat.Type)
+			http.Error(w, lastOwnerMessage, http.StatusBadRequest)
+			return
+		}
 		h.logger.Error("LeaveChat: failed to remove user from chat",
 			"error", err, "user_id", userID, "chat_id", chatID)
 		http.Error(w, "Failed to leave chat", http.StatusInternalServerError)
@@ -676,6 +2114,185 @@ func (h *ChatHandler) LeaveChat(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// SendTyping godoc
+// @Summary      Send a typing indicator
+// @Description  Show or clear the requester's typing indicator in a chat, for clients without a WebSocket. Members receive the same typing event as when it is sent over a WebSocket, and an indicator that is not refreshed is cleared after a few seconds.
+// @Tags         chats
+// @Accept       json
+// @Param        id       path      string                true  "Chat ID"
+// @Param        request  body      models.TypingRequest  true  "Typing state"
+// @Success      204      "No Content"
+// @Failure      400      {object}  map[string]string "Invalid request body"
+// @Failure      401      {object}  map[string]string "Unauthorized"
+// @Failure      404      {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/typing [post]
+func (h *ChatHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("SendTyping: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("SendTyping: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TypingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("SendTyping: invalid request body", "user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("SendTyping: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to send typing indicator", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("SendTyping: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	h.hub.SendTyping(chatID, userID, req.IsTyping)
+
+	h.logger.Debug("SendTyping: typing indicator sent",
+		"user_id", userID, "chat_id", chatID, "is_typing", req.IsTyping)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClearChatHistory godoc
+// @Summary      Clear chat history
+// @Description  Clear existing messages without deleting the chat. Scope "me" (default) hides them for the requester only. Scope "everyone" deletes them for all members and is allowed for group admins and for either participant of a direct chat.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string                      true   "Chat ID"
+// @Param        scope  body      models.ClearHistoryRequest  false  "Clear scope"
+// @Success      200    {object}  map[string]string "Chat history cleared"
+// @Failure      400    {object}  map[string]string "Invalid scope"
+// @Failure      401    {object}  map[string]string "Unauthorized"
+// @Failure      403    {object}  map[string]string "Only admins can clear history for everyone"
+// @Failure      404    {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/clear [post]
+func (h *ChatHandler) ClearChatHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("ClearChatHistory: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("ClearChatHistory: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional; an empty body clears for the requester only
+	var req models.ClearHistoryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.logger.Warn("ClearChatHistory: invalid request body",
+				"user_id", userID, "chat_id", chatID, "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Scope == "" {
+		req.Scope = models.ClearHistoryScopeMe
+	}
+	if req.Scope != models.ClearHistoryScopeMe && req.Scope != models.ClearHistoryScopeEveryone {
+		h.logger.Warn("ClearChatHistory: invalid scope",
+			"user_id", userID, "chat_id", chatID, "scope", req.Scope)
+		http.Error(w, "Scope must be 'me' or 'everyone'", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.store.GetChatMember(chatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("ClearChatHistory: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("ClearChatHistory: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat member", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Scope == models.ClearHistoryScopeEveryone {
+		chat, err := h.store.GetChat(chatID)
+		if errors.Is(err, store.ErrNotFound) {
+			h.logger.Warn("ClearChatHistory: chat not found",
+				"user_id", userID, "chat_id", chatID)
+			http.Error(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.logger.Error("ClearChatHistory: failed to get chat",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+			return
+		}
+
+		// Either party may clear a direct chat; groups and channels need an admin
+		if chat.Type != models.ChatTypeDirect && !isChatAdmin(member) {
+			h.logger.Warn("ClearChatHistory: non-admin tried to clear history for everyone",
+				"user_id", userID, "chat_id", chatID)
+			http.Error(w, "Only admins can clear history for everyone", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.store.ClearChatHistory(chatID, userID, req.Scope); err != nil {
+		h.logger.Error("ClearChatHistory: failed to clear history",
+			"error", err, "user_id", userID, "chat_id", chatID, "scope", req.Scope)
+		http.Error(w, "Failed to clear chat history", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Scope == models.ClearHistoryScopeEveryone {
+		h.recordAudit(chatID, userID, models.AuditActionClearHistory, "")
+		h.hub.BroadcastChatUpdate(chatID, models.ChatUpdateEvent{
+			Event:  models.ChatUpdateEventHistoryCleared,
+			ChatID: chatID,
+			UserID: userID,
+		})
+	}
+
+	h.logger.Info("ClearChatHistory: history cleared",
+		"user_id", userID, "chat_id", chatID, "scope", req.Scope)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Chat history cleared",
+		"scope":   string(req.Scope),
+	})
+}
+
 // MarkChatAsRead godoc
 // @Summary      Mark chat as read
 // @Description  Mark all messages in a chat as read for the current user
@@ -706,12 +2323,16 @@ func (h *ChatHandler) MarkChatAsRead(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("MarkChatAsRead: marking chat as read", "user_id", userID, "chat_id", chatID)
 
 	// Verify user is a member
-	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	if _, _, err := h.store.GetChatForMember(chatID, userID); errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("MarkChatAsRead: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
+	} else if err != nil {
+		h.logger.Error("MarkChatAsRead: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+		return
 	}
 
 	// Mark all messages as read
@@ -725,12 +2346,101 @@ func (h *ChatHandler) MarkChatAsRead(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("MarkChatAsRead: chat marked as read successfully",
 		"user_id", userID, "chat_id", chatID)
 
+	h.hub.Webhooks.Dispatch(models.WebhookEvent{
+		Event:  models.WebhookEventMessageRead,
+		ChatID: chatID,
+		UserID: userID,
+	})
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Chat marked as read",
 	})
 }
 
+// MarkChatAsUnread godoc
+// @Summary      Mark chat as unread
+// @Description  Flag a chat as unread again as a reminder, by moving the requester's read marker back to just before the given message, or before the latest message from someone else. Read receipts already sent are not withdrawn.
+// @Tags         chats
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                    true   "Chat ID"
+// @Param        request  body      models.MarkUnreadRequest  false  "Message to mark unread from"
+// @Success      200      {object}  map[string]string "Chat marked as unread"
+// @Failure      400      {object}  map[string]string "Invalid request body"
+// @Failure      401      {object}  map[string]string "Unauthorized"
+// @Failure      404      {object}  map[string]string "Chat or message not found"
+// @Router       /api/chats/{id}/unread [post]
+func (h *ChatHandler) MarkChatAsUnread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("MarkChatAsUnread: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("MarkChatAsUnread: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.MarkUnreadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.logger.Warn("MarkChatAsUnread: invalid request body",
+				"user_id", userID, "chat_id", chatID, "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Verify user is a member
+	if _, _, err := h.store.GetChatForMember(chatID, userID); errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("MarkChatAsUnread: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.logger.Error("MarkChatAsUnread: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get chat", http.StatusInternalServerError)
+		return
+	}
+
+	err := h.store.MarkChatAsUnread(chatID, userID, req.MessageID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("MarkChatAsUnread: no message to mark unread from",
+			"user_id", userID, "chat_id", chatID, "message_id", req.MessageID)
+		if req.MessageID != "" {
+			http.Error(w, "Message not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "No messages from others to mark unread", http.StatusNotFound)
+		}
+		return
+	}
+	if err != nil {
+		h.logger.Error("MarkChatAsUnread: failed to mark chat as unread",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to mark chat as unread", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("MarkChatAsUnread: chat marked as unread",
+		"user_id", userID, "chat_id", chatID, "message_id", req.MessageID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Chat marked as unread",
+	})
+}
+
 // SearchChats godoc
 // @Summary      Search user chats
 // @Description  Search for chats by name or description
@@ -738,8 +2448,10 @@ func (h *ChatHandler) MarkChatAsRead(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        q      query     string  true  "Search query"
 // @Param        type   query     string  false "Filter by type (direct/group)"
-// @Param        limit  query     int     false "Limit results"
-// @Success      200    {array}   models.Chat
+// @Param        limit   query     int     false "Limit results (default 20, max 50)"
+// @Param        offset  query     int     false "Number of results to skip (default 0)"
+// @Param        format  query     string  false "Set to 'array' for a bare array without paging metadata"
+// @Success      200     {object}  models.ChatSearchResponse
 // @Router       /api/chats/search [get]
 func (h *ChatHandler) SearchChats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -768,18 +2480,14 @@ func (h *ChatHandler) SearchChats(w http.ResponseWriter, r *http.Request) {
 		chatTypePtr = &ct
 	}
 
-	limit := 20
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
+	limit := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+	offset := parseOffset(r)
 
 	h.logger.Info("SearchChats: searching chats",
-		"user_id", userID, "query", query, "type", chatType, "limit", limit)
+		"user_id", userID, "query", query, "type", chatType, "limit", limit, "offset", offset)
 
-	// Search chats
-	chats, err := h.store.SearchChats(query, chatTypePtr, limit)
+	// Search chats, fetching one extra to tell whether another page follows
+	chats, err := h.store.SearchChats(query, chatTypePtr, offset, limit+1)
 	if err != nil {
 		h.logger.Error("SearchChats: failed to search chats",
 			"error", err, "user_id", userID, "query", query)
@@ -787,9 +2495,264 @@ func (h *ChatHandler) SearchChats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hasMore := len(chats) > limit
+	if hasMore {
+		chats = chats[:limit]
+	}
+	if chats == nil {
+		chats = []models.Chat{}
+	}
+
 	h.logger.Debug("SearchChats: search completed",
-		"user_id", userID, "query", query, "result_count", len(chats))
+		"user_id", userID, "query", query, "result_count", len(chats), "has_more", hasMore)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chats)
+	if wantsBareArray(r) {
+		json.NewEncoder(w).Encode(chats)
+		return
+	}
+	json.NewEncoder(w).Encode(models.ChatSearchResponse{
+		Results:    chats,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(chats), hasMore),
+		HasMore:    hasMore,
+	})
+}
+
+// ExportChat godoc
+// @Summary      Export chat history
+// @Description  Stream all non-deleted messages of a chat (with sender names resolved) as a downloadable JSON array, or as newline-delimited JSON when format=ndjson. The requester must be a member of the chat.
+// @Tags         chats
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Param        id      path      string  true   "Chat ID"
+// @Param        format  query     string  false  "Export format (json/ndjson, default json)"
+// @Success      200     {array}   models.Message
+// @Failure      400     {object}  map[string]string "Invalid format"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      404     {object}  map[string]string "Chat not found or access denied"
+// @Router       /api/chats/{id}/export [get]
+func (h *ChatHandler) ExportChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("ExportChat: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.PathValue("id")
+	if chatID == "" {
+		h.logger.Warn("ExportChat: missing chat ID", "user_id", userID)
+		http.Error(w, "Chat ID required", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "ndjson" {
+		h.logger.Warn("ExportChat: invalid format", "user_id", userID, "chat_id", chatID, "format", format)
+		http.Error(w, "Invalid format, expected json or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("ExportChat: exporting chat", "user_id", userID, "chat_id", chatID, "format", format)
+
+	// Verify user is a member
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("ExportChat: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to export chat", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("ExportChat: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="chat-%s.%s"`, chatID, format))
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	senderNames := make(map[string]string)
+
+	var afterSentAt time.Time
+	var afterID string
+	exported := 0
+
+	if format == "json" {
+		w.Write([]byte("["))
+	}
+
+	// Page through the chat by sent_at so memory stays bounded for large chats
+	for {
+		messages, err := h.store.GetMessagesAfter(chatID, userID, afterSentAt, afterID, exportBatchSize)
+		if err != nil {
+			// Headers are already sent, so the best we can do is log and stop
+			h.logger.Error("ExportChat: failed to get messages",
+				"error", err, "user_id", userID, "chat_id", chatID, "exported", exported)
+			return
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		// Resolve senders not seen in earlier batches
+		var unknownIDs []string
+		for _, msg := range messages {
+			if _, ok := senderNames[msg.SenderID]; !ok {
+				senderNames[msg.SenderID] = ""
+				unknownIDs = append(unknownIDs, msg.SenderID)
+			}
+		}
+		if len(unknownIDs) > 0 {
+			senders, err := h.store.GetUsersByIDs(unknownIDs)
+			if err != nil {
+				h.logger.Error("ExportChat: failed to get sender details",
+					"error", err, "user_id", userID, "chat_id", chatID)
+				return
+			}
+			for _, sender := range senders {
+				senderNames[sender.ID] = sender.Name
+			}
+		}
+
+		for i := range messages {
+			messages[i].SenderName = senderNames[messages[i].SenderID]
+
+			if format == "json" && exported > 0 {
+				w.Write([]byte(","))
+			}
+			if err := encoder.Encode(messages[i]); err != nil {
+				h.logger.Warn("ExportChat: failed to write message",
+					"error", err, "user_id", userID, "chat_id", chatID)
+				return
+			}
+			exported++
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		last := messages[len(messages)-1]
+		afterSentAt, afterID = last.SentAt, last.ID
+
+		if len(messages) < exportBatchSize {
+			break
+		}
+	}
+
+	if format == "json" {
+		w.Write([]byte("]"))
+	}
+
+	h.logger.Info("ExportChat: chat exported successfully",
+		"user_id", userID, "chat_id", chatID, "format", format, "message_count", exported)
+}
+
+// recordAudit adds a moderation action to the chat's audit log. The action has
+// already succeeded, so a failure to record it is only logged.
+func (h *ChatHandler) recordAudit(chatID, actorID string, action models.AuditAction, targetID string) {
+	if err := h.store.RecordAuditEvent(chatID, actorID, action, targetID); err != nil {
+		h.logger.Warn("Failed to record audit event",
+			"error", err, "chat_id", chatID, "actor_id", actorID, "action", action, "target_id", targetID)
+	}
+}
+
+// applyLastSenderNames shows each last message preview's sender under the name the
+// requester saved them as in their contacts, if any
+func (h *ChatHandler) applyLastSenderNames(requesterID string, chats []models.Chat) error {
+	var senderIDs []string
+	for _, chat := range chats {
+		if chat.LastMessage != nil && chat.LastMessage.SenderID != "" {
+			senderIDs = append(senderIDs, chat.LastMessage.SenderID)
+		}
+	}
+	if len(senderIDs) == 0 {
+		return nil
+	}
+
+	names, err := h.store.GetContactDisplayNames(requesterID, senderIDs)
+	if err != nil {
+		return err
+	}
+	for i := range chats {
+		if chats[i].LastMessage == nil {
+			continue
+		}
+		// Copy rather than modify the message, which may be shared with the cache
+		if name := names[chats[i].LastMessage.SenderID]; name != "" {
+			preview := *chats[i].LastMessage
+			preview.SenderName = name
+			chats[i].LastMessage = &preview
+		}
+	}
+	return nil
+}
+
+// Orders accepted by GetChatMembers
+const (
+	memberSortJoined = "joined"
+	memberSortRole   = "role"
+)
+
+// roleRank orders roles by privilege so role changes can be told apart as
+// promotions or demotions
+func roleRank(role models.ChatMemberRole) int {
+	switch role {
+	case models.ChatMemberRoleOwner:
+		return 3
+	case models.ChatMemberRoleAdmin:
+		return 2
+	case models.ChatMemberRoleMember:
+		return 1
+	}
+	return 0
+}
+
+// normalizeChatDetails trims a chat's name and description in place and returns
+// why they are unacceptable, or "" if they are fine. Either may be nil when it is
+// not being set; a name that is set may not be blank.
+func normalizeChatDetails(name, description *string) string {
+	if name != nil {
+		*name = strings.TrimSpace(*name)
+		if *name == "" {
+			return "Chat name cannot be empty"
+		}
+		if utf8.RuneCountInString(*name) > maxChatNameLength {
+			return fmt.Sprintf("Chat name must be at most %d characters", maxChatNameLength)
+		}
+	}
+	if description != nil {
+		*description = strings.TrimSpace(*description)
+		if utf8.RuneCountInString(*description) > maxChatDescriptionLength {
+			return fmt.Sprintf("Chat description must be at most %d characters", maxChatDescriptionLength)
+		}
+	}
+	return ""
+}
+
+// Helper function to check if a member can administer the chat
+func isChatAdmin(member *models.ChatMember) bool {
+	if member == nil {
+		return false
+	}
+	return member.IsChatAdmin()
 }
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+func TestAddChatMemberRejectsDirectChat(t *testing.T) {
+	s := newTestStore(t)
+	h := NewChatHandler(s, newTestHub(s), config.ChatConfig{}, testLogger)
+	alice, bob, carol := createTestUser(t, s), createTestUser(t, s), createTestUser(t, s)
+
+	direct, err := s.CreateChat(&models.ChatRequest{Type: models.ChatTypeDirect, UserIDs: []string{bob.ID}}, alice.ID)
+	if err != nil {
+		t.Fatalf("create direct chat: %v", err)
+	}
+
+	r := newRequest(http.MethodPost, "/api/chats/"+direct.ID+"/members", alice.ID,
+		models.ChatMemberRequest{UserID: carol.ID})
+	r.SetPathValue("id", direct.ID)
+	w := httptest.NewRecorder()
+	h.AddChatMember(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("AddChatMember to direct chat = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAddChatMemberRequiresAdmin(t *testing.T) {
+	s := newTestStore(t)
+	h := NewChatHandler(s, newTestHub(s), config.ChatConfig{}, testLogger)
+	owner, member, admin := createTestUser(t, s), createTestUser(t, s), createTestUser(t, s)
+	outsider, newcomer := createTestUser(t, s), createTestUser(t, s)
+
+	name := "Group"
+	group, err := s.CreateChat(&models.ChatRequest{
+		Type: models.ChatTypeGroup, Name: &name, UserIDs: []string{member.ID, admin.ID},
+	}, owner.ID)
+	if err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := s.UpdateChatMemberRole(group.ID, admin.ID, models.ChatMemberRoleAdmin); err != nil {
+		t.Fatalf("promote admin: %v", err)
+	}
+
+	roleAdmin := string(models.ChatMemberRoleAdmin)
+	tests := []struct {
+		name      string
+		requester string
+		role      *string
+		want      int
+	}{
+		{"non-member", outsider.ID, nil, http.StatusNotFound},
+		{"plain member", member.ID, nil, http.StatusForbidden},
+		{"admin granting admin", admin.ID, &roleAdmin, http.StatusForbidden},
+		{"owner granting admin", owner.ID, &roleAdmin, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		r := newRequest(http.MethodPost, "/api/chats/"+group.ID+"/members", tt.requester,
+			models.ChatMemberRequest{UserID: newcomer.ID, Role: tt.role})
+		r.SetPathValue("id", group.ID)
+		w := httptest.NewRecorder()
+		h.AddChatMember(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("AddChatMember by %s = %d %q, want %d", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestCreateDirectChatConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	h := NewChatHandler(s, newTestHub(s), config.ChatConfig{}, testLogger)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+
+	const attempts = 8
+	recorders := make([]*httptest.ResponseRecorder, attempts)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		// Half the requests come from each side of the pair
+		creator, other := alice.ID, bob.ID
+		if i%2 == 1 {
+			creator, other = other, creator
+		}
+		r := newRequest(http.MethodPost, "/api/chats", creator,
+			models.ChatRequest{Type: models.ChatTypeDirect, UserIDs: []string{other}})
+		recorders[i] = httptest.NewRecorder()
+
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			<-start
+			h.CreateChat(w, r)
+		}(recorders[i])
+	}
+	close(start)
+	wg.Wait()
+
+	// Losers of the race get the winner's chat back, whether they found it up
+	// front or only when their insert hit idx_chats_direct_key
+	var chatID string
+	created := 0
+	for _, w := range recorders {
+		switch w.Code {
+		case http.StatusCreated:
+			created++
+		case http.StatusOK:
+		default:
+			t.Fatalf("CreateChat = %d %q, want 200 or 201", w.Code, w.Body.String())
+		}
+
+		var response models.ChatResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if chatID == "" {
+			chatID = response.Chat.ID
+		} else if response.Chat.ID != chatID {
+			t.Fatalf("responses name different chats: %s and %s", chatID, response.Chat.ID)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d requests created a chat, want 1", created)
+	}
+}
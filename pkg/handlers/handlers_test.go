@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/moderation"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestStore returns a store on the database named by TEST_DATABASE_URL, with the
+// schema applied, and skips the test when it isn't set. Tests leave their rows
+// behind, so point it at a throwaway database. Redis is optional: without
+// TEST_REDIS_URL the store runs on its in-memory fallback.
+func newTestStore(tb testing.TB) *store.Store {
+	tb.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if redisURL == "" {
+		redisURL = "redis://127.0.0.1:1"
+	}
+
+	s, err := store.NewStore(context.Background(), url, redisURL, true, 0, testLogger)
+	if err != nil {
+		tb.Fatalf("new store: %v", err)
+	}
+	tb.Cleanup(func() { s.DB.Close() })
+
+	if err := s.InitSchema(); err != nil {
+		tb.Fatalf("init schema: %v", err)
+	}
+	return s
+}
+
+// newTestHub returns a hub that is never run, for handlers that only need its
+// moderator and store
+func newTestHub(s *store.Store) *hub.Hub {
+	return hub.NewHub(s, nil, nil, moderation.Noop{}, config.WebSocketConfig{}, config.ChatConfig{}, testLogger)
+}
+
+// testPhone returns a random 10-digit phone number, as Register requires
+func testPhone() string {
+	return fmt.Sprintf("9%09d", rand.Int64N(1e9))
+}
+
+// createTestUser creates a user with a phone number no other test uses
+func createTestUser(tb testing.TB, s *store.Store) *models.User {
+	tb.Helper()
+
+	user := &models.User{Phone: testPhone(), Name: "Test User"}
+	if err := s.CreateUser(user); err != nil {
+		tb.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// newRequest builds a request with body encoded as JSON, authenticated as userID
+// unless it is empty
+func newRequest(method, target, userID string, body any) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserIDKey, userID))
+	}
+	return r
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// Page sizes shared by list and search endpoints
+const (
+	defaultListLimit   = 20
+	maxListLimit       = 100
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// parseLimit reads the "limit" query parameter. Missing or non-positive values
+// fall back to def, and values above max are clamped to max.
+func parseLimit(r *http.Request, def, max int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// bareArrayFormat is the format query value that makes search endpoints return a
+// plain JSON array of results, as they did before paging metadata was added
+const bareArrayFormat = "array"
+
+// wantsBareArray reports whether a search request asked for the legacy response
+// without paging metadata
+func wantsBareArray(r *http.Request) bool {
+	return r.URL.Query().Get("format") == bareArrayFormat
+}
+
+// nextOffset returns the offset of the page after one of count results starting at
+// offset, or nil when there are no more results
+func nextOffset(offset, count int, hasMore bool) *int {
+	if !hasMore {
+		return nil
+	}
+	next := offset + count
+	return &next
+}
+
+// parseOffset reads the "offset" query parameter, treating missing, invalid and
+// negative values as zero
+func parseOffset(r *http.Request) int {
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp from the query parameter
+// name. It returns nil when the parameter is absent. The time is converted to the
+// server's zone, which is how sent_at and the other timestamp columns are written.
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	t = t.Local()
+	return &t, nil
+}
+
+// writeJSONWithETag writes v as a JSON response tagged with an ETag derived from
+// its content. A request whose If-None-Match already carries that tag gets 304 Not
+// Modified with no body. Only the payload is hashed, so it suits responses that
+// depend on live state such as presence or unread counts, where an updated_at would
+// miss changes.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag, using the
+// weak comparison RFC 9110 prescribes for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// applyContactNames sets DisplayName on each user the requester has saved under a
+// custom name in their contacts
+func applyContactNames(s *store.Store, requesterID string, users []models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+
+	names, err := s.GetContactDisplayNames(requesterID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range users {
+		users[i].DisplayName = names[users[i].ID]
+	}
+	return nil
+}
@@ -2,23 +2,42 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
 	"github.com/msniranjan18/common/middleware/auth"
 
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/middleware"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 	"github.com/msniranjan18/chit-chat/pkg/store"
 )
 
+// maxForwardCommentLength caps the comment that can accompany a forwarded message,
+// in characters
+const maxForwardCommentLength = 1000
+
+// deletedSenderName is shown in place of the name of a sender whose account no longer exists
+const deletedSenderName = "Deleted Account"
+
 type MessageHandler struct {
 	store  *store.Store
+	hub    *hub.Hub
+	cfg    config.ChatConfig
 	logger *slog.Logger
 }
 
-func NewMessageHandler(store *store.Store, logger *slog.Logger) *MessageHandler {
-	return &MessageHandler{store: store, logger: logger}
+func NewMessageHandler(store *store.Store, hub *hub.Hub, cfg config.ChatConfig, logger *slog.Logger) *MessageHandler {
+	return &MessageHandler{store: store, hub: hub, cfg: cfg, logger: logger}
 }
 
 // GetMessages godoc
@@ -28,7 +47,7 @@ func NewMessageHandler(store *store.Store, logger *slog.Logger) *MessageHandler
 // @Produce      json
 // @Param        chat_id  query     string  true   "Chat ID"
 // @Param        before   query     string  false  "Message ID to get messages before (for pagination)"
-// @Param        limit    query     int     false  "Number of messages to return (default 20, max 50)"
+// @Param        limit    query     int     false  "Number of messages to return (default 50, max 100)"
 // @Success      200      {array}   models.Message
 // @Failure      400      {object}  map[string]string "Chat ID required"
 // @Failure      401      {object}  map[string]string "Unauthorized"
@@ -59,34 +78,34 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		"user_id", userID, "chat_id", chatID)
 
 	// Verify user is a member
-	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	if _, _, err := h.store.GetChatForMember(chatID, userID); errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("GetMessages: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
+	} else if err != nil {
+		h.logger.Error("GetMessages: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
 	}
 
 	// Get pagination parameters
-	offset := 0
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	limit := 50
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
+	offset := parseOffset(r)
+	limit := parseLimit(r, 50, maxListLimit)
 
 	h.logger.Debug("GetMessages: pagination",
 		"chat_id", chatID, "user_id", userID, "offset", offset, "limit", limit)
 
 	// Get messages
-	messages, err := h.store.GetMessages(chatID, offset, limit)
+	messages, err := h.store.GetMessages(chatID, userID, offset, limit)
+	if errors.Is(err, store.ErrNotFound) {
+		// Left the chat since the membership check
+		h.logger.Warn("GetMessages: user is no longer a member",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.logger.Error("GetMessages: failed to get messages",
 			"error", err, "user_id", userID, "chat_id", chatID)
@@ -95,32 +114,13 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get sender details
-	var senderIDs []string
-	for _, msg := range messages {
-		senderIDs = append(senderIDs, msg.SenderID)
-	}
-
-	senders, err := h.store.GetUsersByIDs(senderIDs)
-	if err != nil {
+	if err := h.attachSenders(userID, messages); err != nil {
 		h.logger.Error("GetMessages: failed to get sender details",
-			"error", err, "user_id", userID, "chat_id", chatID, "sender_count", len(senderIDs))
+			"error", err, "user_id", userID, "chat_id", chatID)
 		http.Error(w, "Failed to get sender details", http.StatusInternalServerError)
 		return
 	}
 
-	// Create sender map
-	senderMap := make(map[string]models.User)
-	for _, sender := range senders {
-		senderMap[sender.ID] = sender
-	}
-
-	// Add sender names to messages
-	for i := range messages {
-		if sender, ok := senderMap[messages[i].SenderID]; ok {
-			messages[i].SenderName = sender.Name
-		}
-	}
-
 	h.logger.Debug("GetMessages: retrieved messages",
 		"chat_id", chatID, "user_id", userID, "message_count", len(messages))
 
@@ -139,6 +139,97 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetMessagesAround godoc
+// @Summary      Get messages around a message
+// @Description  Retrieve up to 'radius' messages before and after a target message in chronological order, for jumping to a search result or reply. Cursors can be passed back as message_id to keep paging in either direction.
+// @Tags         messages
+// @Produce      json
+// @Param        chat_id     query     string  true   "Chat ID"
+// @Param        message_id  query     string  true   "Target message ID"
+// @Param        radius      query     int     false  "Messages on each side of the target (default 20, max 50)"
+// @Success      200         {object}  models.MessagesAround
+// @Failure      400         {object}  map[string]string "Chat ID and message ID required"
+// @Failure      401         {object}  map[string]string "Unauthorized"
+// @Failure      404         {object}  map[string]string "Chat or message not found"
+// @Router       /api/messages/around [get]
+func (h *MessageHandler) GetMessagesAround(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetMessagesAround: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetMessagesAround: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chatID := r.URL.Query().Get("chat_id")
+	messageID := r.URL.Query().Get("message_id")
+	if chatID == "" || messageID == "" {
+		h.logger.Warn("GetMessagesAround: missing chat or message ID",
+			"user_id", userID, "chat_id", chatID, "message_id", messageID)
+		http.Error(w, "Chat ID and message ID are required", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is a member
+	isMember, err := h.store.IsChatMember(chatID, userID)
+	if err != nil {
+		h.logger.Error("GetMessagesAround: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("GetMessagesAround: user is not a member or chat not found",
+			"user_id", userID, "chat_id", chatID)
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+
+	radius := 20
+	if radiusStr := r.URL.Query().Get("radius"); radiusStr != "" {
+		if rd, err := strconv.Atoi(radiusStr); err == nil && rd > 0 && rd <= 50 {
+			radius = rd
+		}
+	}
+
+	h.logger.Debug("GetMessagesAround: fetching context",
+		"user_id", userID, "chat_id", chatID, "message_id", messageID, "radius", radius)
+
+	result, err := h.store.GetMessagesAround(chatID, userID, messageID, radius)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetMessagesAround: target message not found",
+			"user_id", userID, "chat_id", chatID, "message_id", messageID)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetMessagesAround: failed to get messages",
+			"error", err, "user_id", userID, "chat_id", chatID, "message_id", messageID)
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	// Add sender details to messages
+	if err := h.attachSenders(userID, result.Messages); err != nil {
+		h.logger.Error("GetMessagesAround: failed to get sender details",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to get sender details", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetMessagesAround: retrieved messages",
+		"user_id", userID, "chat_id", chatID, "message_id", messageID,
+		"message_count", len(result.Messages))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // SendMessage godoc
 // @Summary      Send a message
 // @Description  Send a new message to a specific chat (Direct or Group).
@@ -147,8 +238,10 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        message  body      models.MessageRequest  true  "Message Details"
 // @Success      201      {object}  models.Message
-// @Failure      400      {object}  map[string]string "Invalid request body"
+// @Failure      400      {object}  map[string]string "Invalid request body, reply_to, forward_from or media"
+// @Failure      403      {object}  map[string]string "User is not accepting messages from you, or group settings restrict sending"
 // @Failure      404      {object}  map[string]string "Chat not found"
+// @Failure      429      {object}  map[string]string "Too many messages sent to this chat"
 // @Router       /api/messages [post]
 func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -181,6 +274,16 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bots may only post to the chats their API key is scoped to
+	if chatIDs, ok := middleware.APIKeyChats(r.Context()); ok && !slices.Contains(chatIDs, req.ChatID) {
+		h.logger.Warn("SendMessage: chat outside API key scope", "user_id", userID, "chat_id", req.ChatID)
+		http.Error(w, "API key not allowed for this chat", http.StatusForbidden)
+		return
+	}
+
+	if h.cfg.SanitizeContent {
+		req.Content = models.SanitizeContent(req.Content)
+	}
 	if req.Content == "" {
 		h.logger.Warn("SendMessage: empty content", "user_id", userID, "chat_id", req.ChatID)
 		http.Error(w, "Message content is required", http.StatusBadRequest)
@@ -191,14 +294,78 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		req.ContentType = string(models.ContentTypeText)
 	}
 
-	// Verify user is a member
-	isMember, err := h.store.IsChatMember(req.ChatID, userID)
-	if err != nil || !isMember {
+	if invalidMedia := req.MessageMedia.Validate(models.ContentType(req.ContentType),
+		h.cfg.MaxMediaFileSize, h.cfg.MaxMediaDuration); invalidMedia != "" {
+		h.logger.Warn("SendMessage: invalid media",
+			"user_id", userID, "chat_id", req.ChatID, "content_type", req.ContentType, "reason", invalidMedia)
+		http.Error(w, invalidMedia, http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is a member; the chat is reused for the response
+	chat, _, err := h.store.GetChatForMember(req.ChatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
 		h.logger.Warn("SendMessage: user is not a member or chat not found",
-			"user_id", userID, "chat_id", req.ChatID, "error", err)
+			"user_id", userID, "chat_id", req.ChatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		h.logger.Error("SendMessage: failed to get chat info",
+			"error", err, "user_id", userID, "chat_id", req.ChatID)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	// Replies must reference a live message in the same chat
+	invalidReply, err := h.store.ValidateReplyTo(req.ChatID, req.ReplyTo)
+	if err != nil {
+		h.logger.Error("SendMessage: failed to validate reply",
+			"error", err, "user_id", userID, "chat_id", req.ChatID)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+	if invalidReply != "" {
+		h.logger.Warn("SendMessage: invalid reply_to",
+			"user_id", userID, "chat_id", req.ChatID, "reply_to", *req.ReplyTo, "reason", invalidReply)
+		http.Error(w, invalidReply, http.StatusBadRequest)
+		return
+	}
+
+	invalidForward, err := h.store.ValidateForward(req.Forwarded, req.ForwardFrom)
+	if err != nil {
+		h.logger.Error("SendMessage: failed to validate forward",
+			"error", err, "user_id", userID, "chat_id", req.ChatID)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+	if invalidForward != "" {
+		h.logger.Warn("SendMessage: invalid forward",
+			"user_id", userID, "chat_id", req.ChatID, "reason", invalidForward)
+		http.Error(w, invalidForward, http.StatusBadRequest)
+		return
+	}
+
+	if allow, reason := h.hub.Moderator.Check(r.Context(), req.Draft(userID)); !allow {
+		h.logger.Warn("SendMessage: message rejected by moderation",
+			"user_id", userID, "chat_id", req.ChatID, "reason", reason)
+		http.Error(w, reason, http.StatusBadRequest)
+		return
+	}
+
+	status, reason, err := h.checkSendAllowed(userID, req.ChatID, req.ContentType)
+	if err != nil {
+		h.logger.Error("SendMessage: failed to check send permissions",
+			"error", err, "user_id", userID, "chat_id", req.ChatID)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+	if reason != "" {
+		h.logger.Warn("SendMessage: message not allowed",
+			"user_id", userID, "chat_id", req.ChatID, "content_type", req.ContentType, "reason", reason)
+		http.Error(w, reason, status)
+		return
+	}
 
 	// Save message
 	message, err := h.store.SaveMessage(
@@ -206,6 +373,7 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		userID,
 		req.Content,
 		req.ContentType,
+		&req.MessageMedia,
 		req.ReplyTo,
 		req.ForwardFrom,
 		req.Forwarded,
@@ -217,14 +385,17 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get chat info
-	chat, err := h.store.GetChat(req.ChatID)
-	if err != nil {
-		h.logger.Error("SendMessage: failed to get chat info",
-			"error", err, "user_id", userID, "chat_id", req.ChatID)
-		http.Error(w, "Failed to get chat info", http.StatusInternalServerError)
-		return
-	}
+	go h.hub.NotifyMentions(message)
+	h.hub.Webhooks.Dispatch(models.WebhookEvent{
+		Event:     models.WebhookEventMessageSent,
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		UserID:    userID,
+		Message:   message,
+	})
+
+	// SaveMessage bumped the chat's activity after we loaded it
+	chat.LastActivity = message.SentAt
 
 	// Get chat members for response
 	members, err := h.store.GetChatMembers(req.ChatID)
@@ -248,6 +419,11 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyContactNames(h.store, userID, users); err != nil {
+		h.logger.Warn("SendMessage: failed to apply contact names",
+			"error", err, "user_id", userID, "chat_id", req.ChatID)
+	}
+
 	h.logger.Info("SendMessage: message sent successfully",
 		"user_id", userID, "chat_id", req.ChatID, "message_id", message.ID)
 
@@ -262,15 +438,244 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// ForwardMessage godoc
+// @Summary      Forward a message
+// @Description  Forward a message to one or more chats the user belongs to. The number of destination chats per request is capped, and each forward increments the source message's forward_count. An optional comment (up to 1000 characters) is sent in each chat as a text message replying to the forwarded copy.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                 true  "Message ID"
+// @Param        forward  body      models.ForwardRequest  true  "Destination chats and optional comment"
+// @Success      201      {object}  models.ForwardResponse
+// @Failure      400      {object}  map[string]string "Invalid request body, comment too long or rejected, or too many destination chats"
+// @Failure      403      {object}  map[string]string "Sending to a destination chat is not allowed"
+// @Failure      404      {object}  map[string]string "Message or destination chat not found"
+// @Failure      429      {object}  map[string]string "Too many messages sent to a destination chat"
+// @Router       /api/messages/{id}/forward [post]
+func (h *MessageHandler) ForwardMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("ForwardMessage: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("ForwardMessage: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	messageID := r.PathValue("id")
+	if messageID == "" {
+		h.logger.Warn("ForwardMessage: missing message ID", "user_id", userID)
+		http.Error(w, "Message ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("ForwardMessage: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool, len(req.ChatIDs))
+	var chatIDs []string
+	for _, chatID := range req.ChatIDs {
+		if chatID == "" || seen[chatID] {
+			continue
+		}
+		if _, err := uuid.Parse(chatID); err != nil {
+			h.logger.Warn("ForwardMessage: invalid chat ID", "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Invalid chat ID: "+chatID, http.StatusBadRequest)
+			return
+		}
+		seen[chatID] = true
+		chatIDs = append(chatIDs, chatID)
+	}
+	if len(chatIDs) == 0 {
+		h.logger.Warn("ForwardMessage: no destination chats", "user_id", userID, "message_id", messageID)
+		http.Error(w, "At least one chat ID is required", http.StatusBadRequest)
+		return
+	}
+	if len(chatIDs) > h.cfg.MaxForwardChats {
+		h.logger.Warn("ForwardMessage: too many destination chats",
+			"user_id", userID, "message_id", messageID, "chat_count", len(chatIDs), "max", h.cfg.MaxForwardChats)
+		http.Error(w, fmt.Sprintf("A message can be forwarded to at most %d chats at once", h.cfg.MaxForwardChats),
+			http.StatusBadRequest)
+		return
+	}
+
+	if h.cfg.SanitizeContent {
+		req.Comment = models.SanitizeContent(req.Comment)
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if utf8.RuneCountInString(req.Comment) > maxForwardCommentLength {
+		h.logger.Warn("ForwardMessage: comment too long",
+			"user_id", userID, "message_id", messageID, "length", utf8.RuneCountInString(req.Comment))
+		http.Error(w, fmt.Sprintf("Comment must be at most %d characters", maxForwardCommentLength),
+			http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("ForwardMessage: forwarding message",
+		"user_id", userID, "message_id", messageID, "chat_count", len(chatIDs), "has_comment", req.Comment != "")
+
+	source, err := h.store.GetMessage(messageID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && source.IsDeleted) {
+		h.logger.Warn("ForwardMessage: message not found", "user_id", userID, "message_id", messageID)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("ForwardMessage: failed to get message",
+			"error", err, "user_id", userID, "message_id", messageID)
+		http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+		return
+	}
+
+	isMember, err := h.store.IsChatMember(source.ChatID, userID)
+	if err != nil {
+		h.logger.Error("ForwardMessage: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", source.ChatID)
+		http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
+		h.logger.Warn("ForwardMessage: user is not a member of the source chat",
+			"user_id", userID, "chat_id", source.ChatID)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	// Copies keep crediting the author of the original, not whoever forwarded it last
+	forwardFrom := source.SenderID
+	if source.Forwarded && source.ForwardFrom != nil {
+		forwardFrom = *source.ForwardFrom
+	}
+
+	// Check every destination before saving anything so a refused chat doesn't
+	// leave the message forwarded to only some of them
+	destinations, err := h.store.GetChatsByIDs(userID, chatIDs)
+	if err != nil {
+		h.logger.Error("ForwardMessage: failed to get destination chats",
+			"error", err, "user_id", userID, "chat_count", len(chatIDs))
+		http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+		return
+	}
+	if len(destinations) != len(chatIDs) {
+		h.logger.Warn("ForwardMessage: user is not a member of every destination chat",
+			"user_id", userID, "requested", len(chatIDs), "found", len(destinations))
+		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
+		return
+	}
+	if req.Comment != "" {
+		comment := &models.Message{SenderID: userID, Content: req.Comment, ContentType: string(models.ContentTypeText)}
+		for _, chatID := range chatIDs {
+			comment.ChatID = chatID
+			if allow, reason := h.hub.Moderator.Check(r.Context(), comment); !allow {
+				h.logger.Warn("ForwardMessage: comment rejected by moderation",
+					"user_id", userID, "chat_id", chatID, "reason", reason)
+				http.Error(w, reason, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	for _, chatID := range chatIDs {
+		status, reason, err := h.checkSendAllowed(userID, chatID, source.ContentType)
+		if err != nil {
+			h.logger.Error("ForwardMessage: failed to check send permissions",
+				"error", err, "user_id", userID, "chat_id", chatID)
+			http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+			return
+		}
+		if reason != "" {
+			h.logger.Warn("ForwardMessage: forward not allowed",
+				"user_id", userID, "chat_id", chatID, "reason", reason)
+			http.Error(w, reason, status)
+			return
+		}
+	}
+
+	// Copies keep the original attachment
+	media := &models.MessageMedia{
+		MediaURL:     source.MediaURL,
+		ThumbnailURL: source.ThumbnailURL,
+		FileSize:     source.FileSize,
+		Duration:     source.Duration,
+	}
+	forwarded := make([]models.Message, 0, len(chatIDs))
+	var comments []models.Message
+	for _, chatID := range chatIDs {
+		message, err := h.store.SaveMessage(chatID, userID, source.Content, source.ContentType, media, nil, &forwardFrom, true)
+		if err != nil {
+			h.logger.Error("ForwardMessage: failed to save forwarded message",
+				"error", err, "user_id", userID, "chat_id", chatID, "message_id", messageID)
+			http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+			return
+		}
+		h.announceMessage(userID, message)
+		forwarded = append(forwarded, *message)
+
+		if req.Comment == "" {
+			continue
+		}
+		// Replying to the copy lets clients show the comment with what it is about
+		comment, err := h.store.SaveMessage(chatID, userID, req.Comment, string(models.ContentTypeText), nil, &message.ID, nil, false)
+		if err != nil {
+			h.logger.Error("ForwardMessage: failed to save forward comment",
+				"error", err, "user_id", userID, "chat_id", chatID, "message_id", messageID)
+			http.Error(w, "Failed to forward message", http.StatusInternalServerError)
+			return
+		}
+		comment.ReplyMessage = message.AsReplyQuote()
+		h.announceMessage(userID, comment)
+		comments = append(comments, *comment)
+	}
+
+	forwardCount, err := h.store.IncrementForwardCount(messageID, len(forwarded))
+	if err != nil {
+		// The copies are already sent; only the counter is off
+		h.logger.Error("ForwardMessage: failed to increment forward count",
+			"error", err, "user_id", userID, "message_id", messageID)
+		forwardCount = source.ForwardCount
+	}
+
+	if err := h.attachSenders(userID, forwarded); err != nil {
+		h.logger.Warn("ForwardMessage: failed to attach senders",
+			"error", err, "user_id", userID, "message_id", messageID)
+	}
+	if err := h.attachSenders(userID, comments); err != nil {
+		h.logger.Warn("ForwardMessage: failed to attach comment senders",
+			"error", err, "user_id", userID, "message_id", messageID)
+	}
+
+	h.logger.Info("ForwardMessage: message forwarded successfully",
+		"user_id", userID, "message_id", messageID, "chat_count", len(forwarded), "forward_count", forwardCount)
+
+	response := models.ForwardResponse{
+		Messages:            forwarded,
+		Comments:            comments,
+		ForwardCount:        forwardCount,
+		FrequentlyForwarded: h.isFrequentlyForwarded(forwardCount),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
 // UpdateMessage godoc
 // @Summary      Edit a message
-// @Description  Update the text content of a previously sent message. Only the sender can edit their own message.
+// @Description  Update the text of a previously sent message, or the caption of a media message. The attached media is kept and the content type cannot be changed. Only the sender can edit their own message.
 // @Tags         messages
 // @Accept       json
 // @Produce      json
 // @Param        id       path      string                      true  "Message ID"
 // @Param        updates  body      models.MessageUpdateRequest  true  "New Content"
 // @Success      200      {object}  models.Message
+// @Failure      400      {object}  map[string]string "Missing content, content type change, media message without valid media or content rejected by moderation"
 // @Failure      403      {object}  map[string]string "Forbidden - Not the sender"
 // @Failure      404      {object}  map[string]string "Message not found"
 // @Router       /api/messages/{id} [put]
@@ -299,12 +704,17 @@ func (h *MessageHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Get message to verify ownership
 	message, err := h.store.GetMessage(messageID)
-	if err != nil || message == nil {
-		h.logger.Warn("UpdateMessage: message not found",
-			"user_id", userID, "message_id", messageID, "error", err)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && message.IsDeleted) {
+		h.logger.Warn("UpdateMessage: message not found", "user_id", userID, "message_id", messageID)
 		http.Error(w, "Message not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		h.logger.Error("UpdateMessage: failed to get message",
+			"error", err, "user_id", userID, "message_id", messageID)
+		http.Error(w, "Failed to update message", http.StatusInternalServerError)
+		return
+	}
 
 	// Only sender can edit message
 	if message.SenderID != userID {
@@ -330,13 +740,43 @@ func (h *MessageHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Content == "" {
+	if req.ContentType != "" && req.ContentType != message.ContentType {
+		h.logger.Warn("UpdateMessage: content type change not allowed",
+			"user_id", userID, "message_id", messageID, "from", message.ContentType, "to", req.ContentType)
+		http.Error(w, "Message content type cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	if h.cfg.SanitizeContent {
+		req.Content = models.SanitizeContent(req.Content)
+	}
+
+	// Media messages keep their attachment, so only the caption changes and it may be
+	// cleared; other messages must still have text
+	if models.ContentType(message.ContentType).IsMedia() {
+		if !models.ValidMediaURL(message.MediaURL) {
+			h.logger.Warn("UpdateMessage: media message has no valid media",
+				"user_id", userID, "message_id", messageID, "content_type", message.ContentType)
+			http.Error(w, "Media message has no valid media attached", http.StatusBadRequest)
+			return
+		}
+	} else if req.Content == "" {
 		h.logger.Warn("UpdateMessage: empty content",
 			"user_id", userID, "message_id", messageID)
 		http.Error(w, "Content is required", http.StatusBadRequest)
 		return
 	}
 
+	// Edits are vetted like new messages, so an edit can't slip past moderation
+	edited := *message
+	edited.Content = req.Content
+	if allow, reason := h.hub.Moderator.Check(r.Context(), &edited); !allow {
+		h.logger.Warn("UpdateMessage: edit rejected by moderation",
+			"user_id", userID, "message_id", messageID, "reason", reason)
+		http.Error(w, reason, http.StatusBadRequest)
+		return
+	}
+
 	// Update message
 	if err := h.store.UpdateMessageContent(messageID, req.Content); err != nil {
 		h.logger.Error("UpdateMessage: failed to update message",
@@ -354,6 +794,25 @@ func (h *MessageHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	updated := []models.Message{*updatedMessage}
+	if err := h.attachSenders(userID, updated); err != nil {
+		h.logger.Warn("UpdateMessage: failed to attach senders",
+			"error", err, "user_id", userID, "message_id", messageID)
+	}
+	updatedMessage = &updated[0]
+
+	if updatedMessage.ReplyTo != nil {
+		reply, err := h.store.GetMessage(*updatedMessage.ReplyTo)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				h.logger.Warn("UpdateMessage: failed to get replied-to message",
+					"error", err, "user_id", userID, "message_id", messageID, "reply_to", *updatedMessage.ReplyTo)
+			}
+		} else if !reply.IsDeleted {
+			updatedMessage.ReplyMessage = reply.AsReplyQuote()
+		}
+	}
+
 	h.logger.Info("UpdateMessage: message updated successfully",
 		"user_id", userID, "message_id", messageID)
 
@@ -363,11 +822,14 @@ func (h *MessageHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
 
 // DeleteMessage godoc
 // @Summary      Delete a message
-// @Description  Permanently delete a message for all participants.
+// @Description  Delete a message for all participants. Senders may only do so within DELETE_FOR_EVERYONE_WINDOW of sending it, unless they own or administer the group; after that the message is deleted for them only and a 200 response explains why.
 // @Tags         messages
+// @Produce      json
 // @Param        id   path      string  true  "Message ID"
-// @Success      204  "No Content"
+// @Success      204  "Deleted for everyone"
+// @Success      200  {object}  models.MessageDeleteResponse "Too old to delete for everyone; deleted for the requester only"
 // @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      403  {object}  map[string]string "Only message sender can delete"
 // @Failure      404  {object}  map[string]string "Message not found"
 // @Router       /api/messages/{id} [delete]
 func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
@@ -395,12 +857,17 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Get message to verify ownership
 	message, err := h.store.GetMessage(messageID)
-	if err != nil || message == nil {
-		h.logger.Warn("DeleteMessage: message not found",
-			"user_id", userID, "message_id", messageID, "error", err)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("DeleteMessage: message not found", "user_id", userID, "message_id", messageID)
 		http.Error(w, "Message not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		h.logger.Error("DeleteMessage: failed to get message",
+			"error", err, "user_id", userID, "message_id", messageID)
+		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+		return
+	}
 
 	// Only sender can delete message
 	if message.SenderID != userID {
@@ -410,6 +877,41 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Past the window a sender can only remove the message from their own view,
+	// unless they run the group
+	if window := h.cfg.DeleteForEveryoneWindow; window > 0 && time.Since(message.SentAt) > window {
+		chat, role, err := h.store.GetChatForMember(message.ChatID, userID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			h.logger.Error("DeleteMessage: failed to get chat info",
+				"error", err, "user_id", userID, "message_id", messageID, "chat_id", message.ChatID)
+			http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+			return
+		}
+		isGroupAdmin := err == nil && chat.Type != models.ChatTypeDirect &&
+			(role == models.ChatMemberRoleOwner || role == models.ChatMemberRoleAdmin)
+
+		if !isGroupAdmin {
+			if err := h.store.HideMessage(message.ChatID, messageID, userID); err != nil {
+				h.logger.Error("DeleteMessage: failed to delete message for sender",
+					"error", err, "user_id", userID, "message_id", messageID)
+				http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+				return
+			}
+
+			h.logger.Info("DeleteMessage: too old to delete for everyone, deleted for sender only",
+				"user_id", userID, "message_id", messageID, "sent_at", message.SentAt, "window", window)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.MessageDeleteResponse{
+				MessageID: messageID,
+				Scope:     models.DeleteScopeMe,
+				Message: fmt.Sprintf("Messages can only be deleted for everyone within %s of sending, so it was deleted for you only",
+					formatWindow(window)),
+			})
+			return
+		}
+	}
+
 	// Delete message
 	if err := h.store.DeleteMessage(messageID); err != nil {
 		h.logger.Error("DeleteMessage: failed to delete message",
@@ -424,6 +926,18 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// formatWindow writes a duration the way a person would, e.g. 1h rather than 1h0m0s
+func formatWindow(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
 // MarkAsRead godoc
 // @Summary      Mark message as read
 // @Description  Updates the status of a specific message to 'read'.
@@ -480,22 +994,123 @@ func (h *MessageHandler) UpdateMessageStatus(w http.ResponseWriter, r *http.Requ
 	h.logger.Debug("UpdateMessageStatus: status updated successfully",
 		"user_id", userID, "message_id", req.MessageID, "status", req.Status)
 
+	if event, ok := models.WebhookEventForStatus(req.Status); ok {
+		if message, err := h.store.GetMessage(req.MessageID); err != nil {
+			h.logger.Warn("UpdateMessageStatus: failed to get message for webhooks",
+				"error", err, "user_id", userID, "message_id", req.MessageID)
+		} else {
+			h.hub.Webhooks.Dispatch(models.WebhookEvent{
+				Event:     event,
+				ChatID:    message.ChatID,
+				MessageID: message.ID,
+				UserID:    userID,
+			})
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Message status updated",
 	})
 }
 
+// GetMessageStatus godoc
+// @Summary      Get message status summary
+// @Description  List which members a message has been delivered to and read by. Only available to the sender and chat admins.
+// @Tags         messages
+// @Produce      json
+// @Param        id   path      string  true  "Message ID"
+// @Success      200  {object}  models.MessageStatusSummary
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      403  {object}  map[string]string "Forbidden - Not the sender or an admin"
+// @Failure      404  {object}  map[string]string "Message not found"
+// @Router       /api/messages/{id}/status [get]
+func (h *MessageHandler) GetMessageStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetMessageStatus: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetMessageStatus: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	messageID := r.PathValue("id")
+	if messageID == "" {
+		h.logger.Warn("GetMessageStatus: missing message ID", "user_id", userID)
+		http.Error(w, "Message ID required", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.store.GetMessage(messageID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetMessageStatus: message not found", "user_id", userID, "message_id", messageID)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetMessageStatus: failed to get message",
+			"error", err, "user_id", userID, "message_id", messageID)
+		http.Error(w, "Failed to get message status", http.StatusInternalServerError)
+		return
+	}
+
+	member, err := h.store.GetChatMember(message.ChatID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetMessageStatus: user is not a member of the chat",
+			"user_id", userID, "message_id", messageID, "chat_id", message.ChatID)
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("GetMessageStatus: failed to get chat member",
+			"error", err, "user_id", userID, "chat_id", message.ChatID)
+		http.Error(w, "Failed to get message status", http.StatusInternalServerError)
+		return
+	}
+
+	// Only the sender and chat admins can see per-member receipts
+	if message.SenderID != userID && !isChatAdmin(member) {
+		h.logger.Warn("GetMessageStatus: user is not the sender or an admin",
+			"user_id", userID, "message_id", messageID, "sender_id", message.SenderID)
+		http.Error(w, "Only the message sender or chat admins can view message status", http.StatusForbidden)
+		return
+	}
+
+	summary, err := h.store.GetMessageStatusSummary(messageID)
+	if err != nil {
+		h.logger.Error("GetMessageStatus: failed to get message status summary",
+			"error", err, "user_id", userID, "message_id", messageID)
+		http.Error(w, "Failed to get message status", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetMessageStatus: retrieved message status summary",
+		"user_id", userID, "message_id", messageID,
+		"delivered_count", len(summary.DeliveredTo), "read_count", len(summary.ReadBy))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
 // SearchMessages godoc
 // @Summary      Search messages
-// @Description  Search for text within messages of a specific chat.
+// @Description  Search for text within messages of a specific chat, optionally only those sent within a date range.
 // @Tags         messages
 // @Produce      json
 // @Param        chat_id  query     string  true   "Chat ID"
 // @Param        q        query     string  true   "Search query"
-// @Param        limit    query     int     false  "Limit results"
-// @Success      200      {array}   models.Message
-// @Failure      400      {object}  map[string]string "Query required"
+// @Param        from     query     string  false  "Only messages sent at or after this RFC 3339 time"
+// @Param        to       query     string  false  "Only messages sent at or before this RFC 3339 time"
+// @Param        limit    query     int     false  "Limit results (default 20, max 50)"
+// @Param        offset   query     int     false  "Number of results to skip (default 0)"
+// @Param        format   query     string  false  "Set to 'array' for a bare array without paging metadata"
+// @Success      200      {object}  models.MessageSearchResponse
+// @Failure      400      {object}  map[string]string "Query required or invalid date range"
 // @Router       /api/messages/search [get]
 func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -525,27 +1140,48 @@ func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		h.logger.Warn("SearchMessages: invalid from time", "user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid from time, expected RFC 3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		h.logger.Warn("SearchMessages: invalid to time", "user_id", userID, "chat_id", chatID, "error", err)
+		http.Error(w, "Invalid to time, expected RFC 3339", http.StatusBadRequest)
+		return
+	}
+	if from != nil && to != nil && from.After(*to) {
+		h.logger.Warn("SearchMessages: from is after to",
+			"user_id", userID, "chat_id", chatID, "from", from, "to", to)
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
 	h.logger.Info("SearchMessages: searching messages",
-		"user_id", userID, "chat_id", chatID, "query", query)
+		"user_id", userID, "chat_id", chatID, "query", query, "from", from, "to", to)
 
 	// Verify user is a member
 	isMember, err := h.store.IsChatMember(chatID, userID)
-	if err != nil || !isMember {
+	if err != nil {
+		h.logger.Error("SearchMessages: failed to check membership",
+			"error", err, "user_id", userID, "chat_id", chatID)
+		http.Error(w, "Failed to search messages", http.StatusInternalServerError)
+		return
+	}
+	if !isMember {
 		h.logger.Warn("SearchMessages: user is not a member or chat not found",
-			"user_id", userID, "chat_id", chatID, "error", err)
+			"user_id", userID, "chat_id", chatID)
 		http.Error(w, "Chat not found or access denied", http.StatusNotFound)
 		return
 	}
 
-	limit := 20
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
-			limit = l
-		}
-	}
+	limit := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+	offset := parseOffset(r)
 
-	// Search messages
-	messages, err := h.store.SearchMessages(chatID, query, limit)
+	// Search messages, fetching one extra to tell whether another page follows
+	messages, err := h.store.SearchMessages(chatID, userID, query, from, to, offset, limit+1)
 	if err != nil {
 		h.logger.Error("SearchMessages: failed to search messages",
 			"error", err, "user_id", userID, "chat_id", chatID, "query", query)
@@ -553,9 +1189,198 @@ func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
 	h.logger.Debug("SearchMessages: search completed",
-		"user_id", userID, "chat_id", chatID, "query", query, "result_count", len(messages))
+		"user_id", userID, "chat_id", chatID, "query", query, "result_count", len(messages), "has_more", hasMore)
+
+	w.Header().Set("Content-Type", "application/json")
+	if wantsBareArray(r) {
+		json.NewEncoder(w).Encode(messages)
+		return
+	}
+	json.NewEncoder(w).Encode(models.MessageSearchResponse{
+		Results:    messages,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(messages), hasMore),
+		HasMore:    hasMore,
+	})
+}
+
+// GetMentions godoc
+// @Summary      List mentions
+// @Description  List messages in which the current user was mentioned with @{userID}, newest first, across all their chats.
+// @Tags         messages
+// @Produce      json
+// @Param        limit   query     int  false  "Number of messages to return (default 20, max 100)"
+// @Param        offset  query     int  false  "Number of messages to skip"
+// @Success      200     {array}   models.Message
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Router       /api/messages/mentions [get]
+func (h *MessageHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetMentions: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetMentions: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	messages, err := h.store.GetMentions(userID, limit, offset)
+	if err != nil {
+		h.logger.Error("GetMentions: failed to get mentions",
+			"error", err, "user_id", userID)
+		http.Error(w, "Failed to get mentions", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.attachSenders(userID, messages); err != nil {
+		h.logger.Error("GetMentions: failed to attach senders",
+			"error", err, "user_id", userID)
+		http.Error(w, "Failed to get mentions", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetMentions: mentions retrieved",
+		"user_id", userID, "count", len(messages), "limit", limit, "offset", offset)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(messages)
 }
+
+// checkSendAllowed applies the checks every new message in a chat must pass besides
+// membership: direct chats must still be open and the recipient's privacy settings
+// must allow it, group send restrictions and the per-chat rate limit. A non-empty
+// reason means the message is refused with the returned HTTP status.
+func (h *MessageHandler) checkSendAllowed(userID, chatID, contentType string) (int, string, error) {
+	peerID, err := h.store.GetDirectChatPeer(chatID, userID)
+	if errors.Is(err, store.ErrChatClosed) {
+		return http.StatusForbidden, "This chat is closed because the other participant left", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if peerID != "" {
+		allowed, err := h.store.CanMessageUser(userID, peerID)
+		if err != nil {
+			return 0, "", err
+		}
+		if !allowed {
+			return http.StatusForbidden, "This user is not accepting messages from you", nil
+		}
+	}
+
+	restriction, err := h.store.CheckGroupSendPermission(chatID, userID, contentType)
+	if err != nil {
+		return 0, "", err
+	}
+	if restriction != "" {
+		return http.StatusForbidden, restriction, nil
+	}
+
+	// Counted last so messages rejected above don't use up the sender's allowance
+	allowed, err := h.store.AllowChatMessage(chatID, userID, h.cfg.MessageRateLimit, h.cfg.MessageRateWindow)
+	if err != nil {
+		return 0, "", err
+	}
+	if !allowed {
+		return http.StatusTooManyRequests, "Too many messages in this chat, slow down", nil
+	}
+
+	return 0, "", nil
+}
+
+// announceMessage tells mentioned members and webhooks about a message sent by
+// userID
+func (h *MessageHandler) announceMessage(userID string, message *models.Message) {
+	go h.hub.NotifyMentions(message)
+	h.hub.Webhooks.Dispatch(models.WebhookEvent{
+		Event:     models.WebhookEventMessageSent,
+		ChatID:    message.ChatID,
+		MessageID: message.ID,
+		UserID:    userID,
+		Message:   message,
+	})
+}
+
+// isFrequentlyForwarded reports whether a message forwarded count times should be
+// flagged so clients can warn before it is shared further
+func (h *MessageHandler) isFrequentlyForwarded(count int) bool {
+	return h.cfg.FrequentlyForwardedAt > 0 && count >= h.cfg.FrequentlyForwardedAt
+}
+
+// attachSenders fills in the sender name and avatar of each message, and the original
+// sender's name of forwarded messages, with a single lookup per distinct user.
+// Names the requester saved in their contacts take precedence, and users whose
+// accounts no longer exist get a placeholder. Messages forwarded past the configured
+// threshold are flagged as frequently forwarded.
+func (h *MessageHandler) attachSenders(requesterID string, messages []models.Message) error {
+	seen := make(map[string]bool)
+	var senderIDs []string
+	for _, msg := range messages {
+		if !seen[msg.SenderID] {
+			seen[msg.SenderID] = true
+			senderIDs = append(senderIDs, msg.SenderID)
+		}
+		if msg.ForwardFrom != nil && !seen[*msg.ForwardFrom] {
+			seen[*msg.ForwardFrom] = true
+			senderIDs = append(senderIDs, *msg.ForwardFrom)
+		}
+	}
+	if len(senderIDs) == 0 {
+		return nil
+	}
+
+	senders, err := h.store.GetUsersByIDs(senderIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := applyContactNames(h.store, requesterID, senders); err != nil {
+		return err
+	}
+
+	senderMap := make(map[string]models.User, len(senders))
+	for _, sender := range senders {
+		if sender.DisplayName != "" {
+			sender.Name = sender.DisplayName
+		}
+		senderMap[sender.ID] = sender
+	}
+
+	for i := range messages {
+		if sender, ok := senderMap[messages[i].SenderID]; ok {
+			messages[i].SenderName = sender.Name
+			messages[i].SenderAvatar = sender.AvatarURL
+		} else {
+			messages[i].SenderName = deletedSenderName
+		}
+
+		if messages[i].Forwarded && messages[i].ForwardFrom != nil {
+			if original, ok := senderMap[*messages[i].ForwardFrom]; ok {
+				messages[i].ForwardName = original.Name
+			} else {
+				messages[i].ForwardName = deletedSenderName
+			}
+		}
+
+		messages[i].FrequentlyForwarded = h.isFrequentlyForwarded(messages[i].ForwardCount)
+	}
+
+	return nil
+}
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
 
 	"github.com/msniranjan18/common/middleware/auth"
 
@@ -13,6 +18,18 @@ import (
 	"github.com/msniranjan18/chit-chat/pkg/store"
 )
 
+// maxLookupPhones caps the number of phone numbers accepted by a single contact lookup
+const maxLookupPhones = 500
+
+// maxBulkContacts caps the number of entries accepted by a single contacts import
+const maxBulkContacts = 500
+
+// maxSessionLabelLength matches the user_sessions.label column
+const maxSessionLabelLength = 100
+
+// maxPushTokenLength is well above the longest FCM and APNs device tokens
+const maxPushTokenLength = 4096
+
 type UserHandler struct {
 	store  *store.Store
 	logger *slog.Logger
@@ -27,7 +44,9 @@ func NewUserHandler(store *store.Store, logger *slog.Logger) *UserHandler {
 // @Description  Retrieve the profile details of the currently authenticated user
 // @Tags         users
 // @Produce      json
+// @Param        If-None-Match  header  string  false  "ETag from a previous response"
 // @Success      200  {object}  models.User
+// @Success      304  "Not modified since the given ETag"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      404  {object}  map[string]string "User not found"
 // @Router       /api/users/me [get]
@@ -48,27 +67,211 @@ func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("GetCurrentUser: fetching user", "user_id", userID)
 
 	user, err := h.store.GetUserByID(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetCurrentUser: user not found", "user_id", userID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.logger.Error("GetCurrentUser: failed to get user", "error", err, "user_id", userID)
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
 		return
 	}
 
-	if user == nil {
-		h.logger.Warn("GetCurrentUser: user not found", "user_id", userID)
-		http.Error(w, "User not found", http.StatusNotFound)
+	h.logger.Debug("GetCurrentUser: retrieved user", "user_id", userID, "name", user.Name)
+
+	if err := writeJSONWithETag(w, r, user); err != nil {
+		h.logger.Error("GetCurrentUser: failed to write response", "error", err, "user_id", userID)
+	}
+}
+
+// GetTotalUnread godoc
+// @Summary      Get total unread count
+// @Description  Count unread messages across all of the current user's chats, e.g. for an app icon badge
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  models.UnreadSummary
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /api/users/me/unread [get]
+func (h *UserHandler) GetTotalUnread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetTotalUnread: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	h.logger.Debug("GetCurrentUser: retrieved user", "user_id", userID, "name", user.Name)
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetTotalUnread: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := h.store.GetTotalUnread(userID)
+	if err != nil {
+		h.logger.Error("GetTotalUnread: failed to get unread count", "error", err, "user_id", userID)
+		http.Error(w, "Failed to get unread count", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetTotalUnread: retrieved unread count",
+		"user_id", userID, "total_unread", summary.TotalUnread)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// GetNotificationSettings godoc
+// @Summary      Get notification settings
+// @Description  Get the current user's notification preferences, shared by all of their devices. Users who never changed them get the defaults.
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  models.NotificationSettings
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /api/users/me/notifications [get]
+func (h *UserHandler) GetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetNotificationSettings: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetNotificationSettings: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.store.GetNotificationSettings(userID)
+	if err != nil {
+		h.logger.Error("GetNotificationSettings: failed to get settings", "error", err, "user_id", userID)
+		http.Error(w, "Failed to get notification settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// UpdateNotificationSettings godoc
+// @Summary      Update notification settings
+// @Description  Change the current user's notification preferences. Omitted fields keep their value. Quiet hours are HH:MM times in the given IANA timezone, set together and possibly wrapping past midnight; send both as "" to clear them.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        settings  body      models.NotificationSettingsRequest  true  "Settings to change"
+// @Success      200       {object}  models.NotificationSettings
+// @Failure      400       {object}  map[string]string "Invalid settings"
+// @Failure      401       {object}  map[string]string "Unauthorized"
+// @Router       /api/users/me/notifications [put]
+func (h *UserHandler) UpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		h.logger.Warn("UpdateNotificationSettings: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateNotificationSettings: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.NotificationSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("UpdateNotificationSettings: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.store.GetNotificationSettings(userID)
+	if err != nil {
+		h.logger.Error("UpdateNotificationSettings: failed to get settings", "error", err, "user_id", userID)
+		http.Error(w, "Failed to update notification settings", http.StatusInternalServerError)
+		return
+	}
+
+	settings := req.Apply(*current)
+	if invalid := settings.Validate(); invalid != "" {
+		h.logger.Warn("UpdateNotificationSettings: invalid settings", "user_id", userID, "reason", invalid)
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveNotificationSettings(userID, settings); err != nil {
+		h.logger.Error("UpdateNotificationSettings: failed to save settings", "error", err, "user_id", userID)
+		http.Error(w, "Failed to update notification settings", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("UpdateNotificationSettings: settings updated",
+		"user_id", userID, "mute_all", settings.MuteAll, "has_quiet_hours", settings.QuietHoursStart != nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// RegisterPushToken godoc
+// @Summary      Register a push token
+// @Description  Register the current device's push token so the user is notified of new messages while not connected. android and web tokens come from Firebase Cloud Messaging, ios tokens from APNs. Registering a token again refreshes it; tokens the push service rejects are removed.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        token  body      models.PushTokenRequest  true  "Device token and platform"
+// @Success      201    {object}  models.PushToken
+// @Failure      400    {object}  map[string]string "Missing token or unknown platform"
+// @Failure      401    {object}  map[string]string "Unauthorized"
+// @Router       /api/users/me/push-tokens [post]
+func (h *UserHandler) RegisterPushToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("RegisterPushToken: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("RegisterPushToken: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.PushTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("RegisterPushToken: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || len(req.Token) > maxPushTokenLength {
+		h.logger.Warn("RegisterPushToken: missing or oversized token", "user_id", userID, "length", len(req.Token))
+		http.Error(w, "A valid token is required", http.StatusBadRequest)
+		return
+	}
+	if !req.Platform.Valid() {
+		h.logger.Warn("RegisterPushToken: unknown platform", "user_id", userID, "platform", req.Platform)
+		http.Error(w, "platform must be android, web or ios", http.StatusBadRequest)
+		return
+	}
+
+	pushToken, err := h.store.SavePushToken(userID, req.Token, req.Platform)
+	if err != nil {
+		h.logger.Error("RegisterPushToken: failed to save token", "error", err, "user_id", userID)
+		http.Error(w, "Failed to register push token", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("RegisterPushToken: token registered", "user_id", userID, "platform", req.Platform)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pushToken)
 }
 
 // UpdateUser godoc
 // @Summary      Update user profile
-// @Description  Update name or status for the current user
+// @Description  Update name, status, or message privacy (everyone/contacts) for the current user
 // @Tags         users
 // @Accept       json
 // @Produce      json
@@ -76,6 +279,7 @@ func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 // @Success      200      {object}  models.User
 // @Failure      400      {object}  map[string]string "Invalid request body"
 // @Failure      401      {object}  map[string]string "Unauthorized"
+// @Failure      409      {object}  map[string]string "Profile changed since expected_updated_at"
 // @Router       /api/users/me [put]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
@@ -102,8 +306,21 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debug("UpdateUser: update request", "user_id", userID, "request", req)
 
+	if req.PrivacyMessages != nil &&
+		*req.PrivacyMessages != string(models.MessagePrivacyEveryone) &&
+		*req.PrivacyMessages != string(models.MessagePrivacyContacts) {
+		h.logger.Warn("UpdateUser: invalid privacy_messages value",
+			"user_id", userID, "privacy_messages", *req.PrivacyMessages)
+		http.Error(w, "privacy_messages must be 'everyone' or 'contacts'", http.StatusBadRequest)
+		return
+	}
+
 	// Update user
 	if err := h.store.UpdateUser(userID, &req); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Profile was modified by another request", http.StatusConflict)
+			return
+		}
 		h.logger.Error("UpdateUser: failed to update user", "error", err, "user_id", userID)
 		http.Error(w, "Failed to update user", http.StatusInternalServerError)
 		return
@@ -126,14 +343,17 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 // SearchUsers godoc
 // @Summary      Search for users
-// @Description  Find users by phone number or name
+// @Description  Find users by phone number or name. Excludes the caller and blocked users; the total match count is returned in the X-Total-Count header.
 // @Tags         users
 // @Produce      json
-// @Param        q      query     string  true  "Search query"
-// @Param        limit  query     int     false "Limit results (default 20)"
-// @Success      200    {array}   models.User
-// @Failure      400    {object}  map[string]string "Query required"
-// @Failure      401    {object}  map[string]string "Unauthorized"
+// @Param        q       query     string  true  "Search query"
+// @Param        limit   query     int     false "Limit results (default 20, max 50)"
+// @Param        offset  query     int     false "Number of results to skip (default 0)"
+// @Param        format  query     string  false "Set to 'array' for a bare array without paging metadata"
+// @Success      200     {object}  models.UserSearchResponse
+// @Header       200     {integer} X-Total-Count "Total number of matching users"
+// @Failure      400     {object}  map[string]string "Query required"
+// @Failure      401     {object}  map[string]string "Unauthorized"
 // @Router       /api/users/search [get]
 func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -158,17 +378,14 @@ func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Info("SearchUsers: searching users", "user_id", userID, "query", query)
 
-	limit := 20
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
+	limit := parseLimit(r, defaultSearchLimit, maxSearchLimit)
+	offset := parseOffset(r)
 
-	h.logger.Debug("SearchUsers: search parameters", "user_id", userID, "query", query, "limit", limit)
+	h.logger.Debug("SearchUsers: search parameters",
+		"user_id", userID, "query", query, "limit", limit, "offset", offset)
 
-	// Search users
-	users, err := h.store.SearchUsers(query, limit)
+	// Search users (self and blocked users are excluded by the store)
+	users, total, err := h.store.SearchUsers(userID, query, offset, limit)
 	if err != nil {
 		h.logger.Error("SearchUsers: failed to search users",
 			"error", err, "user_id", userID, "query", query)
@@ -176,30 +393,109 @@ func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Filter out current user
-	var filteredUsers []models.User
-	for _, user := range users {
-		if user.ID != userID {
-			filteredUsers = append(filteredUsers, user)
-		} else {
-			h.logger.Debug("SearchUsers: filtered out current user", "user_id", userID)
-		}
+	if users == nil {
+		users = []models.User{}
 	}
 
+	hasMore := offset+len(users) < total
+
 	h.logger.Debug("SearchUsers: search completed",
-		"user_id", userID, "query", query, "found", len(users), "filtered", len(filteredUsers))
+		"user_id", userID, "query", query, "found", len(users), "total", total)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if wantsBareArray(r) {
+		json.NewEncoder(w).Encode(users)
+		return
+	}
+	json.NewEncoder(w).Encode(models.UserSearchResponse{
+		Results:    users,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(users), hasMore),
+		HasMore:    hasMore,
+		Total:      total,
+	})
+}
+
+// LookupUsers godoc
+// @Summary      Find registered users by phone number
+// @Description  Contact discovery: returns the users registered with any of the given phone numbers. Numbers are normalized to 10 digits. Only matches are returned, so the response never reveals anything about non-matching numbers.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        lookup  body      models.UserLookupRequest  true  "Phone numbers (max 500)"
+// @Success      200     {array}   models.User
+// @Failure      400     {object}  map[string]string "Invalid request or too many phone numbers"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Router       /api/users/lookup [post]
+func (h *UserHandler) LookupUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("LookupUsers: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("LookupUsers: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UserLookupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("LookupUsers: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Phones) > maxLookupPhones {
+		h.logger.Warn("LookupUsers: too many phone numbers",
+			"user_id", userID, "phone_count", len(req.Phones), "max", maxLookupPhones)
+		http.Error(w, "Too many phone numbers (max 500)", http.StatusBadRequest)
+		return
+	}
+
+	// Normalize and de-duplicate, silently dropping numbers that can't be valid
+	seen := make(map[string]bool, len(req.Phones))
+	phones := make([]string, 0, len(req.Phones))
+	for _, raw := range req.Phones {
+		phone := normalizePhone(raw)
+		if phone == "" || seen[phone] {
+			continue
+		}
+		seen[phone] = true
+		phones = append(phones, phone)
+	}
+
+	h.logger.Info("LookupUsers: looking up phone numbers",
+		"user_id", userID, "requested", len(req.Phones), "normalized", len(phones))
+
+	users, err := h.store.GetUsersByPhones(userID, phones)
+	if err != nil {
+		h.logger.Error("LookupUsers: failed to look up users",
+			"error", err, "user_id", userID, "phone_count", len(phones))
+		http.Error(w, "Failed to look up users", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("LookupUsers: lookup completed",
+		"user_id", userID, "phone_count", len(phones), "found", len(users))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(filteredUsers)
+	json.NewEncoder(w).Encode(users)
 }
 
 // GetUser godoc
 // @Summary      Get user by ID
-// @Description  Retrieve profile details for a specific user ID
+// @Description  Retrieve profile details for a specific user ID. privacy_messages is only included for the requester's own profile.
 // @Tags         users
 // @Produce      json
 // @Param        id   path      string  true  "User ID"
+// @Param        If-None-Match  header  string  false  "ETag from a previous response"
 // @Success      200  {object}  models.User
+// @Success      304  "Not modified since the given ETag"
 // @Failure      400  {object}  map[string]string "ID required"
 // @Failure      404  {object}  map[string]string "User not found"
 // @Router       /api/users/{id} [get]
@@ -228,25 +524,144 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 
 	// Get user
 	user, err := h.store.GetUserByID(targetUserID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetUser: user not found",
+			"requester_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.logger.Error("GetUser: failed to get user",
 			"error", err, "requester_id", userID, "target_user_id", targetUserID)
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
 		return
 	}
 
-	if user == nil {
-		h.logger.Warn("GetUser: user not found",
+	// Who may message a user is their own setting; others only find out by trying
+	if targetUserID != userID {
+		user.PrivacyMessages = ""
+	}
+
+	// Presence comes from the same Redis entry the hub maintains
+	if online, err := h.store.IsUserOnline(targetUserID); err != nil {
+		h.logger.Warn("GetUser: failed to get presence",
+			"error", err, "requester_id", userID, "target_user_id", targetUserID)
+	} else {
+		user.IsOnline = online
+	}
+
+	h.logger.Debug("GetUser: retrieved user",
+		"requester_id", userID, "target_user_id", targetUserID, "name", user.Name)
+
+	if err := writeJSONWithETag(w, r, user); err != nil {
+		h.logger.Error("GetUser: failed to write response",
+			"error", err, "requester_id", userID, "target_user_id", targetUserID)
+	}
+}
+
+// GetSharedMedia godoc
+// @Summary      Get media shared with a user
+// @Description  List the photos, videos and other attachments the requester and another user have sent each other in the chats they are both in, mainly their direct chat, newest first. Messages the requester cleared from their history are left out.
+// @Tags         users
+// @Produce      json
+// @Param        id      path      string  true   "User ID"
+// @Param        type    query     string  false  "Only this media type (image, video, audio, document, sticker); all when omitted"
+// @Param        limit   query     int     false  "Limit results (default 20, max 100)"
+// @Param        offset  query     int     false  "Number of results to skip (default 0)"
+// @Success      200     {object}  models.MediaResponse
+// @Failure      400     {object}  map[string]string "Invalid media type or own user ID"
+// @Failure      401     {object}  map[string]string "Unauthorized"
+// @Failure      403     {object}  map[string]string "Users have blocked each other"
+// @Failure      404     {object}  map[string]string "User not found"
+// @Router       /api/users/{id}/shared-media [get]
+func (h *UserHandler) GetSharedMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetSharedMedia: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetUserID := r.PathValue("id")
+	if targetUserID == "" {
+		h.logger.Warn("GetSharedMedia: missing target user ID", "requester_id", userID)
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+	if targetUserID == userID {
+		h.logger.Warn("GetSharedMedia: requested media shared with self", "requester_id", userID)
+		http.Error(w, "Cannot get media shared with yourself", http.StatusBadRequest)
+		return
+	}
+
+	contentType := models.ContentType(r.URL.Query().Get("type"))
+	if contentType != "" && !contentType.IsMedia() {
+		h.logger.Warn("GetSharedMedia: invalid media type",
+			"requester_id", userID, "target_user_id", targetUserID, "type", contentType)
+		http.Error(w, "Invalid media type", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.store.GetUserByID(targetUserID); errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("GetSharedMedia: target user not found",
 			"requester_id", userID, "target_user_id", targetUserID)
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		h.logger.Error("GetSharedMedia: failed to get target user",
+			"error", err, "requester_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Failed to get shared media", http.StatusInternalServerError)
+		return
 	}
 
-	h.logger.Debug("GetUser: retrieved user",
-		"requester_id", userID, "target_user_id", targetUserID, "name", user.Name)
+	blocked, err := h.store.IsBlocked(userID, targetUserID)
+	if err != nil {
+		h.logger.Error("GetSharedMedia: failed to check block status",
+			"error", err, "requester_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Failed to get shared media", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		h.logger.Warn("GetSharedMedia: users have blocked each other",
+			"requester_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Cannot view media shared with this user", http.StatusForbidden)
+		return
+	}
+
+	limit := parseLimit(r, defaultListLimit, maxListLimit)
+	offset := parseOffset(r)
+
+	// Fetch one extra to tell whether another page follows
+	items, err := h.store.GetSharedMedia(userID, targetUserID, contentType, limit+1, offset)
+	if err != nil {
+		h.logger.Error("GetSharedMedia: failed to get shared media",
+			"error", err, "requester_id", userID, "target_user_id", targetUserID, "type", contentType)
+		http.Error(w, "Failed to get shared media", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	h.logger.Debug("GetSharedMedia: retrieved shared media",
+		"requester_id", userID, "target_user_id", targetUserID, "type", contentType,
+		"count", len(items), "has_more", hasMore)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(models.MediaResponse{
+		Items:      items,
+		Limit:      limit,
+		Offset:     offset,
+		NextOffset: nextOffset(offset, len(items), hasMore),
+		HasMore:    hasMore,
+	})
 }
 
 // GetContacts godoc
@@ -290,13 +705,15 @@ func (h *UserHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
 
 // AddContact godoc
 // @Summary      Add a contact
-// @Description  Add another user to the current user's contact list
+// @Description  Add another user to the current user's contact list. Set mutual to also add the current user to the target's contacts. Adding an existing contact is idempotent and reports created=false.
 // @Tags         contacts
 // @Accept       json
 // @Produce      json
-// @Param        contact  body      object  true  "Contact Request (user_id required)"
-// @Success      201      {object}  map[string]string "Contact added"
+// @Param        contact  body      models.ContactRequest  true  "Contact Request"
+// @Success      201      {object}  models.ContactResponse "Contact added"
+// @Success      200      {object}  models.ContactResponse "Contact already existed"
 // @Failure      400      {object}  map[string]string "Invalid request"
+// @Failure      403      {object}  map[string]string "Cannot add this user"
 // @Failure      404      {object}  map[string]string "User not found"
 // @Router       /api/contacts [post]
 func (h *UserHandler) AddContact(w http.ResponseWriter, r *http.Request) {
@@ -315,11 +732,7 @@ func (h *UserHandler) AddContact(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Info("AddContact: adding contact", "requester_id", userID)
 
-	var req struct {
-		UserID      string  `json:"user_id"`
-		DisplayName *string `json:"display_name,omitempty"`
-	}
-
+	var req models.ContactRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Warn("AddContact: invalid request body", "requester_id", userID, "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -332,22 +745,42 @@ func (h *UserHandler) AddContact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.UserID == userID {
+		h.logger.Warn("AddContact: cannot add self as contact", "requester_id", userID)
+		http.Error(w, "Cannot add yourself as a contact", http.StatusBadRequest)
+		return
+	}
+
 	h.logger.Debug("AddContact: contact request",
-		"requester_id", userID, "target_user_id", req.UserID, "display_name", req.DisplayName)
+		"requester_id", userID, "target_user_id", req.UserID,
+		"display_name", req.DisplayName, "mutual", req.Mutual)
 
 	// Check if target user exists
-	targetUser, err := h.store.GetUserByID(req.UserID)
+	_, err := h.store.GetUserByID(req.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("AddContact: target user not found",
+			"requester_id", userID, "target_user_id", req.UserID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		h.logger.Error("AddContact: failed to check target user",
 			"error", err, "requester_id", userID, "target_user_id", req.UserID)
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Failed to add contact", http.StatusInternalServerError)
 		return
 	}
 
-	if targetUser == nil {
-		h.logger.Warn("AddContact: target user not found",
+	blocked, err := h.store.IsBlocked(userID, req.UserID)
+	if err != nil {
+		h.logger.Error("AddContact: failed to check block status",
+			"error", err, "requester_id", userID, "target_user_id", req.UserID)
+		http.Error(w, "Failed to add contact", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		h.logger.Warn("AddContact: users have blocked each other",
 			"requester_id", userID, "target_user_id", req.UserID)
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, "Cannot add this user as a contact", http.StatusForbidden)
 		return
 	}
 
@@ -357,20 +790,199 @@ func (h *UserHandler) AddContact(w http.ResponseWriter, r *http.Request) {
 		displayName = *req.DisplayName
 	}
 
-	if err := h.store.AddContact(userID, req.UserID, displayName); err != nil {
+	created, err := h.store.AddContact(userID, req.UserID, displayName, req.Mutual)
+	if err != nil {
 		h.logger.Error("AddContact: failed to add contact",
 			"error", err, "requester_id", userID, "target_user_id", req.UserID)
 		http.Error(w, "Failed to add contact", http.StatusInternalServerError)
 		return
 	}
 
+	mutual, err := h.store.AreContacts(userID, req.UserID)
+	if err != nil {
+		h.logger.Warn("AddContact: failed to check mutual contact",
+			"error", err, "requester_id", userID, "target_user_id", req.UserID)
+	}
+
 	h.logger.Info("AddContact: contact added successfully",
-		"requester_id", userID, "target_user_id", req.UserID)
+		"requester_id", userID, "target_user_id", req.UserID, "created", created, "mutual", mutual)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Contact added successfully",
-	})
+	response := models.ContactResponse{
+		Message: "Contact already exists",
+		Created: created,
+		Mutual:  mutual,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		response.Message = "Contact added successfully"
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// ImportContacts godoc
+// @Summary      Add contacts in bulk
+// @Description  Add up to 500 contacts at once, each named by user_id or by phone number. Phone numbers are resolved the same way as /api/users/lookup. Every entry gets a result in request order: added, skipped (yourself, a duplicate, an existing contact or a blocked user) or not_found.
+// @Tags         contacts
+// @Accept       json
+// @Produce      json
+// @Param        contacts  body      models.BulkContactRequest  true  "Contacts to add (max 500)"
+// @Success      200       {object}  models.BulkContactResponse
+// @Failure      400       {object}  map[string]string "Invalid request or too many contacts"
+// @Failure      401       {object}  map[string]string "Unauthorized"
+// @Router       /api/contacts/bulk [post]
+func (h *UserHandler) ImportContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("ImportContacts: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("ImportContacts: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BulkContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("ImportContacts: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Contacts) > maxBulkContacts {
+		h.logger.Warn("ImportContacts: too many contacts",
+			"user_id", userID, "contact_count", len(req.Contacts), "max", maxBulkContacts)
+		http.Error(w, "Too many contacts (max 500)", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("ImportContacts: importing contacts", "user_id", userID, "requested", len(req.Contacts))
+
+	results := make([]models.BulkContactResult, len(req.Contacts))
+	var ids, phones []string
+	for i, entry := range req.Contacts {
+		results[i].Status = models.BulkContactNotFound
+		switch {
+		case entry.UserID != "":
+			results[i].UserID = entry.UserID
+			if _, err := uuid.Parse(entry.UserID); err == nil {
+				ids = append(ids, entry.UserID)
+			}
+		case entry.Phone != "":
+			results[i].Phone = normalizePhone(entry.Phone)
+			if results[i].Phone != "" {
+				phones = append(phones, results[i].Phone)
+			}
+		default:
+			results[i].Status = models.BulkContactSkipped
+			results[i].Reason = "Either user_id or phone is required"
+		}
+	}
+
+	// Resolve both kinds of entry to users; anything left unresolved stays not_found
+	known := make(map[string]bool)
+	users, err := h.store.GetUsersByIDs(ids)
+	if err != nil {
+		h.logger.Error("ImportContacts: failed to look up users by ID",
+			"error", err, "user_id", userID, "id_count", len(ids))
+		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+		return
+	}
+	for _, user := range users {
+		known[user.ID] = true
+	}
+
+	byPhone := make(map[string]string)
+	users, err = h.store.GetUsersByPhones(userID, phones)
+	if err != nil {
+		h.logger.Error("ImportContacts: failed to look up users by phone",
+			"error", err, "user_id", userID, "phone_count", len(phones))
+		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+		return
+	}
+	for _, user := range users {
+		byPhone[user.Phone] = user.ID
+	}
+	if len(phones) > 0 {
+		// The phone lookup leaves out the requester, so spot their own number here
+		requester, err := h.store.GetUserByID(userID)
+		if err != nil {
+			h.logger.Error("ImportContacts: failed to get requester",
+				"error", err, "user_id", userID)
+			http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+			return
+		}
+		byPhone[requester.Phone] = userID
+	}
+
+	seen := make(map[string]bool)
+	var contacts []models.Contact
+	for i, entry := range req.Contacts {
+		result := &results[i]
+		if result.Status != models.BulkContactNotFound {
+			continue
+		}
+		if result.Phone != "" {
+			result.UserID = byPhone[result.Phone]
+		}
+		if result.UserID == userID {
+			result.Status = models.BulkContactSkipped
+			result.Reason = "Cannot add yourself as a contact"
+			continue
+		}
+		if result.UserID == "" || (result.Phone == "" && !known[result.UserID]) {
+			continue
+		}
+		if seen[result.UserID] {
+			result.Status = models.BulkContactSkipped
+			result.Reason = "Duplicate entry"
+			continue
+		}
+		seen[result.UserID] = true
+
+		contact := models.Contact{UserID: userID, ContactID: result.UserID}
+		if entry.DisplayName != nil {
+			contact.DisplayName = *entry.DisplayName
+		}
+		contacts = append(contacts, contact)
+	}
+
+	added, err := h.store.AddContacts(userID, contacts)
+	if err != nil {
+		h.logger.Error("ImportContacts: failed to add contacts",
+			"error", err, "user_id", userID, "contact_count", len(contacts))
+		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+		return
+	}
+
+	wasAdded := make(map[string]bool, len(added))
+	for _, contactID := range added {
+		wasAdded[contactID] = true
+	}
+
+	response := models.BulkContactResponse{Results: results, Added: len(added)}
+	for i := range results {
+		result := &results[i]
+		if !seen[result.UserID] || result.Status != models.BulkContactNotFound {
+			continue
+		}
+		if wasAdded[result.UserID] {
+			result.Status = models.BulkContactAdded
+		} else {
+			result.Status = models.BulkContactSkipped
+			result.Reason = "Already a contact, or cannot be added"
+		}
+	}
+
+	h.logger.Info("ImportContacts: contacts imported",
+		"user_id", userID, "requested", len(req.Contacts), "added", response.Added)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // RemoveContact godoc
@@ -419,6 +1031,142 @@ func (h *UserHandler) RemoveContact(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// BlockUser godoc
+// @Summary      Block a user
+// @Description  Block another user. Neither of you can then message the other, start a direct chat, add the other as a contact or see the other in search or shared media. Blocking someone already blocked is a no-op.
+// @Tags         users
+// @Param        id   path  string  true  "User ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string "ID required or own user ID"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Router       /api/users/{id}/block [post]
+func (h *UserHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("BlockUser: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("BlockUser: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetUserID := r.PathValue("id")
+	if targetUserID == "" {
+		h.logger.Warn("BlockUser: missing target user ID", "user_id", userID)
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+	if targetUserID == userID {
+		h.logger.Warn("BlockUser: attempted to block self", "user_id", userID)
+		http.Error(w, "Cannot block yourself", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.store.GetUserByID(targetUserID); errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("BlockUser: target user not found", "user_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.logger.Error("BlockUser: failed to get target user",
+			"error", err, "user_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Failed to block user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.BlockUser(userID, targetUserID); err != nil {
+		h.logger.Error("BlockUser: failed to block user",
+			"error", err, "user_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Failed to block user", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("BlockUser: user blocked", "user_id", userID, "target_user_id", targetUserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockUser godoc
+// @Summary      Unblock a user
+// @Description  Lift a block you placed on another user. A block they placed on you stays. Unblocking someone who isn't blocked is a no-op.
+// @Tags         users
+// @Param        id   path  string  true  "User ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]string "ID required"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /api/users/{id}/block [delete]
+func (h *UserHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.logger.Warn("UnblockUser: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UnblockUser: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	targetUserID := r.PathValue("id")
+	if targetUserID == "" {
+		h.logger.Warn("UnblockUser: missing target user ID", "user_id", userID)
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.UnblockUser(userID, targetUserID); err != nil {
+		h.logger.Error("UnblockUser: failed to unblock user",
+			"error", err, "user_id", userID, "target_user_id", targetUserID)
+		http.Error(w, "Failed to unblock user", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("UnblockUser: user unblocked", "user_id", userID, "target_user_id", targetUserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBlockedUsers godoc
+// @Summary      List blocked users
+// @Description  Retrieve the users the current user has blocked, most recently blocked first
+// @Tags         users
+// @Produce      json
+// @Success      200  {array}   models.User
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /api/users/me/blocked [get]
+func (h *UserHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Warn("GetBlockedUsers: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("GetBlockedUsers: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := h.store.GetBlockedUsers(userID)
+	if err != nil {
+		h.logger.Error("GetBlockedUsers: failed to get blocked users", "error", err, "user_id", userID)
+		http.Error(w, "Failed to get blocked users", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Debug("GetBlockedUsers: retrieved blocked users", "user_id", userID, "count", len(users))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
 // GetOnlineUsers godoc
 // @Summary      Get online users
 // @Description  Retrieve a list of users currently marked as online
@@ -465,6 +1213,11 @@ func (h *UserHandler) GetOnlineUsers(w http.ResponseWriter, r *http.Request) {
 		users[i].IsOnline = true
 	}
 
+	if err := applyContactNames(h.store, userID, users); err != nil {
+		h.logger.Warn("GetOnlineUsers: failed to apply contact names",
+			"error", err, "requester_id", userID)
+	}
+
 	h.logger.Debug("GetOnlineUsers: retrieved online users",
 		"requester_id", userID, "online_count", len(users))
 
@@ -496,17 +1249,16 @@ func (h *UserHandler) GetUserSessions(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debug("GetUserSessions: fetching user sessions", "user_id", userID)
 
-	// Get sessions (simplified - using in-memory store)
-	// In production, implement in store
-	sessions := []models.UserSession{
-		{
-			UserID:     userID,
-			SessionID:  "current",
-			DeviceInfo: "Web Browser",
-			LastActive: time.Now(),
-			CreatedAt:  time.Now(),
-			IsActive:   true,
-		},
+	sessions, err := h.store.GetUserSessions(userID)
+	if err != nil {
+		h.logger.Error("GetUserSessions: failed to get sessions", "error", err, "user_id", userID)
+		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
+		return
+	}
+
+	currentSessionID := auth.GetSessionID(r.Context())
+	for i := range sessions {
+		sessions[i].IsCurrent = sessions[i].SessionID == currentSessionID
 	}
 
 	h.logger.Debug("GetUserSessions: returning sessions", "user_id", userID, "session_count", len(sessions))
@@ -514,3 +1266,106 @@ func (h *UserHandler) GetUserSessions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sessions)
 }
+
+// UpdateSession godoc
+// @Summary      Rename a session
+// @Description  Set or clear a custom label for one of the current user's sessions, shown in place of the detected device name.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                       true  "Session ID"
+// @Param        session  body      models.SessionUpdateRequest  true  "Session label"
+// @Success      200      {object}  models.UserSession
+// @Failure      400      {object}  map[string]string "Invalid request body or label too long"
+// @Failure      401      {object}  map[string]string "Unauthorized"
+// @Failure      404      {object}  map[string]string "Session not found"
+// @Router       /api/users/sessions/{id} [patch]
+func (h *UserHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		h.logger.Warn("UpdateSession: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("UpdateSession: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	if sessionID == "" {
+		h.logger.Warn("UpdateSession: missing session ID", "user_id", userID)
+		http.Error(w, "Session ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SessionUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("UpdateSession: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Label != nil {
+		label := strings.TrimSpace(*req.Label)
+		if utf8.RuneCountInString(label) > maxSessionLabelLength {
+			h.logger.Warn("UpdateSession: label too long", "user_id", userID, "length", utf8.RuneCountInString(label))
+			http.Error(w, fmt.Sprintf("Label must be at most %d characters", maxSessionLabelLength), http.StatusBadRequest)
+			return
+		}
+		req.Label = &label
+	}
+
+	// Session IDs are UUIDs; anything else can't match and would only fail the query
+	if _, err := uuid.Parse(sessionID); err != nil {
+		h.logger.Warn("UpdateSession: invalid session ID", "user_id", userID, "session_id", sessionID)
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	session, err := h.store.UpdateSessionLabel(userID, sessionID, req.Label)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("UpdateSession: session not found", "user_id", userID, "session_id", sessionID)
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("UpdateSession: failed to update session",
+			"error", err, "user_id", userID, "session_id", sessionID)
+		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+		return
+	}
+	session.IsCurrent = session.SessionID == auth.GetSessionID(r.Context())
+
+	h.logger.Info("UpdateSession: session updated", "user_id", userID, "session_id", sessionID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// normalizePhone reduces a phone number to the 10-digit form stored on users,
+// dropping formatting, a +91/91 country code, or a leading trunk 0. It returns ""
+// if the result is not 10 digits.
+func normalizePhone(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+
+	switch {
+	case len(digits) == 12 && strings.HasPrefix(digits, "91"):
+		digits = digits[2:]
+	case len(digits) == 11 && strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+
+	if len(digits) != 10 {
+		return ""
+	}
+	return digits
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+func TestGetUserHidesPrivacySettingFromOthers(t *testing.T) {
+	s := newTestStore(t)
+	h := NewUserHandler(s, testLogger)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+
+	contacts := string(models.MessagePrivacyContacts)
+	if err := s.UpdateUser(bob.ID, &models.UserUpdateRequest{PrivacyMessages: &contacts}); err != nil {
+		t.Fatalf("update privacy: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name, requester, want string
+	}{
+		{"own profile", bob.ID, contacts},
+		{"someone else's profile", alice.ID, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRequest(http.MethodGet, "/api/users/"+bob.ID, tt.requester, nil)
+			r.SetPathValue("id", bob.ID)
+			w := httptest.NewRecorder()
+			h.GetUser(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("GetUser = %d, want %d", w.Code, http.StatusOK)
+			}
+			var user models.User
+			if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+				t.Fatalf("decode user: %v", err)
+			}
+			if user.PrivacyMessages != tt.want {
+				t.Errorf("privacy_messages = %q, want %q", user.PrivacyMessages, tt.want)
+			}
+		})
+	}
+}
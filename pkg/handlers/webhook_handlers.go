@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/webhook"
+)
+
+// Webhook secrets shorter than this are rejected; generated ones are twice as long
+const minWebhookSecretLength = 16
+
+type WebhookHandler struct {
+	store  *store.Store
+	logger *slog.Logger
+}
+
+func NewWebhookHandler(store *store.Store, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{store: store, logger: logger}
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Subscribe an HTTP endpoint to message events (message.sent, message.delivered, message.read) in every chat the user belongs to. Deliveries are signed with HMAC-SHA256 using the webhook's secret, sent in X-ChitChat-Signature, and retried with backoff until a 2xx response. The secret is generated when omitted and is only returned here.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook  body      models.WebhookRequest  true  "Endpoint URL, events and optional secret"
+// @Success      201      {object}  models.Webhook
+// @Failure      400      {object}  map[string]string "Invalid URL, non-public or unresolvable host, events or secret"
+// @Failure      401      {object}  map[string]string "Unauthorized"
+// @Router       /api/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Warn("CreateWebhook: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("CreateWebhook: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("CreateWebhook: invalid request body", "user_id", userID, "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		h.logger.Warn("CreateWebhook: invalid URL", "user_id", userID, "url", req.URL)
+		http.Error(w, "URL must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	if err := webhook.CheckHost(r.Context(), u.Hostname()); err != nil {
+		h.logger.Warn("CreateWebhook: URL host not allowed", "user_id", userID, "url", req.URL, "error", err)
+		if errors.Is(err, webhook.ErrForbiddenAddress) {
+			http.Error(w, "URL must point to a public host", http.StatusBadRequest)
+		} else {
+			http.Error(w, "URL host could not be resolved", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if len(req.Events) == 0 {
+		h.logger.Warn("CreateWebhook: no events", "user_id", userID)
+		http.Error(w, "At least one event is required", http.StatusBadRequest)
+		return
+	}
+	seen := make(map[models.WebhookEventType]bool)
+	var events []models.WebhookEventType
+	for _, event := range req.Events {
+		if !event.Valid() {
+			h.logger.Warn("CreateWebhook: unknown event", "user_id", userID, "event", event)
+			http.Error(w, "Unknown event: "+string(event), http.StatusBadRequest)
+			return
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, minWebhookSecretLength)
+		if _, err := rand.Read(buf); err != nil {
+			h.logger.Error("CreateWebhook: failed to generate secret", "error", err, "user_id", userID)
+			http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	} else if len(secret) < minWebhookSecretLength {
+		h.logger.Warn("CreateWebhook: secret too short", "user_id", userID)
+		http.Error(w, "Secret must be at least 16 characters", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.store.CreateWebhook(userID, req.URL, secret, events)
+	if err != nil {
+		h.logger.Error("CreateWebhook: failed to create webhook", "error", err, "user_id", userID)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("CreateWebhook: webhook created",
+		"user_id", userID, "webhook_id", webhook.ID, "events", events)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Stop delivering events to one of the user's webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook not found"
+// @Router       /api/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.logger.Warn("DeleteWebhook: method not allowed", "method", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := auth.GetUserID(r.Context())
+	if userID == "" {
+		h.logger.Warn("DeleteWebhook: unauthorized request", "method", r.Method, "path", r.URL.Path)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	webhookID := r.PathValue("id")
+	if webhookID == "" {
+		h.logger.Warn("DeleteWebhook: missing webhook ID", "user_id", userID)
+		http.Error(w, "Webhook ID required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.store.DeleteWebhook(userID, webhookID)
+	if err != nil {
+		h.logger.Error("DeleteWebhook: failed to delete webhook",
+			"error", err, "user_id", userID, "webhook_id", webhookID)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		h.logger.Warn("DeleteWebhook: webhook not found", "user_id", userID, "webhook_id", webhookID)
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("DeleteWebhook: webhook deleted", "user_id", userID, "webhook_id", webhookID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Webhook deleted",
+	})
+}
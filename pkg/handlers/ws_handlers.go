@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/msniranjan18/common/jwt"
 
+	"github.com/msniranjan18/chit-chat/config"
 	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/store"
 )
 
 var upgrader = websocket.Upgrader{
@@ -23,20 +27,24 @@ var upgrader = websocket.Upgrader{
 
 type WSHandler struct {
 	hub    *hub.Hub
+	cfg    config.WebSocketConfig
 	logger *slog.Logger
 }
 
-func NewWSHandler(hub *hub.Hub, logger *slog.Logger) *WSHandler {
-	return &WSHandler{hub: hub, logger: logger}
+func NewWSHandler(hub *hub.Hub, cfg config.WebSocketConfig, logger *slog.Logger) *WSHandler {
+	return &WSHandler{hub: hub, cfg: cfg, logger: logger}
 }
 
 // HandleWS godoc
 // @Summary      Establish WebSocket connection
 // @Description  Upgrades the HTTP connection to a WebSocket for real-time messaging. Requires a valid JWT token as a query parameter.
 // @Tags         websocket
-// @Param        token  query  string  true  "Valid JWT Token"
+// @Param        token     query  string  true   "Valid JWT Token"
+// @Param        versions  query  string  false  "Comma-separated protocol versions the client supports (default 1)"
 // @Success      101    {string} string "Switching Protocols"
-// @Failure      401    {object} map[string]string "Invalid or missing token"
+// @Failure      400    {object} map[string]string "No supported protocol version"
+// @Failure      401    {object} map[string]string "Invalid or missing token, or the token's session has ended"
+// @Failure      500    {object} map[string]string "Failed to verify session"
 // @Router       /ws [get]
 func (h *WSHandler) HandleWS(w http.ResponseWriter, r *http.Request) {
 	// Extract token from query parameters
@@ -57,8 +65,32 @@ func (h *WSHandler) HandleWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The token outlives its session, so refuse it once the session has ended
+	session, err := h.hub.Storage.GetUserSession(claims.SessionID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !session.IsActive) {
+		h.logger.Warn("HandleWS: session has ended",
+			"user_id", claims.UserID, "session_id", claims.SessionID)
+		http.Error(w, "Session has ended", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		h.logger.Error("HandleWS: failed to verify session",
+			"error", err, "session_id", claims.SessionID)
+		http.Error(w, "Failed to verify session", http.StatusInternalServerError)
+		return
+	}
+
+	versions := r.URL.Query().Get("versions")
+	version, ok := hub.NegotiateVersion(versions)
+	if !ok {
+		h.logger.Warn("HandleWS: no supported protocol version",
+			"user_id", claims.UserID, "versions", versions)
+		http.Error(w, "No supported protocol version", http.StatusBadRequest)
+		return
+	}
+
 	h.logger.Info("HandleWS: upgrading to WebSocket",
-		"user_id", claims.UserID, "session_id", claims.SessionID)
+		"user_id", claims.UserID, "session_id", claims.SessionID, "version", version)
 
 	// Upgrade to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -74,8 +106,10 @@ func (h *WSHandler) HandleWS(w http.ResponseWriter, r *http.Request) {
 		UserID:      claims.UserID,
 		SessionID:   claims.SessionID,
 		Conn:        conn,
-		Send:        make(chan []byte, 256),
+		Send:        make(chan []byte, h.cfg.SendBufferSize),
 		ActiveChats: make(map[string]bool),
+		Version:     version,
+		Announced:   strings.TrimSpace(versions) != "",
 	}
 
 	h.logger.Debug("HandleWS: registering client",
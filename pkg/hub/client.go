@@ -2,6 +2,12 @@ package hub
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,6 +20,52 @@ type Client struct {
 	Conn        *websocket.Conn
 	Send        chan []byte
 	ActiveChats map[string]bool
+	Version     int  // Negotiated protocol version, fixed for the connection
+	Announced   bool // Whether the client listed its versions on connect; only those get hello
+
+	// Set once the hub gives up on a client that stopped draining Send
+	dropped atomic.Bool
+
+	// Whether registering counted the client's presence connection, so
+	// unregistering knows to release it
+	presenceCounted bool
+
+	// Payloads that found Send full, in order, waiting for a drain goroutine to
+	// deliver them so senders never wait on a slow client while holding the hub
+	// lock. closing is closed on unregister to stop the drain before Send is.
+	overflowMu sync.Mutex
+	overflow   [][]byte
+	draining   sync.WaitGroup
+	closing    chan struct{}
+
+	// When the outstanding ping was sent and the last measured ping round trip,
+	// both in nanoseconds; pingSentAt is zero while no ping is outstanding
+	pingSentAt atomic.Int64
+	lastRTT    atomic.Int64
+}
+
+// LastRTT returns the round trip of the client's most recent ping, or zero if no
+// pong has been received yet
+func (c *Client) LastRTT() time.Duration {
+	return time.Duration(c.lastRTT.Load())
+}
+
+// recordPong measures the round trip of the outstanding ping, if any
+func (c *Client) recordPong() {
+	sentAt := c.pingSentAt.Swap(0)
+	if sentAt == 0 {
+		return
+	}
+
+	rtt := time.Since(time.Unix(0, sentAt))
+	c.lastRTT.Store(int64(rtt))
+	if rtt > highLatencyThreshold {
+		c.Hub.logger.Warn("High WebSocket latency",
+			"user_id", c.UserID,
+			"session_id", c.SessionID,
+			"rtt", rtt,
+			"threshold", highLatencyThreshold)
+	}
 }
 
 func (c *Client) ReadPump() {
@@ -22,17 +74,39 @@ func (c *Client) ReadPump() {
 		c.Conn.Close()
 	}()
 
-	c.Conn.SetReadLimit(maxMessageSize)
+	// Chat messages are processed off the hub loop, one at a time so they are
+	// saved in the order the client sent them
+	chatMessages := make(chan WsMessage, chatMessageQueueSize)
+	go c.processChatMessages(chatMessages)
+	defer close(chatMessages)
+
+	// Oversized messages are dropped in readMessage, which tells the client why,
+	// rather than by the read limit on the connection, which closes it. The read
+	// limit stays as a hard bound well above the size limit: unsolicited pongs
+	// reset the read deadline, so without it a client could stream one endless
+	// message.
+	limit := c.Hub.cfg.MaxMessageSize
+	if limit <= 0 {
+		limit = maxMessageSize
+	}
+	hardLimit := hardReadLimit(limit)
+	c.Conn.SetReadLimit(hardLimit)
 	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.recordPong()
 		return nil
 	})
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		message, tooLarge, err := c.readMessage(limit)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				c.Hub.logger.Warn("WebSocket message over the hard size limit, closing connection",
+					"user_id", c.UserID,
+					"session_id", c.SessionID,
+					"limit", hardLimit)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.Hub.logger.Warn("WebSocket read error",
 					"error", err,
 					"user_id", c.UserID,
@@ -44,6 +118,23 @@ func (c *Client) ReadPump() {
 			}
 			break
 		}
+		if tooLarge {
+			c.Hub.logger.Warn("WebSocket message too large, dropped",
+				"user_id", c.UserID,
+				"session_id", c.SessionID,
+				"limit", limit)
+			message := fmt.Sprintf("Message exceeds the %d byte limit", limit)
+			if c.Version < ProtocolV2 {
+				// v1 clients were disconnected for oversized messages before they
+				// could stay connected, so close with the error code as the reason
+				c.Conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseMessageTooBig, ErrCodeTooLarge+": "+message),
+					time.Now().Add(writeWait))
+				break
+			}
+			c.Hub.sendError(WsMessage{origin: c}, ErrCodeTooLarge, message)
+			continue
+		}
 
 		var wsMsg WsMessage
 		if err := json.Unmarshal(message, &wsMsg); err != nil {
@@ -51,11 +142,13 @@ func (c *Client) ReadPump() {
 				"error", err,
 				"user_id", c.UserID,
 				"session_id", c.SessionID)
+			c.Hub.sendError(WsMessage{origin: c}, ErrCodeInvalidMessage, "Malformed message")
 			continue
 		}
 
 		// Set sender from client context
 		wsMsg.Sender = c.UserID
+		wsMsg.origin = c
 
 		c.Hub.logger.Debug("Received WebSocket message",
 			"user_id", c.UserID,
@@ -64,10 +157,49 @@ func (c *Client) ReadPump() {
 			"room_id", wsMsg.RoomID)
 
 		// Handle message
+		if MessageType(wsMsg.Type) == MessageTypeMessage {
+			chatMessages <- wsMsg
+			continue
+		}
 		c.Hub.Broadcast <- wsMsg
 	}
 }
 
+// processChatMessages handles the client's chat messages in order until ReadPump
+// closes the queue
+func (c *Client) processChatMessages(queue <-chan WsMessage) {
+	for msg := range queue {
+		c.Hub.handleChatMessage(msg)
+	}
+}
+
+// hardReadLimit returns the read limit that closes a connection, given the size
+// limit over which messages are only dropped
+func hardReadLimit(limit int64) int64 {
+	return min(limit, math.MaxInt64/oversizeDrainFactor) * oversizeDrainFactor
+}
+
+// readMessage reads the next message from the connection. A message over limit is
+// discarded as it arrives instead of being buffered, and reported with tooLarge;
+// the connection stays usable. Discarding stops at the connection's read limit,
+// which fails the read and closes the connection.
+func (c *Client) readMessage(limit int64) (message []byte, tooLarge bool, err error) {
+	_, reader, err := c.Conn.NextReader()
+	if err != nil {
+		return nil, false, err
+	}
+
+	message, err = io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(message)) > limit {
+		_, err = io.Copy(io.Discard, reader)
+		return nil, true, err
+	}
+	return message, false, nil
+}
+
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -139,6 +271,7 @@ func (c *Client) WritePump() {
 
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.pingSentAt.Store(time.Now().UnixNano())
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				c.Hub.logger.Debug("Failed to send ping, connection may be dead",
 					"error", err,
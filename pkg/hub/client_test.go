@@ -0,0 +1,114 @@
+package hub
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/msniranjan18/chit-chat/config"
+)
+
+func TestHardReadLimit(t *testing.T) {
+	for _, tt := range []struct {
+		limit, want int64
+	}{
+		{1024, 1024 * oversizeDrainFactor},
+		{math.MaxInt64, math.MaxInt64 / oversizeDrainFactor * oversizeDrainFactor},
+	} {
+		if got := hardReadLimit(tt.limit); got != tt.want {
+			t.Errorf("hardReadLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestReadMessageSizeLimits(t *testing.T) {
+	const limit = 16
+
+	messages := []string{
+		"small",
+		strings.Repeat("x", limit+1),
+		"after oversized",
+		strings.Repeat("x", int(hardReadLimit(limit))+1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, message := range messages {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
+		}
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadLimit(hardReadLimit(limit))
+	client := &Client{Conn: conn}
+
+	if message, tooLarge, err := client.readMessage(limit); err != nil || tooLarge || string(message) != "small" {
+		t.Fatalf("readMessage = %q, %v, %v; want the small message", message, tooLarge, err)
+	}
+	if _, tooLarge, err := client.readMessage(limit); err != nil || !tooLarge {
+		t.Fatalf("readMessage of an oversized message = tooLarge %v, %v; want true, nil", tooLarge, err)
+	}
+	// The connection stays usable after an oversized message
+	if message, tooLarge, err := client.readMessage(limit); err != nil || tooLarge || string(message) != "after oversized" {
+		t.Fatalf("readMessage after oversized = %q, %v, %v; want the next message", message, tooLarge, err)
+	}
+	if _, _, err := client.readMessage(limit); !errors.Is(err, websocket.ErrReadLimit) {
+		t.Fatalf("readMessage over the hard limit = %v, want ErrReadLimit", err)
+	}
+}
+
+func TestReadPumpClosesV1ClientOnOversizedMessage(t *testing.T) {
+	const limit = 16
+
+	h := NewHub(nil, nil, nil, nil, config.WebSocketConfig{MaxMessageSize: limit}, config.ChatConfig{},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{Hub: h, Conn: conn, Version: ProtocolV1}
+		go func() { <-h.Unregister }()
+		client.ReadPump()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", limit+1))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseMessageTooBig {
+		t.Fatalf("read after oversized message = %v, want close %d", err, websocket.CloseMessageTooBig)
+	}
+	if !strings.HasPrefix(closeErr.Text, ErrCodeTooLarge) {
+		t.Errorf("close reason = %q, want it to start with %q", closeErr.Text, ErrCodeTooLarge)
+	}
+}
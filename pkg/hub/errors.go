@@ -0,0 +1,80 @@
+package hub
+
+import "github.com/msniranjan18/chit-chat/pkg/models"
+
+// WebSocket error codes sent to clients in MessageTypeError payloads
+const (
+	ErrCodeInvalidMessage = "invalid_message"
+	ErrCodeInvalidPayload = "invalid_payload"
+	ErrCodeUnknownType    = "unknown_type"
+	ErrCodeNotMember      = "not_member"
+	ErrCodeForbidden      = "forbidden"
+	ErrCodeSaveFailed     = "save_failed"
+	ErrCodeRateLimited    = "rate_limited"
+	ErrCodeRejected       = "content_rejected"  // Refused by the content moderation policy
+	ErrCodeTooLarge       = "message_too_large" // Over WS_MAX_MESSAGE_SIZE; the message was dropped
+	ErrCodeInternal       = "internal_error"
+)
+
+// WsError is the payload of an error message sent back to the originating client.
+// Ref echoes the client_msg_id of the failed message so the client can correlate
+// and retry it.
+type WsError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Ref     string `json:"ref,omitempty"`
+}
+
+// sendError reports a failed request back to the client that sent msg. Messages
+// that arrived via Redis have no local origin and are only logged by the caller.
+func (h *Hub) sendError(msg WsMessage, code, message string) {
+	h.replyToOrigin(msg, WsMessage{
+		Type:        string(MessageTypeError),
+		RoomID:      msg.RoomID,
+		ClientMsgID: msg.ClientMsgID,
+		Payload: marshalPayload(WsError{
+			Code:    code,
+			Message: message,
+			Ref:     msg.ClientMsgID,
+		}),
+	})
+}
+
+// sendFailedStatus tells the sender that a message was not persisted, so the client
+// can mark its pending copy (matched by client_msg_id) as failed
+func (h *Hub) sendFailedStatus(msg WsMessage, chatID string) {
+	h.replyToOrigin(msg, WsMessage{
+		Type:        string(MessageTypeStatus),
+		RoomID:      chatID,
+		ClientMsgID: msg.ClientMsgID,
+		Payload: marshalPayload(models.MessageStatusUpdate{
+			Status: string(models.MessageStatusFailed),
+			ChatID: chatID,
+		}),
+	})
+}
+
+// replyToOrigin delivers reply to the local client that sent msg, if it is still connected
+func (h *Hub) replyToOrigin(msg, reply WsMessage) {
+	client := msg.origin
+	if client == nil {
+		return
+	}
+
+	payload := marshalMessage(reply)
+
+	// The client may have disconnected while the request was processed
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.Clients[client.UserID][client] || !client.Supports(reply.Type) {
+		return
+	}
+
+	if h.send(client, payload) {
+		h.logger.Debug("Reply sent to client",
+			"user_id", client.UserID,
+			"session_id", client.SessionID,
+			"type", reply.Type,
+			"ref", msg.ClientMsgID)
+	}
+}
@@ -1,26 +1,78 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/msniranjan18/chit-chat/config"
 	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/moderation"
+	"github.com/msniranjan18/chit-chat/pkg/push"
 	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/webhook"
 )
 
 const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 10 * 1024 * 1024 // 10MB
+	maxMessageSize = 10 * 1024 * 1024 // 10MB, used when WS_MAX_MESSAGE_SIZE is not positive
+
+	// Oversized messages up to this many times the size limit are drained and
+	// answered with an error; anything larger closes the connection
+	oversizeDrainFactor = 4
+
+	// Clients whose ping round trip takes longer than this are logged as lagging
+	highLatencyThreshold = 2 * time.Second
+
+	// Presence keys of connected users are refreshed on the same cadence as pings,
+	// well inside the 5 minute presence TTL, so idle-but-connected users stay online
+	presenceRefreshInterval = pingPeriod
+
+	// Typing indicators are cleared server-side if not refreshed within this window
+	typingTimeout = 6 * time.Second
+
+	// Saving a message is retried with doubling delays before it is dead-lettered.
+	// Retries run off the hub loop, but the sender waits on them, so the total delay
+	// is kept short.
+	saveMessageAttempts   = 3
+	saveMessageRetryDelay = 50 * time.Millisecond
+
+	// A resume request covers at most maxResumeChats chats and replays at most
+	// resumeReplayLimit messages per chat; clients page through anything older
+	maxResumeChats    = 100
+	resumeReplayLimit = 200
+
+	// How many chat messages a client can have waiting to be processed before
+	// reading from it pauses
+	chatMessageQueueSize = 16
 )
 
 type Hub struct {
 	Storage *store.Store
+	// Webhooks receives message events; shared with the HTTP handlers
+	Webhooks *webhook.Dispatcher
+	// Moderator vets messages before they are saved; shared with the HTTP handlers
+	Moderator moderation.MessageModerator
+	// Push notifies members who aren't connected about new messages
+	Push    *push.Dispatcher
+	cfg     config.WebSocketConfig
+	chatCfg config.ChatConfig
 	logger  *slog.Logger
 
+	// Unique ID of this instance, stamped on messages it publishes to Redis
+	NodeID string
+
 	// Registered clients by userID (multiple devices per user)
 	Clients map[string]map[*Client]bool
 
@@ -35,13 +87,30 @@ type Hub struct {
 	Unregister chan *Client
 
 	mu sync.RWMutex
+
+	// Number of clients disconnected because their send buffer stayed full
+	slowClientDrops atomic.Int64
+
+	// Pending typing auto-clear timers and last broadcast typing event, by chatID:userID
+	typingTimers map[string]*time.Timer
+	typingSentAt map[string]time.Time
+	typingMu     sync.Mutex
+
+	// Opens the Redis sync subscription; nil subscribes through Storage.RDB
+	subscribe func(ctx context.Context) syncSubscription
 }
 
 type WsMessage struct {
-	Type    string          `json:"type"`
-	Payload json.RawMessage `json:"payload"`
-	RoomID  string          `json:"room_id"`
-	Sender  string          `json:"sender"`
+	Version     int             `json:"v,omitempty"` // Protocol version of the envelope; absent means v1
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	RoomID      string          `json:"room_id"`
+	Sender      string          `json:"sender"`
+	ClientMsgID string          `json:"client_msg_id,omitempty"` // Client-chosen idempotency key, echoed in errors
+	NodeID      string          `json:"node_id,omitempty"`       // Instance that published the message to Redis
+
+	// Local client the message was read from; nil for messages received via Redis
+	origin *Client
 }
 
 type MessageType string
@@ -50,25 +119,49 @@ const (
 	MessageTypeMessage    MessageType = "message"
 	MessageTypeTyping     MessageType = "typing"
 	MessageTypePresence   MessageType = "presence"
+	MessageTypeSnapshot   MessageType = "presence_snapshot"
+	MessageTypeHello      MessageType = "hello"
 	MessageTypeStatus     MessageType = "status_update"
 	MessageTypeChatUpdate MessageType = "chat_update"
 	MessageTypeError      MessageType = "error"
+	MessageTypeMention    MessageType = "mention"
+	MessageTypeResume     MessageType = "resume"  // Client asks for messages missed while disconnected
+	MessageTypeResumed    MessageType = "resumed" // A chat's missed messages have been replayed
+
+	// Published between instances only: a session was logged out, evicted or
+	// revoked, so its connections must close
+	MessageTypeSessionEnded MessageType = "session_ended"
 )
 
-func NewHub(s *store.Store, logger *slog.Logger) *Hub {
+func NewHub(s *store.Store, webhooks *webhook.Dispatcher, pushes *push.Dispatcher, moderator moderation.MessageModerator, cfg config.WebSocketConfig, chatCfg config.ChatConfig, logger *slog.Logger) *Hub {
+	nodeID := uuid.New().String()
 	return &Hub{
 		Storage:    s,
-		logger:     logger,
+		Webhooks:   webhooks,
+		Moderator:  moderator,
+		Push:       pushes,
+		cfg:        cfg,
+		chatCfg:    chatCfg,
+		logger:     logger.With("node_id", nodeID),
+		NodeID:     nodeID,
 		Clients:    make(map[string]map[*Client]bool),
 		ChatRooms:  make(map[string]map[*Client]bool),
 		Broadcast:  make(chan WsMessage),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
+
+		typingTimers: make(map[string]*time.Timer),
+		typingSentAt: make(map[string]time.Time),
 	}
 }
 
 func (h *Hub) Run() {
 	h.logger.Info("WebSocket hub started")
+
+	presenceTicker := time.NewTicker(presenceRefreshInterval)
+	defer presenceTicker.Stop()
+	h.heartbeat()
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -79,6 +172,10 @@ func (h *Hub) Run() {
 
 		case message := <-h.Broadcast:
 			h.handleBroadcast(message)
+
+		case <-presenceTicker.C:
+			h.refreshPresence()
+			h.heartbeat()
 		}
 	}
 }
@@ -87,12 +184,23 @@ func (h *Hub) handleRegister(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	client.closing = make(chan struct{})
+
 	// Register client under user
 	if h.Clients[client.UserID] == nil {
 		h.Clients[client.UserID] = make(map[*Client]bool)
 	}
 	h.Clients[client.UserID][client] = true
 
+	// Confirm the negotiated protocol version before anything else is delivered.
+	// Clients that didn't announce versions predate hello and wouldn't expect it.
+	if client.Announced {
+		h.send(client, marshalMessage(WsMessage{
+			Type:    string(MessageTypeHello),
+			Payload: marshalPayload(map[string]int{"version": client.Version}),
+		}))
+	}
+
 	// Join all active chats for this user
 	chats, err := h.Storage.GetUserChats(client.UserID)
 	if err == nil {
@@ -115,10 +223,20 @@ func (h *Hub) handleRegister(client *Client) {
 
 	// Update user status
 	h.Storage.UpdateUserLastSeen(client.UserID, time.Now())
+	if err := h.Storage.SetUserOnline(client.UserID, h.NodeID); err != nil {
+		h.logger.Warn("Failed to set user online",
+			"user_id", client.UserID,
+			"error", err)
+	} else {
+		client.presenceCounted = true
+	}
 
 	// Notify contacts that user is online
 	go h.notifyPresence(client.UserID, "online")
 
+	// Tell the new client who is already online
+	go h.sendPresenceSnapshot(client)
+
 	h.logger.Info("Client registered",
 		"user_id", client.UserID,
 		"session_id", client.SessionID,
@@ -134,10 +252,6 @@ func (h *Hub) handleUnregister(client *Client) {
 		delete(userClients, client)
 		if len(userClients) == 0 {
 			delete(h.Clients, client.UserID)
-			// User went offline
-			go h.notifyPresence(client.UserID, "offline")
-			h.logger.Debug("All clients disconnected, user offline",
-				"user_id", client.UserID)
 		} else {
 			h.logger.Debug("Client removed, user still has active sessions",
 				"user_id", client.UserID,
@@ -145,6 +259,23 @@ func (h *Hub) handleUnregister(client *Client) {
 		}
 	}
 
+	// Every client whose connection was counted releases it; the user only goes
+	// offline once no connection remains on this or any other instance. Releasing
+	// one that was never counted would take another connection's place.
+	if client.presenceCounted {
+		offline, err := h.Storage.SetUserOffline(client.UserID, h.NodeID)
+		if err != nil {
+			h.logger.Warn("Failed to set user offline",
+				"user_id", client.UserID,
+				"error", err)
+		}
+		if offline {
+			go h.notifyPresence(client.UserID, "offline")
+			h.logger.Debug("All clients disconnected, user offline",
+				"user_id", client.UserID)
+		}
+	}
+
 	// Remove from all chat rooms
 	leftChats := 0
 	for chatID := range client.ActiveChats {
@@ -157,6 +288,9 @@ func (h *Hub) handleUnregister(client *Client) {
 		}
 	}
 
+	// Stop draining the client's overflow before closing the channel it sends on
+	close(client.closing)
+	client.draining.Wait()
 	close(client.Send)
 
 	h.logger.Info("Client unregistered",
@@ -165,27 +299,99 @@ func (h *Hub) handleUnregister(client *Client) {
 		"left_chats", leftChats)
 }
 
+// sendPresenceSnapshot pushes the set of currently online chat peers to a freshly
+// registered client so it doesn't start with a blank online state
+func (h *Hub) sendPresenceSnapshot(client *Client) {
+	peerIDs, err := h.Storage.GetChatPeerIDs(client.UserID)
+	if err != nil {
+		h.logger.Warn("Failed to get chat peers for presence snapshot",
+			"user_id", client.UserID,
+			"error", err)
+		return
+	}
+
+	online, err := h.Storage.FilterOnlineUsers(peerIDs)
+	if err != nil {
+		h.logger.Warn("Failed to get online peers for presence snapshot",
+			"user_id", client.UserID,
+			"error", err)
+		return
+	}
+
+	payload := marshalMessage(WsMessage{
+		Type:    string(MessageTypeSnapshot),
+		Payload: marshalPayload(models.PresenceSnapshot{OnlineUserIDs: online}),
+	})
+
+	// The client may have disconnected while the snapshot was being built
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if !h.Clients[client.UserID][client] || !client.Supports(string(MessageTypeSnapshot)) {
+		return
+	}
+
+	if h.send(client, payload) {
+		h.logger.Debug("Presence snapshot sent",
+			"user_id", client.UserID,
+			"session_id", client.SessionID,
+			"peer_count", len(peerIDs),
+			"online_count", len(online))
+	}
+}
+
+// heartbeat marks this instance as alive so other nodes can tell when it has died
+func (h *Hub) heartbeat() {
+	if err := h.Storage.SetNodeHeartbeat(h.NodeID); err != nil {
+		h.logger.Warn("Failed to record node heartbeat", "error", err)
+	}
+}
+
+// refreshPresence keeps the presence keys of all locally connected users alive
+func (h *Hub) refreshPresence() {
+	h.mu.RLock()
+	userIDs := make([]string, 0, len(h.Clients))
+	for userID := range h.Clients {
+		userIDs = append(userIDs, userID)
+	}
+	h.mu.RUnlock()
+
+	if err := h.Storage.RefreshUserPresence(userIDs); err != nil {
+		h.logger.Warn("Failed to refresh presence",
+			"user_count", len(userIDs),
+			"error", err)
+		return
+	}
+
+	h.logger.Debug("Presence refreshed", "user_count", len(userIDs))
+}
+
 func (h *Hub) handleBroadcast(message WsMessage) {
 	switch MessageType(message.Type) {
-	case MessageTypeMessage:
-		h.handleChatMessage(message)
 	case MessageTypeTyping:
 		h.handleTypingIndicator(message)
 	case MessageTypeStatus:
 		h.handleStatusUpdate(message)
+	case MessageTypeResume:
+		// Replays can be long, so they don't hold up the hub loop
+		go h.handleResume(message)
 	default:
 		h.logger.Warn("Unknown message type received",
 			"type", message.Type,
 			"sender", message.Sender)
+		h.sendError(message, ErrCodeUnknownType, "Unknown message type: "+message.Type)
 	}
 }
 
+// handleChatMessage validates, saves and delivers a chat message from a local
+// client. Its checks query the database and the moderator, so it runs on the
+// sender's own message goroutine rather than the hub loop.
 func (h *Hub) handleChatMessage(msg WsMessage) {
 	var messageReq models.MessageRequest
 	if err := json.Unmarshal(msg.Payload, &messageReq); err != nil {
 		h.logger.Error("Error unmarshaling message",
 			"error", err,
 			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeInvalidPayload, "Invalid message payload")
 		return
 	}
 
@@ -194,24 +400,217 @@ func (h *Hub) handleChatMessage(msg WsMessage) {
 		"chat_id", messageReq.ChatID,
 		"content_type", messageReq.ContentType)
 
-	// Save message to database
-	savedMsg, err := h.Storage.SaveMessage(
+	if h.chatCfg.SanitizeContent {
+		messageReq.Content = models.SanitizeContent(messageReq.Content)
+	}
+
+	contentType := messageReq.ContentType
+	if contentType == "" {
+		contentType = string(models.ContentTypeText)
+	}
+
+	// Membership, a closed direct chat and group send restrictions are checked in
+	// one query
+	peerID, restriction, err := h.Storage.CheckSendPermission(messageReq.ChatID, msg.Sender, contentType)
+	if errors.Is(err, store.ErrNotFound) {
+		h.logger.Warn("Sender is not a member of the chat",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeNotMember, "Chat not found or access denied")
+		return
+	}
+	if errors.Is(err, store.ErrChatClosed) {
+		h.logger.Warn("Sender wrote to a closed direct chat",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeForbidden, "This chat is closed because the other participant left")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Error checking send permission",
+			"error", err,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeInternal, "Failed to send message")
+		return
+	}
+	if restriction != "" {
+		h.logger.Warn("Message blocked by group settings",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"reason", restriction)
+		h.sendError(msg, ErrCodeForbidden, restriction)
+		return
+	}
+
+	// In direct chats, respect the recipient's privacy settings
+	if peerID != "" {
+		allowed, err := h.Storage.CanMessageUser(msg.Sender, peerID)
+		if err != nil {
+			h.logger.Error("Error checking recipient privacy",
+				"error", err,
+				"sender", msg.Sender,
+				"recipient", peerID)
+			h.sendError(msg, ErrCodeInternal, "Failed to send message")
+			return
+		}
+		if !allowed {
+			h.logger.Warn("Recipient does not accept messages from sender",
+				"sender", msg.Sender,
+				"recipient", peerID,
+				"chat_id", messageReq.ChatID)
+			h.sendError(msg, ErrCodeForbidden, "This user is not accepting messages from you")
+			return
+		}
+	}
+
+	// Replies must reference a live message in the same chat
+	invalidReply, err := h.Storage.ValidateReplyTo(messageReq.ChatID, messageReq.ReplyTo)
+	if err != nil {
+		h.logger.Error("Error validating reply",
+			"error", err,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeInternal, "Failed to send message")
+		return
+	}
+	if invalidReply != "" {
+		h.logger.Warn("Invalid reply_to in message",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"reason", invalidReply)
+		h.sendError(msg, ErrCodeInvalidPayload, invalidReply)
+		return
+	}
+
+	invalidForward, err := h.Storage.ValidateForward(messageReq.Forwarded, messageReq.ForwardFrom)
+	if err != nil {
+		h.logger.Error("Error validating forward",
+			"error", err,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeInternal, "Failed to send message")
+		return
+	}
+	if invalidForward != "" {
+		h.logger.Warn("Invalid forward in message",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"reason", invalidForward)
+		h.sendError(msg, ErrCodeInvalidPayload, invalidForward)
+		return
+	}
+
+	if messageReq.Content == "" && !models.ContentType(contentType).IsMedia() {
+		h.logger.Warn("Empty message content",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeInvalidPayload, "Message content is required")
+		return
+	}
+	if invalidMedia := messageReq.MessageMedia.Validate(models.ContentType(contentType),
+		h.chatCfg.MaxMediaFileSize, h.chatCfg.MaxMediaDuration); invalidMedia != "" {
+		h.logger.Warn("Invalid media in message",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"content_type", contentType,
+			"reason", invalidMedia)
+		h.sendError(msg, ErrCodeInvalidPayload, invalidMedia)
+		return
+	}
+
+	if allow, reason := h.Moderator.Check(context.Background(), messageReq.Draft(msg.Sender)); !allow {
+		h.logger.Warn("Message rejected by moderation",
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"reason", reason)
+		h.sendError(msg, ErrCodeRejected, reason)
+		return
+	}
+
+	// Counted last so messages rejected above don't use up the sender's allowance
+	allowed, err := h.Storage.AllowChatMessage(messageReq.ChatID, msg.Sender, h.chatCfg.MessageRateLimit, h.chatCfg.MessageRateWindow)
+	if err != nil {
+		h.logger.Error("Error checking chat rate limit",
+			"error", err,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID)
+		h.sendError(msg, ErrCodeInternal, "Failed to send message")
+		return
+	}
+	if !allowed {
+		h.sendError(msg, ErrCodeRateLimited, "Too many messages in this chat, slow down")
+		return
+	}
+
+	// Save message to database. A failed save is retried in the background, so a
+	// struggling database doesn't hold up the sender's later messages.
+	savedMsg, err := h.saveMessage(msg.Sender, messageReq)
+	if err != nil {
+		h.logger.Error("Error saving message to database",
+			"error", err,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"attempt", 1)
+		go h.retrySaveMessage(msg, messageReq, err)
+		return
+	}
+
+	h.deliverMessage(msg, messageReq, savedMsg)
+}
+
+// saveMessage stores a chat message sent by senderID
+func (h *Hub) saveMessage(senderID string, messageReq models.MessageRequest) (*models.Message, error) {
+	return h.Storage.SaveMessage(
 		messageReq.ChatID,
-		msg.Sender,
+		senderID,
 		messageReq.Content,
 		messageReq.ContentType,
+		&messageReq.MessageMedia,
 		messageReq.ReplyTo,
 		messageReq.ForwardFrom,
 		messageReq.Forwarded,
 	)
-	if err != nil {
+}
+
+// retrySaveMessage retries a message whose first save failed with err, with
+// doubling delays, and delivers it once saved. If every attempt fails the message
+// is dead-lettered and the sender told it failed.
+func (h *Hub) retrySaveMessage(msg WsMessage, messageReq models.MessageRequest, err error) {
+	delay := saveMessageRetryDelay
+	for attempt := 2; attempt <= saveMessageAttempts; attempt++ {
+		time.Sleep(delay)
+		delay *= 2
+
+		savedMsg, saveErr := h.saveMessage(msg.Sender, messageReq)
+		if saveErr == nil {
+			h.deliverMessage(msg, messageReq, savedMsg)
+			return
+		}
+		err = saveErr
+
 		h.logger.Error("Error saving message to database",
 			"error", err,
 			"sender", msg.Sender,
-			"chat_id", messageReq.ChatID)
-		return
+			"chat_id", messageReq.ChatID,
+			"attempt", attempt)
 	}
 
+	if recErr := h.Storage.RecordFailedMessage(messageReq.ChatID, msg.Sender, msg.ClientMsgID,
+		msg.Payload, max(saveMessageAttempts, 1), err); recErr != nil {
+		h.logger.Error("Message lost: could not record failed message",
+			"error", recErr,
+			"sender", msg.Sender,
+			"chat_id", messageReq.ChatID,
+			"client_msg_id", msg.ClientMsgID)
+	}
+	h.sendFailedStatus(msg, messageReq.ChatID)
+	h.sendError(msg, ErrCodeSaveFailed, "Failed to save message")
+}
+
+// deliverMessage fans a saved message out to the chat's members, here and on
+// other instances, and records its delivery
+func (h *Hub) deliverMessage(msg WsMessage, messageReq models.MessageRequest, savedMsg *models.Message) {
 	// Get chat members
 	members, err := h.Storage.GetChatMembers(messageReq.ChatID)
 	if err != nil {
@@ -255,30 +654,25 @@ func (h *Hub) handleChatMessage(msg WsMessage) {
 
 	// Broadcast to all online clients in the chat room
 	deliveredCount := 0
+	deliveredTo := make(map[string]bool)
 	h.mu.RLock()
 	if room, ok := h.ChatRooms[messageReq.ChatID]; ok {
 		for client := range room {
-			// Skip sender (they already sent the message)
-			if client.UserID == msg.Sender {
+			// Skip the connection that sent the message; the sender's other
+			// devices still need it to stay in sync
+			if client == msg.origin {
 				continue
 			}
 
 			// Mark as delivered for online recipients
-			go h.Storage.UpdateMessageStatus(savedMsg.ID, client.UserID, "delivered")
-			deliveredCount++
+			if client.UserID != msg.Sender {
+				go h.Storage.UpdateMessageStatus(savedMsg.ID, client.UserID, "delivered")
+				deliveredCount++
+				deliveredTo[client.UserID] = true
+			}
 
 			// Send message to client
-			select {
-			case client.Send <- marshalMessage(response):
-				// Message sent successfully
-			default:
-				// Client buffer full, disconnect
-				close(client.Send)
-				delete(room, client)
-				h.logger.Warn("Client buffer full, disconnected",
-					"user_id", client.UserID,
-					"chat_id", messageReq.ChatID)
-			}
+			h.send(client, marshalMessage(response))
 		}
 	}
 	h.mu.RUnlock()
@@ -287,16 +681,41 @@ func (h *Hub) handleChatMessage(msg WsMessage) {
 	for _, offlineMemberID := range offlineMembers {
 		go h.Storage.UpdateMessageStatus(savedMsg.ID, offlineMemberID, "sent")
 	}
+	h.Push.NotifyOffline(savedMsg, offlineMembers)
 
-	// Publish to Redis for other instances
+	h.Webhooks.Dispatch(models.WebhookEvent{
+		Event:     models.WebhookEventMessageSent,
+		ChatID:    savedMsg.ChatID,
+		MessageID: savedMsg.ID,
+		UserID:    savedMsg.SenderID,
+		Message:   savedMsg,
+	})
+	for userID := range deliveredTo {
+		h.Webhooks.Dispatch(models.WebhookEvent{
+			Event:     models.WebhookEventMessageDelivered,
+			ChatID:    savedMsg.ChatID,
+			MessageID: savedMsg.ID,
+			UserID:    userID,
+		})
+	}
+
+	// Publish the saved message to Redis for other instances; local clients
+	// were already served above
 	go func() {
-		payload, _ := json.Marshal(msg)
-		h.Storage.RDB.Publish(h.Storage.Ctx, "chat_sync", payload)
+		if err := h.publish(response); err != nil {
+			h.logger.Error("Error publishing message to Redis",
+				"error", err,
+				"chat_id", messageReq.ChatID,
+				"message_id", savedMsg.ID)
+			return
+		}
 		h.logger.Debug("Message published to Redis",
 			"chat_id", messageReq.ChatID,
 			"sender", msg.Sender)
 	}()
 
+	go h.NotifyMentions(savedMsg)
+
 	// Update chat last activity
 	go h.Storage.UpdateChatLastActivity(messageReq.ChatID)
 
@@ -309,12 +728,110 @@ func (h *Hub) handleChatMessage(msg WsMessage) {
 		"delivered", deliveredCount)
 }
 
+// handleResume replays to a reconnected client the messages it missed in each chat
+// after the last one it saw, followed by a resumed message per chat. Messages that
+// arrive live during the replay may also be replayed; clients drop them by ID.
+func (h *Hub) handleResume(msg WsMessage) {
+	var req models.ResumeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		h.logger.Warn("Error unmarshaling resume request",
+			"error", err,
+			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeInvalidPayload, "Invalid resume payload")
+		return
+	}
+	if len(req.Chats) == 0 || len(req.Chats) > maxResumeChats {
+		h.sendError(msg, ErrCodeInvalidPayload,
+			fmt.Sprintf("A resume request must name between 1 and %d chats", maxResumeChats))
+		return
+	}
+
+	chatIDs := make([]string, 0, len(req.Chats))
+	for _, cursor := range req.Chats {
+		if _, err := uuid.Parse(cursor.ChatID); err != nil {
+			h.sendError(msg, ErrCodeInvalidPayload, "Invalid chat ID: "+cursor.ChatID)
+			return
+		}
+		chatIDs = append(chatIDs, cursor.ChatID)
+	}
+
+	chats, err := h.Storage.GetChatsByIDs(msg.Sender, chatIDs)
+	if err != nil {
+		h.logger.Error("Error getting chats to resume",
+			"error", err,
+			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeInternal, "Failed to resume")
+		return
+	}
+	isMember := make(map[string]bool, len(chats))
+	for _, chat := range chats {
+		isMember[chat.ID] = true
+	}
+
+	replayed := 0
+	for _, cursor := range req.Chats {
+		chatMsg := msg
+		chatMsg.RoomID = cursor.ChatID
+		if !isMember[cursor.ChatID] {
+			h.sendError(chatMsg, ErrCodeNotMember, "Chat not found or access denied")
+			continue
+		}
+
+		messages, err := h.Storage.GetMissedMessages(cursor.ChatID, msg.Sender, cursor.After, resumeReplayLimit+1)
+		if errors.Is(err, store.ErrNotFound) {
+			h.sendError(chatMsg, ErrCodeInvalidPayload, "Unknown resume cursor: "+cursor.After)
+			continue
+		}
+		if err != nil {
+			h.logger.Error("Error getting missed messages",
+				"error", err,
+				"sender", msg.Sender,
+				"chat_id", cursor.ChatID)
+			h.sendError(chatMsg, ErrCodeInternal, "Failed to resume")
+			continue
+		}
+
+		hasMore := len(messages) > resumeReplayLimit
+		if hasMore {
+			messages = messages[:resumeReplayLimit]
+		}
+
+		for i := range messages {
+			h.replyToOrigin(msg, WsMessage{
+				Type:   string(MessageTypeMessage),
+				RoomID: cursor.ChatID,
+				Sender: messages[i].SenderID,
+				Payload: marshalPayload(models.MessageResponse{
+					Message: messages[i],
+					Users:   []models.User{},
+				}),
+			})
+		}
+		h.replyToOrigin(msg, WsMessage{
+			Type:   string(MessageTypeResumed),
+			RoomID: cursor.ChatID,
+			Payload: marshalPayload(models.ResumeResult{
+				ChatID:  cursor.ChatID,
+				Count:   len(messages),
+				HasMore: hasMore,
+			}),
+		})
+		replayed += len(messages)
+	}
+
+	h.logger.Info("Client resumed",
+		"sender", msg.Sender,
+		"chat_count", len(req.Chats),
+		"replayed", replayed)
+}
+
 func (h *Hub) handleTypingIndicator(msg WsMessage) {
 	var typing models.TypingIndicator
 	if err := json.Unmarshal(msg.Payload, &typing); err != nil {
 		h.logger.Error("Error unmarshaling typing indicator",
 			"error", err,
 			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeInvalidPayload, "Invalid typing payload")
 		return
 	}
 
@@ -323,29 +840,27 @@ func (h *Hub) handleTypingIndicator(msg WsMessage) {
 		"chat_id", typing.ChatID,
 		"is_typing", typing.IsTyping)
 
+	// Keystroke bursts only keep the auto-clear timer alive; stopping is always forwarded
+	if !h.allowTypingBroadcast(typing.ChatID, msg.Sender, typing.IsTyping) {
+		h.scheduleTypingClear(typing.ChatID, msg.Sender, typing.IsTyping)
+		return
+	}
+
 	// Broadcast typing indicator to all in chat except sender
+	response := WsMessage{
+		Type:    string(MessageTypeTyping),
+		RoomID:  typing.ChatID,
+		Sender:  msg.Sender,
+		Payload: msg.Payload,
+	}
 	notifiedCount := 0
 	h.mu.RLock()
 	if room, ok := h.ChatRooms[typing.ChatID]; ok {
-		response := WsMessage{
-			Type:    string(MessageTypeTyping),
-			RoomID:  typing.ChatID,
-			Sender:  msg.Sender,
-			Payload: msg.Payload,
-		}
-
 		payload := marshalMessage(response)
 		for client := range room {
 			if client.UserID != msg.Sender {
-				select {
-				case client.Send <- payload:
+				if h.send(client, payload) {
 					notifiedCount++
-				default:
-					close(client.Send)
-					delete(room, client)
-					h.logger.Warn("Client buffer full during typing indicator",
-						"user_id", client.UserID,
-						"chat_id", typing.ChatID)
 				}
 			}
 		}
@@ -356,6 +871,111 @@ func (h *Hub) handleTypingIndicator(msg WsMessage) {
 		"sender", msg.Sender,
 		"chat_id", typing.ChatID,
 		"notified_users", notifiedCount)
+
+	// Members connected to other instances get it through Redis
+	go func() {
+		if err := h.publish(response); err != nil {
+			h.logger.Error("Error publishing typing indicator to Redis",
+				"error", err,
+				"chat_id", typing.ChatID,
+				"sender", msg.Sender)
+		}
+	}()
+
+	h.scheduleTypingClear(typing.ChatID, msg.Sender, typing.IsTyping)
+}
+
+// SendTyping broadcasts a typing indicator for a user who is not sending it over a
+// WebSocket. It is throttled and cleared after a pause exactly like one that is.
+// Callers must have checked that the user is a member of the chat.
+func (h *Hub) SendTyping(chatID, userID string, isTyping bool) {
+	h.Broadcast <- WsMessage{
+		Type:   string(MessageTypeTyping),
+		RoomID: chatID,
+		Sender: userID,
+		Payload: marshalPayload(models.TypingIndicator{
+			ChatID:   chatID,
+			UserID:   userID,
+			IsTyping: isTyping,
+		}),
+	}
+}
+
+// allowTypingBroadcast reports whether a typing event should be re-broadcast, allowing
+// at most one is_typing=true event per throttle interval for each user and chat
+func (h *Hub) allowTypingBroadcast(chatID, userID string, isTyping bool) bool {
+	key := chatID + ":" + userID
+
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	if !isTyping {
+		delete(h.typingSentAt, key)
+		return true
+	}
+
+	now := time.Now()
+	if last, ok := h.typingSentAt[key]; ok && now.Sub(last) < h.cfg.TypingThrottle {
+		return false
+	}
+	h.typingSentAt[key] = now
+	return true
+}
+
+// scheduleTypingClear (re)arms a timer that broadcasts typing=false for the user
+// unless another typing event arrives first, so a client that disconnects
+// mid-typing does not leave a stuck indicator behind
+func (h *Hub) scheduleTypingClear(chatID, userID string, isTyping bool) {
+	key := chatID + ":" + userID
+
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	if timer, ok := h.typingTimers[key]; ok {
+		timer.Stop()
+		delete(h.typingTimers, key)
+	}
+
+	if !isTyping {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(typingTimeout, func() {
+		h.typingMu.Lock()
+		if h.typingTimers[key] != timer {
+			// Superseded by a newer typing event
+			h.typingMu.Unlock()
+			return
+		}
+		delete(h.typingTimers, key)
+		h.typingMu.Unlock()
+
+		payload, err := json.Marshal(models.TypingIndicator{
+			ChatID:   chatID,
+			UserID:   userID,
+			IsTyping: false,
+		})
+		if err != nil {
+			h.logger.Error("Error marshaling typing clear",
+				"error", err,
+				"chat_id", chatID,
+				"user_id", userID)
+			return
+		}
+
+		h.logger.Debug("Typing indicator timed out, clearing",
+			"chat_id", chatID,
+			"user_id", userID)
+
+		h.Broadcast <- WsMessage{
+			Type:    string(MessageTypeTyping),
+			Payload: payload,
+			RoomID:  chatID,
+			Sender:  userID,
+		}
+	})
+	h.typingTimers[key] = timer
 }
 
 func (h *Hub) handleStatusUpdate(msg WsMessage) {
@@ -364,6 +984,7 @@ func (h *Hub) handleStatusUpdate(msg WsMessage) {
 		h.logger.Error("Error unmarshaling status update",
 			"error", err,
 			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeInvalidPayload, "Invalid status payload")
 		return
 	}
 
@@ -379,11 +1000,15 @@ func (h *Hub) handleStatusUpdate(msg WsMessage) {
 			"error", err,
 			"message_id", statusUpdate.MessageID,
 			"sender", msg.Sender)
+		h.sendError(msg, ErrCodeSaveFailed, "Failed to update message status")
 		return
 	}
 
 	// Get message to find original sender
 	message, err := h.Storage.GetMessage(statusUpdate.MessageID)
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("Error getting message for status update",
 			"error", err,
@@ -391,6 +1016,15 @@ func (h *Hub) handleStatusUpdate(msg WsMessage) {
 		return
 	}
 
+	if event, ok := models.WebhookEventForStatus(statusUpdate.Status); ok {
+		h.Webhooks.Dispatch(models.WebhookEvent{
+			Event:     event,
+			ChatID:    message.ChatID,
+			MessageID: message.ID,
+			UserID:    msg.Sender,
+		})
+	}
+
 	// Notify sender that their message was read/delivered
 	notified := false
 	h.mu.RLock()
@@ -410,15 +1044,8 @@ func (h *Hub) handleStatusUpdate(msg WsMessage) {
 		for client := range room {
 			// Find the original sender
 			if client.UserID == message.SenderID {
-				select {
-				case client.Send <- payload:
+				if h.send(client, payload) {
 					notified = true
-				default:
-					close(client.Send)
-					delete(room, client)
-					h.logger.Warn("Client buffer full during status update",
-						"user_id", client.UserID,
-						"message_id", statusUpdate.MessageID)
 				}
 				break
 			}
@@ -467,16 +1094,9 @@ func (h *Hub) notifyPresence(userID, status string) {
 			notifiedInChat := 0
 			for client := range room {
 				if client.UserID != userID {
-					select {
-					case client.Send <- payload:
+					if h.send(client, payload) {
 						notifiedInChat++
 						notifiedTotal++
-					default:
-						close(client.Send)
-						delete(room, client)
-						h.logger.Warn("Client buffer full during presence notification",
-							"user_id", client.UserID,
-							"chat_id", chat.ID)
 					}
 				}
 			}
@@ -496,8 +1116,235 @@ func (h *Hub) notifyPresence(userID, status string) {
 		"total_chats", len(chats))
 }
 
+// NotifyMentions sends a mention event to every device of the members mentioned in
+// message, whether or not they have the chat open or muted. Like chat updates it
+// is delivered through Redis, including to this instance.
+func (h *Hub) NotifyMentions(message *models.Message) {
+	if len(message.Mentions) == 0 {
+		return
+	}
+
+	msg := WsMessage{
+		Type:   string(MessageTypeMention),
+		RoomID: message.ChatID,
+		Sender: message.SenderID,
+		Payload: marshalPayload(models.MentionEvent{
+			ChatID:       message.ChatID,
+			MessageID:    message.ID,
+			SenderID:     message.SenderID,
+			Content:      message.Content,
+			MentionedIDs: message.Mentions,
+		}),
+	}
+
+	if err := h.publish(msg); err != nil {
+		h.logger.Error("Error publishing mention",
+			"error", err,
+			"chat_id", message.ChatID,
+			"message_id", message.ID)
+		return
+	}
+
+	h.logger.Debug("Mention published",
+		"chat_id", message.ChatID,
+		"message_id", message.ID,
+		"mentioned", len(message.Mentions))
+}
+
+// BroadcastChatUpdate notifies every connected member of a chat, on all instances,
+// that something about the chat changed. Delivery goes through Redis so local and
+// remote clients are reached by the same path.
+func (h *Hub) BroadcastChatUpdate(chatID string, event models.ChatUpdateEvent) {
+	msg := WsMessage{
+		Type:    string(MessageTypeChatUpdate),
+		RoomID:  chatID,
+		Sender:  event.UserID,
+		Payload: marshalPayload(event),
+	}
+
+	if err := h.publish(msg); err != nil {
+		h.logger.Error("Error publishing chat update",
+			"error", err,
+			"chat_id", chatID,
+			"event", event.Event)
+		return
+	}
+
+	h.logger.Debug("Chat update published",
+		"chat_id", chatID,
+		"event", event.Event)
+}
+
+// EndSession disconnects every WebSocket client of a session that no longer exists,
+// on all instances. The session's access token is refused from then on, so the
+// clients can't reconnect with it.
+func (h *Hub) EndSession(userID, sessionID string) {
+	msg := WsMessage{
+		Type:    string(MessageTypeSessionEnded),
+		Sender:  userID,
+		Payload: marshalPayload(map[string]string{"session_id": sessionID}),
+	}
+
+	if err := h.publish(msg); err != nil {
+		h.logger.Error("Error publishing session end",
+			"error", err,
+			"user_id", userID,
+			"session_id", sessionID)
+		return
+	}
+
+	h.logger.Debug("Session end published",
+		"user_id", userID,
+		"session_id", sessionID)
+}
+
+// send queues payload for a client without blocking. If the client's buffer is
+// full the payload is held in its overflow, which a drain goroutine feeds into the
+// buffer, waiting up to the configured send timeout for room each time. A client
+// that still can't keep up, or whose overflow grows as large as its buffer, is
+// disconnected, and its ReadPump then unregisters it. Callers hold h.mu.
+func (h *Hub) send(client *Client, payload []byte) bool {
+	if client.dropped.Load() {
+		return false
+	}
+
+	client.overflowMu.Lock()
+	defer client.overflowMu.Unlock()
+
+	// Anything already waiting goes first, so only try the buffer when nothing is
+	if len(client.overflow) == 0 {
+		select {
+		case client.Send <- payload:
+			return true
+		default:
+		}
+	}
+
+	if len(client.overflow) >= max(cap(client.Send), 1) {
+		h.dropSlowClient(client)
+		return false
+	}
+
+	client.overflow = append(client.overflow, payload)
+	if len(client.overflow) == 1 {
+		client.draining.Add(1)
+		go h.drainOverflow(client)
+	}
+	return true
+}
+
+// drainOverflow moves a client's overflow into its send buffer, in order, until
+// the overflow is empty, the client is unregistered, or the buffer stays full for
+// longer than the send timeout, which drops the client
+func (h *Hub) drainOverflow(client *Client) {
+	defer client.draining.Done()
+
+	timer := time.NewTimer(h.cfg.SendTimeout)
+	defer timer.Stop()
+
+	for {
+		client.overflowMu.Lock()
+		if len(client.overflow) == 0 {
+			client.overflow = nil
+			client.overflowMu.Unlock()
+			return
+		}
+		payload := client.overflow[0]
+		client.overflowMu.Unlock()
+
+		select {
+		case client.Send <- payload:
+			client.overflowMu.Lock()
+			client.overflow = client.overflow[1:]
+			client.overflowMu.Unlock()
+			timer.Reset(h.cfg.SendTimeout)
+		case <-timer.C:
+			h.dropSlowClient(client)
+			return
+		case <-client.closing:
+			return
+		}
+	}
+}
+
+// dropSlowClient disconnects a client that stopped draining its send buffer
+func (h *Hub) dropSlowClient(client *Client) {
+	if !client.dropped.CompareAndSwap(false, true) {
+		return
+	}
+
+	drops := h.slowClientDrops.Add(1)
+	h.logger.Warn("Dropping slow client, send buffer full",
+		"user_id", client.UserID,
+		"session_id", client.SessionID,
+		"buffer_size", cap(client.Send),
+		"timeout", h.cfg.SendTimeout,
+		"total_slow_drops", drops)
+	client.Conn.Close()
+}
+
+// Snapshot copies the clients connected to this instance, grouped by user and
+// sorted so repeated calls are easy to compare
+func (h *Hub) Snapshot() models.ConnectionsSnapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshot := models.ConnectionsSnapshot{
+		NodeID:          h.NodeID,
+		Users:           make([]models.UserConnections, 0, len(h.Clients)),
+		SlowClientDrops: h.slowClientDrops.Load(),
+	}
+	for userID, clients := range h.Clients {
+		user := models.UserConnections{
+			UserID:      userID,
+			ClientCount: len(clients),
+			Clients:     make([]models.ClientConnection, 0, len(clients)),
+		}
+		for client := range clients {
+			chatIDs := make([]string, 0, len(client.ActiveChats))
+			for chatID := range client.ActiveChats {
+				chatIDs = append(chatIDs, chatID)
+			}
+			slices.Sort(chatIDs)
+
+			user.Clients = append(user.Clients, models.ClientConnection{
+				SessionID: client.SessionID,
+				Version:   client.Version,
+				ChatIDs:   chatIDs,
+				LastRTTMs: client.LastRTT().Milliseconds(),
+			})
+		}
+		slices.SortFunc(user.Clients, func(a, b models.ClientConnection) int {
+			return strings.Compare(a.SessionID, b.SessionID)
+		})
+
+		snapshot.Users = append(snapshot.Users, user)
+		snapshot.ClientCount += len(clients)
+	}
+	slices.SortFunc(snapshot.Users, func(a, b models.UserConnections) int {
+		return strings.Compare(a.UserID, b.UserID)
+	})
+
+	return snapshot
+}
+
+// publish sends a message to every instance via Redis, stamped with this node's ID
+// so the receiving handlers can tell local and remote messages apart. Without Redis
+// this is the only instance, so the message goes straight to the sync handlers.
+func (h *Hub) publish(msg WsMessage) error {
+	msg.NodeID = h.NodeID
+	if !h.Storage.RedisAvailable() {
+		go h.handleSyncMessage(msg)
+		return nil
+	}
+	return h.Storage.RDB.Publish(h.Storage.Ctx, "chat_sync", marshalMessage(msg)).Err()
+}
+
 // Helper functions
 func marshalMessage(msg WsMessage) []byte {
+	if msg.Version == 0 {
+		msg.Version = minVersion(msg.Type)
+	}
 	data, _ := json.Marshal(msg)
 	return data
 }
@@ -0,0 +1,138 @@
+package hub
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/msniranjan18/chit-chat/config"
+)
+
+// newSendTestClient returns a registered-looking client with a one-slot send
+// buffer on a live connection. When the test ends it is torn down the way
+// handleUnregister does it, so a drain still sending would panic on the closed
+// channel.
+func newSendTestClient(t *testing.T, h *Hub) *Client {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	client := &Client{
+		Hub:     h,
+		UserID:  "user",
+		Conn:    conn,
+		Send:    make(chan []byte, 1),
+		closing: make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		close(client.closing)
+		client.draining.Wait()
+		close(client.Send)
+		conn.Close()
+	})
+	return client
+}
+
+func newSendTestHub(sendTimeout time.Duration) *Hub {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHub(nil, nil, nil, nil, config.WebSocketConfig{SendTimeout: sendTimeout}, config.ChatConfig{}, logger)
+}
+
+func TestSendDoesNotWaitOnFullBuffer(t *testing.T) {
+	h := newSendTestHub(time.Hour)
+	client := newSendTestClient(t, h)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Holding the hub lock, as every caller does
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		for _, payload := range []string{"first", "second"} {
+			if !h.send(client, []byte(payload)) {
+				t.Errorf("send(%q) = false, want true", payload)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send waited on the full buffer")
+	}
+
+	// The payload that didn't fit is delivered once there is room, in order
+	for _, want := range []string{"first", "second"} {
+		select {
+		case got := <-client.Send:
+			if string(got) != want {
+				t.Errorf("received %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q was never delivered", want)
+		}
+	}
+	if client.dropped.Load() {
+		t.Error("client was dropped although it caught up")
+	}
+}
+
+func TestSendDropsClientThatDoesNotCatchUp(t *testing.T) {
+	h := newSendTestHub(10 * time.Millisecond)
+	client := newSendTestClient(t, h)
+
+	h.send(client, []byte("first"))
+	h.send(client, []byte("second"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !client.dropped.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("slow client was not dropped after the send timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := h.slowClientDrops.Load(); got != 1 {
+		t.Errorf("slow client drops = %d, want 1", got)
+	}
+	if h.send(client, []byte("third")) {
+		t.Error("send to a dropped client = true, want false")
+	}
+}
+
+func TestSendDropsClientWhenOverflowFills(t *testing.T) {
+	h := newSendTestHub(time.Hour)
+	client := newSendTestClient(t, h)
+
+	// One payload fills the buffer and one the overflow; the next has nowhere to go
+	h.send(client, []byte("first"))
+	h.send(client, []byte("second"))
+	if h.send(client, []byte("third")) {
+		t.Error("send with a full overflow = true, want false")
+	}
+	if !client.dropped.Load() {
+		t.Error("client was not dropped when its overflow filled")
+	}
+}
@@ -1,18 +1,107 @@
 package hub
 
 import (
+	"context"
 	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+const (
+	redisReconnectMinBackoff = 1 * time.Second
+	redisReconnectMaxBackoff = 30 * time.Second
 )
 
+// syncSubscription is the part of a Redis Pub/Sub subscription the listener uses
+type syncSubscription interface {
+	Receive(ctx context.Context) (interface{}, error)
+	Channel(opts ...redis.ChannelOption) <-chan *redis.Message
+	Close() error
+}
+
+// ListenToRedis relays messages published by other instances to local clients.
+// If the subscription drops it re-subscribes with exponential backoff, returning
+// only once the store context is cancelled. Without Redis there is nothing to
+// listen to, and publish hands messages straight to the same handlers instead.
 func (h *Hub) ListenToRedis() {
+	if !h.Storage.RedisAvailable() {
+		h.logger.Warn("Redis is unavailable, cross-instance sync is disabled; " +
+			"clients connected to other instances will not receive messages from this one")
+		return
+	}
+
+	backoff := redisReconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		if h.Storage.Ctx.Err() != nil {
+			h.logger.Info("Stopped listening for Redis Pub/Sub messages")
+			return
+		}
+
+		if h.listenToRedisOnce() {
+			// The subscription was established, so start the next retry from scratch
+			attempt, backoff = 1, redisReconnectMinBackoff
+		}
+		if h.Storage.Ctx.Err() != nil {
+			h.logger.Info("Stopped listening for Redis Pub/Sub messages")
+			return
+		}
+
+		h.logger.Warn("Redis Pub/Sub subscription lost, reconnecting",
+			"attempt", attempt,
+			"backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-h.Storage.Ctx.Done():
+			h.logger.Info("Stopped listening for Redis Pub/Sub messages")
+			return
+		}
+
+		backoff *= 2
+		if backoff > redisReconnectMaxBackoff {
+			backoff = redisReconnectMaxBackoff
+		}
+	}
+}
+
+// listenToRedisOnce subscribes to the sync channel and processes messages until
+// the subscription ends. It reports whether the subscription was established.
+func (h *Hub) listenToRedisOnce() bool {
 	// Subscribe to the global chat channel
-	pubsub := h.Storage.RDB.Subscribe(h.Storage.Ctx, "chat_sync")
+	var pubsub syncSubscription
+	if h.subscribe != nil {
+		pubsub = h.subscribe(h.Storage.Ctx)
+	} else {
+		pubsub = h.Storage.RDB.Subscribe(h.Storage.Ctx, "chat_sync")
+	}
 	defer pubsub.Close()
 
+	// Wait for the subscription to be confirmed so connection errors surface here
+	if _, err := pubsub.Receive(h.Storage.Ctx); err != nil {
+		h.logger.Error("Failed to subscribe to Redis Pub/Sub",
+			"error", err)
+		return false
+	}
+
 	ch := pubsub.Channel()
 	h.logger.Info("Listening for Redis Pub/Sub messages")
 
-	for msg := range ch {
+	for {
+		var msg *redis.Message
+		var ok bool
+		select {
+		case msg, ok = <-ch:
+			if !ok {
+				return true
+			}
+		case <-h.Storage.Ctx.Done():
+			return true
+		}
+
 		var incoming WsMessage
 		if err := json.Unmarshal([]byte(msg.Payload), &incoming); err != nil {
 			h.logger.Error("Error unmarshaling Redis message",
@@ -25,49 +114,56 @@ func (h *Hub) ListenToRedis() {
 			"channel", msg.Channel,
 			"type", incoming.Type,
 			"sender", incoming.Sender,
-			"room_id", incoming.RoomID)
-
-		// Process message based on type
-		switch MessageType(incoming.Type) {
-		case MessageTypeMessage:
-			h.handleRedisChatMessage(incoming)
-		case MessageTypeTyping:
-			h.handleRedisTypingIndicator(incoming)
-		case MessageTypeStatus:
-			h.handleRedisStatusUpdate(incoming)
-		case MessageTypePresence:
-			h.handleRedisPresenceUpdate(incoming)
-		default:
-			h.logger.Warn("Unknown Redis message type",
-				"type", incoming.Type,
-				"sender", incoming.Sender)
-		}
+			"room_id", incoming.RoomID,
+			"origin_node", incoming.NodeID)
+
+		h.handleSyncMessage(incoming)
+	}
+}
+
+// handleSyncMessage processes a message published to the sync channel based on its type
+func (h *Hub) handleSyncMessage(incoming WsMessage) {
+	switch MessageType(incoming.Type) {
+	case MessageTypeMessage:
+		h.handleRedisChatMessage(incoming)
+	case MessageTypeTyping:
+		h.handleRedisTypingIndicator(incoming)
+	case MessageTypeStatus:
+		h.handleRedisStatusUpdate(incoming)
+	case MessageTypePresence:
+		h.handleRedisPresenceUpdate(incoming)
+	case MessageTypeChatUpdate:
+		h.handleRedisChatUpdate(incoming)
+	case MessageTypeMention:
+		h.handleRedisMention(incoming)
+	case MessageTypeSessionEnded:
+		h.handleRedisSessionEnded(incoming)
+	default:
+		h.logger.Warn("Unknown Redis message type",
+			"type", incoming.Type,
+			"sender", incoming.Sender)
 	}
 }
 
 func (h *Hub) handleRedisChatMessage(msg WsMessage) {
+	// Messages published by this instance were delivered locally before publishing
+	if msg.NodeID == h.NodeID {
+		return
+	}
+
 	h.logger.Debug("Forwarding Redis chat message to local clients",
 		"sender", msg.Sender,
 		"room_id", msg.RoomID)
 
-	// Forward to local clients
+	// Forward to local clients, including the sender's devices connected here
 	forwardedCount := 0
 	h.mu.RLock()
 	if room, ok := h.ChatRooms[msg.RoomID]; ok {
+		msg.NodeID = ""
 		payload := marshalMessage(msg)
 		for client := range room {
-			// Don't send back to sender
-			if client.UserID != msg.Sender {
-				select {
-				case client.Send <- payload:
-					forwardedCount++
-				default:
-					close(client.Send)
-					delete(room, client)
-					h.logger.Warn("Client buffer full during Redis forwarding",
-						"user_id", client.UserID,
-						"room_id", msg.RoomID)
-				}
+			if h.send(client, payload) {
+				forwardedCount++
 			}
 		}
 	}
@@ -79,6 +175,10 @@ func (h *Hub) handleRedisChatMessage(msg WsMessage) {
 }
 
 func (h *Hub) handleRedisTypingIndicator(msg WsMessage) {
+	if msg.NodeID == h.NodeID {
+		return
+	}
+
 	h.logger.Debug("Forwarding Redis typing indicator to local clients",
 		"sender", msg.Sender,
 		"room_id", msg.RoomID)
@@ -90,15 +190,8 @@ func (h *Hub) handleRedisTypingIndicator(msg WsMessage) {
 		payload := marshalMessage(msg)
 		for client := range room {
 			if client.UserID != msg.Sender {
-				select {
-				case client.Send <- payload:
+				if h.send(client, payload) {
 					forwardedCount++
-				default:
-					close(client.Send)
-					delete(room, client)
-					h.logger.Warn("Client buffer full during Redis typing forwarding",
-						"user_id", client.UserID,
-						"room_id", msg.RoomID)
 				}
 			}
 		}
@@ -110,7 +203,107 @@ func (h *Hub) handleRedisTypingIndicator(msg WsMessage) {
 		"forwarded_to", forwardedCount)
 }
 
+func (h *Hub) handleRedisChatUpdate(msg WsMessage) {
+	// Chat updates are only delivered through Redis, so this node's own updates
+	// are forwarded to its local clients as well
+	msg.NodeID = ""
+
+	h.logger.Debug("Forwarding Redis chat update to local clients",
+		"sender", msg.Sender,
+		"room_id", msg.RoomID)
+
+	// Forward to every local client in the chat, including the sender's devices
+	forwardedCount := 0
+	h.mu.RLock()
+	if room, ok := h.ChatRooms[msg.RoomID]; ok {
+		payload := marshalMessage(msg)
+		for client := range room {
+			if h.send(client, payload) {
+				forwardedCount++
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	h.logger.Debug("Redis chat update forwarded",
+		"room_id", msg.RoomID,
+		"forwarded_to", forwardedCount)
+}
+
+func (h *Hub) handleRedisMention(msg WsMessage) {
+	var event models.MentionEvent
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		h.logger.Error("Error unmarshaling Redis mention",
+			"error", err,
+			"room_id", msg.RoomID)
+		return
+	}
+
+	// Mentions are only delivered through Redis, so this node's own mentions are
+	// forwarded to its local clients as well
+	msg.NodeID = ""
+
+	// Deliver to every device of each mentioned member, whether or not the chat
+	// is open or muted there
+	forwardedCount := 0
+	h.mu.RLock()
+	payload := marshalMessage(msg)
+	for _, userID := range event.MentionedIDs {
+		for client := range h.Clients[userID] {
+			if !client.Supports(msg.Type) {
+				continue
+			}
+			if h.send(client, payload) {
+				forwardedCount++
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	h.logger.Debug("Redis mention forwarded",
+		"room_id", msg.RoomID,
+		"message_id", event.MessageID,
+		"forwarded_to", forwardedCount)
+}
+
+// handleRedisSessionEnded closes the local connections of an ended session. Their
+// ReadPumps then unregister them as for any other disconnect.
+func (h *Hub) handleRedisSessionEnded(msg WsMessage) {
+	var ended struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &ended); err != nil {
+		h.logger.Error("Error unmarshaling Redis session end",
+			"error", err,
+			"raw_payload", string(msg.Payload))
+		return
+	}
+
+	closed := 0
+	h.mu.RLock()
+	for client := range h.Clients[msg.Sender] {
+		if client.SessionID != ended.SessionID {
+			continue
+		}
+		client.Conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session ended"),
+			time.Now().Add(writeWait))
+		client.Conn.Close()
+		closed++
+	}
+	h.mu.RUnlock()
+
+	h.logger.Info("Session ended, clients disconnected",
+		"user_id", msg.Sender,
+		"session_id", ended.SessionID,
+		"closed", closed)
+}
+
 func (h *Hub) handleRedisStatusUpdate(msg WsMessage) {
+	if msg.NodeID == h.NodeID {
+		return
+	}
+
 	h.logger.Debug("Processing Redis status update")
 
 	// Forward to original sender if they're connected to this instance
@@ -146,15 +339,8 @@ func (h *Hub) handleRedisStatusUpdate(msg WsMessage) {
 	if userClients, ok := h.Clients[message.SenderID]; ok {
 		payload := marshalMessage(msg)
 		for client := range userClients {
-			select {
-			case client.Send <- payload:
+			if h.send(client, payload) {
 				forwardedCount++
-			default:
-				close(client.Send)
-				delete(userClients, client)
-				h.logger.Warn("Client buffer full during Redis status forwarding",
-					"user_id", client.UserID,
-					"message_id", statusUpdate.MessageID)
 			}
 		}
 	}
@@ -167,6 +353,10 @@ func (h *Hub) handleRedisStatusUpdate(msg WsMessage) {
 }
 
 func (h *Hub) handleRedisPresenceUpdate(msg WsMessage) {
+	if msg.NodeID == h.NodeID {
+		return
+	}
+
 	h.logger.Debug("Processing Redis presence update")
 
 	// Forward presence updates to all clients in relevant chats
@@ -204,16 +394,9 @@ func (h *Hub) handleRedisPresenceUpdate(msg WsMessage) {
 			payload := marshalMessage(msg)
 			for client := range room {
 				if client.UserID != presence.UserID {
-					select {
-					case client.Send <- payload:
+					if h.send(client, payload) {
 						forwardedInChat++
 						totalForwarded++
-					default:
-						close(client.Send)
-						delete(room, client)
-						h.logger.Warn("Client buffer full during Redis presence forwarding",
-							"user_id", client.UserID,
-							"chat_id", chat.ID)
 					}
 				}
 			}
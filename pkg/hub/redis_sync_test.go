@@ -0,0 +1,142 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// fakeSubscription stands in for a Redis Pub/Sub subscription
+type fakeSubscription struct {
+	receiveErr error
+	ch         chan *redis.Message
+	closed     atomic.Bool
+}
+
+func (f *fakeSubscription) Receive(context.Context) (interface{}, error) {
+	if f.receiveErr != nil {
+		return nil, f.receiveErr
+	}
+	return &redis.Subscription{Kind: "subscribe", Channel: "chat_sync", Count: 1}, nil
+}
+
+func (f *fakeSubscription) Channel(...redis.ChannelOption) <-chan *redis.Message {
+	return f.ch
+}
+
+func (f *fakeSubscription) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+func newSyncTestHub(ctx context.Context) *Hub {
+	s := &store.Store{
+		Ctx: ctx,
+		// Never dialed: subscriptions come from the fake
+		RDB: redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"}),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHub(s, nil, nil, nil, config.WebSocketConfig{}, config.ChatConfig{}, logger)
+}
+
+func TestListenToRedisOnceDroppedChannel(t *testing.T) {
+	h := newSyncTestHub(context.Background())
+
+	sub := &fakeSubscription{ch: make(chan *redis.Message, 1)}
+	// A message of no known type is skipped; the listener keeps reading after it
+	sub.ch <- &redis.Message{Channel: "chat_sync", Payload: `{"type":"unknown"}`}
+	close(sub.ch)
+	h.subscribe = func(context.Context) syncSubscription { return sub }
+
+	if !h.listenToRedisOnce() {
+		t.Fatal("listenToRedisOnce() = false, want true once the subscription was established")
+	}
+	if !sub.closed.Load() {
+		t.Error("subscription was not closed after its channel dropped")
+	}
+}
+
+func TestListenToRedisOnceSubscribeError(t *testing.T) {
+	h := newSyncTestHub(context.Background())
+
+	sub := &fakeSubscription{receiveErr: errors.New("connection refused")}
+	h.subscribe = func(context.Context) syncSubscription { return sub }
+
+	if h.listenToRedisOnce() {
+		t.Fatal("listenToRedisOnce() = true, want false when subscribing fails")
+	}
+	if !sub.closed.Load() {
+		t.Error("subscription was not closed after subscribing failed")
+	}
+}
+
+func TestListenToRedisResubscribesAfterDrop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newSyncTestHub(ctx)
+
+	var calls atomic.Int32
+	resubscribed := make(chan struct{})
+	h.subscribe = func(context.Context) syncSubscription {
+		sub := &fakeSubscription{ch: make(chan *redis.Message)}
+		if calls.Add(1) == 1 {
+			// The first subscription drops straight away
+			close(sub.ch)
+		} else {
+			close(resubscribed)
+		}
+		return sub
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.ListenToRedis()
+		close(done)
+	}()
+
+	select {
+	case <-resubscribed:
+	case <-time.After(redisReconnectMinBackoff + 5*time.Second):
+		t.Fatal("listener did not re-subscribe after the channel dropped")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenToRedis did not return after the context was cancelled")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("subscribed %d times, want 2", got)
+	}
+}
+
+func TestHandleRedisSessionEndedClosesOnlyThatSession(t *testing.T) {
+	h := newSendTestHub(time.Hour)
+	ended, other := newSendTestClient(t, h), newSendTestClient(t, h)
+	ended.SessionID, other.SessionID = "ended", "other"
+	h.Clients["user"] = map[*Client]bool{ended: true, other: true}
+
+	h.handleSyncMessage(WsMessage{
+		Type:    string(MessageTypeSessionEnded),
+		Sender:  "user",
+		Payload: marshalPayload(map[string]string{"session_id": "ended"}),
+	})
+
+	if err := ended.Conn.WriteMessage(websocket.TextMessage, []byte("ping")); err == nil {
+		t.Error("client of the ended session is still connected")
+	}
+	if err := other.Conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Errorf("client of another session was disconnected: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package hub
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WebSocket protocol versions. Clients that don't announce versions on connect
+// speak DefaultProtocolVersion.
+const (
+	ProtocolV1 = 1 // message, typing, presence, status_update, chat_update, error
+	ProtocolV2 = 2 // adds presence_snapshot, mention and resumed
+
+	DefaultProtocolVersion = ProtocolV1
+	LatestProtocolVersion  = ProtocolV2
+)
+
+// minVersions lists message types introduced after v1; anything not listed is v1
+var minVersions = map[MessageType]int{
+	MessageTypeSnapshot: ProtocolV2,
+	MessageTypeMention:  ProtocolV2,
+	MessageTypeResumed:  ProtocolV2,
+}
+
+func minVersion(msgType string) int {
+	if v, ok := minVersions[MessageType(msgType)]; ok {
+		return v
+	}
+	return ProtocolV1
+}
+
+// Supports reports whether the client's negotiated protocol understands msgType
+func (c *Client) Supports(msgType string) bool {
+	return c.Version >= minVersion(msgType)
+}
+
+// NegotiateVersion picks the highest protocol version in a comma-separated list
+// announced by the client (e.g. "1,2") that the server also speaks. An empty list
+// falls back to the default version; ok is false if nothing matches.
+func NegotiateVersion(announced string) (version int, ok bool) {
+	if strings.TrimSpace(announced) == "" {
+		return DefaultProtocolVersion, true
+	}
+
+	for _, part := range strings.Split(announced, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || v < ProtocolV1 || v > LatestProtocolVersion {
+			continue
+		}
+		if v > version {
+			version = v
+		}
+	}
+	return version, version != 0
+}
@@ -0,0 +1,28 @@
+package hub
+
+import "testing"
+
+func TestSupports(t *testing.T) {
+	tests := []struct {
+		msgType MessageType
+		v1, v2  bool
+	}{
+		{MessageTypeMessage, true, true},
+		{MessageTypeHello, true, true},
+		// Errors predate versioning, so clients that never announce versions get them
+		{MessageTypeError, true, true},
+		{MessageTypeSnapshot, false, true},
+		{MessageTypeMention, false, true},
+		{MessageTypeResumed, false, true},
+	}
+
+	v1, v2 := &Client{Version: ProtocolV1}, &Client{Version: ProtocolV2}
+	for _, tt := range tests {
+		if got := v1.Supports(string(tt.msgType)); got != tt.v1 {
+			t.Errorf("v1 client Supports(%q) = %v, want %v", tt.msgType, got, tt.v1)
+		}
+		if got := v2.Supports(string(tt.msgType)); got != tt.v2 {
+			t.Errorf("v2 client Supports(%q) = %v, want %v", tt.msgType, got, tt.v2)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+)
+
+// AdminTokenHeader carries the operator token for the admin API
+const AdminTokenHeader = "X-Admin-Token"
+
+// Admin guards operator-only routes with a shared token. When token is empty the
+// admin API is disabled and every request gets a 404, so an unconfigured
+// deployment doesn't advertise it.
+func Admin(next http.Handler, token string, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			logger.Warn("Admin API called but ADMIN_TOKEN is not set",
+				"method", r.Method, "path", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		provided := r.Header.Get(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Warn("Invalid admin token",
+				"method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		logger.Info("Admin request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/token"
+)
+
+// APIKeyHeader carries a bot's API key
+const APIKeyHeader = "X-API-Key"
+
+type contextKey string
+
+const apiKeyChatsKey contextKey = "api_key_chats"
+
+// APIKey authenticates bots. Requests carrying X-API-Key are resolved to the key's
+// bot user, which handlers then see through auth.GetUserID, and passed to next;
+// requests without it go to fallback, normally the JWT auth middleware. Bots may
+// only send messages; any other route is refused.
+func APIKey(next, fallback http.Handler, s *store.Store, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.Header.Get(APIKeyHeader)
+		if raw == "" {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost || r.URL.Path != "/api/messages" {
+			logger.Warn("API key used on a route bots can't call",
+				"method", r.Method, "path", r.URL.Path)
+			http.Error(w, "Not available to API keys", http.StatusForbidden)
+			return
+		}
+
+		key, err := s.UseAPIKey(token.HashAPIKey(raw))
+		if errors.Is(err, store.ErrNotFound) {
+			logger.Warn("Invalid API key", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to verify API key", "error", err)
+			http.Error(w, "Failed to verify API key", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Debug("API key authenticated", "key_id", key.ID, "bot_id", key.BotID)
+
+		ctx := context.WithValue(r.Context(), auth.UserIDKey, key.BotID)
+		ctx = context.WithValue(ctx, apiKeyChatsKey, key.ChatIDs)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// APIKeyChats returns the chats an API key request is limited to. ok is false for
+// requests authenticated any other way.
+func APIKeyChats(ctx context.Context) (chatIDs []string, ok bool) {
+	chatIDs, ok = ctx.Value(apiKeyChatsKey).([]string)
+	return chatIDs, ok
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// Session refuses access tokens whose session has ended. The JWT auth middleware
+// only checks the signature and expiry, so without this a token stays usable after
+// logout, eviction or revocation until it expires. It runs after that middleware,
+// which puts the token's session ID in the context.
+func Session(next http.Handler, s *store.Store, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID := auth.GetSessionID(r.Context())
+		if sessionID == "" {
+			logger.Warn("Access token without a session", "path", r.URL.Path)
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		session, err := s.GetUserSession(sessionID)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && !session.IsActive) {
+			logger.Warn("Access token for an ended session",
+				"path", r.URL.Path, "session_id", sessionID)
+			http.Error(w, "Session has ended; please log in again", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to verify session", "error", err, "session_id", sessionID)
+			http.Error(w, "Failed to verify session", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/msniranjan18/common/middleware/auth"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+	"github.com/msniranjan18/chit-chat/pkg/token"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestStore returns a store on the database named by TEST_DATABASE_URL, with the
+// schema applied, and skips the test when it isn't set
+func newTestStore(tb testing.TB) *store.Store {
+	tb.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if redisURL == "" {
+		redisURL = "redis://127.0.0.1:1"
+	}
+
+	s, err := store.NewStore(context.Background(), url, redisURL, true, 0, testLogger)
+	if err != nil {
+		tb.Fatalf("new store: %v", err)
+	}
+	tb.Cleanup(func() { s.DB.Close() })
+
+	if err := s.InitSchema(); err != nil {
+		tb.Fatalf("init schema: %v", err)
+	}
+	return s
+}
+
+// createTestSession creates a user with one session and returns an access token for it
+func createTestSession(tb testing.TB, s *store.Store) (*models.UserSession, string) {
+	tb.Helper()
+
+	user := &models.User{Phone: fmt.Sprintf("9%09d", rand.Int64N(1e9)), Name: "Test User"}
+	if err := s.CreateUser(user); err != nil {
+		tb.Fatalf("create user: %v", err)
+	}
+	session := &models.UserSession{UserID: user.ID, SessionID: uuid.New().String(), IPAddress: "127.0.0.1"}
+	if err := s.CreateUserSession(session); err != nil {
+		tb.Fatalf("create session: %v", err)
+	}
+
+	token.InitJWT("test-secret", 0)
+	accessToken, _, err := token.GenerateJWT(user.ID, session.SessionID)
+	if err != nil {
+		tb.Fatalf("generate JWT: %v", err)
+	}
+	return session, accessToken
+}
+
+// serveAuthenticated sends a request with accessToken through the JWT and session
+// middleware, as the API routes do, and returns the response code
+func serveAuthenticated(s *store.Store, accessToken string) int {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := auth.AuthMiddleware(Session(ok, s, testLogger))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/verify", nil)
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestSessionRejectsEndedSession(t *testing.T) {
+	s := newTestStore(t)
+	session, accessToken := createTestSession(t, s)
+
+	if code := serveAuthenticated(s, accessToken); code != http.StatusOK {
+		t.Fatalf("request with a live session = %d, want %d", code, http.StatusOK)
+	}
+
+	if err := s.DeleteSession(session.SessionID); err != nil {
+		t.Fatalf("delete session: %v", err)
+	}
+	if code := serveAuthenticated(s, accessToken); code != http.StatusUnauthorized {
+		t.Fatalf("request after the session ended = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestSessionRejectsSessionRevokedByRefreshTokenReuse(t *testing.T) {
+	s := newTestStore(t)
+	session, accessToken := createTestSession(t, s)
+
+	_, hash, err := token.NewRefreshToken()
+	if err != nil {
+		t.Fatalf("new refresh token: %v", err)
+	}
+	if err := s.CreateRefreshToken(session.UserID, session.SessionID, hash, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	_, rotated, err := token.NewRefreshToken()
+	if err != nil {
+		t.Fatalf("new refresh token: %v", err)
+	}
+	if _, _, err := s.RotateRefreshToken(hash, rotated, time.Now().Add(time.Hour), "127.0.0.1"); err != nil {
+		t.Fatalf("rotate refresh token: %v", err)
+	}
+
+	// Presenting the rotated-out token again revokes the session
+	_, _, err = s.RotateRefreshToken(hash, "unused", time.Now().Add(time.Hour), "127.0.0.1")
+	if !errors.Is(err, store.ErrRefreshTokenReused) {
+		t.Fatalf("reusing a refresh token = %v, want %v", err, store.ErrRefreshTokenReused)
+	}
+
+	if code := serveAuthenticated(s, accessToken); code != http.StatusUnauthorized {
+		t.Fatalf("request with the revoked session's JWT = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
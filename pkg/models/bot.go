@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Bot is a service account: a user without a phone login that acts through API
+// keys. It joins chats like any other user, by being added by an admin.
+// @name Bot
+type Bot struct {
+	ID        string    `json:"id"` // The bot's user ID
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// @name BotRequest
+type BotRequest struct {
+	Name string `json:"name"`
+}
+
+// APIKey authenticates a bot through the X-API-Key header. It can only send
+// messages, and only to the listed chats the bot is a member of.
+// @name APIKey
+type APIKey struct {
+	ID         string     `json:"id"`
+	BotID      string     `json:"bot_id"`
+	ChatIDs    []string   `json:"chat_ids"`
+	Key        string     `json:"key,omitempty"` // Only returned when the key is created
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// @name APIKeyRequest
+type APIKeyRequest struct {
+	ChatIDs []string `json:"chat_ids"`
+}
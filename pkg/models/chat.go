@@ -25,9 +25,29 @@ type Chat struct {
 	LastActivity time.Time `json:"last_activity" db:"last_activity"`
 	LastMessage  *Message  `json:"last_message,omitempty" db:"-"`
 	UnreadCount  int       `json:"unread_count,omitempty" db:"-"`
-	IsArchived   bool      `json:"is_archived" db:"is_archived"`
-	IsMuted      bool      `json:"is_muted" db:"is_muted"`
-	IsPinned     bool      `json:"is_pinned" db:"is_pinned"`
+	// Unread messages mentioning the user, counted even when the chat is muted
+	UnreadMentions int  `json:"unread_mentions,omitempty" db:"-"`
+	IsArchived     bool `json:"is_archived" db:"is_archived"`
+	IsMuted        bool `json:"is_muted" db:"is_muted"`             // Muted by the requesting member, not for everyone
+	IsPinned       bool `json:"is_pinned" db:"is_pinned"`           // Pinned by the requesting member, not for everyone
+	PinOrder       *int `json:"pin_order,omitempty" db:"pin_order"` // Position among the member's pinned chats, lowest first
+	// When the other participant left a direct chat, which is read-only from then on
+	ClosedAt *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+	// A direct chat with only the user in it, for saving notes and links
+	IsSelf bool `json:"is_self,omitempty" db:"-"`
+}
+
+// SelfChatName is shown as the name of a user's chat with themselves
+const SelfChatName = "Saved Messages"
+
+// MarkSelf flags c as the user's chat with themselves and names it, unless the
+// user gave it a name of their own
+func (c *Chat) MarkSelf() {
+	c.IsSelf = true
+	if c.Name == nil {
+		name := SelfChatName
+		c.Name = &name
+	}
 }
 
 // @name ChatMember
@@ -41,6 +61,70 @@ type ChatMember struct {
 	DisplayName *string    `json:"display_name,omitempty" db:"display_name"`
 	IsBanned    bool       `json:"is_banned" db:"is_banned"`
 	BannedUntil *time.Time `json:"banned_until,omitempty" db:"banned_until"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"` // Last role, ban or display name change
+}
+
+// IsChatAdmin reports whether the member can administer the chat, as an owner or
+// admin
+func (m *ChatMember) IsChatAdmin() bool {
+	return m.IsAdmin ||
+		m.Role == string(ChatMemberRoleOwner) ||
+		m.Role == string(ChatMemberRoleAdmin)
+}
+
+// Permissions works out what the member may do in chat. settings are the group's
+// settings, or nil for chats without any, which get the column defaults. Banned
+// members and viewers are read-only, and nobody can post to a closed direct chat.
+func (m *ChatMember) Permissions(chat *Chat, settings *GroupSettings) ChatPermissions {
+	if m.IsBanned {
+		return ChatPermissions{}
+	}
+
+	if chat.Type == ChatTypeDirect {
+		open := chat.ClosedAt == nil
+		return ChatPermissions{CanPost: open, CanPostMedia: open}
+	}
+
+	sendMessages, sendMedia, membersCanInvite, adminsCanEdit := true, true, true, true
+	if settings != nil {
+		sendMessages, sendMedia = settings.SendMessagesAllowed, settings.SendMediaAllowed
+		membersCanInvite, adminsCanEdit = settings.MembersCanInvite, settings.AdminsCanEdit
+	}
+
+	if m.IsChatAdmin() {
+		return ChatPermissions{
+			CanPost:         true,
+			CanPostMedia:    true,
+			CanAddMembers:   true,
+			CanEditSettings: m.Role == string(ChatMemberRoleOwner) || adminsCanEdit,
+		}
+	}
+	if m.Role == string(ChatMemberRoleViewer) {
+		return ChatPermissions{}
+	}
+	return ChatPermissions{
+		CanPost:       sendMessages,
+		CanPostMedia:  sendMessages && sendMedia,
+		CanAddMembers: membersCanInvite,
+	}
+}
+
+// ChatPermissions tells clients which actions to offer a member, so they don't
+// each derive them from roles and group settings
+// @name ChatPermissions
+type ChatPermissions struct {
+	CanPost         bool `json:"can_post"`
+	CanPostMedia    bool `json:"can_post_media"`
+	CanAddMembers   bool `json:"can_add_members"`
+	CanEditSettings bool `json:"can_edit_settings"`
+}
+
+// MyMembership is the requester's own membership in a chat
+// @name MyMembership
+type MyMembership struct {
+	ChatMember
+	IsMuted     bool            `json:"is_muted"`
+	Permissions ChatPermissions `json:"permissions"`
 }
 
 type ChatMemberRole string
@@ -52,6 +136,15 @@ const (
 	ChatMemberRoleViewer ChatMemberRole = "viewer"
 )
 
+// Valid reports whether r is one of the known member roles
+func (r ChatMemberRole) Valid() bool {
+	switch r {
+	case ChatMemberRoleOwner, ChatMemberRoleAdmin, ChatMemberRoleMember, ChatMemberRoleViewer:
+		return true
+	}
+	return false
+}
+
 // @name ChatRequest
 type ChatRequest struct {
 	Type        ChatType `json:"type"`
@@ -69,6 +162,10 @@ type ChatUpdateRequest struct {
 	IsArchived  *bool   `json:"is_archived,omitempty"`
 	IsMuted     *bool   `json:"is_muted,omitempty"`
 	IsPinned    *bool   `json:"is_pinned,omitempty"`
+	PinOrder    *int    `json:"pin_order,omitempty"` // Ignored unless the chat is or becomes pinned
+
+	// If set, the update only applies when the chat's updated_at still matches
+	ExpectedUpdatedAt *time.Time `json:"expected_updated_at,omitempty"`
 }
 
 // @name ChatMemberRequest
@@ -78,11 +175,152 @@ type ChatMemberRequest struct {
 	DisplayName *string `json:"display_name,omitempty"`
 }
 
+type ClearHistoryScope string
+
+const (
+	// ClearHistoryScopeMe hides existing messages for the requester only (default)
+	ClearHistoryScopeMe ClearHistoryScope = "me"
+	// ClearHistoryScopeEveryone deletes existing messages for all members
+	ClearHistoryScopeEveryone ClearHistoryScope = "everyone"
+)
+
+// @name MarkUnreadRequest
+type MarkUnreadRequest struct {
+	MessageID string `json:"message_id,omitempty"` // First message to show as unread; the latest from others when omitted
+}
+
+// @name ClearHistoryRequest
+type ClearHistoryRequest struct {
+	Scope ClearHistoryScope `json:"scope,omitempty"` // me (default) or everyone
+}
+
+// @name ChatMemberUpdateRequest
+type ChatMemberUpdateRequest struct {
+	DisplayName *string `json:"display_name"` // Empty or null clears the nickname
+}
+
+// @name ChatMemberRoleRequest
+type ChatMemberRoleRequest struct {
+	Role ChatMemberRole `json:"role"` // owner, admin, member or viewer
+}
+
+// MemberRoleCounts summarizes a chat's membership, so clients can show a member
+// count without loading every member
+// @name MemberRoleCounts
+type MemberRoleCounts struct {
+	ChatID  string `json:"chat_id"`
+	Total   int    `json:"total"`
+	Owners  int    `json:"owners"`
+	Admins  int    `json:"admins"`
+	Members int    `json:"members"`
+	Viewers int    `json:"viewers"`
+}
+
+// @name ChatMembersResponse
+type ChatMembersResponse struct {
+	Members    []ChatMember `json:"members"`
+	Total      int          `json:"total"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+	NextOffset *int         `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore    bool         `json:"has_more"`
+}
+
+type ChatUpdateEventType string
+
+const (
+	ChatUpdateEventMemberUpdated   ChatUpdateEventType = "member_updated"
+	ChatUpdateEventHistoryCleared  ChatUpdateEventType = "history_cleared"
+	ChatUpdateEventRoleChanged     ChatUpdateEventType = "role_changed"
+	ChatUpdateEventMessagesExpired ChatUpdateEventType = "messages_expired"
+	ChatUpdateEventSettingsUpdated ChatUpdateEventType = "settings_updated"
+)
+
+// ChatUpdateEvent is the payload of chat_update WebSocket messages
+// @name ChatUpdateEvent
+type ChatUpdateEvent struct {
+	Event  ChatUpdateEventType `json:"event"`
+	ChatID string              `json:"chat_id"`
+	UserID string              `json:"user_id,omitempty"` // User who made the change
+	Member *ChatMember         `json:"member,omitempty"`
+
+	MessageIDs []string       `json:"message_ids,omitempty"` // Disappearing messages that were purged
+	Settings   *GroupSettings `json:"settings,omitempty"`
+}
+
+type AuditAction string
+
+const (
+	AuditActionAdd          AuditAction = "add"
+	AuditActionRemove       AuditAction = "remove"
+	AuditActionBan          AuditAction = "ban"
+	AuditActionPromote      AuditAction = "promote"
+	AuditActionDemote       AuditAction = "demote"
+	AuditActionDelete       AuditAction = "delete"
+	AuditActionClearHistory AuditAction = "clear_history"
+)
+
+// AuditEvent is a moderation action recorded in a chat's audit log
+// @name AuditEvent
+type AuditEvent struct {
+	ID        string      `json:"id" db:"id"`
+	ChatID    string      `json:"chat_id" db:"chat_id"`
+	ActorID   string      `json:"actor_id" db:"actor_id"`
+	Action    AuditAction `json:"action" db:"action"`
+	TargetID  *string     `json:"target_id,omitempty" db:"target_id"` // User the action applied to, if any
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
+
+// @name AuditLogResponse
+type AuditLogResponse struct {
+	Events []AuditEvent `json:"events"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
 // @name ChatResponse
 type ChatResponse struct {
 	Chat    Chat         `json:"chat"`
 	Members []ChatMember `json:"members,omitempty"`
 	Users   []User       `json:"users,omitempty"`
+	// Direct chats only: the latest of the requester's messages the other
+	// participant has read, where clients show the "seen" marker
+	LastReadMessage *Message `json:"last_read_message,omitempty"`
+}
+
+// ChatMemberMatch is a member found by a member search, with the user's name and
+// avatar
+// @name ChatMemberMatch
+type ChatMemberMatch struct {
+	ChatMember
+	Name      string  `json:"name"`
+	AvatarURL *string `json:"avatar_url,omitempty"`
+}
+
+// ChatMemberSearchResponse holds the best matches of a member search
+// @name ChatMemberSearchResponse
+type ChatMemberSearchResponse struct {
+	Results []ChatMemberMatch `json:"results"`
+	Limit   int               `json:"limit"`
+	HasMore bool              `json:"has_more"` // More members match; refine the query to find them
+}
+
+// ChatSearchResponse is one page of chat search results
+// @name ChatSearchResponse
+type ChatSearchResponse struct {
+	Results    []Chat `json:"results"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextOffset *int   `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore    bool   `json:"has_more"`
+}
+
+// CacheRebuildResult reports what a chat cache rebuild re-warmed
+// @name CacheRebuildResult
+type CacheRebuildResult struct {
+	ChatID   string `json:"chat_id"`
+	Members  int    `json:"members"`  // Members cached, each with rebuilt unread counters
+	Messages int    `json:"messages"` // Messages in the re-warmed first page
 }
 
 // @name ChatListResponse
@@ -14,7 +14,8 @@ type GroupSettings struct {
 	MembersCanInvite    bool       `json:"members_can_invite" db:"members_can_invite"`
 	SendMediaAllowed    bool       `json:"send_media_allowed" db:"send_media_allowed"`
 	SendMessagesAllowed bool       `json:"send_messages_allowed" db:"send_messages_allowed"`
-	SlowModeDelay       int        `json:"slow_mode_delay" db:"slow_mode_delay"` // seconds
+	SlowModeDelay       int        `json:"slow_mode_delay" db:"slow_mode_delay"`   // seconds
+	DisappearingTTL     int        `json:"disappearing_ttl" db:"disappearing_ttl"` // seconds new messages last; 0 keeps them
 	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
 }
@@ -63,6 +64,7 @@ type GroupSettingsRequest struct {
 	SendMediaAllowed    *bool `json:"send_media_allowed,omitempty"`
 	SendMessagesAllowed *bool `json:"send_messages_allowed,omitempty"`
 	SlowModeDelay       *int  `json:"slow_mode_delay,omitempty"`
+	DisappearingTTL     *int  `json:"disappearing_ttl,omitempty"` // seconds; 0 turns disappearing messages off
 }
 
 // @name GroupUpdateRequest
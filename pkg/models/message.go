@@ -1,7 +1,11 @@
 package models
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // @name Message
@@ -10,6 +14,7 @@ type Message struct {
 	ChatID       string     `json:"chat_id" db:"chat_id"`
 	SenderID     string     `json:"sender_id" db:"sender_id"`
 	SenderName   string     `json:"sender_name,omitempty" db:"-"`
+	SenderAvatar *string    `json:"sender_avatar,omitempty" db:"-"`
 	Content      string     `json:"content" db:"content"`
 	ContentType  string     `json:"content_type" db:"content_type"`
 	MediaURL     *string    `json:"media_url,omitempty" db:"media_url"`
@@ -21,15 +26,34 @@ type Message struct {
 	DeliveredAt  *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
 	ReadAt       *time.Time `json:"read_at,omitempty" db:"read_at"`
 	ReplyTo      *string    `json:"reply_to,omitempty" db:"reply_to"`
-	ReplyMessage *Message   `json:"reply_message,omitempty" db:"-"`
+	ReplyMessage *Message   `json:"reply_message,omitempty" db:"-"` // Quoted parent, one level deep only
 	Forwarded    bool       `json:"forwarded" db:"forwarded"`
 	ForwardFrom  *string    `json:"forward_from,omitempty" db:"forward_from"`
+	ForwardName  string     `json:"forward_from_name,omitempty" db:"-"` // Display name of the original sender
 	IsEdited     bool       `json:"is_edited" db:"is_edited"`
 	EditedAt     *time.Time `json:"edited_at,omitempty" db:"edited_at"`
 	IsDeleted    bool       `json:"is_deleted" db:"is_deleted"`
 	DeletedAt    *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"` // Disappearing messages are purged after this
+
+	Mentions            []string `json:"mentions,omitempty" db:"-"`             // Members mentioned with @{userID}; set when the message is sent
+	ForwardCount        int      `json:"forward_count" db:"forward_count"`      // Times this message was forwarded
+	ReadCount           int      `json:"read_count" db:"read_count"`            // Members other than the sender who have read it
+	FrequentlyForwarded bool     `json:"frequently_forwarded,omitempty" db:"-"` // Forwarded past the configured threshold
+}
+
+// AsReplyQuote returns a copy of m for use as another message's ReplyMessage.
+// Quotes are capped at one level: the parent is shown, but not the message the
+// parent itself replied to, so reply chains can't nest without bound.
+func (m Message) AsReplyQuote() *Message {
+	m.ReplyMessage = nil
+	return &m
 }
 
+// DeletedMessagePlaceholder replaces the content of a deleted message where it is
+// still shown, such as a chat's last message preview
+const DeletedMessagePlaceholder = "This message was deleted"
+
 type MessageStatus string
 
 const (
@@ -39,6 +63,15 @@ const (
 	MessageStatusFailed    MessageStatus = "failed"
 )
 
+// Valid reports whether s is one of the known message statuses
+func (s MessageStatus) Valid() bool {
+	switch s {
+	case MessageStatusSent, MessageStatusDelivered, MessageStatusRead, MessageStatusFailed:
+		return true
+	}
+	return false
+}
+
 type ContentType string
 
 const (
@@ -52,6 +85,100 @@ const (
 	ContentTypeSticker  ContentType = "sticker"
 )
 
+// IsMedia reports whether messages of this type carry an attachment in media_url,
+// with content used as the caption
+func (ct ContentType) IsMedia() bool {
+	switch ct {
+	case ContentTypeImage, ContentTypeVideo, ContentTypeAudio, ContentTypeDocument, ContentTypeSticker:
+		return true
+	}
+	return false
+}
+
+// MessageMedia is the attachment a media message carries
+// @name MessageMedia
+type MessageMedia struct {
+	MediaURL     *string `json:"media_url,omitempty"`
+	ThumbnailURL *string `json:"thumbnail_url,omitempty"`
+	FileSize     *int64  `json:"file_size,omitempty"` // Bytes
+	Duration     *int    `json:"duration,omitempty"`  // Seconds; required for audio and video
+}
+
+// Validate checks the attachment against the message's content type and returns
+// why it is unacceptable, or "" if it is fine. Media types need a loadable
+// media_url; images, documents and stickers must fit in maxFileSize, and audio
+// and video must give a duration no longer than maxDuration. Other types may not
+// carry an attachment. A zero limit is not enforced.
+func (m MessageMedia) Validate(contentType ContentType, maxFileSize int64, maxDuration time.Duration) string {
+	if !contentType.IsMedia() {
+		if m.MediaURL != nil || m.ThumbnailURL != nil || m.FileSize != nil || m.Duration != nil {
+			return fmt.Sprintf("Messages of type %q cannot carry media", contentType)
+		}
+		return ""
+	}
+
+	if !ValidMediaURL(m.MediaURL) {
+		return "A valid media_url is required for media messages"
+	}
+	if m.ThumbnailURL != nil && !ValidMediaURL(m.ThumbnailURL) {
+		return "Invalid thumbnail_url"
+	}
+	if m.FileSize != nil && *m.FileSize <= 0 {
+		return "file_size must be positive"
+	}
+
+	switch contentType {
+	case ContentTypeAudio, ContentTypeVideo:
+		if m.Duration == nil {
+			return fmt.Sprintf("duration is required for %s messages", contentType)
+		}
+		if *m.Duration <= 0 {
+			return "duration must be positive"
+		}
+		if maxDuration > 0 && time.Duration(*m.Duration)*time.Second > maxDuration {
+			return fmt.Sprintf("%s messages may be at most %d seconds long", contentType, int(maxDuration.Seconds()))
+		}
+	default:
+		if m.Duration != nil {
+			return fmt.Sprintf("duration does not apply to %s messages", contentType)
+		}
+		if maxFileSize > 0 && m.FileSize != nil && *m.FileSize > maxFileSize {
+			return fmt.Sprintf("%s messages may be at most %d bytes", contentType, maxFileSize)
+		}
+	}
+	return ""
+}
+
+// ValidMediaURL reports whether a media message's URL points at something a client
+// can load: an absolute http(s) URL or a path on this server
+func ValidMediaURL(mediaURL *string) bool {
+	if mediaURL == nil || *mediaURL == "" {
+		return false
+	}
+	u, err := url.Parse(*mediaURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		return u.Host == "" && len(u.Path) > 0 && u.Path[0] == '/'
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// SanitizeContent removes what malformed clients put in message text that breaks
+// rendering and logs: control characters other than newlines and tabs, and
+// invalid UTF-8. Trailing whitespace is trimmed as well.
+func SanitizeContent(content string) string {
+	content = strings.ToValidUTF8(content, "")
+	content = strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, content)
+	return strings.TrimRightFunc(content, unicode.IsSpace)
+}
+
 // @name MessageRequest
 type MessageRequest struct {
 	ChatID      string  `json:"chat_id"`
@@ -60,11 +187,82 @@ type MessageRequest struct {
 	ReplyTo     *string `json:"reply_to,omitempty"`
 	ForwardFrom *string `json:"forward_from,omitempty"`
 	Forwarded   bool    `json:"forwarded,omitempty"`
+	MessageMedia
+}
+
+// Draft returns the message the request would create, before it is saved
+func (r *MessageRequest) Draft(senderID string) *Message {
+	return &Message{
+		ChatID:       r.ChatID,
+		SenderID:     senderID,
+		Content:      r.Content,
+		ContentType:  r.ContentType,
+		MediaURL:     r.MediaURL,
+		ThumbnailURL: r.ThumbnailURL,
+		FileSize:     r.FileSize,
+		Duration:     r.Duration,
+		ReplyTo:      r.ReplyTo,
+		Forwarded:    r.Forwarded,
+		ForwardFrom:  r.ForwardFrom,
+	}
+}
+
+// DeleteScope is who a deleted message is removed for
+type DeleteScope string
+
+const (
+	DeleteScopeEveryone DeleteScope = "everyone"
+	DeleteScopeMe       DeleteScope = "me" // Hidden for the requester; everyone else still sees it
+)
+
+// MessageDeleteResponse explains a delete for everyone that was turned into a
+// delete for the requester only
+// @name MessageDeleteResponse
+type MessageDeleteResponse struct {
+	MessageID string      `json:"message_id"`
+	Scope     DeleteScope `json:"scope"`
+	Message   string      `json:"message"`
 }
 
 // @name MessageUpdateRequest
 type MessageUpdateRequest struct {
-	Content string `json:"content,omitempty"`
+	Content     string `json:"content,omitempty"`      // New text, or caption for media messages; media captions may be cleared
+	ContentType string `json:"content_type,omitempty"` // Optional; must match the message's current type
+}
+
+// MentionEvent is the payload of mention WebSocket messages, sent to members
+// mentioned in a new message
+// @name MentionEvent
+type MentionEvent struct {
+	ChatID       string   `json:"chat_id"`
+	MessageID    string   `json:"message_id"`
+	SenderID     string   `json:"sender_id"`
+	Content      string   `json:"content"`
+	MentionedIDs []string `json:"mentioned_ids"`
+}
+
+// ResumeCursor is the last message a client saw in a chat before it lost its
+// connection
+// @name ResumeCursor
+type ResumeCursor struct {
+	ChatID string `json:"chat_id"`
+	After  string `json:"after,omitempty"` // Message ID; empty replays the chat from the start of its visible history
+}
+
+// ResumeRequest is the payload of a resume WebSocket message, sent by a client
+// after reconnecting to get the messages it missed
+// @name ResumeRequest
+type ResumeRequest struct {
+	Chats []ResumeCursor `json:"chats"`
+}
+
+// ResumeResult is the payload of a resumed WebSocket message, sent once a chat's
+// missed messages have been replayed
+// @name ResumeResult
+type ResumeResult struct {
+	ChatID  string `json:"chat_id"`
+	Count   int    `json:"count"`    // Messages replayed
+	HasMore bool   `json:"has_more"` // More were missed than one resume replays; fetch the rest over REST
 }
 
 // @name MessageStatusUpdate
@@ -81,6 +279,26 @@ type TypingIndicator struct {
 	IsTyping bool   `json:"is_typing"`
 }
 
+// TypingRequest starts or stops a typing indicator over REST
+// @name TypingRequest
+type TypingRequest struct {
+	IsTyping bool `json:"is_typing"`
+}
+
+// @name ForwardRequest
+type ForwardRequest struct {
+	ChatIDs []string `json:"chat_ids"`
+	Comment string   `json:"comment,omitempty"` // Sent in each chat as a text reply to the forwarded copy
+}
+
+// @name ForwardResponse
+type ForwardResponse struct {
+	Messages            []Message `json:"messages"`           // The new copies, one per destination chat
+	Comments            []Message `json:"comments,omitempty"` // The comment sent with each copy, in the same order
+	ForwardCount        int       `json:"forward_count"`      // Source message's forward count after this request
+	FrequentlyForwarded bool      `json:"frequently_forwarded"`
+}
+
 // @name MessageResponse
 type MessageResponse struct {
 	Message  Message `json:"message"`
@@ -93,3 +311,81 @@ type BulkMessageStatusUpdate struct {
 	MessageIDs []string `json:"message_ids"`
 	Status     string   `json:"status"`
 }
+
+// @name MessageStatusEntry
+type MessageStatusEntry struct {
+	UserID    string    `json:"user_id"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// @name MessageStatusSummary
+type MessageStatusSummary struct {
+	MessageID   string               `json:"message_id"`
+	DeliveredTo []MessageStatusEntry `json:"delivered_to"`
+	ReadBy      []MessageStatusEntry `json:"read_by"`
+}
+
+// MessageSearchResponse is one page of message search results
+// @name MessageSearchResponse
+type MessageSearchResponse struct {
+	Results    []Message `json:"results"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	NextOffset *int      `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore    bool      `json:"has_more"`
+}
+
+// MediaItem is one attachment in a chat's media gallery
+// @name MediaItem
+type MediaItem struct {
+	MessageID    string    `json:"message_id"`
+	ChatID       string    `json:"chat_id"`
+	ContentType  string    `json:"content_type"`
+	MediaURL     string    `json:"media_url"`
+	ThumbnailURL *string   `json:"thumbnail_url,omitempty"`
+	FileSize     *int64    `json:"file_size,omitempty"`
+	Duration     *int      `json:"duration,omitempty"`
+	Caption      string    `json:"caption,omitempty"`
+	SenderID     string    `json:"sender_id"`
+	SentAt       time.Time `json:"sent_at"`
+}
+
+// @name MediaResponse
+type MediaResponse struct {
+	Items      []MediaItem `json:"items"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	NextOffset *int        `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore    bool        `json:"has_more"`
+}
+
+// @name MessagesByStatusResponse
+type MessagesByStatusResponse struct {
+	Status   MessageStatus `json:"status"`
+	Messages []Message     `json:"messages"`
+	Limit    int           `json:"limit"`
+}
+
+// UnreadMessagesResponse is a page of the messages a member hasn't read in a chat,
+// oldest first
+// @name UnreadMessagesResponse
+type UnreadMessagesResponse struct {
+	ChatID        string    `json:"chat_id"`
+	Messages      []Message `json:"messages"`
+	FirstUnreadID *string   `json:"first_unread_id,omitempty"` // Where the "new messages" divider goes; only on the first page
+	Limit         int       `json:"limit"`
+	Offset        int       `json:"offset"`
+	NextOffset    *int      `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore       bool      `json:"has_more"`
+}
+
+// @name MessagesAround
+type MessagesAround struct {
+	Messages      []Message `json:"messages"`
+	TargetID      string    `json:"target_id"`
+	HasMoreBefore bool      `json:"has_more_before"`
+	HasMoreAfter  bool      `json:"has_more_after"`
+	BeforeCursor  *string   `json:"before_cursor,omitempty"` // Oldest returned message ID, set when older messages exist
+	AfterCursor   *string   `json:"after_cursor,omitempty"`  // Newest returned message ID, set when newer messages exist
+}
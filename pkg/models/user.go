@@ -15,17 +15,40 @@ type User struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	IsOnline  bool      `json:"is_online,omitempty" db:"-"`
+
+	// Name the requester saved this user under in their contacts, if any
+	DisplayName string `json:"display_name,omitempty" db:"-"`
+
+	// Only returned to the user themselves
+	PrivacyMessages string `json:"privacy_messages,omitempty" db:"privacy_messages"`
 }
 
+type MessagePrivacy string
+
+const (
+	MessagePrivacyEveryone MessagePrivacy = "everyone"
+	MessagePrivacyContacts MessagePrivacy = "contacts"
+)
+
 // @name UserSession
 type UserSession struct {
 	UserID     string    `json:"user_id" db:"user_id"`
 	SessionID  string    `json:"session_id" db:"session_id"`
-	DeviceInfo string    `json:"device_info,omitempty" db:"device_info"`
-	IPAddress  string    `json:"ip_address,omitempty" db:"ip_address"`
+	DeviceInfo string    `json:"device_info,omitempty" db:"device_info"` // Raw User-Agent
+	Browser    string    `json:"browser,omitempty" db:"browser"`
+	OS         string    `json:"os,omitempty" db:"os"`
+	DeviceName string    `json:"device_name,omitempty" db:"device_name"` // e.g. "Chrome on Windows"
+	Label      *string   `json:"label,omitempty" db:"label"`             // Name chosen by the user
+	IPAddress  string    `json:"ip_address,omitempty" db:"ip_address"`   // Address of the latest login or token refresh
 	LastActive time.Time `json:"last_active" db:"last_active"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 	IsActive   bool      `json:"is_active" db:"is_active"`
+	IsCurrent  bool      `json:"is_current" db:"-"` // Session the request was made with
+}
+
+// @name SessionUpdateRequest
+type SessionUpdateRequest struct {
+	Label *string `json:"label"` // Empty or null clears the label
 }
 
 // @name Contact
@@ -36,6 +59,68 @@ type Contact struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
+// @name ContactRequest
+type ContactRequest struct {
+	UserID      string  `json:"user_id"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Mutual      bool    `json:"mutual,omitempty"` // Also add the requester to the target's contacts
+}
+
+// @name ContactResponse
+type ContactResponse struct {
+	Message string `json:"message"`
+	Created bool   `json:"created"` // False if the contact already existed
+	Mutual  bool   `json:"mutual"`  // True if both users have each other as contacts
+}
+
+// BulkContactEntry is one contact to import, named by user ID or phone number
+// @name BulkContactEntry
+type BulkContactEntry struct {
+	UserID      string  `json:"user_id,omitempty"`
+	Phone       string  `json:"phone,omitempty"` // Used when user_id is empty
+	DisplayName *string `json:"display_name,omitempty"`
+}
+
+// @name BulkContactRequest
+type BulkContactRequest struct {
+	Contacts []BulkContactEntry `json:"contacts"`
+}
+
+type BulkContactStatus string
+
+const (
+	BulkContactAdded    BulkContactStatus = "added"
+	BulkContactSkipped  BulkContactStatus = "skipped"
+	BulkContactNotFound BulkContactStatus = "not_found"
+)
+
+// BulkContactResult reports what happened to the entry at the same position in the
+// request
+// @name BulkContactResult
+type BulkContactResult struct {
+	UserID string            `json:"user_id,omitempty"` // The resolved user, when there is one
+	Phone  string            `json:"phone,omitempty"`
+	Status BulkContactStatus `json:"status"`
+	Reason string            `json:"reason,omitempty"` // Why the entry was skipped
+}
+
+// @name BulkContactResponse
+type BulkContactResponse struct {
+	Results []BulkContactResult `json:"results"`
+	Added   int                 `json:"added"`
+}
+
+// UserSearchResponse is one page of user search results
+// @name UserSearchResponse
+type UserSearchResponse struct {
+	Results    []User `json:"results"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextOffset *int   `json:"next_offset,omitempty"` // Offset of the next page; absent on the last page
+	HasMore    bool   `json:"has_more"`
+	Total      int    `json:"total"`
+}
+
 // @name UserPresence
 type UserPresence struct {
 	UserID   string    `json:"user_id"`
@@ -43,6 +128,194 @@ type UserPresence struct {
 	LastSeen time.Time `json:"last_seen,omitempty"`
 }
 
+// ConnectionsSnapshot lists the WebSocket clients connected to one instance
+// @name ConnectionsSnapshot
+type ConnectionsSnapshot struct {
+	NodeID          string            `json:"node_id"`
+	Users           []UserConnections `json:"users"`
+	ClientCount     int               `json:"client_count"`
+	SlowClientDrops int64             `json:"slow_client_drops"` // Clients dropped since startup for not draining their send buffer
+}
+
+// @name UserConnections
+type UserConnections struct {
+	UserID      string             `json:"user_id"`
+	ClientCount int                `json:"client_count"`
+	Clients     []ClientConnection `json:"clients"`
+}
+
+// @name ClientConnection
+type ClientConnection struct {
+	SessionID string   `json:"session_id"`
+	Version   int      `json:"version"`     // Negotiated protocol version
+	ChatIDs   []string `json:"chat_ids"`    // Chat rooms the client has joined
+	LastRTTMs int64    `json:"last_rtt_ms"` // Round trip of the last ping; zero before the first pong
+}
+
+// PresenceSnapshot is pushed to a client right after it connects
+// @name PresenceSnapshot
+type PresenceSnapshot struct {
+	OnlineUserIDs []string `json:"online_user_ids"`
+}
+
+// UnreadSummary totals unread messages across all of a user's chats
+// @name UnreadSummary
+type UnreadSummary struct {
+	TotalUnread int `json:"total_unread"`
+	UnreadChats int `json:"unread_chats"` // Chats with at least one unread message
+}
+
+// NotificationSettings are a user's notification preferences, shared by all of
+// their devices. Quiet hours are "HH:MM" wall-clock times in Timezone and may
+// wrap past midnight.
+// @name NotificationSettings
+type NotificationSettings struct {
+	MuteAll         bool       `json:"mute_all"`
+	Sound           bool       `json:"sound"`
+	PreviewEnabled  bool       `json:"preview_enabled"` // Show message content in notifications
+	QuietHoursStart *string    `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   *string    `json:"quiet_hours_end,omitempty"`
+	Timezone        string     `json:"timezone"`             // IANA name the quiet hours are in
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"` // Absent while the defaults apply
+}
+
+// DefaultNotificationSettings are the settings of a user who never changed them
+func DefaultNotificationSettings() *NotificationSettings {
+	return &NotificationSettings{Sound: true, PreviewEnabled: true, Timezone: "UTC"}
+}
+
+// quietHoursLayout is the wall-clock format of quiet hours
+const quietHoursLayout = "15:04"
+
+// Validate returns why the settings are unacceptable, or "" if they are fine
+func (s *NotificationSettings) Validate() string {
+	if (s.QuietHoursStart == nil) != (s.QuietHoursEnd == nil) {
+		return "quiet_hours_start and quiet_hours_end must be set together"
+	}
+	if s.QuietHoursStart != nil {
+		start, err := time.Parse(quietHoursLayout, *s.QuietHoursStart)
+		if err != nil {
+			return "quiet_hours_start must be HH:MM"
+		}
+		end, err := time.Parse(quietHoursLayout, *s.QuietHoursEnd)
+		if err != nil {
+			return "quiet_hours_end must be HH:MM"
+		}
+		if start.Equal(end) {
+			return "quiet hours must not start and end at the same time"
+		}
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		return "timezone must be an IANA time zone name"
+	}
+	return ""
+}
+
+// InQuietHours reports whether t falls within the quiet hours, if any are set
+func (s *NotificationSettings) InQuietHours(t time.Time) bool {
+	if s.QuietHoursStart == nil || s.QuietHoursEnd == nil {
+		return false
+	}
+	start, err := time.Parse(quietHoursLayout, *s.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(quietHoursLayout, *s.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	// Wraps past midnight, e.g. 22:00 to 07:00
+	return minute >= from || minute < to
+}
+
+// NotificationSettingsRequest changes notification settings. Omitted fields keep
+// their current value; an empty quiet_hours_start and quiet_hours_end clear the
+// quiet hours.
+// @name NotificationSettingsRequest
+type NotificationSettingsRequest struct {
+	MuteAll         *bool   `json:"mute_all,omitempty"`
+	Sound           *bool   `json:"sound,omitempty"`
+	PreviewEnabled  *bool   `json:"preview_enabled,omitempty"`
+	QuietHoursStart *string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   *string `json:"quiet_hours_end,omitempty"`
+	Timezone        *string `json:"timezone,omitempty"`
+}
+
+// Apply returns settings with the request's changes applied
+func (r *NotificationSettingsRequest) Apply(settings NotificationSettings) *NotificationSettings {
+	if r.MuteAll != nil {
+		settings.MuteAll = *r.MuteAll
+	}
+	if r.Sound != nil {
+		settings.Sound = *r.Sound
+	}
+	if r.PreviewEnabled != nil {
+		settings.PreviewEnabled = *r.PreviewEnabled
+	}
+	if r.QuietHoursStart != nil {
+		settings.QuietHoursStart = r.QuietHoursStart
+		if *r.QuietHoursStart == "" {
+			settings.QuietHoursStart = nil
+		}
+	}
+	if r.QuietHoursEnd != nil {
+		settings.QuietHoursEnd = r.QuietHoursEnd
+		if *r.QuietHoursEnd == "" {
+			settings.QuietHoursEnd = nil
+		}
+	}
+	if r.Timezone != nil {
+		settings.Timezone = *r.Timezone
+	}
+	return &settings
+}
+
+// PushPlatform is the kind of device a push token belongs to, which decides the
+// service it is delivered through
+type PushPlatform string
+
+const (
+	PushPlatformAndroid PushPlatform = "android" // Firebase Cloud Messaging
+	PushPlatformWeb     PushPlatform = "web"     // Firebase Cloud Messaging
+	PushPlatformIOS     PushPlatform = "ios"     // Apple Push Notification service
+)
+
+func (p PushPlatform) Valid() bool {
+	switch p {
+	case PushPlatformAndroid, PushPlatformWeb, PushPlatformIOS:
+		return true
+	}
+	return false
+}
+
+// PushToken is a device's registration for push notifications
+// @name PushToken
+type PushToken struct {
+	Token     string       `json:"token"`
+	UserID    string       `json:"user_id"`
+	Platform  PushPlatform `json:"platform"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"` // Last time the device registered it
+}
+
+// @name PushTokenRequest
+type PushTokenRequest struct {
+	Token    string       `json:"token"`
+	Platform PushPlatform `json:"platform"`
+}
+
 // @name AuthRequest
 type AuthRequest struct {
 	Phone    string `json:"phone"`
@@ -51,14 +324,38 @@ type AuthRequest struct {
 }
 
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	User      User      `json:"user"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token            string    `json:"token"`
+	User             User      `json:"user"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// @name RefreshRequest
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// @name TokenResponse
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"` // Replaces the one sent; the old token can't be used again
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
 }
 
 type UserUpdateRequest struct {
-	Name   *string `json:"name,omitempty"`
-	Status *string `json:"status,omitempty"`
+	Name            *string `json:"name,omitempty"`
+	Status          *string `json:"status,omitempty"`
+	PrivacyMessages *string `json:"privacy_messages,omitempty"` // everyone or contacts
+
+	// If set, the update only applies when the user's updated_at still matches
+	ExpectedUpdatedAt *time.Time `json:"expected_updated_at,omitempty"`
+}
+
+// @name UserLookupRequest
+type UserLookupRequest struct {
+	Phones []string `json:"phones"`
 }
 
 type SearchUserRequest struct {
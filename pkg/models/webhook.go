@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type WebhookEventType string
+
+const (
+	WebhookEventMessageSent      WebhookEventType = "message.sent"
+	WebhookEventMessageDelivered WebhookEventType = "message.delivered"
+	WebhookEventMessageRead      WebhookEventType = "message.read"
+)
+
+// Valid reports whether t is an event webhooks can subscribe to
+func (t WebhookEventType) Valid() bool {
+	switch t {
+	case WebhookEventMessageSent, WebhookEventMessageDelivered, WebhookEventMessageRead:
+		return true
+	}
+	return false
+}
+
+// WebhookEventForStatus maps a message status receipt to its webhook event
+func WebhookEventForStatus(status string) (WebhookEventType, bool) {
+	switch MessageStatus(status) {
+	case MessageStatusDelivered:
+		return WebhookEventMessageDelivered, true
+	case MessageStatusRead:
+		return WebhookEventMessageRead, true
+	}
+	return "", false
+}
+
+// Webhook is an endpoint that receives events from the chats its owner belongs to
+// @name Webhook
+type Webhook struct {
+	ID        string             `json:"id" db:"id"`
+	UserID    string             `json:"user_id" db:"user_id"`
+	URL       string             `json:"url" db:"url"`
+	Secret    string             `json:"secret,omitempty" db:"secret"` // Only returned when the webhook is created
+	Events    []WebhookEventType `json:"events" db:"events"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+}
+
+// @name WebhookRequest
+type WebhookRequest struct {
+	URL    string             `json:"url"`
+	Events []WebhookEventType `json:"events"`
+	Secret string             `json:"secret,omitempty"` // Generated when omitted
+}
+
+// WebhookEvent is the JSON body POSTed to webhooks. Deliveries carry the event type
+// in X-ChitChat-Event and an HMAC-SHA256 of the body, keyed with the webhook's
+// secret, in X-ChitChat-Signature as "sha256=<hex>".
+// @name WebhookEvent
+type WebhookEvent struct {
+	ID         string           `json:"id"` // Unique per event; repeated on retries
+	Event      WebhookEventType `json:"event"`
+	ChatID     string           `json:"chat_id"`
+	MessageID  string           `json:"message_id,omitempty"` // Empty when a whole chat was marked read
+	UserID     string           `json:"user_id"`              // Sender for message.sent, recipient otherwise
+	Message    *Message         `json:"message,omitempty"`    // Set for message.sent
+	OccurredAt time.Time        `json:"occurred_at"`
+}
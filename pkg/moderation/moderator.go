@@ -0,0 +1,69 @@
+// Package moderation checks outgoing messages against the operator's content policy
+// before they are saved.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// MessageModerator decides whether a message may be sent. It sees the message as
+// the sender submitted it, before it has an ID or timestamps. When it refuses,
+// reason is shown to the sender.
+type MessageModerator interface {
+	Check(ctx context.Context, message *models.Message) (allow bool, reason string)
+}
+
+// New returns the moderator configured by cfg: a KeywordModerator when blocked
+// patterns are set, otherwise one that allows everything
+func New(cfg config.ModerationConfig) (MessageModerator, error) {
+	if strings.TrimSpace(cfg.BlockedPatterns) == "" {
+		return Noop{}, nil
+	}
+	return NewKeywordModerator(strings.Split(cfg.BlockedPatterns, ","), cfg.RejectReason)
+}
+
+// Noop allows every message
+type Noop struct{}
+
+func (Noop) Check(context.Context, *models.Message) (bool, string) {
+	return true, ""
+}
+
+// KeywordModerator rejects messages whose content matches any of its patterns
+type KeywordModerator struct {
+	patterns []*regexp.Regexp
+	reason   string
+}
+
+// NewKeywordModerator compiles patterns as case-insensitive regular expressions,
+// skipping blank ones. Messages matching any of them are rejected with reason.
+func NewKeywordModerator(patterns []string, reason string) (*KeywordModerator, error) {
+	m := &KeywordModerator{reason: reason}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern %q: %w", pattern, err)
+		}
+		m.patterns = append(m.patterns, re)
+	}
+	return m, nil
+}
+
+func (m *KeywordModerator) Check(_ context.Context, message *models.Message) (bool, string) {
+	for _, re := range m.patterns {
+		if re.MatchString(message.Content) {
+			return false, m.reason
+		}
+	}
+	return true, ""
+}
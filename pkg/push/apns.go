@@ -0,0 +1,156 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	apnsProductionHost = "https://api.push.apple.com"
+	apnsSandboxHost    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and throttles ones renewed
+	// more often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNs sends notifications through the Apple Push Notification service using
+// token-based authentication
+type APNs struct {
+	keyID  string
+	teamID string
+	topic  string
+	host   string
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu       sync.Mutex
+	jwt      string
+	issuedAt time.Time
+}
+
+// NewAPNs loads the .p8 signing key created in the Apple developer account
+func NewAPNs(keyFile, keyID, teamID, topic string, sandbox bool, client *http.Client) (*APNs, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, fmt.Errorf("APNs needs a key ID, team ID and topic")
+	}
+
+	raw, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read APNs key: %w", err)
+	}
+	key, err := jwtlib.ParseECPrivateKeyFromPEM(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse APNs key: %w", err)
+	}
+
+	host := apnsProductionHost
+	if sandbox {
+		host = apnsSandboxHost
+	}
+
+	return &APNs{
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		host:   host,
+		key:    key,
+		client: client,
+	}, nil
+}
+
+func (a *APNs) Send(ctx context.Context, token string, notification Notification) error {
+	providerToken, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+
+	aps := map[string]any{
+		"alert":     map[string]string{"title": notification.Title, "body": notification.Body},
+		"thread-id": notification.ChatID,
+	}
+	if !notification.Silent {
+		aps["sound"] = "default"
+	}
+	body, err := json.Marshal(map[string]any{
+		"aps":        aps,
+		"chat_id":    notification.ChatID,
+		"message_id": notification.MessageID,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-collapse-id", notification.MessageID)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		failure.Reason == "BadDeviceToken",
+		failure.Reason == "Unregistered",
+		failure.Reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	case failure.Reason == "ExpiredProviderToken":
+		a.mu.Lock()
+		a.jwt = ""
+		a.mu.Unlock()
+	}
+	return fmt.Errorf("APNs responded %d: %s", resp.StatusCode, failure.Reason)
+}
+
+// providerToken returns the signed JWT APNs authenticates requests with, renewing
+// it before Apple considers it expired
+func (a *APNs) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jwt != "" && time.Since(a.issuedAt) < apnsTokenLifetime {
+		return a.jwt, nil
+	}
+
+	now := time.Now()
+	token := jwtlib.NewWithClaims(jwtlib.SigningMethodES256, jwtlib.MapClaims{
+		"iss": a.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = a.keyID
+
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		return "", fmt.Errorf("sign APNs provider token: %w", err)
+	}
+
+	a.jwt = signed
+	a.issuedAt = now
+	return a.jwt, nil
+}
@@ -0,0 +1,216 @@
+package push
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// previewLength caps how much of a message's text a notification shows
+const previewLength = 100
+
+// hiddenPreview replaces the message text for users who turned previews off
+const hiddenPreview = "New message"
+
+// delivery is one notification queued for one device
+type delivery struct {
+	token        models.PushToken
+	notification Notification
+	attempt      int
+}
+
+// Dispatcher turns new messages into push notifications for members who are not
+// connected, honoring their notification settings and chat mutes. Pushes go
+// through a bounded queue drained by a fixed set of workers, and failures are put
+// back on it with doubling delays.
+type Dispatcher struct {
+	store     *store.Store
+	notifiers map[models.PushPlatform]PushNotifier
+	cfg       config.PushConfig
+	queue     chan delivery
+	logger    *slog.Logger
+}
+
+// NewDispatcher starts the workers. Platforms missing from notifiers are not sent
+// pushes.
+func NewDispatcher(store *store.Store, notifiers map[models.PushPlatform]PushNotifier, cfg config.PushConfig, logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		store:     store,
+		notifiers: notifiers,
+		cfg:       cfg,
+		queue:     make(chan delivery, max(cfg.QueueSize, 1)),
+		logger:    logger.With("component", "push"),
+	}
+	for range max(cfg.Workers, 1) {
+		go d.work()
+	}
+	return d
+}
+
+// NotifyOffline queues notifications of message for those of userIDs who are not
+// connected to any instance. It never blocks the caller, and a nil Dispatcher or
+// one without notifiers drops them.
+func (d *Dispatcher) NotifyOffline(message *models.Message, userIDs []string) {
+	if d == nil || len(d.notifiers) == 0 || len(userIDs) == 0 {
+		return
+	}
+	go d.notify(message, userIDs)
+}
+
+func (d *Dispatcher) notify(message *models.Message, userIDs []string) {
+	// The hub only knows its own connections; members may be online elsewhere
+	online, err := d.store.FilterOnlineUsers(userIDs)
+	if err != nil {
+		d.logger.Error("Failed to check which members are online", "error", err, "message_id", message.ID)
+		return
+	}
+	offline := slices.DeleteFunc(slices.Clone(userIDs), func(userID string) bool {
+		return slices.Contains(online, userID)
+	})
+	if len(offline) == 0 {
+		return
+	}
+
+	// Muting is each member's own; skip only those who muted the chat
+	muted, err := d.store.FilterMutedMembers(message.ChatID, offline)
+	if err != nil {
+		d.logger.Error("Failed to check which members muted the chat", "error", err, "chat_id", message.ChatID)
+		return
+	}
+	offline = slices.DeleteFunc(offline, func(userID string) bool {
+		return slices.Contains(muted, userID)
+	})
+	if len(offline) == 0 {
+		d.logger.Debug("All offline members muted the chat, skipping push", "chat_id", message.ChatID)
+		return
+	}
+
+	chat, err := d.store.GetChat(message.ChatID)
+	if err != nil {
+		d.logger.Error("Failed to get chat for push", "error", err, "chat_id", message.ChatID)
+		return
+	}
+
+	tokens, err := d.store.GetPushTokens(offline)
+	if err != nil {
+		d.logger.Error("Failed to get push tokens", "error", err, "message_id", message.ID)
+		return
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	title := "New message"
+	if sender, err := d.store.GetUserByID(message.SenderID); err == nil {
+		title = sender.Name
+	}
+	if chat.Type != models.ChatTypeDirect && chat.Name != nil {
+		title += " @ " + *chat.Name
+	}
+
+	now := time.Now()
+	settingsByUser := make(map[string]*models.NotificationSettings)
+	for _, token := range tokens {
+		settings, ok := settingsByUser[token.UserID]
+		if !ok {
+			settings, err = d.store.GetNotificationSettings(token.UserID)
+			if err != nil {
+				d.logger.Error("Failed to get notification settings, skipping push",
+					"error", err, "user_id", token.UserID)
+				continue
+			}
+			settingsByUser[token.UserID] = settings
+		}
+		if settings.MuteAll {
+			continue
+		}
+
+		body := hiddenPreview
+		if settings.PreviewEnabled {
+			body = preview(message)
+		}
+		d.enqueue(delivery{
+			token: token,
+			notification: Notification{
+				Title:     title,
+				Body:      body,
+				ChatID:    message.ChatID,
+				MessageID: message.ID,
+				Silent:    !settings.Sound || settings.InQuietHours(now),
+			},
+			attempt: 1,
+		})
+	}
+}
+
+// preview is the notification text for a message
+func preview(message *models.Message) string {
+	if message.ContentType != "" && message.ContentType != string(models.ContentTypeText) && message.Content == "" {
+		return "Sent a " + message.ContentType
+	}
+	if utf8.RuneCountInString(message.Content) <= previewLength {
+		return message.Content
+	}
+	return string([]rune(message.Content)[:previewLength]) + "…"
+}
+
+func (d *Dispatcher) enqueue(item delivery) {
+	select {
+	case d.queue <- item:
+	default:
+		d.logger.Warn("Push queue full, dropping notification",
+			"user_id", item.token.UserID, "message_id", item.notification.MessageID, "queue_size", cap(d.queue))
+	}
+}
+
+func (d *Dispatcher) work() {
+	for item := range d.queue {
+		d.deliver(item)
+	}
+}
+
+// deliver sends one push. Tokens the service rejects are deleted; other failures
+// are queued again after a doubling delay until attempts run out or the store
+// shuts down.
+func (d *Dispatcher) deliver(item delivery) {
+	notifier, ok := d.notifiers[item.token.Platform]
+	if !ok {
+		return
+	}
+
+	err := notifier.Send(d.store.Ctx, item.token.Token, item.notification)
+	if err == nil {
+		d.logger.Debug("Push delivered",
+			"user_id", item.token.UserID, "platform", item.token.Platform,
+			"message_id", item.notification.MessageID, "attempt", item.attempt)
+		return
+	}
+
+	if errors.Is(err, ErrInvalidToken) {
+		d.logger.Info("Push token rejected, removing it",
+			"user_id", item.token.UserID, "platform", item.token.Platform)
+		d.store.DeletePushToken(item.token.Token)
+		return
+	}
+
+	d.logger.Warn("Push delivery failed",
+		"error", err, "user_id", item.token.UserID, "platform", item.token.Platform,
+		"message_id", item.notification.MessageID, "attempt", item.attempt)
+
+	if item.attempt >= d.cfg.MaxAttempts || d.store.Ctx.Err() != nil {
+		d.logger.Error("Push delivery abandoned",
+			"user_id", item.token.UserID, "platform", item.token.Platform,
+			"message_id", item.notification.MessageID, "attempts", item.attempt)
+		return
+	}
+
+	delay := d.cfg.RetryBackoff << (item.attempt - 1)
+	item.attempt++
+	time.AfterFunc(delay, func() { d.enqueue(item) })
+}
@@ -0,0 +1,184 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	fcmScope       = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL     = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	fcmJWTBearer   = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	fcmTokenMargin = time.Minute // Renew the access token this long before it expires
+)
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating as a service account
+type FCM struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCM loads the service account key file downloaded from the Firebase console
+func NewFCM(credentialsFile string, client *http.Client) (*FCM, error) {
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read FCM credentials: %w", err)
+	}
+
+	var creds struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("parse FCM credentials: %w", err)
+	}
+	if creds.ProjectID == "" || creds.ClientEmail == "" || creds.TokenURI == "" {
+		return nil, fmt.Errorf("FCM credentials are missing project_id, client_email or token_uri")
+	}
+
+	key, err := jwtlib.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse FCM private key: %w", err)
+	}
+
+	return &FCM{
+		projectID:   creds.ProjectID,
+		clientEmail: creds.ClientEmail,
+		tokenURI:    creds.TokenURI,
+		key:         key,
+		client:      client,
+	}, nil
+}
+
+func (f *FCM) Send(ctx context.Context, token string, notification Notification) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	androidNotification := map[string]any{"tag": notification.ChatID}
+	if !notification.Silent {
+		androidNotification["sound"] = "default"
+	}
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token": token,
+			"notification": map[string]string{
+				"title": notification.Title,
+				"body":  notification.Body,
+			},
+			"data": map[string]string{
+				"chat_id":    notification.ChatID,
+				"message_id": notification.MessageID,
+			},
+			"android": map[string]any{"notification": androidNotification},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(fcmSendURL, f.projectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+
+	// UNREGISTERED comes back as 404; a malformed token as INVALID_ARGUMENT
+	if resp.StatusCode == http.StatusNotFound ||
+		(failure.Error.Status == "INVALID_ARGUMENT" && strings.Contains(failure.Error.Message, "token")) {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("FCM responded %d %s: %s", resp.StatusCode, failure.Error.Status, failure.Error.Message)
+}
+
+// token returns an OAuth access token for the service account, exchanging a
+// freshly signed assertion for a new one when the cached token is about to expire
+func (f *FCM) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.accessToken != "" && time.Until(f.expiresAt) > fcmTokenMargin {
+		return f.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, jwtlib.MapClaims{
+		"iss":   f.clientEmail,
+		"scope": fcmScope,
+		"aud":   f.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", fmt.Errorf("sign FCM assertion: %w", err)
+	}
+
+	form := url.Values{"grant_type": {fcmJWTBearer}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token exchange responded %d", resp.StatusCode)
+	}
+
+	var grant struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+		return "", fmt.Errorf("parse FCM token response: %w", err)
+	}
+
+	f.accessToken = grant.AccessToken
+	f.expiresAt = now.Add(time.Duration(grant.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
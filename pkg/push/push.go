@@ -0,0 +1,63 @@
+// Package push notifies members who are offline about new messages through the
+// platform push services.
+package push
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// ErrInvalidToken is returned by a PushNotifier when the service reports the device
+// token as unregistered or malformed. The token is dropped rather than retried.
+var ErrInvalidToken = errors.New("push token is no longer valid")
+
+// Notification is what a device shows for a new message
+type Notification struct {
+	Title     string
+	Body      string
+	ChatID    string
+	MessageID string
+	Silent    bool // Deliver without sound
+}
+
+// PushNotifier sends a notification to a single device through one push service
+type PushNotifier interface {
+	Send(ctx context.Context, token string, notification Notification) error
+}
+
+// Noop drops every notification, for platforms without a configured service
+type Noop struct{}
+
+func (Noop) Send(context.Context, string, Notification) error {
+	return nil
+}
+
+// NewNotifiers returns the notifier for each platform whose push service is
+// configured. Platforms left out fall back to Noop in the Dispatcher.
+func NewNotifiers(cfg config.PushConfig) (map[models.PushPlatform]PushNotifier, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	notifiers := make(map[models.PushPlatform]PushNotifier)
+
+	if cfg.FCMCredentialsFile != "" {
+		fcm, err := NewFCM(cfg.FCMCredentialsFile, client)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[models.PushPlatformAndroid] = fcm
+		notifiers[models.PushPlatformWeb] = fcm
+	}
+
+	if cfg.APNsKeyFile != "" {
+		apns, err := NewAPNs(cfg.APNsKeyFile, cfg.APNsKeyID, cfg.APNsTeamID, cfg.APNsTopic, cfg.APNsSandbox, client)
+		if err != nil {
+			return nil, err
+		}
+		notifiers[models.PushPlatformIOS] = apns
+	}
+
+	return notifiers, nil
+}
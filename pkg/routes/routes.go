@@ -3,11 +3,15 @@ package routes
 import (
 	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/msniranjan18/common/middleware/auth"
 
+	"github.com/msniranjan18/chit-chat/config"
 	"github.com/msniranjan18/chit-chat/pkg/handlers"
 	"github.com/msniranjan18/chit-chat/pkg/hub"
+	"github.com/msniranjan18/chit-chat/pkg/middleware"
 	"github.com/msniranjan18/chit-chat/pkg/store"
 
 	_ "github.com/msniranjan18/chit-chat/docs"
@@ -21,21 +25,38 @@ type RouterHandler struct {
 	logger *slog.Logger
 }
 
+// gzipMinSize is the smallest API response body worth compressing
+const gzipMinSize = 1024
+
 // NewRouter creates a new HTTP router with all routes configured
-func NewRouter(h *hub.Hub, s *store.Store, logger *slog.Logger) *http.ServeMux {
+func NewRouter(cfg *config.Config, h *hub.Hub, s *store.Store, logger *slog.Logger) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Create handlers with logger
-	authHandler := handlers.NewAuthHandler(s, logger)
+	authHandler := handlers.NewAuthHandler(s, h, cfg.JWT, logger)
 	userHandler := handlers.NewUserHandler(s, logger)
-	chatHandler := handlers.NewChatHandler(s, logger)
-	messageHandler := handlers.NewMessageHandler(s, logger)
-	wsHandler := handlers.NewWSHandler(h, logger)
-
-	// Static files
-	fileServer := http.FileServer(http.Dir("./static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fileServer))
-	logger.Debug("Static file server configured", "path", "/static/")
+	chatHandler := handlers.NewChatHandler(s, h, cfg.Chat, logger)
+	messageHandler := handlers.NewMessageHandler(s, h, cfg.Chat, logger)
+	wsHandler := handlers.NewWSHandler(h, cfg.WebSocket, logger)
+	webhookHandler := handlers.NewWebhookHandler(s, logger)
+	botHandler := handlers.NewBotHandler(s, logger)
+	adminHandler := handlers.NewAdminHandler(s, h, logger)
+
+	// Static files, only when the asset directory is present
+	staticDir := cfg.Server.StaticDir
+	serveStatic := true
+	if info, err := os.Stat(staticDir); err != nil || !info.IsDir() {
+		logger.Warn("Static directory not found, web client disabled",
+			"static_dir", staticDir, "error", err)
+		serveStatic = false
+	}
+	indexFile := filepath.Join(staticDir, "index.html")
+
+	if serveStatic {
+		fileServer := http.FileServer(http.Dir(staticDir))
+		mux.Handle("/static/", http.StripPrefix("/static/", fileServer))
+		logger.Debug("Static file server configured", "path", "/static/", "static_dir", staticDir)
+	}
 
 	// Swagger UI
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
@@ -63,14 +84,25 @@ func NewRouter(h *hub.Hub, s *store.Store, logger *slog.Logger) *http.ServeMux {
 	apiRouter.HandleFunc("GET /api/users/me", userHandler.GetCurrentUser)
 	apiRouter.HandleFunc("PUT /api/users/me", userHandler.UpdateUser)
 	apiRouter.HandleFunc("PATCH /api/users/me", userHandler.UpdateUser)
+	apiRouter.HandleFunc("GET /api/users/me/unread", userHandler.GetTotalUnread)
+	apiRouter.HandleFunc("GET /api/users/me/notifications", userHandler.GetNotificationSettings)
+	apiRouter.HandleFunc("PUT /api/users/me/notifications", userHandler.UpdateNotificationSettings)
+	apiRouter.HandleFunc("POST /api/users/me/push-tokens", userHandler.RegisterPushToken)
+	apiRouter.HandleFunc("GET /api/users/me/blocked", userHandler.GetBlockedUsers)
 	apiRouter.HandleFunc("GET /api/users/search", userHandler.SearchUsers)
+	apiRouter.HandleFunc("POST /api/users/lookup", userHandler.LookupUsers)
 	apiRouter.HandleFunc("GET /api/users/{id}", userHandler.GetUser)
+	apiRouter.HandleFunc("GET /api/users/{id}/shared-media", userHandler.GetSharedMedia)
+	apiRouter.HandleFunc("POST /api/users/{id}/block", userHandler.BlockUser)
+	apiRouter.HandleFunc("DELETE /api/users/{id}/block", userHandler.UnblockUser)
 	apiRouter.HandleFunc("GET /api/users/online", userHandler.GetOnlineUsers)
 	apiRouter.HandleFunc("GET /api/users/sessions", userHandler.GetUserSessions)
+	apiRouter.HandleFunc("PATCH /api/users/sessions/{id}", userHandler.UpdateSession)
 
 	// Contact endpoints
 	apiRouter.HandleFunc("GET /api/contacts", userHandler.GetContacts)
 	apiRouter.HandleFunc("POST /api/contacts", userHandler.AddContact)
+	apiRouter.HandleFunc("POST /api/contacts/bulk", userHandler.ImportContacts)
 	apiRouter.HandleFunc("DELETE /api/contacts/{id}", userHandler.RemoveContact)
 
 	// Chat endpoints
@@ -81,26 +113,57 @@ func NewRouter(h *hub.Hub, s *store.Store, logger *slog.Logger) *http.ServeMux {
 	apiRouter.HandleFunc("PUT /api/chats/{id}", chatHandler.UpdateChat)
 	apiRouter.HandleFunc("PATCH /api/chats/{id}", chatHandler.UpdateChat)
 	apiRouter.HandleFunc("DELETE /api/chats/{id}", chatHandler.DeleteChat)
+	apiRouter.HandleFunc("GET /api/chats/{id}/me", chatHandler.GetMyMembership)
 	apiRouter.HandleFunc("GET /api/chats/{id}/members", chatHandler.GetChatMembers)
+	apiRouter.HandleFunc("GET /api/chats/{id}/members/summary", chatHandler.GetMemberSummary)
+	apiRouter.HandleFunc("GET /api/chats/{id}/members/search", chatHandler.SearchChatMembers)
 	apiRouter.HandleFunc("POST /api/chats/{id}/members", chatHandler.AddChatMember)
+	apiRouter.HandleFunc("PATCH /api/chats/{id}/members/me", chatHandler.UpdateMyMember)
+	apiRouter.HandleFunc("PATCH /api/chats/{id}/members/{memberId}", chatHandler.UpdateChatMember)
+	apiRouter.HandleFunc("PATCH /api/chats/{id}/members/{memberId}/role", chatHandler.UpdateChatMemberRole)
 	apiRouter.HandleFunc("DELETE /api/chats/{id}/members/{memberId}", chatHandler.RemoveChatMember)
 	apiRouter.HandleFunc("POST /api/chats/{id}/leave", chatHandler.LeaveChat)
 	apiRouter.HandleFunc("POST /api/chats/{id}/read", chatHandler.MarkChatAsRead)
+	apiRouter.HandleFunc("POST /api/chats/{id}/unread", chatHandler.MarkChatAsUnread)
+	apiRouter.HandleFunc("POST /api/chats/{id}/typing", chatHandler.SendTyping)
+	apiRouter.HandleFunc("POST /api/chats/{id}/clear", chatHandler.ClearChatHistory)
+	apiRouter.HandleFunc("GET /api/chats/{id}/export", chatHandler.ExportChat)
+	apiRouter.HandleFunc("GET /api/chats/{id}/audit", chatHandler.GetAuditLog)
+	apiRouter.HandleFunc("GET /api/chats/{id}/messages", chatHandler.GetMessagesByStatus)
+	apiRouter.HandleFunc("GET /api/chats/{id}/messages/unread", chatHandler.GetUnreadMessages)
+	apiRouter.HandleFunc("GET /api/chats/{id}/media", chatHandler.GetChatMedia)
+	apiRouter.HandleFunc("GET /api/chats/{id}/group", chatHandler.GetGroup)
+	apiRouter.HandleFunc("PATCH /api/chats/{id}/group/settings", chatHandler.UpdateGroupSettings)
 
 	// Message endpoints
 	apiRouter.HandleFunc("GET /api/messages", messageHandler.GetMessages)
 	apiRouter.HandleFunc("POST /api/messages", messageHandler.SendMessage)
 	apiRouter.HandleFunc("GET /api/messages/search", messageHandler.SearchMessages)
+	apiRouter.HandleFunc("GET /api/messages/around", messageHandler.GetMessagesAround)
+	apiRouter.HandleFunc("GET /api/messages/mentions", messageHandler.GetMentions)
 	apiRouter.HandleFunc("PUT /api/messages/{id}", messageHandler.UpdateMessage)
 	apiRouter.HandleFunc("PATCH /api/messages/{id}", messageHandler.UpdateMessage)
 	apiRouter.HandleFunc("DELETE /api/messages/{id}", messageHandler.DeleteMessage)
+	apiRouter.HandleFunc("POST /api/messages/{id}/forward", messageHandler.ForwardMessage)
+	apiRouter.HandleFunc("GET /api/messages/{id}/status", messageHandler.GetMessageStatus)
 	apiRouter.HandleFunc("POST /api/messages/status", messageHandler.UpdateMessageStatus)
 
-	// Apply authentication middleware to API routes with logging
-	authenticatedAPI := auth.AuthMiddleware(apiRouter)
+	// Webhook endpoints
+	apiRouter.HandleFunc("POST /api/webhooks", webhookHandler.CreateWebhook)
+	apiRouter.HandleFunc("DELETE /api/webhooks/{id}", webhookHandler.DeleteWebhook)
+
+	// Bot endpoints
+	apiRouter.HandleFunc("POST /api/bots", botHandler.CreateBot)
+	apiRouter.HandleFunc("DELETE /api/bots/{id}", botHandler.DeleteBot)
+	apiRouter.HandleFunc("POST /api/bots/{id}/keys", botHandler.CreateAPIKey)
+	apiRouter.HandleFunc("DELETE /api/bots/{id}/keys/{keyId}", botHandler.DeleteAPIKey)
+
+	// Apply authentication middleware to API routes with logging. Bots authenticate
+	// with an API key instead of a JWT; a JWT is only accepted while its session lasts.
+	authenticatedAPI := middleware.APIKey(apiRouter, auth.AuthMiddleware(middleware.Session(apiRouter, s, logger)), s, logger)
 
-	// Wrap the authenticated API with route logging
-	mux.Handle("/api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// Wrap the authenticated API with route logging and response compression
+	mux.Handle("/api/", middleware.Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("API request received",
 			"method", r.Method,
 			"path", r.URL.Path,
@@ -109,33 +172,48 @@ func NewRouter(h *hub.Hub, s *store.Store, logger *slog.Logger) *http.ServeMux {
 
 		// Pass through to authenticated handler
 		authenticatedAPI.ServeHTTP(w, r)
-	}))
+	}), gzipMinSize))
 
 	logger.Info("API routes configured",
 		"auth_endpoints", 2,
-		"user_endpoints", 8,
-		"contact_endpoints", 3,
-		"chat_endpoints", 14,
-		"message_endpoints", 7)
+		"user_endpoints", 18,
+		"contact_endpoints", 4,
+		"chat_endpoints", 30,
+		"message_endpoints", 11,
+		"webhook_endpoints", 2,
+		"bot_endpoints", 4)
+
+	// Admin endpoints, authenticated with ADMIN_TOKEN rather than a user's JWT
+	adminRouter := http.NewServeMux()
+	adminRouter.HandleFunc("GET /api/admin/connections", adminHandler.GetConnections)
+	adminRouter.HandleFunc("POST /api/admin/chats/{id}/rebuild-cache", adminHandler.RebuildChatCache)
+	mux.Handle("/api/admin/", middleware.Admin(adminRouter, cfg.Admin.Token, logger))
+
+	logger.Info("Admin routes configured",
+		"enabled", cfg.Admin.Token != "",
+		"admin_endpoints", 2)
 
 	// SPA catch-all route (must be last)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Only serve index.html for non-API routes
-		if r.URL.Path == "/" || !isAPIRoute(r.URL.Path) {
+		if serveStatic && (r.URL.Path == "/" || !isAPIRoute(r.URL.Path)) {
 			logger.Debug("Serving SPA index", "path", r.URL.Path, "method", r.Method)
-			http.ServeFile(w, r, "./static/index.html")
+			http.ServeFile(w, r, indexFile)
 			return
 		}
 
-		// For undefined API routes, return 404 with logging
-		logger.Warn("API route not found",
+		// For undefined API routes, or any route while the web client is
+		// disabled, return 404 with logging
+		logger.Warn("Route not found",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"remote_addr", r.RemoteAddr)
 		http.NotFound(w, r)
 	})
 
-	logger.Debug("SPA catch-all route configured", "serve_from", "./static/index.html")
+	if serveStatic {
+		logger.Debug("SPA catch-all route configured", "serve_from", indexFile)
+	}
 
 	return mux
 }
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// RecordAuditEvent stores a moderation action taken in a chat. targetID may be empty
+// for actions that don't apply to a specific user.
+func (s *Store) RecordAuditEvent(chatID, actorID string, action models.AuditAction, targetID string) error {
+	s.logger.Debug("Recording audit event",
+		"chat_id", chatID, "actor_id", actorID, "action", action, "target_id", targetID)
+
+	query := `
+		INSERT INTO audit_log (chat_id, actor_id, action, target_id)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid)`
+
+	_, err := s.DB.Exec(query, chatID, actorID, action, targetID)
+	if err != nil {
+		s.logger.Error("Failed to record audit event",
+			"error", err, "chat_id", chatID, "actor_id", actorID, "action", action)
+		return err
+	}
+
+	return nil
+}
+
+// GetAuditLog returns a chat's moderation history, newest first
+func (s *Store) GetAuditLog(chatID string, limit, offset int) ([]models.AuditEvent, error) {
+	s.logger.Debug("Getting audit log", "chat_id", chatID, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT id, chat_id, actor_id, action, target_id, created_at
+		FROM audit_log
+		WHERE chat_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.DB.Query(query, chatID, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query audit log", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.Scan(
+			&event.ID, &event.ChatID, &event.ActorID,
+			&event.Action, &event.TargetID, &event.CreatedAt,
+		); err != nil {
+			s.logger.Error("Failed to scan audit event", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating audit log", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	s.logger.Debug("Audit log retrieved", "chat_id", chatID, "count", len(events))
+	return events, nil
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// botPhonePrefix starts the placeholder phone of a bot's user row. It can't pass
+// registration's 10 digit check, and login refuses bot accounts outright.
+const botPhonePrefix = "bot:"
+
+// CreateBot creates a service account owned by ownerID: a user row with a
+// placeholder phone, plus the bot record linking it to its owner
+func (s *Store) CreateBot(ownerID, name string) (*models.Bot, error) {
+	s.logger.Info("Creating bot", "owner_id", ownerID, "name", name)
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	phone := botPhonePrefix + hex.EncodeToString(suffix)[:11]
+
+	bot := &models.Bot{
+		ID:        uuid.New().String(),
+		Name:      name,
+		OwnerID:   ownerID,
+		CreatedAt: time.Now(),
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for CreateBot", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO users (id, phone, name, status, last_seen, created_at, updated_at)
+		VALUES ($1, $2, $3, 'Bot', $4, $4, $4)`,
+		bot.ID, phone, name, bot.CreatedAt,
+	)
+	if err != nil {
+		s.logger.Error("Failed to create bot user", "error", err, "owner_id", ownerID)
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO bots (user_id, owner_id, created_at) VALUES ($1, $2, $3)`,
+		bot.ID, ownerID, bot.CreatedAt)
+	if err != nil {
+		s.logger.Error("Failed to create bot", "error", err, "owner_id", ownerID)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for CreateBot", "error", err)
+		return nil, err
+	}
+
+	s.logger.Info("Bot created", "bot_id", bot.ID, "owner_id", ownerID)
+	return bot, nil
+}
+
+// GetBot returns a bot, or ErrNotFound if there is none with that ID
+func (s *Store) GetBot(botID string) (*models.Bot, error) {
+	s.logger.Debug("Getting bot", "bot_id", botID)
+
+	bot := &models.Bot{}
+	err := s.DB.QueryRow(`
+		SELECT b.user_id, u.name, b.owner_id, b.created_at
+		FROM bots b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.user_id = $1`,
+		botID,
+	).Scan(&bot.ID, &bot.Name, &bot.OwnerID, &bot.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get bot", "error", err, "bot_id", botID)
+		return nil, err
+	}
+	return bot, nil
+}
+
+// IsBot reports whether a user is a bot
+func (s *Store) IsBot(userID string) (bool, error) {
+	var isBot bool
+	err := s.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM bots WHERE user_id = $1)`, userID).Scan(&isBot)
+	if err != nil {
+		s.logger.Error("Failed to check for bot", "error", err, "user_id", userID)
+		return false, err
+	}
+	return isBot, nil
+}
+
+// DeleteBot removes a bot's user, and with it its memberships and API keys
+func (s *Store) DeleteBot(botID string) error {
+	s.logger.Info("Deleting bot", "bot_id", botID)
+
+	chats, err := s.GetUserChats(botID)
+	if err != nil {
+		s.logger.Warn("Failed to get bot chats before deletion", "error", err, "bot_id", botID)
+	}
+
+	_, err = s.DB.Exec(`DELETE FROM users WHERE id = $1 AND id IN (SELECT user_id FROM bots)`, botID)
+	if err != nil {
+		s.logger.Error("Failed to delete bot", "error", err, "bot_id", botID)
+		return err
+	}
+
+	s.InvalidateUserChatsCache(botID)
+	for _, chat := range chats {
+		s.InvalidateChatMembersCache(chat.ID)
+	}
+
+	s.logger.Info("Bot deleted", "bot_id", botID)
+	return nil
+}
+
+// CreateAPIKey stores the hash of a new API key for a bot, scoped to chatIDs
+func (s *Store) CreateAPIKey(botID, keyHash string, chatIDs []string) (*models.APIKey, error) {
+	s.logger.Info("Creating API key", "bot_id", botID, "chat_count", len(chatIDs))
+
+	key := &models.APIKey{BotID: botID, ChatIDs: chatIDs}
+	err := s.DB.QueryRow(`
+		INSERT INTO api_keys (bot_id, key_hash, chat_ids)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		botID, keyHash, pq.Array(chatIDs),
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		s.logger.Error("Failed to create API key", "error", err, "bot_id", botID)
+		return nil, err
+	}
+
+	s.logger.Info("API key created", "key_id", key.ID, "bot_id", botID)
+	return key, nil
+}
+
+// DeleteAPIKey revokes one of a bot's API keys, reporting whether it existed
+func (s *Store) DeleteAPIKey(botID, keyID string) (bool, error) {
+	s.logger.Info("Deleting API key", "bot_id", botID, "key_id", keyID)
+
+	result, err := s.DB.Exec(`DELETE FROM api_keys WHERE id = $1 AND bot_id = $2`, keyID, botID)
+	if err != nil {
+		s.logger.Error("Failed to delete API key", "error", err, "bot_id", botID, "key_id", keyID)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// UseAPIKey looks up an API key by hash and records that it was used. It returns
+// ErrNotFound if no key has that hash.
+func (s *Store) UseAPIKey(keyHash string) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := s.DB.QueryRow(`
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE key_hash = $1
+		RETURNING id, bot_id, chat_ids, created_at, last_used_at`,
+		keyHash,
+	).Scan(&key.ID, &key.BotID, pq.Array(&key.ChatIDs), &key.CreatedAt, &key.LastUsedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to look up API key", "error", err)
+		return nil, err
+	}
+	return key, nil
+}
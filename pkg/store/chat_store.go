@@ -2,9 +2,13 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 )
 
@@ -35,18 +39,30 @@ func (s *Store) CreateChat(chatReq *models.ChatRequest, createdBy string) (*mode
 		LastActivity: now,
 	}
 
+	var directKey *string
+	if chatReq.Type == models.ChatTypeDirect && len(chatReq.UserIDs) == 1 {
+		key := directChatKey(createdBy, chatReq.UserIDs[0])
+		directKey = &key
+	}
+
 	query := `
-		INSERT INTO chats (id, type, name, description, avatar_url, created_by, created_at, updated_at, last_activity)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO chats (id, type, name, description, avatar_url, created_by, created_at, updated_at, last_activity, direct_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id`
 
 	err = tx.QueryRow(
 		query,
 		chat.ID, chat.Type, chat.Name, chat.Description,
 		chat.AvatarURL, chat.CreatedBy, chat.CreatedAt,
-		chat.UpdatedAt, chat.LastActivity,
+		chat.UpdatedAt, chat.LastActivity, directKey,
 	).Scan(&chat.ID)
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == "idx_chats_direct_key" {
+		s.logger.Info("Direct chat was created concurrently",
+			"created_by", createdBy, "other_user_id", chatReq.UserIDs[0])
+		return nil, ErrDirectChatExists
+	}
 	if err != nil {
 		s.logger.Error("Failed to insert chat", "error", err, "chat_id", chatID)
 		return nil, err
@@ -108,6 +124,10 @@ func (s *Store) CreateChat(chatReq *models.ChatRequest, createdBy string) (*mode
 		return nil, err
 	}
 
+	if chatReq.Type == models.ChatTypeDirect && len(chatReq.UserIDs) == 1 && chatReq.UserIDs[0] == createdBy {
+		chat.MarkSelf()
+	}
+
 	// Invalidate cache
 	s.InvalidateUserChatsCache(createdBy)
 	for _, userID := range chatReq.UserIDs {
@@ -120,12 +140,26 @@ func (s *Store) CreateChat(chatReq *models.ChatRequest, createdBy string) (*mode
 	return chat, nil
 }
 
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+// directChatKey identifies a direct chat by its two members, in either order
+func directChatKey(user1ID, user2ID string) string {
+	user1ID, user2ID = strings.ToLower(user1ID), strings.ToLower(user2ID)
+	if user2ID < user1ID {
+		user1ID, user2ID = user2ID, user1ID
+	}
+	return user1ID + ":" + user2ID
+}
+
+// GetChat returns the chat on its own. Per-member state such as pinning and muting
+// is only filled in by the lookups made for a member, like GetChatForMember.
 func (s *Store) GetChat(chatID string) (*models.Chat, error) {
 	s.logger.Debug("Getting chat", "chat_id", chatID)
 
 	query := `
 		SELECT id, type, name, description, avatar_url, created_by, created_at, updated_at, last_activity,
-		       is_archived, is_muted, is_pinned
+		       is_archived, closed_at
 		FROM chats WHERE id = $1`
 
 	chat := &models.Chat{}
@@ -133,12 +167,12 @@ func (s *Store) GetChat(chatID string) (*models.Chat, error) {
 		&chat.ID, &chat.Type, &chat.Name, &chat.Description,
 		&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
 		&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
-		&chat.IsMuted, &chat.IsPinned,
+		&chat.ClosedAt,
 	)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("Chat not found", "chat_id", chatID)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get chat", "error", err, "chat_id", chatID)
@@ -149,31 +183,131 @@ func (s *Store) GetChat(chatID string) (*models.Chat, error) {
 	return chat, nil
 }
 
+// GetChatForMember returns the chat along with userID's role in it, checking
+// membership in the same query. A chat that doesn't exist, or that the user isn't
+// in or is banned from, is reported as ErrNotFound so callers can't tell the two apart.
+func (s *Store) GetChatForMember(chatID, userID string) (*models.Chat, models.ChatMemberRole, error) {
+	s.logger.Debug("Getting chat for member", "chat_id", chatID, "user_id", userID)
+
+	// A malformed ID can't name a chat; don't let it surface as a query error
+	if _, err := uuid.Parse(chatID); err != nil {
+		return nil, "", ErrNotFound
+	}
+
+	query := `
+		SELECT c.id, c.type, c.name, c.description, c.avatar_url, c.created_by, c.created_at,
+		       c.updated_at, c.last_activity, c.is_archived, cm.is_muted, cm.is_pinned, cm.pin_order, c.closed_at,
+		       COALESCE(cm.role, 'member')
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id AND cm.user_id = $2 AND cm.is_banned = FALSE
+		WHERE c.id = $1`
+
+	chat := &models.Chat{}
+	var role models.ChatMemberRole
+	err := s.DB.QueryRow(query, chatID, userID).Scan(
+		&chat.ID, &chat.Type, &chat.Name, &chat.Description,
+		&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
+		&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
+		&chat.IsMuted, &chat.IsPinned, &chat.PinOrder, &chat.ClosedAt,
+		&role,
+	)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Chat not found or user is not a member", "chat_id", chatID, "user_id", userID)
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get chat for member", "error", err, "chat_id", chatID, "user_id", userID)
+		return nil, "", err
+	}
+
+	return chat, role, nil
+}
+
+// GetChatsByIDs returns the chats among chatIDs that userID is a member of, in a
+// single query. Chats that don't exist, or that the user isn't in or is banned
+// from, are left out.
+func (s *Store) GetChatsByIDs(userID string, chatIDs []string) ([]models.Chat, error) {
+	s.logger.Debug("Getting chats by IDs", "user_id", userID, "chat_count", len(chatIDs))
+
+	if len(chatIDs) == 0 {
+		return []models.Chat{}, nil
+	}
+
+	query := `
+		SELECT c.id, c.type, c.name, c.description, c.avatar_url, c.created_by,
+		       c.created_at, c.updated_at, c.last_activity,
+		       c.is_archived, cm.is_muted, cm.is_pinned, cm.pin_order, c.closed_at
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id
+		WHERE c.id = ANY($1) AND cm.user_id = $2 AND cm.is_banned = FALSE`
+
+	rows, err := s.DB.Query(query, pq.Array(chatIDs), userID)
+	if err != nil {
+		s.logger.Error("Failed to get chats by IDs",
+			"error", err, "user_id", userID, "chat_count", len(chatIDs))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []models.Chat
+	for rows.Next() {
+		var chat models.Chat
+		err := rows.Scan(
+			&chat.ID, &chat.Type, &chat.Name, &chat.Description,
+			&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
+			&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
+			&chat.IsMuted, &chat.IsPinned, &chat.PinOrder, &chat.ClosedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan chat row in GetChatsByIDs", "error", err, "user_id", userID)
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to get chats by IDs",
+			"error", err, "user_id", userID, "chat_count", len(chatIDs))
+		return nil, err
+	}
+
+	s.logger.Debug("Chats retrieved by IDs", "user_id", userID, "requested", len(chatIDs), "found", len(chats))
+	return chats, nil
+}
+
+// GetDirectChat returns the open direct chat whose only members are user1ID and
+// user2ID. A chat one of them left stays closed; a new one is created instead.
 func (s *Store) GetDirectChat(user1ID, user2ID string) (*models.Chat, error) {
 	s.logger.Debug("Getting direct chat", "user1_id", user1ID, "user2_id", user2ID)
 
 	query := `
 		SELECT c.id, c.type, c.name, c.description, c.avatar_url, c.created_by, 
 		       c.created_at, c.updated_at, c.last_activity,
-		       c.is_archived, c.is_muted, c.is_pinned
+		       c.is_archived, cm1.is_muted, cm1.is_pinned, cm1.pin_order, c.closed_at
 		FROM chats c
 		JOIN chat_members cm1 ON c.id = cm1.chat_id
 		JOIN chat_members cm2 ON c.id = cm2.chat_id
-		WHERE c.type = 'direct'
+		WHERE c.type = 'direct' AND c.closed_at IS NULL
 		AND cm1.user_id = $1 AND cm2.user_id = $2
+		AND (
+			(cm1.user_id <> cm2.user_id
+			 AND (SELECT COUNT(*) FROM chat_members cm WHERE cm.chat_id = c.id) = 2)
+			-- A user's chat with themselves has them as its only member
+			OR (cm1.user_id = cm2.user_id AND c.direct_key = $3)
+		)
+		ORDER BY c.created_at
 		LIMIT 1`
 
 	chat := &models.Chat{}
-	err := s.DB.QueryRow(query, user1ID, user2ID).Scan(
+	err := s.DB.QueryRow(query, user1ID, user2ID, directChatKey(user1ID, user2ID)).Scan(
 		&chat.ID, &chat.Type, &chat.Name, &chat.Description,
 		&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
 		&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
-		&chat.IsMuted, &chat.IsPinned,
+		&chat.IsMuted, &chat.IsPinned, &chat.PinOrder, &chat.ClosedAt,
 	)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("Direct chat not found", "user1_id", user1ID, "user2_id", user2ID)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get direct chat",
@@ -181,32 +315,135 @@ func (s *Store) GetDirectChat(user1ID, user2ID string) (*models.Chat, error) {
 		return nil, err
 	}
 
+	if user1ID == user2ID {
+		chat.MarkSelf()
+	}
+
 	s.logger.Debug("Direct chat found", "chat_id", chat.ID, "user1_id", user1ID, "user2_id", user2ID)
 	return chat, nil
 }
 
+// GetDirectChatPeer returns the other member of a direct chat, or "" if the chat
+// is not a direct chat or is the user's chat with themselves. It returns ErrChatClosed if the other member has left.
+func (s *Store) GetDirectChatPeer(chatID, userID string) (string, error) {
+	s.logger.Debug("Getting direct chat peer", "chat_id", chatID, "user_id", userID)
+
+	query := `
+		SELECT c.closed_at IS NOT NULL,
+		       COALESCE((SELECT cm.user_id::text FROM chat_members cm
+		                 WHERE cm.chat_id = c.id AND cm.user_id <> $2 LIMIT 1), '')
+		FROM chats c
+		WHERE c.id = $1 AND c.type = 'direct'`
+
+	var closed bool
+	var peerID string
+	err := s.DB.QueryRow(query, chatID, userID).Scan(&closed, &peerID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get direct chat peer", "error", err, "chat_id", chatID, "user_id", userID)
+		return "", err
+	}
+	if closed {
+		return "", ErrChatClosed
+	}
+
+	return peerID, nil
+}
+
+// CheckSendPermission decides in one query whether userID may post a message of
+// contentType to a chat, combining the membership check of GetChatForMember, the
+// closed check of GetDirectChatPeer and the group restrictions of
+// CheckGroupSendPermission. It returns ErrNotFound if the user isn't a member,
+// ErrChatClosed if the direct chat's other member has left, and otherwise the
+// other member of a direct chat, for privacy checks, and a human-readable reason
+// when group settings refuse the message.
+func (s *Store) CheckSendPermission(chatID, userID, contentType string) (peerID, restriction string, err error) {
+	s.logger.Debug("Checking send permission", "chat_id", chatID, "user_id", userID)
+
+	// A malformed ID can't name a chat; don't let it surface as a query error
+	if _, err := uuid.Parse(chatID); err != nil {
+		return "", "", ErrNotFound
+	}
+
+	query := `
+		SELECT c.type = 'direct' AND c.closed_at IS NOT NULL,
+		       COALESCE(cm.is_admin, FALSE) OR COALESCE(cm.role, 'member') IN ('owner', 'admin'),
+		       COALESCE(gs.send_messages_allowed, TRUE), COALESCE(gs.send_media_allowed, TRUE),
+		       CASE WHEN c.type = 'direct' THEN
+		           COALESCE((SELECT peer.user_id::text FROM chat_members peer
+		                     WHERE peer.chat_id = c.id AND peer.user_id <> $2 LIMIT 1), '')
+		       ELSE '' END
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id AND cm.user_id = $2 AND cm.is_banned = FALSE
+		LEFT JOIN group_settings gs ON gs.chat_id = c.id
+		WHERE c.id = $1`
+
+	var closed, isAdmin, messagesAllowed, mediaAllowed bool
+	err = s.DB.QueryRow(query, chatID, userID).Scan(&closed, &isAdmin, &messagesAllowed, &mediaAllowed, &peerID)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Chat not found or user is not a member", "chat_id", chatID, "user_id", userID)
+		return "", "", ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to check send permission", "error", err, "chat_id", chatID, "user_id", userID)
+		return "", "", err
+	}
+	if closed {
+		return "", "", ErrChatClosed
+	}
+
+	// Owners and admins are never restricted
+	switch {
+	case isAdmin:
+	case !messagesAllowed:
+		restriction = "Only admins can send messages to this group"
+	case !mediaAllowed && contentType != string(models.ContentTypeText):
+		restriction = "Only admins can send media to this group"
+	}
+	return peerID, restriction, nil
+}
+
 func (s *Store) GetUserChats(userID string) ([]models.Chat, error) {
 	s.logger.Debug("Getting user chats", "user_id", userID)
 
 	// Try cache first
+	// Unread counts change with every message, so they are kept out of the cached
+	// list and applied from the counters on each read
 	if cached, err := s.GetCachedUserChats(userID); err == nil && cached != nil {
 		s.logger.Debug("Retrieved user chats from cache", "user_id", userID, "chat_count", len(cached))
+		if err := s.applyUnreadCounts(userID, cached); err != nil {
+			return nil, err
+		}
 		return cached, nil
 	}
 
 	query := `
 		SELECT c.id, c.type, c.name, c.description, c.avatar_url, c.created_by,
 		       c.created_at, c.updated_at, c.last_activity,
-		       c.is_archived, c.is_muted, c.is_pinned,
-		       (SELECT COUNT(*) FROM messages m WHERE m.chat_id = c.id AND m.sent_at > cm.last_read_at) as unread_count,
-		       (SELECT content FROM messages WHERE chat_id = c.id ORDER BY sent_at DESC LIMIT 1) as last_message_content,
-		       (SELECT sent_at FROM messages WHERE chat_id = c.id ORDER BY sent_at DESC LIMIT 1) as last_message_time
+		       c.is_archived, cm.is_muted, cm.is_pinned, cm.pin_order, c.closed_at,
+		       COALESCE(c.direct_key = cm.user_id::text || ':' || cm.user_id::text, FALSE) AS is_self,
+		       (SELECT COUNT(*) FROM message_mentions mm
+		        JOIN messages m ON m.id = mm.message_id
+		        WHERE mm.chat_id = c.id AND mm.user_id = cm.user_id
+		        AND m.is_deleted = FALSE AND m.sent_at > cm.last_read_at) as unread_mentions,
+		       lm.id, lm.sender_id, u.name, lm.content, lm.content_type, lm.sent_at, lm.is_deleted
 		FROM chats c
 		JOIN chat_members cm ON c.id = cm.chat_id
+		LEFT JOIN LATERAL (
+			SELECT m.id, m.sender_id, m.content, m.content_type, m.sent_at, m.is_deleted
+			FROM messages m
+			WHERE m.chat_id = c.id
+			AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+			ORDER BY m.sent_at DESC, m.id DESC
+			LIMIT 1
+		) lm ON TRUE
+		LEFT JOIN users u ON u.id = lm.sender_id
 		WHERE cm.user_id = $1 AND c.is_archived = FALSE
-		ORDER BY c.last_activity DESC`
+		ORDER BY cm.is_pinned DESC, cm.pin_order ASC NULLS LAST, c.last_activity DESC`
 
-	rows, err := s.DB.Query(query, userID)
+	rows, err := s.queryContext(s.DB, query, userID)
 	if err != nil {
 		s.logger.Error("Failed to query user chats", "error", err, "user_id", userID)
 		return nil, err
@@ -216,43 +453,88 @@ func (s *Store) GetUserChats(userID string) ([]models.Chat, error) {
 	var chats []models.Chat
 	for rows.Next() {
 		var chat models.Chat
-		var lastMessageContent sql.NullString
-		var lastMessageTime sql.NullTime
-		var unreadCount int
+		var lastID, lastSenderID, lastSenderName, lastContent, lastContentType sql.NullString
+		var lastSentAt sql.NullTime
+		var lastDeleted sql.NullBool
+		var unreadMentions int
+		var isSelf bool
 
 		err := rows.Scan(
 			&chat.ID, &chat.Type, &chat.Name, &chat.Description,
 			&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
 			&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
-			&chat.IsMuted, &chat.IsPinned, &unreadCount,
-			&lastMessageContent, &lastMessageTime,
+			&chat.IsMuted, &chat.IsPinned, &chat.PinOrder, &chat.ClosedAt, &isSelf, &unreadMentions,
+			&lastID, &lastSenderID, &lastSenderName, &lastContent, &lastContentType, &lastSentAt, &lastDeleted,
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan chat row", "error", err, "user_id", userID)
 			return nil, err
 		}
 
-		if lastMessageContent.Valid && lastMessageTime.Valid {
+		if lastID.Valid {
 			chat.LastMessage = &models.Message{
-				Content: lastMessageContent.String,
-				SentAt:  lastMessageTime.Time,
+				ID:          lastID.String,
+				ChatID:      chat.ID,
+				SenderID:    lastSenderID.String,
+				SenderName:  lastSenderName.String,
+				Content:     lastContent.String,
+				ContentType: lastContentType.String,
+				SentAt:      lastSentAt.Time,
+				IsDeleted:   lastDeleted.Bool,
+			}
+			if chat.LastMessage.IsDeleted {
+				chat.LastMessage.Content = models.DeletedMessagePlaceholder
 			}
 		}
-		chat.UnreadCount = unreadCount
+		chat.UnreadMentions = unreadMentions
+		if isSelf {
+			chat.MarkSelf()
+		}
 
 		chats = append(chats, chat)
 	}
 
 	s.logger.Debug("Retrieved user chats from database", "user_id", userID, "chat_count", len(chats))
 
-	// Cache the result
-	go s.CacheUserChats(userID, chats)
+	if err := s.applyUnreadCounts(userID, chats); err != nil {
+		return nil, err
+	}
+
+	// Cache the result; callers own the returned slice
+	go s.CacheUserChats(userID, slices.Clone(chats))
 
 	return chats, nil
 }
 
-func (s *Store) UpdateChat(chatID string, updates *models.ChatUpdateRequest) error {
-	s.logger.Info("Updating chat", "chat_id", chatID, "updates", updates)
+// applyUnreadCounts fills in each chat's unread count for the user
+func (s *Store) applyUnreadCounts(userID string, chats []models.Chat) error {
+	chatIDs := make([]string, len(chats))
+	for i, chat := range chats {
+		chatIDs[i] = chat.ID
+	}
+
+	counts, err := s.GetUnreadCounts(userID, chatIDs)
+	if err != nil {
+		return err
+	}
+	for i := range chats {
+		chats[i].UnreadCount = counts[chats[i].ID]
+	}
+	return nil
+}
+
+// UpdateChat applies updates to the chat. Muting and pinning are userID's own and
+// are written to their membership; the other fields are shared by everyone in the
+// chat.
+func (s *Store) UpdateChat(chatID, userID string, updates *models.ChatUpdateRequest) error {
+	s.logger.Info("Updating chat", "chat_id", chatID, "user_id", userID, "updates", updates)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for UpdateChat", "error", err, "chat_id", chatID)
+		return err
+	}
+	defer tx.Rollback()
 
 	query := `
 		UPDATE chats 
@@ -260,26 +542,111 @@ func (s *Store) UpdateChat(chatID string, updates *models.ChatUpdateRequest) err
 			description = COALESCE($3, description),
 			avatar_url = COALESCE($4, avatar_url),
 			is_archived = COALESCE($5, is_archived),
-			is_muted = COALESCE($6, is_muted),
-			is_pinned = COALESCE($7, is_pinned),
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
+		AND ($6::timestamp IS NULL OR updated_at = $6)
 		RETURNING id`
 
-	err := s.DB.QueryRow(
+	err = tx.QueryRow(
 		query, chatID, updates.Name, updates.Description,
-		updates.AvatarURL, updates.IsArchived, updates.IsMuted, updates.IsPinned,
+		updates.AvatarURL, updates.IsArchived, updates.ExpectedUpdatedAt,
 	).Scan(&chatID)
 
+	if err == sql.ErrNoRows && updates.ExpectedUpdatedAt != nil {
+		s.logger.Warn("Chat modified since expected version",
+			"chat_id", chatID, "expected_updated_at", updates.ExpectedUpdatedAt)
+		return ErrConflict
+	}
 	if err != nil {
 		s.logger.Error("Failed to update chat", "error", err, "chat_id", chatID)
 		return err
 	}
 
+	if updates.IsMuted != nil || updates.IsPinned != nil || updates.PinOrder != nil {
+		memberQuery := `
+			UPDATE chat_members
+			SET is_muted = COALESCE($3, is_muted),
+				is_pinned = COALESCE($4, is_pinned),
+				-- Unpinning drops the manual position
+				pin_order = CASE WHEN COALESCE($4, is_pinned) THEN COALESCE($5, pin_order) END
+			WHERE chat_id = $1 AND user_id = $2`
+
+		_, err = tx.Exec(memberQuery, chatID, userID, updates.IsMuted, updates.IsPinned, updates.PinOrder)
+		if err != nil {
+			s.logger.Error("Failed to update member's chat state", "error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit chat update", "error", err, "chat_id", chatID)
+		return err
+	}
+
+	// Archiving and renaming change members' chat lists
+	if members, err := s.GetChatMembers(chatID); err == nil {
+		for _, member := range members {
+			s.InvalidateUserChatsCache(member.UserID)
+		}
+	}
+
 	s.logger.Info("Chat updated successfully", "chat_id", chatID)
 	return nil
 }
 
+// CountPinnedChats returns how many of the user's chats other than exceptChatID are
+// pinned, so re-pinning a chat that already is doesn't count it twice
+func (s *Store) CountPinnedChats(userID, exceptChatID string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM chats c
+		JOIN chat_members cm ON cm.chat_id = c.id
+		WHERE cm.user_id = $1 AND cm.is_pinned = TRUE AND c.id <> $2`
+
+	var count int
+	if err := s.DB.QueryRow(query, userID, exceptChatID).Scan(&count); err != nil {
+		s.logger.Error("Failed to count pinned chats", "error", err, "user_id", userID)
+		return 0, err
+	}
+	return count, nil
+}
+
+// FilterMutedMembers returns the subset of userIDs who muted the chat
+func (s *Store) FilterMutedMembers(chatID string, userIDs []string) ([]string, error) {
+	s.logger.Debug("Filtering muted members", "chat_id", chatID, "user_count", len(userIDs))
+
+	if len(userIDs) == 0 {
+		return []string{}, nil
+	}
+
+	query := `
+		SELECT user_id FROM chat_members
+		WHERE chat_id = $1 AND user_id = ANY($2) AND is_muted = TRUE`
+
+	rows, err := s.DB.Query(query, chatID, pq.Array(userIDs))
+	if err != nil {
+		s.logger.Error("Failed to filter muted members", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	muted := make([]string, 0, len(userIDs))
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			s.logger.Error("Failed to scan muted member", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		muted = append(muted, userID)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating muted members", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	return muted, nil
+}
+
 func (s *Store) UpdateChatLastActivity(chatID string) error {
 	s.logger.Debug("Updating chat last activity", "chat_id", chatID)
 
@@ -294,6 +661,15 @@ func (s *Store) UpdateChatLastActivity(chatID string) error {
 	return nil
 }
 
+// touchChat records a membership or settings change as activity in the chat when
+// MembershipActivity is set. The change itself has already been saved, so failing
+// to bump last_activity is only logged.
+func (s *Store) touchChat(chatID string) {
+	if s.MembershipActivity {
+		s.UpdateChatLastActivity(chatID)
+	}
+}
+
 func (s *Store) DeleteChat(chatID string) error {
 	s.logger.Warn("Deleting chat", "chat_id", chatID)
 
@@ -312,7 +688,7 @@ func (s *Store) GetChatMembers(chatID string) ([]models.ChatMember, error) {
 	s.logger.Debug("Getting chat members", "chat_id", chatID)
 
 	query := `
-		SELECT chat_id, user_id, joined_at, last_read_at, role, is_admin, display_name, is_banned, banned_until
+		SELECT chat_id, user_id, joined_at, last_read_at, role, is_admin, display_name, is_banned, banned_until, updated_at
 		FROM chat_members 
 		WHERE chat_id = $1 AND is_banned = FALSE
 		ORDER BY joined_at`
@@ -330,7 +706,7 @@ func (s *Store) GetChatMembers(chatID string) ([]models.ChatMember, error) {
 		err := rows.Scan(
 			&member.ChatID, &member.UserID, &member.JoinedAt,
 			&member.LastReadAt, &member.Role, &member.IsAdmin,
-			&member.DisplayName, &member.IsBanned, &member.BannedUntil,
+			&member.DisplayName, &member.IsBanned, &member.BannedUntil, &member.UpdatedAt,
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan chat member row", "error", err, "chat_id", chatID)
@@ -343,64 +719,525 @@ func (s *Store) GetChatMembers(chatID string) ([]models.ChatMember, error) {
 	return members, nil
 }
 
+// GetChatMembersPaged returns one page of a chat's members, in join order or, with
+// byRole, owners first and then by join time within each role
+func (s *Store) GetChatMembersPaged(chatID string, byRole bool, limit, offset int) ([]models.ChatMember, error) {
+	s.logger.Debug("Getting chat members page",
+		"chat_id", chatID, "by_role", byRole, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT chat_id, user_id, joined_at, last_read_at, role, is_admin, display_name, is_banned, banned_until, updated_at
+		FROM chat_members
+		WHERE chat_id = $1 AND is_banned = FALSE
+		ORDER BY
+			CASE WHEN $2 THEN
+				CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 WHEN 'member' THEN 2 ELSE 3 END
+			END,
+			joined_at, user_id
+		LIMIT $3 OFFSET $4`
+
+	rows, err := s.DB.Query(query, chatID, byRole, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query chat members page", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ChatMember{}
+	for rows.Next() {
+		var member models.ChatMember
+		err := rows.Scan(
+			&member.ChatID, &member.UserID, &member.JoinedAt,
+			&member.LastReadAt, &member.Role, &member.IsAdmin,
+			&member.DisplayName, &member.IsBanned, &member.BannedUntil, &member.UpdatedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan chat member row", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to query chat members page", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	s.logger.Debug("Retrieved chat members page", "chat_id", chatID, "member_count", len(members))
+	return members, nil
+}
+
+// SearchChatMembers returns up to limit of a chat's members whose name or per-chat
+// display name contains query, case-insensitively, ordered by the name they are
+// shown under. Banned members are left out.
+func (s *Store) SearchChatMembers(chatID, query string, limit int) ([]models.ChatMemberMatch, error) {
+	s.logger.Debug("Searching chat members", "chat_id", chatID, "query", query, "limit", limit)
+
+	searchQuery := `
+		SELECT cm.chat_id, cm.user_id, cm.joined_at, cm.last_read_at, cm.role, cm.is_admin, cm.display_name,
+		       cm.is_banned, cm.banned_until, cm.updated_at, u.name, u.avatar_url
+		FROM chat_members cm
+		JOIN users u ON u.id = cm.user_id
+		WHERE cm.chat_id = $1 AND cm.is_banned = FALSE
+		AND (u.name ILIKE $2 OR cm.display_name ILIKE $2)
+		ORDER BY COALESCE(cm.display_name, u.name), cm.user_id
+		LIMIT $3`
+
+	rows, err := s.DB.Query(searchQuery, chatID, "%"+query+"%", limit)
+	if err != nil {
+		s.logger.Error("Failed to search chat members", "error", err, "chat_id", chatID, "query", query)
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []models.ChatMemberMatch{}
+	for rows.Next() {
+		var match models.ChatMemberMatch
+		err := rows.Scan(
+			&match.ChatID, &match.UserID, &match.JoinedAt,
+			&match.LastReadAt, &match.Role, &match.IsAdmin,
+			&match.DisplayName, &match.IsBanned, &match.BannedUntil, &match.UpdatedAt,
+			&match.Name, &match.AvatarURL,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan chat member match", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to search chat members", "error", err, "chat_id", chatID, "query", query)
+		return nil, err
+	}
+
+	s.logger.Debug("Chat member search completed", "chat_id", chatID, "count", len(matches))
+	return matches, nil
+}
+
+// GetMemberRoleCounts counts a chat's members by role. Banned members are left out,
+// as they are from member lists, and members with the legacy is_admin flag count
+// as admins.
+func (s *Store) GetMemberRoleCounts(chatID string) (*models.MemberRoleCounts, error) {
+	s.logger.Debug("Getting member role counts", "chat_id", chatID)
+
+	query := `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE role = 'owner'),
+		       COUNT(*) FILTER (WHERE role <> 'owner' AND (role = 'admin' OR is_admin)),
+		       COUNT(*) FILTER (WHERE role = 'member' AND NOT is_admin),
+		       COUNT(*) FILTER (WHERE role = 'viewer' AND NOT is_admin)
+		FROM chat_members
+		WHERE chat_id = $1 AND is_banned = FALSE`
+
+	counts := &models.MemberRoleCounts{ChatID: chatID}
+	err := s.DB.QueryRow(query, chatID).Scan(
+		&counts.Total, &counts.Owners, &counts.Admins, &counts.Members, &counts.Viewers,
+	)
+	if err != nil {
+		s.logger.Error("Failed to count chat members by role", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// rebuildMessagesPage is how many messages a cache rebuild re-warms, matching the
+// default first page of GET /api/messages
+const rebuildMessagesPage = 50
+
+// RebuildChatCaches drops the chat's cached messages, members and its members'
+// unread counters, then re-warms them from the database through the usual
+// getters. It returns ErrNotFound if the chat doesn't exist.
+func (s *Store) RebuildChatCaches(chatID string) (*models.CacheRebuildResult, error) {
+	s.logger.Warn("Rebuilding chat caches", "chat_id", chatID)
+
+	if _, err := s.GetChat(chatID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.GetChatMembers(chatID)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs := make([]string, len(members))
+	for i, member := range members {
+		memberIDs[i] = member.UserID
+	}
+
+	if err := s.InvalidateChatMessagesCache(chatID); err != nil {
+		return nil, err
+	}
+	if err := s.InvalidateChatMembersCache(chatID); err != nil {
+		return nil, err
+	}
+	if err := s.InvalidateUnreadCounters(memberIDs...); err != nil {
+		return nil, err
+	}
+	for _, memberID := range memberIDs {
+		if err := s.InvalidateTotalUnreadCache(memberID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.CacheChatMembers(chatID, members); err != nil {
+		return nil, err
+	}
+
+	result := &models.CacheRebuildResult{ChatID: chatID, Members: len(members)}
+
+	// The shared message cache is only filled for a member who hasn't cleared
+	// their history, so load the first page as one of them
+	for _, memberID := range memberIDs {
+		clearedBefore, err := s.getClearedBefore(chatID, memberID)
+		if errors.Is(err, ErrNotFound) {
+			// Left since the members were loaded
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if clearedBefore != nil {
+			continue
+		}
+		messages, err := s.GetMessages(chatID, memberID, 0, rebuildMessagesPage)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Messages = len(messages)
+		break
+	}
+
+	for _, memberID := range memberIDs {
+		if _, err := s.GetUnreadCounts(memberID, []string{chatID}); err != nil {
+			return nil, err
+		}
+		if _, err := s.GetTotalUnread(memberID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info("Chat caches rebuilt",
+		"chat_id", chatID, "member_count", result.Members, "message_count", result.Messages)
+	return result, nil
+}
+
+func (s *Store) GetChatMember(chatID, userID string) (*models.ChatMember, error) {
+	s.logger.Debug("Getting chat member", "chat_id", chatID, "user_id", userID)
+
+	query := `
+		SELECT chat_id, user_id, joined_at, last_read_at, role, is_admin, display_name, is_banned, banned_until, updated_at
+		FROM chat_members 
+		WHERE chat_id = $1 AND user_id = $2`
+
+	member := &models.ChatMember{}
+	err := s.DB.QueryRow(query, chatID, userID).Scan(
+		&member.ChatID, &member.UserID, &member.JoinedAt,
+		&member.LastReadAt, &member.Role, &member.IsAdmin,
+		&member.DisplayName, &member.IsBanned, &member.BannedUntil, &member.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Chat member not found", "chat_id", chatID, "user_id", userID)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get chat member", "error", err, "chat_id", chatID, "user_id", userID)
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// GetChatPeerIDs returns every other user who shares at least one chat with userID
+func (s *Store) GetChatPeerIDs(userID string) ([]string, error) {
+	s.logger.Debug("Getting chat peers", "user_id", userID)
+
+	query := `
+		SELECT DISTINCT cm2.user_id
+		FROM chat_members cm1
+		JOIN chat_members cm2 ON cm1.chat_id = cm2.chat_id
+		WHERE cm1.user_id = $1 AND cm2.user_id <> $1`
+
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		s.logger.Error("Failed to query chat peers", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peerIDs []string
+	for rows.Next() {
+		var peerID string
+		if err := rows.Scan(&peerID); err != nil {
+			s.logger.Error("Failed to scan chat peer row", "error", err, "user_id", userID)
+			return nil, err
+		}
+		peerIDs = append(peerIDs, peerID)
+	}
+
+	s.logger.Debug("Retrieved chat peers", "user_id", userID, "peer_count", len(peerIDs))
+	return peerIDs, nil
+}
+
+// IsChatAdmin reports whether the user is an owner or admin of the chat
+func (s *Store) IsChatAdmin(chatID, userID string) (bool, error) {
+	s.logger.Debug("Checking chat admin", "chat_id", chatID, "user_id", userID)
+
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM chat_members 
+			WHERE chat_id = $1 AND user_id = $2
+			AND (is_admin = TRUE OR role IN ('owner', 'admin'))
+		)`
+
+	var isAdmin bool
+	err := s.DB.QueryRow(query, chatID, userID).Scan(&isAdmin)
+	if err != nil {
+		s.logger.Error("Failed to check chat admin", "error", err, "chat_id", chatID, "user_id", userID)
+		return false, err
+	}
+
+	return isAdmin, nil
+}
+
 func (s *Store) AddChatMember(chatID, userID string, role models.ChatMemberRole, displayName string) error {
 	s.logger.Info("Adding chat member",
 		"chat_id", chatID, "user_id", userID, "role", role, "display_name", displayName)
 
+	// Direct chats are guarded in the insert itself so a concurrent request
+	// can't slip a third member in between a type check and the write
 	query := `
 		INSERT INTO chat_members (chat_id, user_id, joined_at, role, display_name)
-		VALUES ($1, $2, CURRENT_TIMESTAMP, $3, $4)
+		SELECT $1, $2, CURRENT_TIMESTAMP, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM chats WHERE id = $1 AND type = 'direct')
 		ON CONFLICT (chat_id, user_id) DO UPDATE
 		SET role = EXCLUDED.role,
 			display_name = COALESCE(EXCLUDED.display_name, chat_members.display_name),
 			is_banned = FALSE,
 			banned_until = NULL`
 
-	_, err := s.DB.Exec(query, chatID, userID, role, displayName)
+	result, err := s.DB.Exec(query, chatID, userID, role, displayName)
 	if err != nil {
 		s.logger.Error("Failed to add chat member",
 			"error", err, "chat_id", chatID, "user_id", userID)
 		return err
 	}
 
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		s.logger.Warn("Rejected adding member to direct chat", "chat_id", chatID, "user_id", userID)
+		return ErrDirectChatMembers
+	}
+
 	// Invalidate user's chat cache
 	s.InvalidateUserChatsCache(userID)
+	s.touchChat(chatID)
 
 	s.logger.Info("Chat member added successfully",
 		"chat_id", chatID, "user_id", userID, "role", role)
 	return nil
 }
 
+// RemoveChatMember removes a user from a chat. It returns ErrLastOwner if the user
+// is the only owner of a group or channel that still has other members.
 func (s *Store) RemoveChatMember(chatID, userID string) error {
 	s.logger.Info("Removing chat member", "chat_id", chatID, "user_id", userID)
 
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for RemoveChatMember", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	owners, err := s.lockOwners(tx, chatID)
+	if err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		// An owner leaving an otherwise empty chat leaves nobody to hand it to
+		var othersRemain bool
+		err := tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM chat_members WHERE chat_id = $1 AND user_id <> $2)`,
+			chatID, userID,
+		).Scan(&othersRemain)
+		if err != nil {
+			s.logger.Error("Failed to check remaining chat members",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+		if othersRemain {
+			s.logger.Warn("Rejected removing the last chat owner", "chat_id", chatID, "user_id", userID)
+			return ErrLastOwner
+		}
+	}
+
 	query := `DELETE FROM chat_members WHERE chat_id = $1 AND user_id = $2`
-	_, err := s.DB.Exec(query, chatID, userID)
+	result, err := tx.Exec(query, chatID, userID)
 	if err != nil {
 		s.logger.Error("Failed to remove chat member",
 			"error", err, "chat_id", chatID, "user_id", userID)
 		return err
 	}
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		// Already gone; leaving again changes nothing, and outsiders can't close a
+		// direct chat they were never in
+		s.logger.Debug("User was not a chat member", "chat_id", chatID, "user_id", userID)
+		return nil
+	}
+
+	// A direct chat someone has left is closed: it no longer stands for the pair, so
+	// a new one can be created for them, and the other participant keeps it as
+	// read-only history until they leave as well
+	var peerID sql.NullString
+	err = tx.QueryRow(`
+		UPDATE chats c SET direct_key = NULL, closed_at = COALESCE(c.closed_at, CURRENT_TIMESTAMP)
+		WHERE c.id = $1 AND c.type = 'direct'
+		RETURNING (SELECT cm.user_id::text FROM chat_members cm WHERE cm.chat_id = c.id LIMIT 1)`,
+		chatID,
+	).Scan(&peerID)
+	if err != nil && err != sql.ErrNoRows {
+		s.logger.Error("Failed to close direct chat", "error", err, "chat_id", chatID)
+		return err
+	}
+	isDirect := err == nil
+
+	// Once nobody is left in it the direct chat goes away with its messages
+	if isDirect && !peerID.Valid {
+		if _, err := tx.Exec(`DELETE FROM chats WHERE id = $1`, chatID); err != nil {
+			s.logger.Error("Failed to delete empty direct chat", "error", err, "chat_id", chatID)
+			return err
+		}
+		s.logger.Info("Deleted empty direct chat", "chat_id", chatID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for RemoveChatMember", "error", err)
+		return err
+	}
 
 	// Invalidate user's chat cache
 	s.InvalidateUserChatsCache(userID)
+	s.InvalidateChatMembersCache(chatID)
+	if peerID.Valid {
+		// The peer's copy of the chat now shows it closed
+		s.InvalidateUserChatsCache(peerID.String)
+	}
+	if !isDirect {
+		s.touchChat(chatID)
+	}
 
 	s.logger.Info("Chat member removed successfully", "chat_id", chatID, "user_id", userID)
 	return nil
 }
 
+// lockOwners returns the owners of a group or channel, locking their rows until tx
+// ends so concurrent removals and demotions can't each count on the other owner.
+// Direct chats have no ownership to protect, so they report none.
+func (s *Store) lockOwners(tx *sql.Tx, chatID string) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT cm.user_id
+		FROM chat_members cm
+		JOIN chats c ON c.id = cm.chat_id
+		WHERE cm.chat_id = $1 AND cm.role = 'owner' AND c.type <> 'direct'
+		FOR UPDATE OF cm`,
+		chatID,
+	)
+	if err != nil {
+		s.logger.Error("Failed to lock chat owners", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []string
+	for rows.Next() {
+		var ownerID string
+		if err := rows.Scan(&ownerID); err != nil {
+			s.logger.Error("Failed to scan chat owner row", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		owners = append(owners, ownerID)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to lock chat owners", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	return owners, nil
+}
+
+// UpdateChatMemberDisplayName sets or clears a member's per-chat nickname.
+// It returns ErrNotFound if the user is not a member of the chat.
+func (s *Store) UpdateChatMemberDisplayName(chatID, userID string, displayName *string) error {
+	s.logger.Info("Updating chat member display name", "chat_id", chatID, "user_id", userID)
+
+	query := `UPDATE chat_members SET display_name = NULLIF($3, '') WHERE chat_id = $1 AND user_id = $2`
+	result, err := s.DB.Exec(query, chatID, userID, displayName)
+	if err != nil {
+		s.logger.Error("Failed to update chat member display name",
+			"error", err, "chat_id", chatID, "user_id", userID)
+		return err
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.logger.Debug("Chat member not found for display name update", "chat_id", chatID, "user_id", userID)
+		return ErrNotFound
+	}
+
+	s.InvalidateChatMembersCache(chatID)
+
+	s.logger.Info("Chat member display name updated", "chat_id", chatID, "user_id", userID)
+	return nil
+}
+
+// UpdateChatMemberRole changes a member's role, keeping is_admin in step with it.
+// It returns ErrNotFound if the user is not a member of the chat, and
+// ErrLastOwner if it would demote the chat's only owner.
 func (s *Store) UpdateChatMemberRole(chatID, userID string, role models.ChatMemberRole) error {
 	s.logger.Info("Updating chat member role",
 		"chat_id", chatID, "user_id", userID, "role", role)
 
-	query := `UPDATE chat_members SET role = $3 WHERE chat_id = $1 AND user_id = $2`
-	_, err := s.DB.Exec(query, chatID, userID, role)
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for UpdateChatMemberRole", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	owners, err := s.lockOwners(tx, chatID)
+	if err != nil {
+		return err
+	}
+
+	if role != models.ChatMemberRoleOwner && len(owners) == 1 && owners[0] == userID {
+		s.logger.Warn("Rejected demoting the last chat owner", "chat_id", chatID, "user_id", userID, "role", role)
+		return ErrLastOwner
+	}
+
+	query := `
+		UPDATE chat_members
+		SET role = $3, is_admin = $3 IN ('owner', 'admin')
+		WHERE chat_id = $1 AND user_id = $2`
+	result, err := tx.Exec(query, chatID, userID, role)
 	if err != nil {
 		s.logger.Error("Failed to update chat member role",
 			"error", err, "chat_id", chatID, "user_id", userID)
 		return err
 	}
 
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.logger.Debug("Chat member not found for role update", "chat_id", chatID, "user_id", userID)
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for UpdateChatMemberRole", "error", err)
+		return err
+	}
+
+	s.InvalidateChatMembersCache(chatID)
+	s.touchChat(chatID)
+
 	s.logger.Info("Chat member role updated", "chat_id", chatID, "user_id", userID, "role", role)
 	return nil
 }
@@ -416,13 +1253,34 @@ func (s *Store) UpdateMemberLastRead(chatID, userID string) error {
 		return err
 	}
 
+	s.InvalidateTotalUnreadCache(userID)
+	s.ResetUnreadCounter(chatID, userID)
+
 	s.logger.Debug("Member last read updated", "chat_id", chatID, "user_id", userID)
 	return nil
 }
 
+func (s *Store) CountChatMembers(chatID string) (int, error) {
+	s.logger.Debug("Counting chat members", "chat_id", chatID)
+
+	var count int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM chat_members WHERE chat_id = $1`, chatID).Scan(&count)
+	if err != nil {
+		s.logger.Error("Failed to count chat members", "error", err, "chat_id", chatID)
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func (s *Store) IsChatMember(chatID, userID string) (bool, error) {
 	s.logger.Debug("Checking chat membership", "chat_id", chatID, "user_id", userID)
 
+	// A malformed ID can't name a chat; don't let it surface as a query error
+	if _, err := uuid.Parse(chatID); err != nil {
+		return false, nil
+	}
+
 	query := `SELECT 1 FROM chat_members WHERE chat_id = $1 AND user_id = $2 AND is_banned = FALSE`
 	var exists int
 	err := s.DB.QueryRow(query, chatID, userID).Scan(&exists)
@@ -440,13 +1298,13 @@ func (s *Store) IsChatMember(chatID, userID string) (bool, error) {
 	return true, nil
 }
 
-func (s *Store) SearchChats(queryStr string, chatType *models.ChatType, limit int) ([]models.Chat, error) {
+func (s *Store) SearchChats(queryStr string, chatType *models.ChatType, offset, limit int) ([]models.Chat, error) {
 	s.logger.Info("Searching chats",
-		"query", queryStr, "type", chatType, "limit", limit)
+		"query", queryStr, "type", chatType, "offset", offset, "limit", limit)
 
 	baseQuery := `
 		SELECT id, type, name, description, avatar_url, created_by, created_at, updated_at, last_activity,
-		       is_archived, is_muted, is_pinned
+		       is_archived, closed_at
 		FROM chats 
 		WHERE (name ILIKE $1 OR description ILIKE $1) 
 		AND is_archived = FALSE`
@@ -455,11 +1313,11 @@ func (s *Store) SearchChats(queryStr string, chatType *models.ChatType, limit in
 	var args []interface{}
 
 	if chatType != nil {
-		query = baseQuery + " AND type = $2 ORDER BY last_activity DESC LIMIT $3"
-		args = []interface{}{"%" + queryStr + "%", *chatType, limit}
+		query = baseQuery + " AND type = $2 ORDER BY last_activity DESC, id LIMIT $3 OFFSET $4"
+		args = []interface{}{"%" + queryStr + "%", *chatType, limit, offset}
 	} else {
-		query = baseQuery + " ORDER BY last_activity DESC LIMIT $2"
-		args = []interface{}{"%" + queryStr + "%", limit}
+		query = baseQuery + " ORDER BY last_activity DESC, id LIMIT $2 OFFSET $3"
+		args = []interface{}{"%" + queryStr + "%", limit, offset}
 	}
 
 	rows, err := s.DB.Query(query, args...)
@@ -477,7 +1335,7 @@ func (s *Store) SearchChats(queryStr string, chatType *models.ChatType, limit in
 			&chat.ID, &chat.Type, &chat.Name, &chat.Description,
 			&chat.AvatarURL, &chat.CreatedBy, &chat.CreatedAt,
 			&chat.UpdatedAt, &chat.LastActivity, &chat.IsArchived,
-			&chat.IsMuted, &chat.IsPinned,
+			&chat.ClosedAt,
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan chat row in search", "error", err)
@@ -487,6 +1345,6 @@ func (s *Store) SearchChats(queryStr string, chatType *models.ChatType, limit in
 	}
 
 	s.logger.Info("Chat search completed",
-		"query", queryStr, "results", len(chats), "limit", limit)
+		"query", queryStr, "results", len(chats), "offset", offset, "limit", limit)
 	return chats, nil
 }
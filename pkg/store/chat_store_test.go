@@ -0,0 +1,229 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+func TestAddChatMemberRejectsDirectChat(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob, carol := createTestUser(t, s), createTestUser(t, s), createTestUser(t, s)
+	direct := createTestChat(t, s, models.ChatTypeDirect, alice.ID, bob.ID)
+
+	err := s.AddChatMember(direct.ID, carol.ID, models.ChatMemberRoleMember, "")
+	if !errors.Is(err, ErrDirectChatMembers) {
+		t.Fatalf("AddChatMember to direct chat = %v, want ErrDirectChatMembers", err)
+	}
+
+	isMember, err := s.IsChatMember(direct.ID, carol.ID)
+	if err != nil {
+		t.Fatalf("IsChatMember: %v", err)
+	}
+	if isMember {
+		t.Error("third user was added to the direct chat")
+	}
+}
+
+func TestGetDirectChatIgnoresGroupsWithBothUsers(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+	createTestChat(t, s, models.ChatTypeGroup, alice.ID, bob.ID)
+
+	if chat, err := s.GetDirectChat(alice.ID, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetDirectChat with only a shared group = %+v, %v; want ErrNotFound", chat, err)
+	}
+}
+
+func TestGetDirectChatWithSharedGroup(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+	createTestChat(t, s, models.ChatTypeGroup, alice.ID, bob.ID)
+	direct := createTestChat(t, s, models.ChatTypeDirect, alice.ID, bob.ID)
+	createTestChat(t, s, models.ChatTypeGroup, bob.ID, alice.ID)
+
+	for _, pair := range [][2]string{{alice.ID, bob.ID}, {bob.ID, alice.ID}} {
+		chat, err := s.GetDirectChat(pair[0], pair[1])
+		if err != nil {
+			t.Fatalf("GetDirectChat(%s, %s): %v", pair[0], pair[1], err)
+		}
+		if chat.ID != direct.ID || chat.Type != models.ChatTypeDirect {
+			t.Errorf("GetDirectChat(%s, %s) = %s chat %s, want direct chat %s",
+				pair[0], pair[1], chat.Type, chat.ID, direct.ID)
+		}
+	}
+}
+
+func TestLastOwnerGuards(t *testing.T) {
+	s := newTestStore(t)
+
+	t.Run("remove only owner while members remain", func(t *testing.T) {
+		owner, member := createTestUser(t, s), createTestUser(t, s)
+		group := createTestChat(t, s, models.ChatTypeGroup, owner.ID, member.ID)
+
+		if err := s.RemoveChatMember(group.ID, owner.ID); !errors.Is(err, ErrLastOwner) {
+			t.Fatalf("RemoveChatMember(owner) = %v, want ErrLastOwner", err)
+		}
+		if isMember, _ := s.IsChatMember(group.ID, owner.ID); !isMember {
+			t.Error("last owner was removed")
+		}
+	})
+
+	t.Run("only owner leaves an otherwise empty group", func(t *testing.T) {
+		owner, member := createTestUser(t, s), createTestUser(t, s)
+		group := createTestChat(t, s, models.ChatTypeGroup, owner.ID, member.ID)
+
+		if err := s.RemoveChatMember(group.ID, member.ID); err != nil {
+			t.Fatalf("RemoveChatMember(member): %v", err)
+		}
+		if err := s.RemoveChatMember(group.ID, owner.ID); err != nil {
+			t.Fatalf("RemoveChatMember(owner) of empty group = %v, want nil", err)
+		}
+	})
+
+	t.Run("demote only owner", func(t *testing.T) {
+		owner, member := createTestUser(t, s), createTestUser(t, s)
+		group := createTestChat(t, s, models.ChatTypeGroup, owner.ID, member.ID)
+
+		err := s.UpdateChatMemberRole(group.ID, owner.ID, models.ChatMemberRoleAdmin)
+		if !errors.Is(err, ErrLastOwner) {
+			t.Fatalf("UpdateChatMemberRole(owner, admin) = %v, want ErrLastOwner", err)
+		}
+	})
+
+	t.Run("hand over ownership then leave", func(t *testing.T) {
+		owner, member := createTestUser(t, s), createTestUser(t, s)
+		group := createTestChat(t, s, models.ChatTypeGroup, owner.ID, member.ID)
+
+		if err := s.UpdateChatMemberRole(group.ID, member.ID, models.ChatMemberRoleOwner); err != nil {
+			t.Fatalf("UpdateChatMemberRole(member, owner): %v", err)
+		}
+		if err := s.UpdateChatMemberRole(group.ID, owner.ID, models.ChatMemberRoleMember); err != nil {
+			t.Fatalf("demote one of two owners = %v, want nil", err)
+		}
+		if err := s.RemoveChatMember(group.ID, owner.ID); err != nil {
+			t.Fatalf("former owner leaving = %v, want nil", err)
+		}
+		if err := s.RemoveChatMember(group.ID, member.ID); err != nil {
+			t.Fatalf("new owner leaving an otherwise empty group = %v, want nil", err)
+		}
+	})
+
+	t.Run("direct chat creator leaves", func(t *testing.T) {
+		alice, bob := createTestUser(t, s), createTestUser(t, s)
+		direct := createTestChat(t, s, models.ChatTypeDirect, alice.ID, bob.ID)
+
+		if err := s.RemoveChatMember(direct.ID, alice.ID); err != nil {
+			t.Fatalf("RemoveChatMember from direct chat = %v, want nil", err)
+		}
+	})
+}
+
+func TestCreateDirectChatConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+
+	const attempts = 8
+	chats := make([]*models.Chat, attempts)
+	errs := make([]error, attempts)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		// Half the requests come from each side of the pair
+		creator, other := alice.ID, bob.ID
+		if i%2 == 1 {
+			creator, other = other, creator
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			chats[i], errs[i] = s.CreateChat(&models.ChatRequest{Type: models.ChatTypeDirect, UserIDs: []string{other}}, creator)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var created *models.Chat
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if created != nil {
+				t.Fatalf("two direct chats created for the pair: %s and %s", created.ID, chats[i].ID)
+			}
+			created = chats[i]
+		case !errors.Is(err, ErrDirectChatExists):
+			t.Fatalf("CreateChat = %v, want nil or ErrDirectChatExists", err)
+		}
+	}
+	if created == nil {
+		t.Fatal("no direct chat was created")
+	}
+
+	chat, err := s.GetDirectChat(bob.ID, alice.ID)
+	if err != nil {
+		t.Fatalf("GetDirectChat: %v", err)
+	}
+	if chat.ID != created.ID {
+		t.Errorf("GetDirectChat = %s, want the chat that was created, %s", chat.ID, created.ID)
+	}
+}
+
+func TestMuteIsPerMember(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob, carol := createTestUser(t, s), createTestUser(t, s), createTestUser(t, s)
+	group := createTestChat(t, s, models.ChatTypeGroup, alice.ID, bob.ID, carol.ID)
+
+	muted := true
+	if err := s.UpdateChat(group.ID, bob.ID, &models.ChatUpdateRequest{IsMuted: &muted}); err != nil {
+		t.Fatalf("UpdateChat(mute): %v", err)
+	}
+
+	got, err := s.FilterMutedMembers(group.ID, []string{alice.ID, bob.ID, carol.ID})
+	if err != nil {
+		t.Fatalf("FilterMutedMembers: %v", err)
+	}
+	if len(got) != 1 || got[0] != bob.ID {
+		t.Errorf("FilterMutedMembers = %v, want only %s", got, bob.ID)
+	}
+
+	for userID, want := range map[string]bool{alice.ID: false, bob.ID: true} {
+		chat, _, err := s.GetChatForMember(group.ID, userID)
+		if err != nil {
+			t.Fatalf("GetChatForMember(%s): %v", userID, err)
+		}
+		if chat.IsMuted != want {
+			t.Errorf("GetChatForMember(%s).IsMuted = %v, want %v", userID, chat.IsMuted, want)
+		}
+	}
+}
+
+func TestCheckSendPermission(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob, carol := createTestUser(t, s), createTestUser(t, s), createTestUser(t, s)
+	group := createTestChat(t, s, models.ChatTypeGroup, alice.ID, bob.ID)
+	direct := createTestChat(t, s, models.ChatTypeDirect, alice.ID, bob.ID)
+
+	if _, _, err := s.CheckSendPermission(group.ID, carol.ID, string(models.ContentTypeText)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CheckSendPermission for a non-member = %v, want %v", err, ErrNotFound)
+	}
+
+	peerID, restriction, err := s.CheckSendPermission(direct.ID, alice.ID, string(models.ContentTypeText))
+	if err != nil || peerID != bob.ID || restriction != "" {
+		t.Errorf("CheckSendPermission in a direct chat = %q, %q, %v; want peer %s", peerID, restriction, err, bob.ID)
+	}
+
+	off := false
+	if _, err := s.UpdateGroupSettings(group.ID, &models.GroupSettingsRequest{SendMessagesAllowed: &off}); err != nil {
+		t.Fatalf("UpdateGroupSettings: %v", err)
+	}
+	if _, restriction, err := s.CheckSendPermission(group.ID, bob.ID, string(models.ContentTypeText)); err != nil || restriction == "" {
+		t.Errorf("CheckSendPermission for a member of a locked group = %q, %v; want a restriction", restriction, err)
+	}
+	// The owner is never restricted
+	if _, restriction, err := s.CheckSendPermission(group.ID, alice.ID, string(models.ContentTypeText)); err != nil || restriction != "" {
+		t.Errorf("CheckSendPermission for the owner = %q, %v; want no restriction", restriction, err)
+	}
+}
@@ -10,18 +10,37 @@ import (
 	postgresql "github.com/msniranjan18/common/postgres"
 	redisutil "github.com/msniranjan18/common/redis"
 
+	"github.com/msniranjan18/chit-chat/pkg/models"
+
 	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 )
 
 type Store struct {
 	DB     *sql.DB
-	RDB    *redis.Client
+	RDB    *redis.Client // Nil when Redis is optional and could not be reached
 	Ctx    context.Context
 	logger *slog.Logger
+	local  *localFallback
+
+	slowQueryThreshold time.Duration
+
+	// MembershipActivity makes member changes and group settings updates count as
+	// chat activity, moving the chat up members' chat lists like a new message does
+	MembershipActivity bool
 }
 
-func NewStore(ctx context.Context, pgConnStr, redisAddr string, logger *slog.Logger) (*Store, error) {
+// NewStore connects to PostgreSQL and Redis. When redisOptional is set, failing to
+// reach Redis is not fatal: the store runs without it, caching is skipped and
+// presence is tracked in memory for this instance only. Hot-path queries slower
+// than slowQueryThreshold are logged; zero disables that.
+func NewStore(
+	ctx context.Context,
+	pgConnStr, redisAddr string,
+	redisOptional bool,
+	slowQueryThreshold time.Duration,
+	logger *slog.Logger,
+) (*Store, error) {
 	var db *sql.DB
 	var rdb *redis.Client
 	var err error
@@ -38,20 +57,36 @@ func NewStore(ctx context.Context, pgConnStr, redisAddr string, logger *slog.Log
 	// Connect to Redis
 	rdb, err = redisutil.InitRedis(redisAddr)
 	if err != nil {
-		logger.Error("Failed to connect to Redis", "error", err)
-		return nil, err
+		if !redisOptional {
+			logger.Error("Failed to connect to Redis", "error", err)
+			return nil, err
+		}
+		logger.Warn("REDIS UNAVAILABLE: running without Redis. Caching is disabled, "+
+			"presence is tracked on this instance only and cross-instance sync is OFF. "+
+			"Do not run more than one instance in this mode.",
+			"error", err)
+		rdb = nil
+	} else {
+		logger.Info("Successfully connected to PostgreSQL and Redis")
 	}
 
-	logger.Info("Successfully connected to PostgreSQL and Redis")
-
 	return &Store{
 		DB:     db,
 		RDB:    rdb,
 		Ctx:    ctx,
 		logger: logger,
+		local:  newLocalFallback(),
+
+		slowQueryThreshold: slowQueryThreshold,
 	}, nil
 }
 
+// RedisAvailable reports whether the store is backed by Redis. It is only false
+// when REDIS_OPTIONAL is set and Redis could not be reached at startup.
+func (s *Store) RedisAvailable() bool {
+	return s.RDB != nil
+}
+
 func (s *Store) InitSchema() error {
 	s.logger.Info("Initializing database schema")
 
@@ -75,6 +110,10 @@ func (s *Store) InitSchema() error {
 		CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone);
 		CREATE INDEX IF NOT EXISTS idx_users_last_seen ON users(last_seen);
 
+		-- Who can message the user: everyone or contacts only
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS privacy_messages VARCHAR(10) NOT NULL DEFAULT 'everyone'
+			CHECK (privacy_messages IN ('everyone', 'contacts'));
+
 		-- User sessions
 		CREATE TABLE IF NOT EXISTS user_sessions (
 			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
@@ -86,6 +125,25 @@ func (s *Store) InitSchema() error {
 			is_active BOOLEAN DEFAULT TRUE
 		);
 
+		-- Parsed from the User-Agent at login, plus an optional name set by the user
+		ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS browser VARCHAR(50);
+		ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS os VARCHAR(50);
+		ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS device_name VARCHAR(100);
+		ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS label VARCHAR(100);
+
+		-- Refresh tokens, stored as hashes. A token is marked used when rotated;
+		-- presenting a used token again revokes its session.
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			session_id UUID NOT NULL REFERENCES user_sessions(session_id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+
 		-- Contacts table
 		CREATE TABLE IF NOT EXISTS contacts (
 			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
@@ -95,6 +153,40 @@ func (s *Store) InitSchema() error {
 			PRIMARY KEY (user_id, contact_id)
 		);
 
+		-- Blocked users
+		CREATE TABLE IF NOT EXISTS blocked_users (
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			blocked_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, blocked_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_blocked_users_blocked_id ON blocked_users(blocked_id);
+
+		-- Notification preferences; users without a row get the column defaults
+		CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			mute_all BOOLEAN NOT NULL DEFAULT FALSE,
+			sound BOOLEAN NOT NULL DEFAULT TRUE,
+			preview_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			quiet_hours_start TIME,
+			quiet_hours_end TIME,
+			timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		-- Device tokens for push notifications. A token belongs to whoever last
+		-- registered it, so a shared device follows the signed-in user.
+		CREATE TABLE IF NOT EXISTS push_tokens (
+			token TEXT PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			platform VARCHAR(10) NOT NULL CHECK (platform IN ('android', 'web', 'ios')),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_push_tokens_user_id ON push_tokens(user_id);
+
 		-- Chats table
 		CREATE TABLE IF NOT EXISTS chats (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -106,11 +198,18 @@ func (s *Store) InitSchema() error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			is_archived BOOLEAN DEFAULT FALSE,
-			is_muted BOOLEAN DEFAULT FALSE,
-			is_pinned BOOLEAN DEFAULT FALSE
+			is_archived BOOLEAN DEFAULT FALSE
 		);
 
+		-- Set when a participant leaves a direct chat. The chat stays with the other
+		-- participant as read-only history until they leave too.
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+
+		-- Sorted "user:user" pair of a direct chat. The unique index makes concurrent
+		-- requests for the same pair create a single chat.
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS direct_key TEXT;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_direct_key ON chats(direct_key) WHERE direct_key IS NOT NULL;
+
 		-- Indexes for chats
 		CREATE INDEX IF NOT EXISTS idx_chats_type ON chats(type);
 		CREATE INDEX IF NOT EXISTS idx_chats_last_activity ON chats(last_activity);
@@ -134,6 +233,54 @@ func (s *Store) InitSchema() error {
 		CREATE INDEX IF NOT EXISTS idx_chat_members_user_id ON chat_members(user_id);
 		CREATE INDEX IF NOT EXISTS idx_chat_members_chat_id ON chat_members(chat_id);
 
+		-- Key direct chats created before direct_key existed. Where a pair already has
+		-- several chats only the oldest, the one GetDirectChat returns, is keyed.
+		UPDATE chats c SET direct_key = pairs.pair_key
+		FROM (
+			SELECT DISTINCT ON (pair_key) chat_id, pair_key
+			FROM (
+				SELECT cm.chat_id, ch.created_at,
+				       string_agg(cm.user_id::text, ':' ORDER BY cm.user_id::text) AS pair_key
+				FROM chat_members cm
+				JOIN chats ch ON ch.id = cm.chat_id
+				WHERE ch.type = 'direct' AND ch.direct_key IS NULL
+				GROUP BY cm.chat_id, ch.created_at
+				HAVING COUNT(*) = 2
+			) keyed
+			ORDER BY pair_key, created_at
+		) pairs
+		WHERE c.id = pairs.chat_id
+		AND NOT EXISTS (SELECT 1 FROM chats taken WHERE taken.direct_key = pairs.pair_key);
+
+		-- Close direct chats someone left before closed_at existed. Chats users have
+		-- with themselves only ever have the one member.
+		UPDATE chats c SET closed_at = c.updated_at, direct_key = NULL
+		WHERE c.type = 'direct' AND c.closed_at IS NULL
+		AND (SELECT COUNT(*) FROM chat_members cm WHERE cm.chat_id = c.id) < 2
+		AND NOT EXISTS (
+			SELECT 1 FROM chat_members cm
+			WHERE cm.chat_id = c.id AND c.direct_key = cm.user_id::text || ':' || cm.user_id::text
+		);
+
+		-- Messages sent before this time are hidden from the member after clearing history
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS cleared_before TIMESTAMP;
+
+		-- When the membership itself (role, ban, display name) last changed
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+		-- Pinning is per member. The shared flag on chats let one member pin a chat
+		-- for everyone in it, so it is dropped rather than copied over.
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS is_pinned BOOLEAN DEFAULT FALSE;
+		ALTER TABLE chats DROP COLUMN IF EXISTS is_pinned;
+
+		-- The member's manual position among their pinned chats, lowest first; NULL
+		-- sorts after numbered pins
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS pin_order INTEGER;
+
+		-- Muting is per member too, and the shared flag is dropped for the same reason
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS is_muted BOOLEAN DEFAULT FALSE;
+		ALTER TABLE chats DROP COLUMN IF EXISTS is_muted;
+
 		-- Messages table
 		CREATE TABLE IF NOT EXISTS messages (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -159,10 +306,28 @@ func (s *Store) InitSchema() error {
 		);
 
 		-- Indexes for messages
-		CREATE INDEX IF NOT EXISTS idx_messages_chat_id_sent_at ON messages(chat_id, sent_at);
+		-- Messages are listed by (sent_at, id) so ties in sent_at page deterministically
+		DROP INDEX IF EXISTS idx_messages_chat_id_sent_at;
+		CREATE INDEX IF NOT EXISTS idx_messages_chat_sent_at_id ON messages(chat_id, sent_at, id);
 		CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);
 		CREATE INDEX IF NOT EXISTS idx_messages_status ON messages(status);
 
+		-- Media gallery listings only look at live messages with an attachment
+		CREATE INDEX IF NOT EXISTS idx_messages_chat_media ON messages(chat_id, sent_at DESC)
+			WHERE media_url IS NOT NULL AND is_deleted = FALSE;
+
+		-- How many times a message was forwarded, to flag frequently forwarded content
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS forward_count INTEGER NOT NULL DEFAULT 0;
+
+		-- Members other than the sender who have read the message, kept alongside
+		-- message_status so "seen by" counts don't need to query it
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_count INTEGER NOT NULL DEFAULT 0;
+
+		-- When a disappearing message is purged; NULL keeps it until retention applies
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+		CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at)
+			WHERE expires_at IS NOT NULL;
+
 		-- Message status tracking (for group messages)
 		CREATE TABLE IF NOT EXISTS message_status (
 			message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
@@ -172,6 +337,81 @@ func (s *Store) InitSchema() error {
 			PRIMARY KEY (message_id, user_id)
 		);
 
+		-- Members mentioned in a message with @{userID}
+		CREATE TABLE IF NOT EXISTS message_mentions (
+			message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (message_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_message_mentions_user_id ON message_mentions(user_id, created_at DESC);
+
+		-- Messages a member deleted for themselves only; everyone else still sees them
+		CREATE TABLE IF NOT EXISTS hidden_messages (
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+			chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+			hidden_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, message_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_hidden_messages_user_chat ON hidden_messages(user_id, chat_id);
+
+		-- Outbound webhooks, receiving events from the chats their owner belongs to
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			secret VARCHAR(128) NOT NULL,
+			events TEXT[] NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+		-- Service accounts, acting through API keys instead of a phone login
+		CREATE TABLE IF NOT EXISTS bots (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_bots_owner_id ON bots(owner_id);
+
+		-- Bot API keys, stored hashed and scoped to chats
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			bot_id UUID REFERENCES bots(user_id) ON DELETE CASCADE,
+			key_hash VARCHAR(64) UNIQUE NOT NULL,
+			chat_ids UUID[] NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_api_keys_bot_id ON api_keys(bot_id);
+
+		-- Messages that could not be persisted, kept for later reconciliation
+		CREATE TABLE IF NOT EXISTS failed_messages (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			chat_id UUID,
+			sender_id UUID,
+			client_msg_id VARCHAR(100),
+			payload JSONB NOT NULL,
+			error TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_failed_messages_created_at ON failed_messages(created_at);
+
+		-- Moderation actions per chat. No foreign keys, so entries outlive the
+		-- chat and users they refer to.
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			chat_id UUID NOT NULL,
+			actor_id UUID NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			target_id UUID,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_chat_id_created_at ON audit_log(chat_id, created_at DESC);
+
 		-- Group settings
 		CREATE TABLE IF NOT EXISTS group_settings (
 			chat_id UUID PRIMARY KEY REFERENCES chats(id) ON DELETE CASCADE,
@@ -187,6 +427,9 @@ func (s *Store) InitSchema() error {
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
 
+		-- Seconds after which new messages in the group disappear; 0 keeps them
+		ALTER TABLE group_settings ADD COLUMN IF NOT EXISTS disappearing_ttl INTEGER NOT NULL DEFAULT 0;
+
 		-- Group invites
 		CREATE TABLE IF NOT EXISTS group_invites (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -235,6 +478,13 @@ func (s *Store) InitSchema() error {
 			FOR EACH ROW
 			EXECUTE FUNCTION update_updated_at_column();
 
+		-- Read markers and cleared history are not membership changes
+		DROP TRIGGER IF EXISTS update_chat_members_updated_at ON chat_members;
+		CREATE TRIGGER update_chat_members_updated_at
+			BEFORE UPDATE OF role, is_admin, is_banned, banned_until, display_name ON chat_members
+			FOR EACH ROW
+			EXECUTE FUNCTION update_updated_at_column();
+
 		DROP TRIGGER IF EXISTS update_group_settings_updated_at ON group_settings;
 		CREATE TRIGGER update_group_settings_updated_at
 			BEFORE UPDATE ON group_settings
@@ -262,9 +512,11 @@ func (s *Store) Close() error {
 		errs = append(errs, fmt.Errorf("postgres close error: %w", err))
 	}
 
-	if err := s.RDB.Close(); err != nil {
-		s.logger.Error("Failed to close Redis connection", "error", err)
-		errs = append(errs, fmt.Errorf("redis close error: %w", err))
+	if s.RDB != nil {
+		if err := s.RDB.Close(); err != nil {
+			s.logger.Error("Failed to close Redis connection", "error", err)
+			errs = append(errs, fmt.Errorf("redis close error: %w", err))
+		}
 	}
 
 	if len(errs) > 0 {
@@ -276,13 +528,48 @@ func (s *Store) Close() error {
 	return nil
 }
 
-func (s *Store) StartCleanupWorker(interval time.Duration, maxAge time.Duration) {
-	s.logger.Info("Starting cleanup worker", "interval", interval, "max_age", maxAge)
+// readCountReconcileWindow is how far back the cleanup worker checks message read
+// counts for drift; older messages rarely gain new readers
+const readCountReconcileWindow = 7 * 24 * time.Hour
+
+// messageExpiryInterval is how often the cleanup worker purges disappearing
+// messages, which need much finer timing than the rest of its work
+const messageExpiryInterval = time.Minute
+
+// StartCleanupWorker periodically removes expired sessions and invites, archives
+// inactive chats, purges messages older than the retention set for their chat
+// type and corrects drifted message read counts. Chat types without a positive
+// retention keep their messages forever. Disappearing messages are purged every
+// messageExpiryInterval, and onExpired is called with each chat's purged
+// messages so members can be told.
+func (s *Store) StartCleanupWorker(
+	interval time.Duration,
+	maxAge time.Duration,
+	retention map[models.ChatType]time.Duration,
+	onExpired func(chatID string, messageIDs []string),
+) {
+	s.logger.Info("Starting cleanup worker", "interval", interval, "max_age", maxAge, "retention", retention)
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	expiryTicker := time.NewTicker(messageExpiryInterval)
+	defer expiryTicker.Stop()
+
+	for {
+		select {
+		case <-expiryTicker.C:
+			expired, err := s.PurgeExpiredMessages()
+			if err != nil {
+				s.logger.Error("Error purging expired messages", "error", err)
+				continue
+			}
+			for chatID, messageIDs := range expired {
+				onExpired(chatID, messageIDs)
+			}
+			continue
+		case <-ticker.C:
+		}
 
-	for range ticker.C {
 		s.logger.Debug("Running cleanup cycle")
 
 		// Delete expired sessions
@@ -299,6 +586,18 @@ func (s *Store) StartCleanupWorker(interval time.Duration, maxAge time.Duration)
 			}
 		}
 
+		// Delete expired refresh tokens. Used tokens are kept until then so reuse
+		// can still be detected.
+		result, err = s.DB.Exec(`DELETE FROM refresh_tokens WHERE expires_at < NOW()`)
+		if err != nil {
+			s.logger.Error("Error cleaning up refresh tokens", "error", err)
+		} else {
+			rows, _ := result.RowsAffected()
+			if rows > 0 {
+				s.logger.Debug("Cleaned up expired refresh tokens", "deleted_rows", rows)
+			}
+		}
+
 		// Delete expired group invites
 		result, err = s.DB.Exec(`
 			UPDATE group_invites 
@@ -329,5 +628,19 @@ func (s *Store) StartCleanupWorker(interval time.Duration, maxAge time.Duration)
 				s.logger.Debug("Archived inactive chats", "archived_chats", rows)
 			}
 		}
+
+		// Purge messages past their retention period
+		for chatType, olderThan := range retention {
+			if olderThan <= 0 {
+				continue
+			}
+			if _, err := s.PurgeOldMessages(olderThan, chatType); err != nil {
+				s.logger.Error("Error purging old messages", "error", err, "chat_type", chatType)
+			}
+		}
+
+		if _, err := s.ReconcileReadCounts(readCountReconcileWindow); err != nil {
+			s.logger.Error("Error reconciling message read counts", "error", err)
+		}
 	}
 }
@@ -0,0 +1,35 @@
+package store
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the requested record does not exist, or is not
+	// visible to the caller
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when a conditional update finds the row was
+	// modified since the caller last read it
+	ErrConflict = errors.New("resource was modified concurrently")
+
+	// ErrRefreshTokenInvalid is returned for refresh tokens that are unknown or expired
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again, which suggests it was stolen. Its session has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+	// ErrDirectChatMembers is returned when adding a member to a direct chat, which
+	// always has exactly two members
+	ErrDirectChatMembers = errors.New("direct chats cannot have members added")
+
+	// ErrDirectChatExists is returned when creating a direct chat loses a race with
+	// another request creating the same pair's chat; the caller should load that one
+	ErrDirectChatExists = errors.New("direct chat already exists")
+
+	// ErrChatClosed is returned when sending to a direct chat the other participant
+	// has left. The remaining member keeps its history but can't write to it.
+	ErrChatClosed = errors.New("chat is closed")
+
+	// ErrLastOwner is returned when a change would leave a chat without an owner
+	ErrLastOwner = errors.New("chat must keep at least one owner")
+)
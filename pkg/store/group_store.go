@@ -0,0 +1,157 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// GetGroupSettings returns the settings for a group chat, or ErrNotFound if the chat has none
+func (s *Store) GetGroupSettings(chatID string) (*models.GroupSettings, error) {
+	s.logger.Debug("Getting group settings", "chat_id", chatID)
+
+	if cached, err := s.GetCachedGroupSettings(chatID); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	query := `
+		SELECT chat_id, is_public, join_link, join_link_expires_at, admins_can_edit, members_can_invite,
+		       send_media_allowed, send_messages_allowed, slow_mode_delay, disappearing_ttl, created_at, updated_at
+		FROM group_settings WHERE chat_id = $1`
+
+	settings := &models.GroupSettings{}
+	err := s.DB.QueryRow(query, chatID).Scan(
+		&settings.ChatID, &settings.IsPublic, &settings.JoinLink,
+		&settings.JoinLinkExpiresAt, &settings.AdminsCanEdit, &settings.MembersCanInvite,
+		&settings.SendMediaAllowed, &settings.SendMessagesAllowed, &settings.SlowModeDelay,
+		&settings.DisappearingTTL, &settings.CreatedAt, &settings.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Group settings not found", "chat_id", chatID)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get group settings", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	go s.CacheGroupSettings(chatID, settings)
+
+	return settings, nil
+}
+
+// UpdateGroupSettings applies the fields set in req to a group's settings and
+// returns the result. Groups without a settings row get one, with the column
+// defaults for the fields req leaves out.
+func (s *Store) UpdateGroupSettings(chatID string, req *models.GroupSettingsRequest) (*models.GroupSettings, error) {
+	s.logger.Info("Updating group settings", "chat_id", chatID)
+
+	query := `
+		INSERT INTO group_settings (chat_id, is_public, admins_can_edit, members_can_invite,
+		                            send_media_allowed, send_messages_allowed, slow_mode_delay, disappearing_ttl)
+		VALUES ($1, COALESCE($2, FALSE), COALESCE($3, TRUE), COALESCE($4, TRUE),
+		        COALESCE($5, TRUE), COALESCE($6, TRUE), COALESCE($7, 0), COALESCE($8, 0))
+		ON CONFLICT (chat_id) DO UPDATE SET
+			is_public = COALESCE($2, group_settings.is_public),
+			admins_can_edit = COALESCE($3, group_settings.admins_can_edit),
+			members_can_invite = COALESCE($4, group_settings.members_can_invite),
+			send_media_allowed = COALESCE($5, group_settings.send_media_allowed),
+			send_messages_allowed = COALESCE($6, group_settings.send_messages_allowed),
+			slow_mode_delay = COALESCE($7, group_settings.slow_mode_delay),
+			disappearing_ttl = COALESCE($8, group_settings.disappearing_ttl)`
+
+	_, err := s.DB.Exec(query, chatID,
+		req.IsPublic, req.AdminsCanEdit, req.MembersCanInvite,
+		req.SendMediaAllowed, req.SendMessagesAllowed, req.SlowModeDelay, req.DisappearingTTL)
+	if err != nil {
+		s.logger.Error("Failed to update group settings", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	s.InvalidateGroupSettingsCache(chatID)
+	s.touchChat(chatID)
+	return s.GetGroupSettings(chatID)
+}
+
+// SetChatDisappearingTTL turns disappearing messages on for a group, so messages
+// sent from now on are purged ttl after they were sent. A ttl of zero turns it
+// off; messages already sent keep the expiry they were given.
+func (s *Store) SetChatDisappearingTTL(chatID string, ttl time.Duration) error {
+	seconds := int(ttl / time.Second)
+	_, err := s.UpdateGroupSettings(chatID, &models.GroupSettingsRequest{DisappearingTTL: &seconds})
+	return err
+}
+
+// CheckGroupSendPermission applies the group's send_messages_allowed and
+// send_media_allowed toggles to a message from userID. It returns a
+// human-readable reason when the message is not allowed, or "" otherwise.
+// Owners and admins are never restricted.
+func (s *Store) CheckGroupSendPermission(chatID, userID, contentType string) (string, error) {
+	settings, err := s.GetGroupSettings(chatID)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if settings.SendMessagesAllowed && settings.SendMediaAllowed {
+		return "", nil
+	}
+
+	isAdmin, err := s.IsChatAdmin(chatID, userID)
+	if err != nil {
+		return "", err
+	}
+	if isAdmin {
+		return "", nil
+	}
+
+	if !settings.SendMessagesAllowed {
+		return "Only admins can send messages to this group", nil
+	}
+	if !settings.SendMediaAllowed && contentType != string(models.ContentTypeText) {
+		return "Only admins can send media to this group", nil
+	}
+
+	return "", nil
+}
+
+// GetGroupStats returns member and message activity counts for a group. Active
+// members are those who sent a message in the last week; deleted messages are not
+// counted.
+func (s *Store) GetGroupStats(chatID string) (*models.GroupStats, error) {
+	s.logger.Debug("Getting group stats", "chat_id", chatID)
+
+	query := `
+		SELECT c.created_at, c.updated_at,
+		       (SELECT COUNT(*) FROM chat_members cm WHERE cm.chat_id = c.id AND cm.is_banned = FALSE),
+		       (SELECT COUNT(DISTINCT m.sender_id) FROM messages m
+		        WHERE m.chat_id = c.id AND m.is_deleted = FALSE AND m.sent_at > NOW() - INTERVAL '7 days'),
+		       (SELECT COUNT(*) FROM messages m
+		        WHERE m.chat_id = c.id AND m.is_deleted = FALSE AND m.sent_at >= CURRENT_DATE),
+		       (SELECT COUNT(*) FROM messages m
+		        WHERE m.chat_id = c.id AND m.is_deleted = FALSE AND m.sent_at > NOW() - INTERVAL '7 days'),
+		       (SELECT COUNT(*) FROM messages m
+		        WHERE m.chat_id = c.id AND m.is_deleted = FALSE AND m.sent_at > NOW() - INTERVAL '30 days')
+		FROM chats c
+		WHERE c.id = $1`
+
+	stats := &models.GroupStats{GroupID: chatID}
+	err := s.DB.QueryRow(query, chatID).Scan(
+		&stats.CreatedAt, &stats.UpdatedAt,
+		&stats.TotalMembers, &stats.ActiveMembers,
+		&stats.MessagesToday, &stats.MessagesWeek, &stats.MessagesMonth,
+	)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Group not found for stats", "chat_id", chatID)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get group stats", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	return stats, nil
+}
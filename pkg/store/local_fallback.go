@@ -0,0 +1,103 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindowPruneSize is how many rate limit windows are kept before expired
+// ones are swept out
+const rateWindowPruneSize = 1024
+
+// localFallback stands in for the Redis presence and rate limit keys when Redis
+// is unavailable and REDIS_OPTIONAL is set. It only sees this instance, which is
+// why that mode is meant for single-instance deployments.
+type localFallback struct {
+	mu          sync.Mutex
+	connections map[string]int // Live connections per user
+	rateWindows map[string]rateWindow
+}
+
+type rateWindow struct {
+	count     int
+	expiresAt time.Time
+}
+
+func newLocalFallback() *localFallback {
+	return &localFallback{
+		connections: make(map[string]int),
+		rateWindows: make(map[string]rateWindow),
+	}
+}
+
+// connect registers one more connection for the user and returns the new count
+func (l *localFallback) connect(userID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections[userID]++
+	return l.connections[userID]
+}
+
+// disconnect releases one of the user's connections and returns how many remain
+func (l *localFallback) disconnect(userID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	remaining := l.connections[userID] - 1
+	if remaining <= 0 {
+		delete(l.connections, userID)
+		return 0
+	}
+	l.connections[userID] = remaining
+	return remaining
+}
+
+func (l *localFallback) isOnline(userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.connections[userID] > 0
+}
+
+func (l *localFallback) onlineUsers() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	userIDs := make([]string, 0, len(l.connections))
+	for userID := range l.connections {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+func (l *localFallback) filterOnline(userIDs []string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	online := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if l.connections[userID] > 0 {
+			online = append(online, userID)
+		}
+	}
+	return online
+}
+
+// allow counts one event against the key's fixed window and reports whether it is
+// within limit, mirroring the Redis INCR/EXPIRE window used by AllowChatMessage
+func (l *localFallback) allow(key string, limit int, window time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.rateWindows[key]
+	if !ok || now.After(w.expiresAt) {
+		if len(l.rateWindows) >= rateWindowPruneSize {
+			for k, existing := range l.rateWindows {
+				if now.After(existing.expiresAt) {
+					delete(l.rateWindows, k)
+				}
+			}
+		}
+		w = rateWindow{expiresAt: now.Add(window)}
+	}
+	w.count++
+	l.rateWindows[key] = w
+	return w.count <= limit
+}
@@ -2,14 +2,60 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 )
 
+// mentionPattern matches @{userID} mention tokens in message content
+var mentionPattern = regexp.MustCompile(`@\{([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})\}`)
+
+// ParseMentions returns the distinct user IDs mentioned in content, in order of
+// first appearance
+func ParseMentions(content string) []string {
+	var userIDs []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		userID := strings.ToLower(match[1])
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
+// seedMessageStatus gives every member of the chat a status row for a new message:
+// delivered for the sender, sent for everyone else. It is one statement rather
+// than a round trip per member, which kept large group senders waiting. It
+// returns how many rows were written.
+func (s *Store) seedMessageStatus(q queryRunner, messageID, senderID, chatID string, now time.Time) (int64, error) {
+	res, err := s.execContext(q, `
+		INSERT INTO message_status (message_id, user_id, status, updated_at)
+		SELECT $1::uuid, user_id, CASE WHEN user_id = $2::uuid THEN $3 ELSE $4 END, $5::timestamp
+		FROM chat_members
+		WHERE chat_id = $6 AND is_banned = FALSE
+		ON CONFLICT (message_id, user_id) DO UPDATE
+		SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at`,
+		messageID, senderID, string(models.MessageStatusDelivered), string(models.MessageStatusSent), now, chatID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SaveMessage stores a new message with a status row per member. Mentions of
+// members other than the sender are recorded and returned in Mentions; mentions
+// of anyone outside the chat are ignored.
 func (s *Store) SaveMessage(
 	chatID, senderID, content, contentType string,
+	media *models.MessageMedia,
 	replyTo, forwardFrom *string,
 	forwarded bool,
 ) (*models.Message, error) {
@@ -34,6 +80,12 @@ func (s *Store) SaveMessage(
 		IsEdited:    false,
 		IsDeleted:   false,
 	}
+	if media != nil {
+		message.MediaURL = media.MediaURL
+		message.ThumbnailURL = media.ThumbnailURL
+		message.FileSize = media.FileSize
+		message.Duration = media.Duration
+	}
 
 	// Start transaction
 	tx, err := s.DB.Begin()
@@ -43,18 +95,23 @@ func (s *Store) SaveMessage(
 	}
 	defer tx.Rollback()
 
-	// Save message
+	// Save message. In groups with disappearing messages on, it expires once the
+	// group's TTL has passed.
 	query := `
-		INSERT INTO messages (id, chat_id, sender_id, content, content_type, status, sent_at, reply_to, forwarded, forward_from)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id`
-
-	err = tx.QueryRow(
-		query,
+		INSERT INTO messages (id, chat_id, sender_id, content, content_type, status, sent_at, reply_to, forwarded, forward_from,
+		                      media_url, thumbnail_url, file_size, duration, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+		        (SELECT $7::timestamp + make_interval(secs => gs.disappearing_ttl)
+		         FROM group_settings gs WHERE gs.chat_id = $2 AND gs.disappearing_ttl > 0))
+		RETURNING id, expires_at`
+
+	err = s.queryRowContext(
+		tx, query,
 		message.ID, message.ChatID, message.SenderID,
 		message.Content, message.ContentType, message.Status,
 		message.SentAt, message.ReplyTo, message.Forwarded, message.ForwardFrom,
-	).Scan(&message.ID)
+		message.MediaURL, message.ThumbnailURL, message.FileSize, message.Duration,
+	).Scan(&message.ID, &message.ExpiresAt)
 
 	if err != nil {
 		s.logger.Error("Failed to insert message",
@@ -72,32 +129,46 @@ func (s *Store) SaveMessage(
 		return nil, err
 	}
 
-	s.logger.Debug("Setting message status for members",
-		"message_id", messageID, "member_count", len(members))
-
-	// Set initial status for each member
-	for _, member := range members {
-		status := string(models.MessageStatusSent)
-		if member.UserID == senderID {
-			status = string(models.MessageStatusDelivered)
+	// Seed every member's status in the same transaction as the message, so no
+	// member can be missed
+	seeded, err := s.seedMessageStatus(tx, message.ID, senderID, chatID, now)
+	if err != nil {
+		s.logger.Error("Failed to set message status for members",
+			"error", err, "message_id", messageID, "chat_id", chatID)
+		return nil, err
+	}
+	s.logger.Debug("Message status set for members",
+		"message_id", messageID, "member_count", seeded)
+
+	// Record mentions of current members
+	if mentioned := ParseMentions(content); len(mentioned) > 0 {
+		isMember := make(map[string]bool, len(members))
+		for _, member := range members {
+			isMember[member.UserID] = true
 		}
-
-		_, err = tx.Exec(`
-			INSERT INTO message_status (message_id, user_id, status, updated_at)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (message_id, user_id) DO UPDATE
-			SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at`,
-			message.ID, member.UserID, status, now,
-		)
-		if err != nil {
-			s.logger.Error("Failed to set message status for member",
-				"error", err, "message_id", messageID, "user_id", member.UserID)
-			return nil, err
+		for _, userID := range mentioned {
+			if userID == senderID || !isMember[userID] {
+				continue
+			}
+			_, err = s.execContext(tx, `
+				INSERT INTO message_mentions (message_id, user_id, chat_id, created_at)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT DO NOTHING`,
+				message.ID, userID, chatID, now,
+			)
+			if err != nil {
+				s.logger.Error("Failed to record mention",
+					"error", err, "message_id", messageID, "user_id", userID)
+				return nil, err
+			}
+			message.Mentions = append(message.Mentions, userID)
 		}
+		s.logger.Debug("Mentions recorded",
+			"message_id", messageID, "parsed", len(mentioned), "recorded", len(message.Mentions))
 	}
 
 	// Mark as delivered for sender
-	_, err = tx.Exec(`
+	_, err = s.execContext(tx, `
 		UPDATE messages 
 		SET delivered_at = $1
 		WHERE id = $2 AND delivered_at IS NULL`,
@@ -110,7 +181,7 @@ func (s *Store) SaveMessage(
 	}
 
 	// Update chat last activity
-	_, err = tx.Exec(`UPDATE chats SET last_activity = $1 WHERE id = $2`, now, chatID)
+	_, err = s.execContext(tx, `UPDATE chats SET last_activity = $1 WHERE id = $2`, now, chatID)
 	if err != nil {
 		s.logger.Error("Failed to update chat last activity",
 			"error", err, "chat_id", chatID)
@@ -124,18 +195,460 @@ func (s *Store) SaveMessage(
 
 	// Invalidate cache
 	s.InvalidateChatMessagesCache(chatID)
+	recipients := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.UserID != senderID {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+	s.IncrementUnreadCounters(chatID, recipients)
+	// Mentioned members' chat lists carry an unread mention badge
+	for _, userID := range message.Mentions {
+		s.InvalidateUserChatsCache(userID)
+	}
 
 	s.logger.Info("Message saved successfully",
 		"message_id", messageID, "chat_id", chatID, "sender_id", senderID)
 	return message, nil
 }
 
+// IncrementForwardCount adds n forwards to a message and returns its new forward count
+func (s *Store) IncrementForwardCount(messageID string, n int) (int, error) {
+	s.logger.Debug("Incrementing forward count", "message_id", messageID, "by", n)
+
+	var count int
+	var chatID string
+	err := s.DB.QueryRow(`
+		UPDATE messages SET forward_count = forward_count + $2
+		WHERE id = $1
+		RETURNING forward_count, chat_id`,
+		messageID, n,
+	).Scan(&count, &chatID)
+	if err != nil {
+		s.logger.Error("Failed to increment forward count",
+			"error", err, "message_id", messageID)
+		return 0, err
+	}
+
+	s.InvalidateChatMessagesCache(chatID)
+
+	s.logger.Debug("Forward count incremented", "message_id", messageID, "forward_count", count)
+	return count, nil
+}
+
+// ReconcileReadCounts recomputes read_count from message_status for messages sent
+// within the given window, correcting any drift in the denormalized count. It
+// returns how many messages were corrected.
+func (s *Store) ReconcileReadCounts(window time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-window)
+	s.logger.Debug("Reconciling message read counts", "cutoff", cutoff)
+
+	rows, err := s.DB.Query(`
+		UPDATE messages m
+		SET read_count = counts.read_count
+		FROM (
+			SELECT m2.id, COUNT(ms.user_id) AS read_count
+			FROM messages m2
+			LEFT JOIN message_status ms ON ms.message_id = m2.id
+				AND ms.status = 'read' AND ms.user_id <> m2.sender_id
+			WHERE m2.sent_at >= $1
+			GROUP BY m2.id
+		) AS counts
+		WHERE m.id = counts.id AND m.read_count <> counts.read_count
+		RETURNING m.chat_id`,
+		cutoff,
+	)
+	if err != nil {
+		s.logger.Error("Failed to reconcile message read counts", "error", err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	var corrected int64
+	chatIDs := make(map[string]bool)
+	for rows.Next() {
+		var chatID string
+		if err := rows.Scan(&chatID); err != nil {
+			s.logger.Error("Failed to scan reconciled message row", "error", err)
+			return corrected, err
+		}
+		corrected++
+		chatIDs[chatID] = true
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to reconcile message read counts", "error", err)
+		return corrected, err
+	}
+
+	for chatID := range chatIDs {
+		s.InvalidateChatMessagesCache(chatID)
+		s.invalidateMemberCaches(chatID)
+	}
+
+	if corrected > 0 {
+		s.logger.Warn("Corrected drifted message read counts",
+			"corrected", corrected, "chat_count", len(chatIDs))
+	}
+	return corrected, nil
+}
+
+// GetLastReadMessage returns the latest message, not sent by readerID, that readerID
+// has read in a chat: the place for a "seen" marker. It is found from the reader's
+// last_read_at with a single index lookup rather than by scanning statuses. It
+// returns ErrNotFound if the reader hasn't read any such message.
+func (s *Store) GetLastReadMessage(chatID, readerID string) (*models.Message, error) {
+	s.logger.Debug("Getting last read message", "chat_id", chatID, "reader_id", readerID)
+
+	query := `
+		SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+		       m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+		       m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+		FROM chat_members cm
+		JOIN messages m ON m.chat_id = cm.chat_id
+		WHERE cm.chat_id = $1 AND cm.user_id = $2
+		AND m.sender_id <> $2
+		AND m.sent_at <= cm.last_read_at
+		AND m.is_deleted = FALSE
+		ORDER BY m.sent_at DESC, m.id DESC
+		LIMIT 1`
+
+	message := &models.Message{}
+	err := s.DB.QueryRow(query, chatID, readerID).Scan(
+		&message.ID, &message.ChatID, &message.SenderID,
+		&message.Content, &message.ContentType, &message.MediaURL,
+		&message.ThumbnailURL, &message.FileSize, &message.Duration,
+		&message.Status, &message.SentAt, &message.DeliveredAt,
+		&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+		&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+		&message.IsDeleted, &message.DeletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get last read message",
+			"error", err, "chat_id", chatID, "reader_id", readerID)
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetUnreadMessages returns the messages from others that userID has not read in a
+// chat, oldest first, so the first one is where a "new messages" divider goes.
+// Deleted messages and ones hidden by clearing history are left out.
+func (s *Store) GetUnreadMessages(chatID, userID string, limit, offset int) ([]models.Message, error) {
+	s.logger.Debug("Getting unread messages",
+		"chat_id", chatID, "user_id", userID, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+		       m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+		       m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+		FROM chat_members cm
+		JOIN messages m ON m.chat_id = cm.chat_id
+		WHERE cm.chat_id = $1 AND cm.user_id = $2
+		AND m.sender_id <> $2
+		AND m.sent_at > cm.last_read_at
+		AND m.is_deleted = FALSE
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+		ORDER BY m.sent_at ASC, m.id ASC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := s.DB.Query(query, chatID, userID, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query unread messages", "error", err, "chat_id", chatID, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan unread message", "error", err, "chat_id", chatID, "user_id", userID)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating unread messages", "error", err, "chat_id", chatID, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Debug("Unread messages retrieved", "chat_id", chatID, "user_id", userID, "count", len(messages))
+	return messages, nil
+}
+
+// GetMentions returns messages in which the user was mentioned, newest first.
+// Deleted messages, chats the user is banned from and messages hidden by clearing
+// history are left out.
+func (s *Store) GetMentions(userID string, limit, offset int) ([]models.Message, error) {
+	s.logger.Debug("Getting mentions", "user_id", userID, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+		       m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+		       m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+		FROM message_mentions mm
+		JOIN messages m ON m.id = mm.message_id
+		JOIN chat_members cm ON cm.chat_id = mm.chat_id AND cm.user_id = mm.user_id
+		WHERE mm.user_id = $1
+		AND m.is_deleted = FALSE
+		AND cm.is_banned = FALSE
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+		ORDER BY m.sent_at DESC, m.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.DB.Query(query, userID, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query mentions", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan mention", "error", err, "user_id", userID)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating mentions", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Debug("Mentions retrieved", "user_id", userID, "count", len(messages))
+	return messages, nil
+}
+
+// PurgeOldMessages hard-deletes messages sent more than olderThan ago in chats of
+// the given type. Replies to purged messages keep their content but lose the
+// reference; per-user statuses go with the message via ON DELETE CASCADE. As on
+// DeleteMessage, members' unread counters and chat lists are invalidated.
+func (s *Store) PurgeOldMessages(olderThan time.Duration, chatType models.ChatType) (int64, error) {
+	cutoff := time.Now().Add(-olderThan)
+	s.logger.Debug("Purging old messages", "chat_type", chatType, "cutoff", cutoff)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for PurgeOldMessages", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	purgeable := `
+		SELECT m.id FROM messages m
+		JOIN chats c ON c.id = m.chat_id
+		WHERE c.type = $1 AND m.sent_at < $2`
+
+	// reply_to has no ON DELETE action, so detach replies first
+	if _, err := tx.Exec(`UPDATE messages SET reply_to = NULL WHERE reply_to IN (`+purgeable+`)`,
+		chatType, cutoff); err != nil {
+		s.logger.Error("Failed to detach replies to purged messages", "error", err, "chat_type", chatType)
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+		DELETE FROM messages WHERE id IN (`+purgeable+`)
+		RETURNING chat_id`, chatType, cutoff)
+	if err != nil {
+		s.logger.Error("Failed to purge old messages", "error", err, "chat_type", chatType)
+		return 0, err
+	}
+
+	var purged int64
+	chatIDs := make(map[string]bool)
+	for rows.Next() {
+		var chatID string
+		if err := rows.Scan(&chatID); err != nil {
+			rows.Close()
+			s.logger.Error("Failed to scan purged message", "error", err)
+			return 0, err
+		}
+		chatIDs[chatID] = true
+		purged++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for PurgeOldMessages", "error", err)
+		return 0, err
+	}
+
+	for chatID := range chatIDs {
+		s.InvalidateChatMessagesCache(chatID)
+		s.invalidateMemberCaches(chatID)
+	}
+
+	if purged > 0 {
+		s.logger.Info("Purged old messages",
+			"chat_type", chatType, "older_than", olderThan, "purged", purged, "chats", len(chatIDs))
+	}
+	return purged, nil
+}
+
+// PurgeExpiredMessages hard-deletes disappearing messages whose expiry has passed
+// and returns their IDs by chat, so members can be told they are gone. Like
+// PurgeOldMessages, replies to them keep their content but lose the reference,
+// and members' unread counters and chat lists are invalidated.
+func (s *Store) PurgeExpiredMessages() (map[string][]string, error) {
+	s.logger.Debug("Purging expired messages")
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for PurgeExpiredMessages", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE messages SET reply_to = NULL
+		WHERE reply_to IN (SELECT id FROM messages WHERE expires_at <= NOW())`); err != nil {
+		s.logger.Error("Failed to detach replies to expired messages", "error", err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(`DELETE FROM messages WHERE expires_at <= NOW() RETURNING chat_id, id`)
+	if err != nil {
+		s.logger.Error("Failed to purge expired messages", "error", err)
+		return nil, err
+	}
+
+	purged := make(map[string][]string)
+	count := 0
+	for rows.Next() {
+		var chatID, messageID string
+		if err := rows.Scan(&chatID, &messageID); err != nil {
+			rows.Close()
+			s.logger.Error("Failed to scan expired message", "error", err)
+			return nil, err
+		}
+		purged[chatID] = append(purged[chatID], messageID)
+		count++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for PurgeExpiredMessages", "error", err)
+		return nil, err
+	}
+
+	for chatID := range purged {
+		s.InvalidateChatMessagesCache(chatID)
+		s.invalidateMemberCaches(chatID)
+	}
+
+	if count > 0 {
+		s.logger.Info("Purged expired messages", "purged", count, "chats", len(purged))
+	}
+	return purged, nil
+}
+
+// RecordFailedMessage stores a message that could not be saved after retrying,
+// so it can be reconciled later instead of being lost
+func (s *Store) RecordFailedMessage(chatID, senderID, clientMsgID string, payload []byte, attempts int, cause error) error {
+	s.logger.Info("Recording failed message",
+		"chat_id", chatID, "sender_id", senderID, "client_msg_id", clientMsgID, "attempts", attempts)
+
+	query := `
+		INSERT INTO failed_messages (chat_id, sender_id, client_msg_id, payload, error, attempts)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)`
+
+	_, err := s.DB.Exec(query, chatID, senderID, clientMsgID, payload, cause.Error(), attempts)
+	if err != nil {
+		s.logger.Error("Failed to record failed message",
+			"error", err, "chat_id", chatID, "sender_id", senderID)
+		return err
+	}
+
+	return nil
+}
+
+// ValidateReplyTo checks that a message being replied to exists, isn't deleted and
+// belongs to chatID. It returns a non-empty reason if the reply must be rejected.
+func (s *Store) ValidateReplyTo(chatID string, replyTo *string) (string, error) {
+	if replyTo == nil || *replyTo == "" {
+		return "", nil
+	}
+
+	original, err := s.GetMessage(*replyTo)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	if original == nil || original.ChatID != chatID {
+		s.logger.Debug("Reply target not in chat", "chat_id", chatID, "reply_to", *replyTo)
+		return "Replied message not found in this chat", nil
+	}
+	if original.IsDeleted {
+		return "Cannot reply to a deleted message", nil
+	}
+
+	return "", nil
+}
+
+// ValidateForward checks that the forwarded flag and forward_from are set together
+// and that the original sender exists. It returns a non-empty reason if the message
+// must be rejected.
+func (s *Store) ValidateForward(forwarded bool, forwardFrom *string) (string, error) {
+	hasSource := forwardFrom != nil && *forwardFrom != ""
+	if !forwarded && !hasSource {
+		return "", nil
+	}
+	if !forwarded {
+		return "forward_from requires forwarded to be true", nil
+	}
+	if !hasSource {
+		return "forward_from is required for forwarded messages", nil
+	}
+
+	if _, err := uuid.Parse(*forwardFrom); err != nil {
+		return "Original sender not found", nil
+	}
+	if _, err := s.GetUserByID(*forwardFrom); err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		s.logger.Debug("Forward source user not found", "forward_from", *forwardFrom)
+		return "Original sender not found", nil
+	}
+
+	return "", nil
+}
+
 func (s *Store) GetMessage(messageID string) (*models.Message, error) {
 	s.logger.Debug("Getting message", "message_id", messageID)
 
 	query := `
 		SELECT id, chat_id, sender_id, content, content_type, media_url, thumbnail_url, file_size, duration,
-		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from,
+		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from, forward_count, read_count, expires_at,
 		       is_edited, edited_at, is_deleted, deleted_at
 		FROM messages WHERE id = $1`
 
@@ -146,13 +659,13 @@ func (s *Store) GetMessage(messageID string) (*models.Message, error) {
 		&message.ThumbnailURL, &message.FileSize, &message.Duration,
 		&message.Status, &message.SentAt, &message.DeliveredAt,
 		&message.ReadAt, &message.ReplyTo, &message.Forwarded,
-		&message.ForwardFrom, &message.IsEdited, &message.EditedAt,
+		&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
 		&message.IsDeleted, &message.DeletedAt,
 	)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("Message not found", "message_id", messageID)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get message", "error", err, "message_id", messageID)
@@ -163,32 +676,286 @@ func (s *Store) GetMessage(messageID string) (*models.Message, error) {
 	return message, nil
 }
 
-func (s *Store) GetMessages(chatID string, offset, limit int) ([]models.Message, error) {
+// GetMessagesByStatus returns up to limit of a chat's messages whose overall status
+// is status, newest first. It is meant for diagnosing stuck or failed messages
+// and bypasses the message cache.
+func (s *Store) GetMessagesByStatus(chatID string, status models.MessageStatus, limit int) ([]models.Message, error) {
+	s.logger.Debug("Getting messages by status", "chat_id", chatID, "status", status, "limit", limit)
+
+	query := `
+		SELECT id, chat_id, sender_id, content, content_type, media_url, thumbnail_url, file_size, duration,
+		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from, forward_count, read_count, expires_at,
+		       is_edited, edited_at, is_deleted, deleted_at
+		FROM messages
+		WHERE status = $2 AND chat_id = $1 AND is_deleted = FALSE
+		ORDER BY sent_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := s.DB.Query(query, chatID, status, limit)
+	if err != nil {
+		s.logger.Error("Failed to query messages by status",
+			"error", err, "chat_id", chatID, "status", status)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan message row",
+				"error", err, "chat_id", chatID, "status", status)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to query messages by status",
+			"error", err, "chat_id", chatID, "status", status)
+		return nil, err
+	}
+
+	s.logger.Debug("Retrieved messages by status",
+		"chat_id", chatID, "status", status, "message_count", len(messages))
+	return messages, nil
+}
+
+// GetChatMedia lists the attachments of a chat's messages, newest first, skipping
+// those the user cleared from their history. An empty contentType includes every
+// media type.
+func (s *Store) GetChatMedia(chatID, userID string, contentType models.ContentType, limit, offset int) ([]models.MediaItem, error) {
+	s.logger.Debug("Getting chat media",
+		"chat_id", chatID, "user_id", userID, "content_type", contentType, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT id, chat_id, content_type, media_url, thumbnail_url, file_size, duration, content, sender_id, sent_at
+		FROM messages
+		WHERE chat_id = $1
+		AND media_url IS NOT NULL
+		AND is_deleted = FALSE
+		AND ($2::text = '' OR content_type = $2::text)
+		AND sent_at > COALESCE(
+			(SELECT cleared_before FROM chat_members WHERE chat_id = $1 AND user_id = $3),
+			'-infinity'::timestamp)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $3 AND hm.message_id = messages.id)
+		ORDER BY sent_at DESC, id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := s.DB.Query(query, chatID, string(contentType), userID, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query chat media", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items, err := scanMediaItems(rows)
+	if err != nil {
+		s.logger.Error("Failed to scan chat media", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	s.logger.Debug("Retrieved chat media", "chat_id", chatID, "count", len(items))
+	return items, nil
+}
+
+// GetSharedMedia returns the attachments userA and userB have sent each other,
+// newest first: media either of them posted in a chat they are both members of.
+// An empty contentType includes every media type. Chats userA is banned from and
+// messages userA deleted for themselves or hid by clearing history are left out.
+func (s *Store) GetSharedMedia(userA, userB string, contentType models.ContentType, limit, offset int) ([]models.MediaItem, error) {
+	s.logger.Debug("Getting shared media",
+		"user_a", userA, "user_b", userB, "content_type", contentType, "limit", limit, "offset", offset)
+
+	query := `
+		SELECT m.id, m.chat_id, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration, m.content, m.sender_id, m.sent_at
+		FROM chat_members cm
+		JOIN chat_members peer ON peer.chat_id = cm.chat_id AND peer.user_id = $2
+		JOIN messages m ON m.chat_id = cm.chat_id
+		WHERE cm.user_id = $1
+		AND cm.is_banned = FALSE
+		AND m.sender_id IN ($1, $2)
+		AND m.media_url IS NOT NULL
+		AND m.is_deleted = FALSE
+		AND ($3::text = '' OR m.content_type = $3::text)
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $1 AND hm.message_id = m.id)
+		ORDER BY m.sent_at DESC, m.id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := s.DB.Query(query, userA, userB, string(contentType), limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to query shared media", "error", err, "user_a", userA, "user_b", userB)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items, err := scanMediaItems(rows)
+	if err != nil {
+		s.logger.Error("Failed to scan shared media", "error", err, "user_a", userA, "user_b", userB)
+		return nil, err
+	}
+
+	s.logger.Debug("Retrieved shared media", "user_a", userA, "user_b", userB, "count", len(items))
+	return items, nil
+}
+
+// scanMediaItems reads rows selecting id, chat_id, content_type, media_url,
+// thumbnail_url, file_size, duration, content, sender_id and sent_at
+func scanMediaItems(rows *sql.Rows) ([]models.MediaItem, error) {
+	items := []models.MediaItem{}
+	for rows.Next() {
+		var item models.MediaItem
+		err := rows.Scan(
+			&item.MessageID, &item.ChatID, &item.ContentType,
+			&item.MediaURL, &item.ThumbnailURL, &item.FileSize, &item.Duration,
+			&item.Caption, &item.SenderID, &item.SentAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetMessages returns a page of the chat's messages as userID sees them. It returns
+// ErrNotFound if userID isn't a member.
+func (s *Store) GetMessages(chatID, userID string, offset, limit int) ([]models.Message, error) {
 	s.logger.Debug("Getting messages",
-		"chat_id", chatID, "offset", offset, "limit", limit)
+		"chat_id", chatID, "user_id", userID, "offset", offset, "limit", limit)
+
+	// Members who cleared their history or deleted messages for themselves get a
+	// personal view that bypasses the shared cache
+	clearedBefore, err := s.getClearedBefore(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	hasHidden, err := s.hasHiddenMessages(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	personal := clearedBefore != nil || hasHidden
 
 	// Try cache first
-	if cached, err := s.GetCachedChatMessages(chatID); err == nil && cached != nil {
-		if offset == 0 && len(cached) <= limit {
-			s.logger.Debug("Retrieved messages from cache",
-				"chat_id", chatID, "message_count", len(cached))
-			return cached, nil
+	if !personal {
+		if cached, err := s.GetCachedChatMessages(chatID); err == nil && cached != nil {
+			if offset == 0 && len(cached) <= limit {
+				s.logger.Debug("Retrieved messages from cache",
+					"chat_id", chatID, "message_count", len(cached))
+				return cached, nil
+			}
 		}
 	}
 
 	query := `
 		SELECT id, chat_id, sender_id, content, content_type, media_url, thumbnail_url, file_size, duration,
-		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from,
+		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from, forward_count, read_count, expires_at,
 		       is_edited, edited_at, is_deleted, deleted_at
 		FROM messages 
 		WHERE chat_id = $1 AND is_deleted = FALSE
-		ORDER BY sent_at DESC
+		AND ($4::timestamp IS NULL OR sent_at > $4)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $5 AND hm.message_id = messages.id)
+		ORDER BY sent_at DESC, id DESC
 		LIMIT $2 OFFSET $3`
 
-	rows, err := s.DB.Query(query, chatID, limit, offset)
+	rows, err := s.queryContext(s.DB, query, chatID, limit, offset, clearedBefore, userID)
+	if err != nil {
+		s.logger.Error("Failed to query messages",
+			"error", err, "chat_id", chatID, "offset", offset, "limit", limit)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan message row",
+				"error", err, "chat_id", chatID)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	// Reverse to get chronological order
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	s.logger.Debug("Retrieved messages from database",
+		"chat_id", chatID, "message_count", len(messages))
+
+	// Cache first page
+	if offset == 0 && !personal {
+		go s.CacheChatMessages(chatID, messages)
+	}
+
+	return messages, nil
+}
+
+// GetMissedMessages returns up to limit of the chat's messages that come after the
+// message afterID, oldest first, as the user sees them: deleted messages, ones
+// they deleted for themselves and history they cleared are left out. Messages are ordered by (sent_at, id), so a
+// client resuming from its last seen message gets neither gaps nor repeats. An
+// empty afterID starts from the beginning. It returns ErrNotFound if afterID is
+// not a message in the chat.
+func (s *Store) GetMissedMessages(chatID, userID, afterID string, limit int) ([]models.Message, error) {
+	s.logger.Debug("Getting missed messages",
+		"chat_id", chatID, "user_id", userID, "after", afterID, "limit", limit)
+
+	var afterSentAt *time.Time
+	var afterMessageID *string
+	if afterID != "" {
+		var sentAt time.Time
+		err := s.DB.QueryRow(`SELECT sent_at FROM messages WHERE id = $1 AND chat_id = $2`,
+			afterID, chatID).Scan(&sentAt)
+		if err == sql.ErrNoRows {
+			s.logger.Debug("Resume cursor not found", "chat_id", chatID, "after", afterID)
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			s.logger.Error("Failed to get resume cursor",
+				"error", err, "chat_id", chatID, "after", afterID)
+			return nil, err
+		}
+		afterSentAt, afterMessageID = &sentAt, &afterID
+	}
+
+	query := `
+		SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+		       m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+		       m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+		FROM messages m
+		JOIN chat_members cm ON cm.chat_id = m.chat_id AND cm.user_id = $2
+		WHERE m.chat_id = $1 AND m.is_deleted = FALSE
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $2 AND hm.message_id = m.id)
+		AND ($3::timestamp IS NULL OR (m.sent_at, m.id) > ($3::timestamp, $4::uuid))
+		ORDER BY m.sent_at, m.id
+		LIMIT $5`
+
+	rows, err := s.DB.Query(query, chatID, userID, afterSentAt, afterMessageID, limit)
 	if err != nil {
-		s.logger.Error("Failed to query messages",
-			"error", err, "chat_id", chatID, "offset", offset, "limit", limit)
+		s.logger.Error("Failed to query missed messages",
+			"error", err, "chat_id", chatID, "after", afterID)
 		return nil, err
 	}
 	defer rows.Close()
@@ -202,7 +969,7 @@ func (s *Store) GetMessages(chatID string, offset, limit int) ([]models.Message,
 			&message.ThumbnailURL, &message.FileSize, &message.Duration,
 			&message.Status, &message.SentAt, &message.DeliveredAt,
 			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
-			&message.ForwardFrom, &message.IsEdited, &message.EditedAt,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
 			&message.IsDeleted, &message.DeletedAt,
 		)
 		if err != nil {
@@ -212,20 +979,14 @@ func (s *Store) GetMessages(chatID string, offset, limit int) ([]models.Message,
 		}
 		messages = append(messages, message)
 	}
-
-	// Reverse to get chronological order
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
-	}
-
-	s.logger.Debug("Retrieved messages from database",
-		"chat_id", chatID, "message_count", len(messages))
-
-	// Cache first page
-	if offset == 0 {
-		go s.CacheChatMessages(chatID, messages)
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to query missed messages",
+			"error", err, "chat_id", chatID, "after", afterID)
+		return nil, err
 	}
 
+	s.logger.Debug("Retrieved missed messages",
+		"chat_id", chatID, "after", afterID, "message_count", len(messages))
 	return messages, nil
 }
 
@@ -242,6 +1003,20 @@ func (s *Store) UpdateMessageStatus(messageID, userID, status string) error {
 
 	now := time.Now()
 
+	// The previous status decides whether the message's read count changes
+	var previous sql.NullString
+	err = tx.QueryRow(`
+		SELECT status FROM message_status
+		WHERE message_id = $1 AND user_id = $2
+		FOR UPDATE`,
+		messageID, userID,
+	).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		s.logger.Error("Failed to get previous message status",
+			"error", err, "message_id", messageID, "user_id", userID)
+		return err
+	}
+
 	// Update message_status table
 	query := `
 		INSERT INTO message_status (message_id, user_id, status, updated_at)
@@ -283,6 +1058,27 @@ func (s *Store) UpdateMessageStatus(messageID, userID, status string) error {
 		}
 	}
 
+	// Keep the read count in step when the user's status moves to or from read
+	wasRead := previous.String == string(models.MessageStatusRead)
+	isRead := status == string(models.MessageStatusRead)
+	if wasRead != isRead {
+		delta := 1
+		if wasRead {
+			delta = -1
+		}
+		_, err = tx.Exec(`
+			UPDATE messages
+			SET read_count = GREATEST(read_count + $1, 0)
+			WHERE id = $2 AND sender_id <> $3`,
+			delta, messageID, userID,
+		)
+		if err != nil {
+			s.logger.Error("Failed to update message read count",
+				"error", err, "message_id", messageID)
+			return err
+		}
+	}
+
 	// Get message to update chat last activity
 	var chatID string
 	err = tx.QueryRow("SELECT chat_id FROM messages WHERE id = $1", messageID).Scan(&chatID)
@@ -292,17 +1088,20 @@ func (s *Store) UpdateMessageStatus(messageID, userID, status string) error {
 		return err
 	}
 
-	// Update member's last read time
-	_, err = tx.Exec(`
-		UPDATE chat_members 
-		SET last_read_at = $1 
-		WHERE chat_id = $2 AND user_id = $3`,
-		now, chatID, userID,
-	)
-	if err != nil {
-		s.logger.Error("Failed to update member last read time",
-			"error", err, "chat_id", chatID, "user_id", userID)
-		return err
+	// Update member's last read time; a delivery receipt doesn't mean the user has
+	// seen anything
+	if status == string(models.MessageStatusRead) {
+		_, err = tx.Exec(`
+			UPDATE chat_members 
+			SET last_read_at = $1 
+			WHERE chat_id = $2 AND user_id = $3`,
+			now, chatID, userID,
+		)
+		if err != nil {
+			s.logger.Error("Failed to update member last read time",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -312,6 +1111,10 @@ func (s *Store) UpdateMessageStatus(messageID, userID, status string) error {
 
 	// Invalidate cache
 	s.InvalidateChatMessagesCache(chatID)
+	s.InvalidateMessageStatusCache(messageID)
+	if status == string(models.MessageStatusRead) {
+		s.ResetUnreadCounter(chatID, userID)
+	}
 
 	s.logger.Info("Message status updated successfully",
 		"message_id", messageID, "user_id", userID, "status", status)
@@ -360,11 +1163,28 @@ func (s *Store) DeleteMessage(messageID string) error {
 
 	// Invalidate cache
 	s.InvalidateChatMessagesCache(chatID)
+	s.invalidateMemberCaches(chatID)
 
 	s.logger.Info("Message deleted successfully", "message_id", messageID)
 	return nil
 }
 
+// invalidateMemberCaches drops the unread counters and chat lists of a chat's
+// members after messages are removed from it: members who hadn't read them would
+// keep counting them, and one may be their chat list's last message preview
+func (s *Store) invalidateMemberCaches(chatID string) {
+	members, err := s.GetChatMembers(chatID)
+	if err != nil {
+		return
+	}
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+		s.InvalidateUserChatsCache(member.UserID)
+	}
+	s.InvalidateUnreadCounters(userIDs...)
+}
+
 func (s *Store) GetMessageStatus(messageID, userID string) (string, error) {
 	s.logger.Debug("Getting message status", "message_id", messageID, "user_id", userID)
 
@@ -386,6 +1206,162 @@ func (s *Store) GetMessageStatus(messageID, userID string) (string, error) {
 	return status, nil
 }
 
+// GetMessageStatusSummary returns who a message has been delivered to and read by.
+// Recipients that have read the message only appear in ReadBy.
+func (s *Store) GetMessageStatusSummary(messageID string) (*models.MessageStatusSummary, error) {
+	s.logger.Debug("Getting message status summary", "message_id", messageID)
+
+	if cached, err := s.GetCachedMessageStatus(messageID); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	query := `
+		SELECT user_id, status, updated_at
+		FROM message_status
+		WHERE message_id = $1
+		ORDER BY updated_at`
+
+	rows, err := s.DB.Query(query, messageID)
+	if err != nil {
+		s.logger.Error("Failed to query message status summary", "error", err, "message_id", messageID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := &models.MessageStatusSummary{
+		MessageID:   messageID,
+		DeliveredTo: []models.MessageStatusEntry{},
+		ReadBy:      []models.MessageStatusEntry{},
+	}
+	for rows.Next() {
+		var entry models.MessageStatusEntry
+		if err := rows.Scan(&entry.UserID, &entry.Status, &entry.UpdatedAt); err != nil {
+			s.logger.Error("Failed to scan message status row", "error", err, "message_id", messageID)
+			return nil, err
+		}
+
+		switch entry.Status {
+		case string(models.MessageStatusRead):
+			summary.ReadBy = append(summary.ReadBy, entry)
+		case string(models.MessageStatusDelivered):
+			summary.DeliveredTo = append(summary.DeliveredTo, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to iterate message status rows", "error", err, "message_id", messageID)
+		return nil, err
+	}
+
+	// Cached before returning rather than in the background, so a write landing
+	// after this read invalidates the entry instead of being overwritten by it
+	if err := s.CacheMessageStatus(messageID, summary); err != nil {
+		s.logger.Warn("Failed to cache message status summary", "error", err, "message_id", messageID)
+	}
+
+	s.logger.Debug("Message status summary retrieved",
+		"message_id", messageID,
+		"delivered_count", len(summary.DeliveredTo),
+		"read_count", len(summary.ReadBy))
+	return summary, nil
+}
+
+// GetTotalUnread counts unread messages from others across all of the user's chats,
+// ignoring deleted messages and history the user has cleared
+func (s *Store) GetTotalUnread(userID string) (*models.UnreadSummary, error) {
+	s.logger.Debug("Getting total unread", "user_id", userID)
+
+	if cached, err := s.GetCachedTotalUnread(userID); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	query := `
+		SELECT COUNT(*), COUNT(DISTINCT m.chat_id)
+		FROM chat_members cm
+		JOIN messages m ON m.chat_id = cm.chat_id
+		WHERE cm.user_id = $1
+		AND m.sent_at > cm.last_read_at
+		AND m.sender_id <> $1
+		AND m.is_deleted = FALSE
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)`
+
+	summary := &models.UnreadSummary{}
+	if err := s.DB.QueryRow(query, userID).Scan(&summary.TotalUnread, &summary.UnreadChats); err != nil {
+		s.logger.Error("Failed to get total unread", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.CacheTotalUnread(userID, summary)
+
+	s.logger.Debug("Total unread retrieved",
+		"user_id", userID, "total_unread", summary.TotalUnread, "unread_chats", summary.UnreadChats)
+	return summary, nil
+}
+
+// GetUnreadCounts returns the user's unread message count for each of their chats,
+// counting the same messages as GetTotalUnread. Counts come from the Redis
+// counters; when those are missing or don't cover every chat they are rebuilt
+// from the database, and cached unless the counters changed during the rebuild.
+func (s *Store) GetUnreadCounts(userID string, chatIDs []string) (map[string]int, error) {
+	cached, err := s.GetCachedUnreadCounters(userID)
+	if err == nil && cached != nil {
+		complete := true
+		for _, chatID := range chatIDs {
+			if _, ok := cached[chatID]; !ok {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return cached, nil
+		}
+	}
+
+	s.logger.Debug("Rebuilding unread counters", "user_id", userID)
+
+	// Noted before counting, so updates made while the query runs make the
+	// rebuilt counts stale and keep them out of the cache
+	generation, genErr := s.UnreadCountersGeneration(userID)
+
+	query := `
+		SELECT cm.chat_id, COUNT(m.id)
+		FROM chat_members cm
+		LEFT JOIN messages m ON m.chat_id = cm.chat_id
+		AND m.sent_at > cm.last_read_at
+		AND m.sender_id <> cm.user_id
+		AND m.is_deleted = FALSE
+		AND (cm.cleared_before IS NULL OR m.sent_at > cm.cleared_before)
+		WHERE cm.user_id = $1
+		GROUP BY cm.chat_id`
+
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		s.logger.Error("Failed to count unread messages per chat", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var chatID string
+		var count int
+		if err := rows.Scan(&chatID, &count); err != nil {
+			s.logger.Error("Failed to scan unread count row", "error", err, "user_id", userID)
+			return nil, err
+		}
+		counts[chatID] = count
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to count unread messages per chat", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	if genErr == nil {
+		s.CacheUnreadCounters(userID, generation, counts)
+	}
+
+	return counts, nil
+}
+
 func (s *Store) GetUnreadMessagesCount(chatID, userID string) (int, error) {
 	s.logger.Debug("Getting unread messages count", "chat_id", chatID, "user_id", userID)
 
@@ -436,7 +1412,7 @@ func (s *Store) MarkChatAsRead(chatID, userID string) error {
 	}
 
 	// Update message status for all unread messages
-	result, err := tx.Exec(`
+	rows, err := tx.Query(`
 		INSERT INTO message_status (message_id, user_id, status, updated_at)
 		SELECT m.id, $1, 'read', $2
 		FROM messages m
@@ -447,7 +1423,8 @@ func (s *Store) MarkChatAsRead(chatID, userID string) error {
 			WHERE ms.message_id = m.id AND ms.user_id = $1 AND ms.status = 'read'
 		)
 		ON CONFLICT (message_id, user_id) DO UPDATE
-		SET status = 'read', updated_at = EXCLUDED.updated_at`,
+		SET status = 'read', updated_at = EXCLUDED.updated_at
+		RETURNING message_id`,
 		userID, now, chatID,
 	)
 	if err != nil {
@@ -456,8 +1433,40 @@ func (s *Store) MarkChatAsRead(chatID, userID string) error {
 		return err
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	s.logger.Debug("Updated message statuses", "rows_affected", rowsAffected)
+	var readMessageIDs []string
+	for rows.Next() {
+		var messageID string
+		if err := rows.Scan(&messageID); err != nil {
+			rows.Close()
+			s.logger.Error("Failed to scan updated message status row",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+		readMessageIDs = append(readMessageIDs, messageID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to update message statuses",
+			"error", err, "chat_id", chatID, "user_id", userID)
+		return err
+	}
+
+	s.logger.Debug("Updated message statuses", "rows_affected", len(readMessageIDs))
+
+	// Every returned message was newly read by the user
+	if len(readMessageIDs) > 0 {
+		_, err = tx.Exec(`
+			UPDATE messages
+			SET read_count = read_count + 1
+			WHERE id = ANY($1) AND sender_id <> $2`,
+			pq.Array(readMessageIDs), userID,
+		)
+		if err != nil {
+			s.logger.Error("Failed to update message read counts",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+	}
 
 	// Update messages read_at timestamp
 	_, err = tx.Exec(`
@@ -486,27 +1495,84 @@ func (s *Store) MarkChatAsRead(chatID, userID string) error {
 
 	// Invalidate cache
 	s.InvalidateChatMessagesCache(chatID)
+	s.InvalidateMessageStatusCaches(readMessageIDs)
+	s.InvalidateTotalUnreadCache(userID)
+	s.InvalidateUserChatsCache(userID)
+	s.ResetUnreadCounter(chatID, userID)
 
 	s.logger.Info("Chat marked as read successfully", "chat_id", chatID, "user_id", userID)
 	return nil
 }
 
-func (s *Store) SearchMessages(chatID, queryStr string, limit int) ([]models.Message, error) {
+// MarkChatAsUnread moves the member's read marker back to just before
+// fromMessageID, or before the latest message from someone else when it is empty,
+// so the chat shows as unread again. The marker never moves forward, and read
+// receipts already sent are kept. It returns ErrNotFound if there is no such
+// message in the chat.
+func (s *Store) MarkChatAsUnread(chatID, userID, fromMessageID string) error {
+	s.logger.Info("Marking chat as unread", "chat_id", chatID, "user_id", userID, "from_message_id", fromMessageID)
+
+	var fromID *string
+	if fromMessageID != "" {
+		fromID = &fromMessageID
+	}
+
+	query := `
+		UPDATE chat_members cm
+		SET last_read_at = LEAST(cm.last_read_at, m.sent_at - INTERVAL '1 microsecond')
+		FROM messages m
+		WHERE cm.chat_id = $1 AND cm.user_id = $2
+		AND m.id = (
+			SELECT id FROM messages
+			WHERE chat_id = $1 AND is_deleted = FALSE
+			AND (id = $3::uuid OR ($3::uuid IS NULL AND sender_id <> $2))
+			ORDER BY sent_at DESC, id DESC
+			LIMIT 1
+		)`
+
+	result, err := s.DB.Exec(query, chatID, userID, fromID)
+	if err != nil {
+		s.logger.Error("Failed to mark chat as unread",
+			"error", err, "chat_id", chatID, "user_id", userID)
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.logger.Debug("No message to mark unread from",
+			"chat_id", chatID, "user_id", userID, "from_message_id", fromMessageID)
+		return ErrNotFound
+	}
+
+	s.InvalidateUnreadCounters(userID)
+	s.InvalidateTotalUnreadCache(userID)
+	s.InvalidateUserChatsCache(userID)
+
+	s.logger.Info("Chat marked as unread", "chat_id", chatID, "user_id", userID)
+	return nil
+}
+
+// SearchMessages finds messages in a chat containing queryStr, newest first. from and
+// to bound sent_at inclusively; either may be nil to leave that side open.
+func (s *Store) SearchMessages(chatID, userID, queryStr string, from, to *time.Time, offset, limit int) ([]models.Message, error) {
 	s.logger.Info("Searching messages",
-		"chat_id", chatID, "query", queryStr, "limit", limit)
+		"chat_id", chatID, "user_id", userID, "query", queryStr, "from", from, "to", to, "offset", offset, "limit", limit)
 
 	searchQuery := `
 		SELECT id, chat_id, sender_id, content, content_type, media_url, thumbnail_url, file_size, duration,
-		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from,
+		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from, forward_count, read_count, expires_at,
 		       is_edited, edited_at, is_deleted, deleted_at
 		FROM messages 
 		WHERE chat_id = $1 
 		AND content ILIKE $2
 		AND is_deleted = FALSE
-		ORDER BY sent_at DESC
-		LIMIT $3`
-
-	rows, err := s.DB.Query(searchQuery, chatID, "%"+queryStr+"%", limit)
+		AND sent_at > COALESCE(
+			(SELECT cleared_before FROM chat_members WHERE chat_id = $1 AND user_id = $4),
+			'-infinity'::timestamp)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $4 AND hm.message_id = messages.id)
+		AND sent_at BETWEEN COALESCE($6, '-infinity'::timestamp) AND COALESCE($7, 'infinity'::timestamp)
+		ORDER BY sent_at DESC, id DESC
+		LIMIT $3 OFFSET $5`
+
+	rows, err := s.DB.Query(searchQuery, chatID, "%"+queryStr+"%", limit, userID, offset, from, to)
 	if err != nil {
 		s.logger.Error("Failed to search messages",
 			"error", err, "chat_id", chatID, "query", queryStr)
@@ -523,7 +1589,7 @@ func (s *Store) SearchMessages(chatID, queryStr string, limit int) ([]models.Mes
 			&message.ThumbnailURL, &message.FileSize, &message.Duration,
 			&message.Status, &message.SentAt, &message.DeliveredAt,
 			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
-			&message.ForwardFrom, &message.IsEdited, &message.EditedAt,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
 			&message.IsDeleted, &message.DeletedAt,
 		)
 		if err != nil {
@@ -534,6 +1600,286 @@ func (s *Store) SearchMessages(chatID, queryStr string, limit int) ([]models.Mes
 	}
 
 	s.logger.Info("Message search completed",
-		"chat_id", chatID, "query", queryStr, "results", len(messages), "limit", limit)
+		"chat_id", chatID, "query", queryStr, "results", len(messages), "offset", offset, "limit", limit)
+	return messages, nil
+}
+
+// GetMessagesAfter returns up to limit of the chat's messages after the (sent_at, id)
+// cursor, oldest first, as userID sees them: history they cleared and messages they
+// deleted for themselves are left out
+func (s *Store) GetMessagesAfter(chatID, userID string, afterSentAt time.Time, afterID string, limit int) ([]models.Message, error) {
+	s.logger.Debug("Getting messages after cursor",
+		"chat_id", chatID, "user_id", userID, "after_sent_at", afterSentAt, "after_id", afterID, "limit", limit)
+
+	query := `
+		SELECT id, chat_id, sender_id, content, content_type, media_url, thumbnail_url, file_size, duration,
+		       status, sent_at, delivered_at, read_at, reply_to, forwarded, forward_from, forward_count, read_count, expires_at,
+		       is_edited, edited_at, is_deleted, deleted_at
+		FROM messages 
+		WHERE chat_id = $1 AND is_deleted = FALSE
+		AND (sent_at, id) > ($2, $3::uuid)
+		AND sent_at > COALESCE(
+			(SELECT cleared_before FROM chat_members WHERE chat_id = $1 AND user_id = $5),
+			'-infinity'::timestamp)
+		AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $5 AND hm.message_id = messages.id)
+		ORDER BY sent_at ASC, id ASC
+		LIMIT $4`
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	rows, err := s.DB.Query(query, chatID, afterSentAt, afterID, limit, userID)
+	if err != nil {
+		s.logger.Error("Failed to query messages after cursor",
+			"error", err, "chat_id", chatID, "after_sent_at", afterSentAt)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan message row after cursor",
+				"error", err, "chat_id", chatID)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	s.logger.Debug("Retrieved messages after cursor",
+		"chat_id", chatID, "message_count", len(messages))
 	return messages, nil
 }
+
+// GetMessagesAround returns up to radius messages on either side of messageID in
+// chronological order, as userID sees them: history they cleared and messages they
+// deleted for themselves are left out. It returns ErrNotFound if the target is not
+// a live message in chatID that userID can see.
+func (s *Store) GetMessagesAround(chatID, userID, messageID string, radius int) (*models.MessagesAround, error) {
+	s.logger.Debug("Getting messages around target",
+		"chat_id", chatID, "user_id", userID, "message_id", messageID, "radius", radius)
+
+	// Fetch one extra row on each side to know whether more messages exist
+	query := `
+		WITH cleared AS (
+			SELECT COALESCE(
+				(SELECT cleared_before FROM chat_members WHERE chat_id = $1 AND user_id = $4),
+				'-infinity'::timestamp) AS before
+		),
+		target AS (
+			SELECT m.sent_at, m.id FROM messages m, cleared c
+			WHERE m.id = $2 AND m.chat_id = $1 AND m.is_deleted = FALSE
+			AND m.sent_at > c.before
+			AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $4 AND hm.message_id = m.id)
+		)
+		SELECT * FROM (
+			(SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+			        m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+			        m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+			FROM messages m, target t, cleared c
+			WHERE m.chat_id = $1 AND m.is_deleted = FALSE
+			AND m.sent_at > c.before
+			AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $4 AND hm.message_id = m.id)
+			AND (m.sent_at, m.id) < (t.sent_at, t.id)
+			ORDER BY m.sent_at DESC, m.id DESC
+			LIMIT $3)
+			UNION ALL
+			(SELECT m.id, m.chat_id, m.sender_id, m.content, m.content_type, m.media_url, m.thumbnail_url, m.file_size, m.duration,
+			        m.status, m.sent_at, m.delivered_at, m.read_at, m.reply_to, m.forwarded, m.forward_from, m.forward_count, m.read_count, m.expires_at,
+			        m.is_edited, m.edited_at, m.is_deleted, m.deleted_at
+			FROM messages m, target t, cleared c
+			WHERE m.chat_id = $1 AND m.is_deleted = FALSE
+			AND m.sent_at > c.before
+			AND NOT EXISTS (SELECT 1 FROM hidden_messages hm WHERE hm.user_id = $4 AND hm.message_id = m.id)
+			AND (m.sent_at, m.id) >= (t.sent_at, t.id)
+			ORDER BY m.sent_at ASC, m.id ASC
+			LIMIT $3 + 1)
+		) around
+		ORDER BY sent_at ASC, id ASC`
+
+	rows, err := s.DB.Query(query, chatID, messageID, radius+1, userID)
+	if err != nil {
+		s.logger.Error("Failed to query messages around target",
+			"error", err, "chat_id", chatID, "message_id", messageID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	targetIndex := -1
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID, &message.ChatID, &message.SenderID,
+			&message.Content, &message.ContentType, &message.MediaURL,
+			&message.ThumbnailURL, &message.FileSize, &message.Duration,
+			&message.Status, &message.SentAt, &message.DeliveredAt,
+			&message.ReadAt, &message.ReplyTo, &message.Forwarded,
+			&message.ForwardFrom, &message.ForwardCount, &message.ReadCount, &message.ExpiresAt, &message.IsEdited, &message.EditedAt,
+			&message.IsDeleted, &message.DeletedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan message row around target",
+				"error", err, "chat_id", chatID)
+			return nil, err
+		}
+		if message.ID == messageID {
+			targetIndex = len(messages)
+		}
+		messages = append(messages, message)
+	}
+
+	if targetIndex < 0 {
+		s.logger.Debug("Target message not found in chat", "chat_id", chatID, "message_id", messageID)
+		return nil, ErrNotFound
+	}
+
+	result := &models.MessagesAround{TargetID: messageID}
+
+	// Trim the look-ahead rows and turn them into cursors
+	start, end := 0, len(messages)
+	if targetIndex > radius {
+		start = targetIndex - radius
+		result.HasMoreBefore = true
+	}
+	if end-targetIndex-1 > radius {
+		end = targetIndex + radius + 1
+		result.HasMoreAfter = true
+	}
+	result.Messages = messages[start:end]
+
+	if result.HasMoreBefore {
+		result.BeforeCursor = &result.Messages[0].ID
+	}
+	if result.HasMoreAfter {
+		result.AfterCursor = &result.Messages[len(result.Messages)-1].ID
+	}
+
+	s.logger.Debug("Retrieved messages around target",
+		"chat_id", chatID, "message_id", messageID, "message_count", len(result.Messages),
+		"has_more_before", result.HasMoreBefore, "has_more_after", result.HasMoreAfter)
+	return result, nil
+}
+
+// HideMessage deletes a message for userID only: it stays in the chat for everyone
+// else, but is left out of userID's message lists, search, export and media gallery
+func (s *Store) HideMessage(chatID, messageID, userID string) error {
+	s.logger.Info("Hiding message for user", "chat_id", chatID, "message_id", messageID, "user_id", userID)
+
+	query := `
+		INSERT INTO hidden_messages (user_id, message_id, chat_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, message_id) DO NOTHING`
+
+	if _, err := s.DB.Exec(query, userID, messageID, chatID); err != nil {
+		s.logger.Error("Failed to hide message",
+			"error", err, "chat_id", chatID, "message_id", messageID, "user_id", userID)
+		return err
+	}
+
+	// The message may be the user's chat list preview
+	s.InvalidateUserChatsCache(userID)
+
+	s.logger.Info("Message hidden for user", "chat_id", chatID, "message_id", messageID, "user_id", userID)
+	return nil
+}
+
+// hasHiddenMessages reports whether the member deleted any of the chat's messages
+// for themselves
+func (s *Store) hasHiddenMessages(chatID, userID string) (bool, error) {
+	var hidden bool
+	err := s.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM hidden_messages WHERE user_id = $1 AND chat_id = $2)`,
+		userID, chatID,
+	).Scan(&hidden)
+	if err != nil {
+		s.logger.Error("Failed to check hidden messages", "error", err, "chat_id", chatID, "user_id", userID)
+		return false, err
+	}
+	return hidden, nil
+}
+
+// getClearedBefore returns the member's cleared_before timestamp, or nil if they
+// never cleared the chat. It returns ErrNotFound if userID isn't a member.
+func (s *Store) getClearedBefore(chatID, userID string) (*time.Time, error) {
+	var clearedBefore *time.Time
+	err := s.DB.QueryRow(
+		`SELECT cleared_before FROM chat_members WHERE chat_id = $1 AND user_id = $2`,
+		chatID, userID,
+	).Scan(&clearedBefore)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to get cleared_before", "error", err, "chat_id", chatID, "user_id", userID)
+		return nil, err
+	}
+	return clearedBefore, nil
+}
+
+// ClearChatHistory clears a chat's existing messages. With ClearHistoryScopeMe only
+// the requester's view is affected, by recording a cleared_before timestamp; with
+// ClearHistoryScopeEveryone all current messages are soft-deleted for every member.
+// Permission checks are left to the caller.
+func (s *Store) ClearChatHistory(chatID, userID string, scope models.ClearHistoryScope) error {
+	s.logger.Warn("Clearing chat history", "chat_id", chatID, "user_id", userID, "scope", scope)
+
+	now := time.Now()
+
+	switch scope {
+	case models.ClearHistoryScopeEveryone:
+		result, err := s.DB.Exec(`
+			UPDATE messages 
+			SET is_deleted = TRUE, deleted_at = $2
+			WHERE chat_id = $1 AND is_deleted = FALSE AND sent_at <= $2`,
+			chatID, now,
+		)
+		if err != nil {
+			s.logger.Error("Failed to clear chat history for everyone",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+
+		rowsAffected, _ := result.RowsAffected()
+		s.logger.Debug("Soft-deleted chat messages", "chat_id", chatID, "rows_affected", rowsAffected)
+
+		s.InvalidateChatMessagesCache(chatID)
+		members, err := s.GetChatMembers(chatID)
+		if err == nil {
+			for _, member := range members {
+				s.InvalidateUserChatsCache(member.UserID)
+				s.ResetUnreadCounter(chatID, member.UserID)
+			}
+		}
+
+	default:
+		_, err := s.DB.Exec(`
+			UPDATE chat_members 
+			SET cleared_before = $3
+			WHERE chat_id = $1 AND user_id = $2`,
+			chatID, userID, now,
+		)
+		if err != nil {
+			s.logger.Error("Failed to clear chat history for member",
+				"error", err, "chat_id", chatID, "user_id", userID)
+			return err
+		}
+
+		s.InvalidateUserChatsCache(userID)
+		s.ResetUnreadCounter(chatID, userID)
+	}
+
+	s.logger.Info("Chat history cleared", "chat_id", chatID, "user_id", userID, "scope", scope)
+	return nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// largeGroupSize is the group size status seeding is benchmarked with
+const largeGroupSize = 500
+
+// BenchmarkSeedMessageStatus compares seeding a large group's statuses one INSERT
+// per member, as SaveMessage used to, with the single INSERT ... SELECT it uses now
+func BenchmarkSeedMessageStatus(b *testing.B) {
+	s := newTestStore(b)
+	owner := createTestUser(b, s)
+	members := make([]string, largeGroupSize-1)
+	for i := range members {
+		members[i] = createTestUser(b, s).ID
+	}
+	group := createTestChat(b, s, models.ChatTypeGroup, owner.ID, members...)
+	memberIDs := append([]string{owner.ID}, members...)
+
+	message, err := s.SaveMessage(group.ID, owner.ID, "hello", string(models.ContentTypeText), nil, nil, nil, false)
+	if err != nil {
+		b.Fatalf("save message: %v", err)
+	}
+
+	b.Run("per-member", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tx, err := s.DB.Begin()
+			if err != nil {
+				b.Fatal(err)
+			}
+			now := time.Now()
+			for _, userID := range memberIDs {
+				status := models.MessageStatusSent
+				if userID == owner.ID {
+					status = models.MessageStatusDelivered
+				}
+				_, err := tx.Exec(`
+					INSERT INTO message_status (message_id, user_id, status, updated_at)
+					VALUES ($1, $2, $3, $4)
+					ON CONFLICT (message_id, user_id) DO UPDATE
+					SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at`,
+					message.ID, userID, string(status), now,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tx, err := s.DB.Begin()
+			if err != nil {
+				b.Fatal(err)
+			}
+			seeded, err := s.seedMessageStatus(tx, message.ID, owner.ID, group.ID, time.Now())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if seeded != largeGroupSize {
+				b.Fatalf("seeded %d statuses, want %d", seeded, largeGroupSize)
+			}
+			if err := tx.Commit(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("SaveMessage", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.SaveMessage(group.ID, owner.ID, "hello", string(models.ContentTypeText), nil, nil, nil, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestMessagesWithIdenticalSentAt(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+	chat := createTestChat(t, s, models.ChatTypeDirect, alice.ID, bob.ID)
+
+	const count = 5
+	var want []string
+	for i := 0; i < count; i++ {
+		message, err := s.SaveMessage(chat.ID, alice.ID, "same instant", string(models.ContentTypeText), nil, nil, nil, false)
+		if err != nil {
+			t.Fatalf("save message: %v", err)
+		}
+		want = append(want, message.ID)
+	}
+	_, err := s.DB.Exec(`
+		UPDATE messages SET sent_at = (SELECT MIN(sent_at) FROM messages WHERE chat_id = $1)
+		WHERE chat_id = $1`, chat.ID)
+	if err != nil {
+		t.Fatalf("align sent_at: %v", err)
+	}
+	// Ties on sent_at are broken by id
+	slices.Sort(want)
+
+	t.Run("GetMessages pages", func(t *testing.T) {
+		// Pages run newest first; each page is in chronological order
+		var got []string
+		for offset := 0; offset < count; offset += 2 {
+			page, err := s.GetMessages(chat.ID, bob.ID, offset, 2)
+			if err != nil {
+				t.Fatalf("GetMessages(offset %d): %v", offset, err)
+			}
+			var ids []string
+			for _, message := range page {
+				ids = append(ids, message.ID)
+			}
+			got = append(ids, got...)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("paged messages = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("GetMessagesAfter cursor", func(t *testing.T) {
+		var got []string
+		var afterSentAt time.Time
+		var afterID string
+		for {
+			page, err := s.GetMessagesAfter(chat.ID, bob.ID, afterSentAt, afterID, 2)
+			if err != nil {
+				t.Fatalf("GetMessagesAfter: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			for _, message := range page {
+				got = append(got, message.ID)
+			}
+			last := page[len(page)-1]
+			afterSentAt, afterID = last.SentAt, last.ID
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("messages after cursor = %v, want %v", got, want)
+		}
+	})
+}
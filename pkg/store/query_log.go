@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// slowQueryStatementLimit caps how much of a statement is logged for a slow query
+const slowQueryStatementLimit = 200
+
+// queryRunner is what *sql.DB and *sql.Tx have in common, so the timing helpers
+// work inside and outside transactions
+type queryRunner interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// queryContext runs a query and logs it if it takes longer than SQL_LOG_SLOW_MS
+func (s *Store) queryContext(q queryRunner, query string, args ...any) (*sql.Rows, error) {
+	defer s.logSlowQuery(time.Now(), query)
+	return q.QueryContext(s.Ctx, query, args...)
+}
+
+// queryRowContext is queryContext for queries returning at most one row
+func (s *Store) queryRowContext(q queryRunner, query string, args ...any) *sql.Row {
+	defer s.logSlowQuery(time.Now(), query)
+	return q.QueryRowContext(s.Ctx, query, args...)
+}
+
+// execContext is queryContext for statements that return no rows
+func (s *Store) execContext(q queryRunner, query string, args ...any) (sql.Result, error) {
+	defer s.logSlowQuery(time.Now(), query)
+	return q.ExecContext(s.Ctx, query, args...)
+}
+
+func (s *Store) logSlowQuery(start time.Time, query string) {
+	if s.slowQueryThreshold <= 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed < s.slowQueryThreshold {
+		return
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	if len(statement) > slowQueryStatementLimit {
+		statement = statement[:slowQueryStatementLimit] + "..."
+	}
+	s.logger.Warn("Slow SQL query",
+		"duration", elapsed,
+		"threshold", s.slowQueryThreshold,
+		"statement", statement)
+}
@@ -2,18 +2,38 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/msniranjan18/chit-chat/pkg/models"
 )
 
+// Set of users with at least one live connection on any instance
+const onlineUsersKey = "online_users"
+
+// presenceTTL bounds how long a presence entry survives without a refresh
+const presenceTTL = 5 * time.Minute
+
+// nodeHeartbeatTTL is how long a hub instance is considered alive after its last heartbeat
+const nodeHeartbeatTTL = 2 * time.Minute
+
+// unreadCountersTTL bounds how long a user's unread counters are trusted before
+// they are rebuilt from the database, so any drift heals on its own
+const unreadCountersTTL = 24 * time.Hour
+
 // Redis cache keys
 func userPresenceKey(userID string) string {
 	return fmt.Sprintf("presence:%s", userID)
 }
 
+// userConnectionsKey holds a hash of node ID to the user's connection count there
+func userConnectionsKey(userID string) string {
+	return fmt.Sprintf("presence_nodes:%s", userID)
+}
+
 func userChatsKey(userID string) string {
 	return fmt.Sprintf("chats:%s", userID)
 }
@@ -30,8 +50,36 @@ func messageStatusKey(messageID string) string {
 	return fmt.Sprintf("msg_status:%s", messageID)
 }
 
+func groupSettingsKey(chatID string) string {
+	return fmt.Sprintf("group_settings:%s", chatID)
+}
+
+func unreadTotalKey(userID string) string {
+	return fmt.Sprintf("unread_total:%s", userID)
+}
+
+func unreadCountersKey(userID string) string {
+	return fmt.Sprintf("unread:%s", userID)
+}
+
+func unreadGenerationKey(userID string) string {
+	return fmt.Sprintf("unread_gen:%s", userID)
+}
+
+func chatMessageRateKey(chatID, userID string) string {
+	return fmt.Sprintf("chat_msg_rate:%s:%s", chatID, userID)
+}
+
+func nodeHeartbeatKey(nodeID string) string {
+	return fmt.Sprintf("node_heartbeat:%s", nodeID)
+}
+
 // Cache helpers
 func (s *Store) CacheUserPresence(userID string, presence models.UserPresence) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Caching user presence",
 		"user_id", userID,
 		"presence", presence)
@@ -45,24 +93,28 @@ func (s *Store) CacheUserPresence(userID string, presence models.UserPresence) e
 	}
 
 	key := userPresenceKey(userID)
-	err = s.RDB.Set(s.Ctx, key, data, 5*time.Minute).Err()
+	err = s.RDB.Set(s.Ctx, key, data, presenceTTL).Err()
 	if err != nil {
 		s.logger.Error("Failed to cache user presence in Redis",
 			"error", err,
 			"user_id", userID,
 			"key", key,
-			"ttl", "5m")
+			"ttl", presenceTTL)
 		return err
 	}
 
 	s.logger.Debug("User presence cached successfully",
 		"user_id", userID,
 		"key", key,
-		"ttl", "5m")
+		"ttl", presenceTTL)
 	return nil
 }
 
 func (s *Store) GetCachedUserPresence(userID string) (*models.UserPresence, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
 	s.logger.Debug("Getting cached user presence", "user_id", userID)
 
 	key := userPresenceKey(userID)
@@ -96,7 +148,314 @@ func (s *Store) GetCachedUserPresence(userID string) (*models.UserPresence, erro
 	return &presence, nil
 }
 
+// SetUserOnline registers one more connection for the user on the node and marks
+// them online. Connections are counted per node across all instances so that a
+// device disconnecting on one node does not clear presence while another device is
+// still connected elsewhere, and so the count held by a crashed node can be told
+// apart and dropped. The presence key TTL is what lets GetOnlineUsers drop ghosts
+// left by crashed instances. Without Redis, connections are only counted on this
+// instance.
+func (s *Store) SetUserOnline(userID, nodeID string) error {
+	s.logger.Debug("Setting user online", "user_id", userID, "node_id", nodeID)
+
+	if !s.RedisAvailable() {
+		connections := s.local.connect(userID)
+		s.logger.Debug("User set online locally", "user_id", userID, "connections", connections)
+		return nil
+	}
+
+	key := userConnectionsKey(userID)
+	pipe := s.RDB.TxPipeline()
+	connections := pipe.HIncrBy(s.Ctx, key, nodeID, 1)
+	pipe.Expire(s.Ctx, key, presenceTTL)
+	if _, err := pipe.Exec(s.Ctx); err != nil {
+		s.logger.Error("Failed to count user connection",
+			"error", err,
+			"user_id", userID,
+			"node_id", nodeID,
+			"key", key)
+		return err
+	}
+
+	presence := models.UserPresence{
+		UserID:   userID,
+		IsOnline: true,
+		LastSeen: time.Now(),
+	}
+	if err := s.CacheUserPresence(userID, presence); err != nil {
+		return err
+	}
+
+	if err := s.RDB.SAdd(s.Ctx, onlineUsersKey, userID).Err(); err != nil {
+		s.logger.Error("Failed to add user to online set",
+			"error", err,
+			"user_id", userID,
+			"key", onlineUsersKey)
+		return err
+	}
+
+	s.logger.Debug("User set online", "user_id", userID, "node_connections", connections.Val())
+	return nil
+}
+
+// releaseConnectionScript releases one of the user's connections on a node and
+// drops the counts of nodes whose heartbeat has lapsed, then clears presence if no
+// connection remains. KEYS: connections, presence, online set. ARGV: node ID,
+// user ID, heartbeat key prefix. Returns how many connections remain.
+var releaseConnectionScript = redis.NewScript(`
+if redis.call('HINCRBY', KEYS[1], ARGV[1], -1) <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+local remaining = 0
+local counts = redis.call('HGETALL', KEYS[1])
+for i = 1, #counts, 2 do
+	if redis.call('EXISTS', ARGV[3] .. counts[i]) == 1 then
+		remaining = remaining + tonumber(counts[i + 1])
+	else
+		redis.call('HDEL', KEYS[1], counts[i])
+	end
+end
+if remaining <= 0 then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	redis.call('SREM', KEYS[3], ARGV[2])
+end
+return remaining`)
+
+// SetUserOffline releases one of the user's connections on the node. Presence is
+// only cleared once no connection remains on any live instance, which is reported
+// by the returned bool. Without Redis, the user's last_seen is the only presence
+// record left behind.
+func (s *Store) SetUserOffline(userID, nodeID string) (bool, error) {
+	s.logger.Debug("Setting user offline", "user_id", userID, "node_id", nodeID)
+
+	if !s.RedisAvailable() {
+		if remaining := s.local.disconnect(userID); remaining > 0 {
+			s.logger.Debug("User still connected locally",
+				"user_id", userID,
+				"connections", remaining)
+			return false, nil
+		}
+		return true, s.UpdateUserLastSeen(userID, time.Now())
+	}
+
+	key := userConnectionsKey(userID)
+	remaining, err := releaseConnectionScript.Run(s.Ctx, s.RDB,
+		[]string{key, userPresenceKey(userID), onlineUsersKey},
+		nodeID, userID, nodeHeartbeatKey("")).Int64()
+	if err != nil {
+		s.logger.Error("Failed to release user connection",
+			"error", err,
+			"user_id", userID,
+			"node_id", nodeID,
+			"key", key)
+		return false, err
+	}
+
+	if remaining > 0 {
+		s.logger.Debug("User still connected elsewhere",
+			"user_id", userID,
+			"connections", remaining)
+		return false, nil
+	}
+
+	s.logger.Debug("User set offline", "user_id", userID)
+	return true, nil
+}
+
+// IsUserOnline reports whether the user currently holds a live presence entry
+func (s *Store) IsUserOnline(userID string) (bool, error) {
+	if !s.RedisAvailable() {
+		return s.local.isOnline(userID), nil
+	}
+
+	presence, err := s.GetCachedUserPresence(userID)
+	if err != nil {
+		return false, err
+	}
+	return presence != nil && presence.IsOnline, nil
+}
+
+// FilterOnlineUsers returns the subset of userIDs that currently hold a live presence entry
+func (s *Store) FilterOnlineUsers(userIDs []string) ([]string, error) {
+	s.logger.Debug("Filtering online users", "user_count", len(userIDs))
+
+	if len(userIDs) == 0 {
+		return []string{}, nil
+	}
+	if !s.RedisAvailable() {
+		return s.local.filterOnline(userIDs), nil
+	}
+
+	pipe := s.RDB.Pipeline()
+	checks := make([]*redis.IntCmd, len(userIDs))
+	for i, userID := range userIDs {
+		checks[i] = pipe.Exists(s.Ctx, userPresenceKey(userID))
+	}
+	if _, err := pipe.Exec(s.Ctx); err != nil {
+		s.logger.Error("Failed to check presence keys",
+			"error", err,
+			"user_count", len(userIDs))
+		return nil, err
+	}
+
+	online := make([]string, 0, len(userIDs))
+	for i, userID := range userIDs {
+		if checks[i].Val() > 0 {
+			online = append(online, userID)
+		}
+	}
+
+	s.logger.Debug("Online users filtered", "user_count", len(userIDs), "online_count", len(online))
+	return online, nil
+}
+
+// RefreshUserPresence re-sets the presence keys of connected users so their TTL does not lapse
+func (s *Store) RefreshUserPresence(userIDs []string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	s.logger.Debug("Refreshing user presence", "user_count", len(userIDs))
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	pipe := s.RDB.Pipeline()
+	for _, userID := range userIDs {
+		data, err := json.Marshal(models.UserPresence{
+			UserID:   userID,
+			IsOnline: true,
+			LastSeen: now,
+		})
+		if err != nil {
+			s.logger.Error("Failed to marshal user presence for refresh",
+				"error", err,
+				"user_id", userID)
+			continue
+		}
+		pipe.Set(s.Ctx, userPresenceKey(userID), data, presenceTTL)
+		pipe.Expire(s.Ctx, userConnectionsKey(userID), presenceTTL)
+		pipe.SAdd(s.Ctx, onlineUsersKey, userID)
+	}
+
+	if _, err := pipe.Exec(s.Ctx); err != nil {
+		s.logger.Error("Failed to refresh user presence",
+			"error", err,
+			"user_count", len(userIDs))
+		return err
+	}
+
+	s.logger.Debug("User presence refreshed", "user_count", len(userIDs))
+	return nil
+}
+
+// SetNodeHeartbeat records that a hub instance is alive
+func (s *Store) SetNodeHeartbeat(nodeID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	key := nodeHeartbeatKey(nodeID)
+	if err := s.RDB.Set(s.Ctx, key, time.Now().Unix(), nodeHeartbeatTTL).Err(); err != nil {
+		s.logger.Error("Failed to set node heartbeat",
+			"error", err,
+			"node_id", nodeID)
+		return err
+	}
+
+	s.logger.Debug("Node heartbeat set", "node_id", nodeID, "ttl", nodeHeartbeatTTL)
+	return nil
+}
+
+// AllowChatMessage counts a message from the user in the chat against a fixed window
+// and reports whether it is within limit. Admins of group chats are exempt. A limit
+// of zero or less disables the check.
+func (s *Store) AllowChatMessage(chatID, userID string, limit int, window time.Duration) (bool, error) {
+	if limit <= 0 {
+		return true, nil
+	}
+
+	chat, err := s.GetChat(chatID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if chat != nil && chat.Type == models.ChatTypeGroup {
+		isAdmin, err := s.IsChatAdmin(chatID, userID)
+		if err != nil {
+			return false, err
+		}
+		if isAdmin {
+			return true, nil
+		}
+	}
+
+	key := chatMessageRateKey(chatID, userID)
+	if !s.RedisAvailable() {
+		if !s.local.allow(key, limit, window) {
+			s.logger.Warn("Chat message rate limit exceeded",
+				"chat_id", chatID,
+				"user_id", userID,
+				"limit", limit,
+				"window", window)
+			return false, nil
+		}
+		return true, nil
+	}
+
+	count, err := s.RDB.Incr(s.Ctx, key).Result()
+	if err != nil {
+		s.logger.Error("Failed to count chat message for rate limit",
+			"error", err,
+			"chat_id", chatID,
+			"user_id", userID)
+		return false, err
+	}
+	// The window starts at the first message, so only that one sets the expiry
+	if count == 1 {
+		if err := s.RDB.Expire(s.Ctx, key, window).Err(); err != nil {
+			s.logger.Error("Failed to set chat message rate window",
+				"error", err,
+				"chat_id", chatID,
+				"user_id", userID)
+			return false, err
+		}
+	}
+
+	if count > int64(limit) {
+		s.logger.Warn("Chat message rate limit exceeded",
+			"chat_id", chatID,
+			"user_id", userID,
+			"count", count,
+			"limit", limit,
+			"window", window)
+		return false, nil
+	}
+	return true, nil
+}
+
+// IsNodeAlive reports whether a hub instance has sent a heartbeat recently
+func (s *Store) IsNodeAlive(nodeID string) (bool, error) {
+	if !s.RedisAvailable() {
+		return false, nil
+	}
+
+	n, err := s.RDB.Exists(s.Ctx, nodeHeartbeatKey(nodeID)).Result()
+	if err != nil {
+		s.logger.Error("Failed to check node heartbeat",
+			"error", err,
+			"node_id", nodeID)
+		return false, err
+	}
+	return n > 0, nil
+}
+
 func (s *Store) CacheUserChats(userID string, chats []models.Chat) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Caching user chats",
 		"user_id", userID,
 		"chat_count", len(chats))
@@ -131,6 +490,10 @@ func (s *Store) CacheUserChats(userID string, chats []models.Chat) error {
 }
 
 func (s *Store) GetCachedUserChats(userID string) ([]models.Chat, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
 	s.logger.Debug("Getting cached user chats", "user_id", userID)
 
 	key := userChatsKey(userID)
@@ -165,6 +528,10 @@ func (s *Store) GetCachedUserChats(userID string) ([]models.Chat, error) {
 }
 
 func (s *Store) InvalidateUserChatsCache(userID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Invalidating user chats cache", "user_id", userID)
 
 	key := userChatsKey(userID)
@@ -185,6 +552,10 @@ func (s *Store) InvalidateUserChatsCache(userID string) error {
 }
 
 func (s *Store) CacheChatMessages(chatID string, messages []models.Message) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Caching chat messages",
 		"chat_id", chatID,
 		"message_count", len(messages))
@@ -219,6 +590,10 @@ func (s *Store) CacheChatMessages(chatID string, messages []models.Message) erro
 }
 
 func (s *Store) GetCachedChatMessages(chatID string) ([]models.Message, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
 	s.logger.Debug("Getting cached chat messages", "chat_id", chatID)
 
 	key := chatMessagesKey(chatID)
@@ -253,6 +628,10 @@ func (s *Store) GetCachedChatMessages(chatID string) ([]models.Message, error) {
 }
 
 func (s *Store) InvalidateChatMessagesCache(chatID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Invalidating chat messages cache", "chat_id", chatID)
 
 	key := chatMessagesKey(chatID)
@@ -274,6 +653,10 @@ func (s *Store) InvalidateChatMessagesCache(chatID string) error {
 
 // Cache chat members
 func (s *Store) CacheChatMembers(chatID string, members []models.ChatMember) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Caching chat members",
 		"chat_id", chatID,
 		"member_count", len(members))
@@ -308,6 +691,10 @@ func (s *Store) CacheChatMembers(chatID string, members []models.ChatMember) err
 }
 
 func (s *Store) GetCachedChatMembers(chatID string) ([]models.ChatMember, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
 	s.logger.Debug("Getting cached chat members", "chat_id", chatID)
 
 	key := chatMembersKey(chatID)
@@ -342,6 +729,10 @@ func (s *Store) GetCachedChatMembers(chatID string) ([]models.ChatMember, error)
 }
 
 func (s *Store) InvalidateChatMembersCache(chatID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Invalidating chat members cache", "chat_id", chatID)
 
 	key := chatMembersKey(chatID)
@@ -361,18 +752,116 @@ func (s *Store) InvalidateChatMembersCache(chatID string) error {
 	return nil
 }
 
+// Cache group settings
+func (s *Store) CacheGroupSettings(chatID string, settings *models.GroupSettings) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	s.logger.Debug("Caching group settings", "chat_id", chatID)
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		s.logger.Error("Failed to marshal group settings for caching",
+			"error", err,
+			"chat_id", chatID)
+		return err
+	}
+
+	key := groupSettingsKey(chatID)
+	err = s.RDB.Set(s.Ctx, key, data, 15*time.Minute).Err()
+	if err != nil {
+		s.logger.Error("Failed to cache group settings in Redis",
+			"error", err,
+			"chat_id", chatID,
+			"key", key,
+			"ttl", "15m")
+		return err
+	}
+
+	s.logger.Debug("Group settings cached successfully",
+		"chat_id", chatID,
+		"key", key,
+		"ttl", "15m")
+	return nil
+}
+
+func (s *Store) GetCachedGroupSettings(chatID string) (*models.GroupSettings, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
+	s.logger.Debug("Getting cached group settings", "chat_id", chatID)
+
+	key := groupSettingsKey(chatID)
+	data, err := s.RDB.Get(s.Ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			s.logger.Debug("Group settings not found in cache", "chat_id", chatID, "key", key)
+			return nil, nil
+		}
+		s.logger.Error("Failed to get group settings from cache",
+			"error", err,
+			"chat_id", chatID,
+			"key", key)
+		return nil, err
+	}
+
+	var settings models.GroupSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		s.logger.Error("Failed to unmarshal group settings from cache",
+			"error", err,
+			"chat_id", chatID,
+			"key", key,
+			"data_length", len(data))
+		return nil, err
+	}
+
+	s.logger.Debug("Group settings retrieved from cache", "chat_id", chatID, "key", key)
+	return &settings, nil
+}
+
+func (s *Store) InvalidateGroupSettingsCache(chatID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	s.logger.Debug("Invalidating group settings cache", "chat_id", chatID)
+
+	key := groupSettingsKey(chatID)
+	result, err := s.RDB.Del(s.Ctx, key).Result()
+	if err != nil {
+		s.logger.Error("Failed to invalidate group settings cache",
+			"error", err,
+			"chat_id", chatID,
+			"key", key)
+		return err
+	}
+
+	s.logger.Debug("Group settings cache invalidated",
+		"chat_id", chatID,
+		"key", key,
+		"deleted_keys", result)
+	return nil
+}
+
 // Cache message status
-func (s *Store) CacheMessageStatus(messageID string, status map[string]string) error {
+func (s *Store) CacheMessageStatus(messageID string, summary *models.MessageStatusSummary) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	statusCount := len(summary.DeliveredTo) + len(summary.ReadBy)
 	s.logger.Debug("Caching message status",
 		"message_id", messageID,
-		"status_count", len(status))
+		"status_count", statusCount)
 
-	data, err := json.Marshal(status)
+	data, err := json.Marshal(summary)
 	if err != nil {
 		s.logger.Error("Failed to marshal message status for caching",
 			"error", err,
 			"message_id", messageID,
-			"status_count", len(status))
+			"status_count", statusCount)
 		return err
 	}
 
@@ -384,19 +873,23 @@ func (s *Store) CacheMessageStatus(messageID string, status map[string]string) e
 			"message_id", messageID,
 			"key", key,
 			"ttl", "2m",
-			"status_count", len(status))
+			"status_count", statusCount)
 		return err
 	}
 
 	s.logger.Debug("Message status cached successfully",
 		"message_id", messageID,
 		"key", key,
-		"status_count", len(status),
+		"status_count", statusCount,
 		"ttl", "2m")
 	return nil
 }
 
-func (s *Store) GetCachedMessageStatus(messageID string) (map[string]string, error) {
+func (s *Store) GetCachedMessageStatus(messageID string) (*models.MessageStatusSummary, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
 	s.logger.Debug("Getting cached message status", "message_id", messageID)
 
 	key := messageStatusKey(messageID)
@@ -413,8 +906,8 @@ func (s *Store) GetCachedMessageStatus(messageID string) (map[string]string, err
 		return nil, err
 	}
 
-	var status map[string]string
-	if err := json.Unmarshal(data, &status); err != nil {
+	var summary models.MessageStatusSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
 		s.logger.Error("Failed to unmarshal message status from cache",
 			"error", err,
 			"message_id", messageID,
@@ -426,11 +919,15 @@ func (s *Store) GetCachedMessageStatus(messageID string) (map[string]string, err
 	s.logger.Debug("Message status retrieved from cache",
 		"message_id", messageID,
 		"key", key,
-		"status_count", len(status))
-	return status, nil
+		"status_count", len(summary.DeliveredTo)+len(summary.ReadBy))
+	return &summary, nil
 }
 
 func (s *Store) InvalidateMessageStatusCache(messageID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
 	s.logger.Debug("Invalidating message status cache", "message_id", messageID)
 
 	key := messageStatusKey(messageID)
@@ -449,3 +946,332 @@ func (s *Store) InvalidateMessageStatusCache(messageID string) error {
 		"deleted_keys", result)
 	return nil
 }
+
+func (s *Store) InvalidateMessageStatusCaches(messageIDs []string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	s.logger.Debug("Invalidating message status caches", "message_count", len(messageIDs))
+
+	keys := make([]string, 0, len(messageIDs))
+	for _, messageID := range messageIDs {
+		keys = append(keys, messageStatusKey(messageID))
+	}
+
+	result, err := s.RDB.Del(s.Ctx, keys...).Result()
+	if err != nil {
+		s.logger.Error("Failed to invalidate message status caches",
+			"error", err,
+			"message_count", len(messageIDs))
+		return err
+	}
+
+	s.logger.Debug("Message status caches invalidated",
+		"message_count", len(messageIDs),
+		"deleted_keys", result)
+	return nil
+}
+
+// Unread total cache helpers. The TTL is short because new messages don't
+// invalidate every member's entry.
+func (s *Store) CacheTotalUnread(userID string, summary *models.UnreadSummary) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	s.logger.Debug("Caching total unread", "user_id", userID)
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		s.logger.Error("Failed to marshal total unread for caching",
+			"error", err,
+			"user_id", userID)
+		return err
+	}
+
+	key := unreadTotalKey(userID)
+	err = s.RDB.Set(s.Ctx, key, data, 30*time.Second).Err()
+	if err != nil {
+		s.logger.Error("Failed to cache total unread in Redis",
+			"error", err,
+			"user_id", userID,
+			"key", key,
+			"ttl", "30s")
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) GetCachedTotalUnread(userID string) (*models.UnreadSummary, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
+	key := unreadTotalKey(userID)
+	data, err := s.RDB.Get(s.Ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			s.logger.Debug("Total unread not found in cache", "user_id", userID, "key", key)
+			return nil, nil
+		}
+		s.logger.Error("Failed to get total unread from cache",
+			"error", err,
+			"user_id", userID,
+			"key", key)
+		return nil, err
+	}
+
+	var summary models.UnreadSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		s.logger.Error("Failed to unmarshal total unread from cache",
+			"error", err,
+			"user_id", userID,
+			"key", key)
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+func (s *Store) InvalidateTotalUnreadCache(userID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	key := unreadTotalKey(userID)
+	if err := s.RDB.Del(s.Ctx, key).Err(); err != nil {
+		s.logger.Error("Failed to invalidate total unread cache",
+			"error", err,
+			"user_id", userID,
+			"key", key)
+		return err
+	}
+
+	s.logger.Debug("Total unread cache invalidated", "user_id", userID, "key", key)
+	return nil
+}
+
+// Unread counters are kept per user as a hash of chat ID to count. Updates only
+// apply to hashes that already exist: a user whose counters were lost gets them
+// rebuilt in full from the database on the next read, instead of starting from
+// a partial count.
+//
+// Every update, applied or not, also bumps the user's generation. A rebuild
+// notes the generation before it queries the database and only stores its counts
+// if the generation is unchanged, so an update that lands while the rebuild is
+// running can't be overwritten by counts that predate it.
+var (
+	incrUnreadScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+end
+return false`)
+
+	resetUnreadScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HSET', KEYS[1], ARGV[1], 0)
+end
+return false`)
+
+	// KEYS: counters, generation. ARGV: expected generation, TTL in seconds, then
+	// chat ID and count pairs. Returns 0 without writing if the generation moved.
+	cacheUnreadScript = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '') ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+if #ARGV > 2 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return 1`)
+)
+
+const unreadCountersTTLSeconds = int(unreadCountersTTL / time.Second)
+
+// IncrementUnreadCounters counts a new message in the chat as unread for each user
+func (s *Store) IncrementUnreadCounters(chatID string, userIDs []string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	pipe := s.RDB.Pipeline()
+	for _, userID := range userIDs {
+		incrUnreadScript.Eval(s.Ctx, pipe,
+			[]string{unreadCountersKey(userID), unreadGenerationKey(userID)},
+			chatID, unreadCountersTTLSeconds)
+	}
+	return s.execUnreadPipeline(pipe, "increment", chatID)
+}
+
+// ResetUnreadCounter zeroes the user's unread counter for the chat
+func (s *Store) ResetUnreadCounter(chatID, userID string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	pipe := s.RDB.Pipeline()
+	resetUnreadScript.Eval(s.Ctx, pipe,
+		[]string{unreadCountersKey(userID), unreadGenerationKey(userID)},
+		chatID, unreadCountersTTLSeconds)
+	return s.execUnreadPipeline(pipe, "reset", chatID)
+}
+
+// execUnreadPipeline runs counter updates, where a nil reply just means the user
+// had no counters loaded
+func (s *Store) execUnreadPipeline(pipe redis.Pipeliner, op, chatID string) error {
+	cmds, _ := pipe.Exec(s.Ctx)
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			s.logger.Error("Failed to update unread counters",
+				"error", err,
+				"op", op,
+				"chat_id", chatID)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCachedUnreadCounters returns the user's unread count per chat, or nil if the
+// counters aren't loaded
+func (s *Store) GetCachedUnreadCounters(userID string) (map[string]int, error) {
+	if !s.RedisAvailable() {
+		return nil, nil
+	}
+
+	key := unreadCountersKey(userID)
+	values, err := s.RDB.HGetAll(s.Ctx, key).Result()
+	if err != nil {
+		s.logger.Error("Failed to get unread counters from cache",
+			"error", err,
+			"user_id", userID,
+			"key", key)
+		return nil, err
+	}
+	if len(values) == 0 {
+		s.logger.Debug("Unread counters not found in cache", "user_id", userID, "key", key)
+		return nil, nil
+	}
+
+	counts := make(map[string]int, len(values))
+	for chatID, value := range values {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			s.logger.Error("Invalid unread counter in cache",
+				"error", err,
+				"user_id", userID,
+				"chat_id", chatID,
+				"value", value)
+			return nil, err
+		}
+		counts[chatID] = count
+	}
+	return counts, nil
+}
+
+// UnreadCountersGeneration returns the user's current counter generation, to be
+// passed to CacheUnreadCounters after rebuilding the counts
+func (s *Store) UnreadCountersGeneration(userID string) (string, error) {
+	if !s.RedisAvailable() {
+		return "", nil
+	}
+
+	key := unreadGenerationKey(userID)
+	generation, err := s.RDB.Get(s.Ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get unread counters generation",
+			"error", err,
+			"user_id", userID,
+			"key", key)
+		return "", err
+	}
+	return generation, nil
+}
+
+// CacheUnreadCounters replaces the user's unread counters with counts rebuilt at
+// generation. It reports false, leaving the cache alone, if the counters changed
+// since then; the next read rebuilds them again.
+func (s *Store) CacheUnreadCounters(userID, generation string, counts map[string]int) (bool, error) {
+	if !s.RedisAvailable() {
+		return false, nil
+	}
+
+	key := unreadCountersKey(userID)
+
+	args := make([]interface{}, 0, 2+2*len(counts))
+	args = append(args, generation, unreadCountersTTLSeconds)
+	for chatID, count := range counts {
+		args = append(args, chatID, count)
+	}
+	stored, err := cacheUnreadScript.Run(s.Ctx, s.RDB,
+		[]string{key, unreadGenerationKey(userID)}, args...).Int()
+	if err != nil {
+		s.logger.Error("Failed to cache unread counters in Redis",
+			"error", err,
+			"user_id", userID,
+			"key", key,
+			"chat_count", len(counts))
+		return false, err
+	}
+	if stored == 0 {
+		s.logger.Debug("Unread counters changed during rebuild, not cached",
+			"user_id", userID,
+			"key", key)
+		return false, nil
+	}
+
+	s.logger.Debug("Unread counters cached",
+		"user_id", userID,
+		"key", key,
+		"chat_count", len(counts))
+	return true, nil
+}
+
+// InvalidateUnreadCounters drops the users' unread counters so they are rebuilt
+// from the database on the next read
+func (s *Store) InvalidateUnreadCounters(userIDs ...string) error {
+	if !s.RedisAvailable() {
+		return nil
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	// Bumping the generations also discards rebuilds already under way
+	pipe := s.RDB.TxPipeline()
+	for _, userID := range userIDs {
+		pipe.Del(s.Ctx, unreadCountersKey(userID))
+		pipe.Incr(s.Ctx, unreadGenerationKey(userID))
+		pipe.Expire(s.Ctx, unreadGenerationKey(userID), unreadCountersTTL)
+	}
+	if _, err := pipe.Exec(s.Ctx); err != nil {
+		s.logger.Error("Failed to invalidate unread counters",
+			"error", err,
+			"user_count", len(userIDs))
+		return err
+	}
+
+	s.logger.Debug("Unread counters invalidated", "user_count", len(userIDs))
+	return nil
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// newRedisTestStore returns a store on the Redis named by TEST_REDIS_URL, without a
+// database, and skips the test when it isn't set
+func newRedisTestStore(tb testing.TB) *Store {
+	tb.Helper()
+
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		tb.Skip("TEST_REDIS_URL is not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		tb.Fatalf("parse redis URL: %v", err)
+	}
+	rdb := redis.NewClient(opts)
+	tb.Cleanup(func() { rdb.Close() })
+
+	return &Store{
+		RDB:    rdb,
+		Ctx:    context.Background(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		local:  newLocalFallback(),
+	}
+}
+
+func TestCacheUnreadCountersSkipsStaleRebuild(t *testing.T) {
+	s := newRedisTestStore(t)
+	userID := uuid.New().String()
+
+	generation, err := s.UnreadCountersGeneration(userID)
+	if err != nil {
+		t.Fatalf("get generation: %v", err)
+	}
+
+	// A message arrives while the rebuild is counting; the key doesn't exist yet,
+	// so the increment itself is dropped
+	if err := s.IncrementUnreadCounters("chat", []string{userID}); err != nil {
+		t.Fatalf("increment: %v", err)
+	}
+
+	stored, err := s.CacheUnreadCounters(userID, generation, map[string]int{"chat": 0})
+	if err != nil {
+		t.Fatalf("cache counters: %v", err)
+	}
+	if stored {
+		t.Fatal("counts rebuilt before an increment were cached")
+	}
+	if counts, _ := s.GetCachedUnreadCounters(userID); counts != nil {
+		t.Fatalf("cached counters = %v, want none", counts)
+	}
+
+	// A rebuild that nothing raced with is cached
+	generation, err = s.UnreadCountersGeneration(userID)
+	if err != nil {
+		t.Fatalf("get generation: %v", err)
+	}
+	stored, err = s.CacheUnreadCounters(userID, generation, map[string]int{"chat": 1})
+	if err != nil {
+		t.Fatalf("cache counters: %v", err)
+	}
+	counts, err := s.GetCachedUnreadCounters(userID)
+	if err != nil {
+		t.Fatalf("get cached counters: %v", err)
+	}
+	if !stored || counts["chat"] != 1 {
+		t.Fatalf("cached = %v, counters = %v; want the rebuilt count of 1", stored, counts)
+	}
+}
+
+func TestSetUserOfflineIgnoresDeadNodes(t *testing.T) {
+	s := newRedisTestStore(t)
+	userID := uuid.New().String()
+	live, dead := uuid.New().String(), uuid.New().String()
+
+	if err := s.SetNodeHeartbeat(live); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	// The dead node counted a connection and then crashed without releasing it
+	for _, nodeID := range []string{dead, live} {
+		if err := s.SetUserOnline(userID, nodeID); err != nil {
+			t.Fatalf("set online on %s: %v", nodeID, err)
+		}
+	}
+
+	offline, err := s.SetUserOffline(userID, live)
+	if err != nil {
+		t.Fatalf("set offline: %v", err)
+	}
+	if !offline {
+		t.Fatal("user still online after their last live connection closed")
+	}
+	if online, _ := s.IsUserOnline(userID); online {
+		t.Error("presence was not cleared")
+	}
+}
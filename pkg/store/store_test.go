@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// newTestStore returns a store on the database named by TEST_DATABASE_URL, with the
+// schema applied, and skips the test when it isn't set. Tests leave their rows
+// behind, so point it at a throwaway database. Redis is left out, so the store runs
+// on its in-memory fallback as it does with REDIS_OPTIONAL.
+func newTestStore(tb testing.TB) *Store {
+	tb.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		tb.Fatalf("open database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	s := &Store{
+		DB:     db,
+		Ctx:    context.Background(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		local:  newLocalFallback(),
+	}
+	if err := s.InitSchema(); err != nil {
+		tb.Fatalf("init schema: %v", err)
+	}
+	return s
+}
+
+// createTestUser creates a user with a phone number no other test uses
+func createTestUser(tb testing.TB, s *Store) *models.User {
+	tb.Helper()
+
+	user := &models.User{
+		Phone: "t" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12],
+		Name:  "Test User",
+	}
+	if err := s.CreateUser(user); err != nil {
+		tb.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// createTestChat creates a chat of the given type owned by owner, with members
+func createTestChat(tb testing.TB, s *Store, chatType models.ChatType, owner string, members ...string) *models.Chat {
+	tb.Helper()
+
+	req := &models.ChatRequest{Type: chatType, UserIDs: members}
+	if chatType != models.ChatTypeDirect {
+		name := "Test " + string(chatType)
+		req.Name = &name
+	}
+	chat, err := s.CreateChat(req, owner)
+	if err != nil {
+		tb.Fatalf("create %s chat: %v", chatType, err)
+	}
+	return chat
+}
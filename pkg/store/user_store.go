@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/msniranjan18/chit-chat/pkg/models"
@@ -42,18 +43,19 @@ func (s *Store) GetUserByID(userID string) (*models.User, error) {
 	s.logger.Debug("Getting user by ID", "user_id", userID)
 
 	query := `
-		SELECT id, phone, name, status, avatar_url, last_seen, created_at, updated_at
+		SELECT id, phone, name, status, avatar_url, last_seen, created_at, updated_at, privacy_messages
 		FROM users WHERE id = $1`
 
 	user := &models.User{}
 	err := s.DB.QueryRow(query, userID).Scan(
 		&user.ID, &user.Phone, &user.Name, &user.Status,
 		&user.AvatarURL, &user.LastSeen, &user.CreatedAt, &user.UpdatedAt,
+		&user.PrivacyMessages,
 	)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("User not found by ID", "user_id", userID)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get user by ID", "error", err, "user_id", userID)
@@ -68,18 +70,19 @@ func (s *Store) GetUserByPhone(phone string) (*models.User, error) {
 	s.logger.Debug("Getting user by phone", "phone", phone)
 
 	query := `
-		SELECT id, phone, name, status, avatar_url, last_seen, created_at, updated_at
+		SELECT id, phone, name, status, avatar_url, last_seen, created_at, updated_at, privacy_messages
 		FROM users WHERE phone = $1`
 
 	user := &models.User{}
 	err := s.DB.QueryRow(query, phone).Scan(
 		&user.ID, &user.Phone, &user.Name, &user.Status,
 		&user.AvatarURL, &user.LastSeen, &user.CreatedAt, &user.UpdatedAt,
+		&user.PrivacyMessages,
 	)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("User not found by phone", "phone", phone)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get user by phone", "error", err, "phone", phone)
@@ -97,11 +100,19 @@ func (s *Store) UpdateUser(userID string, updates *models.UserUpdateRequest) err
 		UPDATE users 
 		SET name = COALESCE($2, name),
 			status = COALESCE($3, status),
+			privacy_messages = COALESCE($4, privacy_messages),
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
+		AND ($5::timestamp IS NULL OR updated_at = $5)
 		RETURNING id`
 
-	err := s.DB.QueryRow(query, userID, updates.Name, updates.Status).Scan(&userID)
+	err := s.DB.QueryRow(query, userID, updates.Name, updates.Status, updates.PrivacyMessages,
+		updates.ExpectedUpdatedAt).Scan(&userID)
+	if err == sql.ErrNoRows && updates.ExpectedUpdatedAt != nil {
+		s.logger.Warn("User modified since expected version",
+			"user_id", userID, "expected_updated_at", updates.ExpectedUpdatedAt)
+		return ErrConflict
+	}
 	if err != nil {
 		s.logger.Error("Failed to update user", "error", err, "user_id", userID)
 		return err
@@ -126,20 +137,73 @@ func (s *Store) UpdateUserLastSeen(userID string, lastSeen time.Time) error {
 	return nil
 }
 
-func (s *Store) SearchUsers(queryStr string, limit int) ([]models.User, error) {
-	s.logger.Info("Searching users", "query", queryStr, "limit", limit)
+// CanMessageUser reports whether senderID may start or continue a conversation with
+// recipientID, honouring blocks in either direction and the recipient's
+// privacy_messages setting
+func (s *Store) CanMessageUser(senderID, recipientID string) (bool, error) {
+	s.logger.Debug("Checking message privacy", "sender_id", senderID, "recipient_id", recipientID)
 
 	query := `
-		SELECT id, phone, name, status, avatar_url, last_seen, created_at, updated_at
-		FROM users 
-		WHERE phone ILIKE $1 OR name ILIKE $1
-		ORDER BY name
-		LIMIT $2`
+		SELECT
+			u.privacy_messages = 'everyone'
+				OR EXISTS (SELECT 1 FROM contacts c WHERE c.user_id = u.id AND c.contact_id = $2),
+			EXISTS (
+				SELECT 1 FROM blocked_users b
+				WHERE (b.user_id = u.id AND b.blocked_id = $2)
+				OR (b.user_id = $2 AND b.blocked_id = u.id)
+			)
+		FROM users u
+		WHERE u.id = $1`
+
+	var allowed, blocked bool
+	err := s.DB.QueryRow(query, recipientID, senderID).Scan(&allowed, &blocked)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Recipient not found for privacy check", "recipient_id", recipientID)
+		return false, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to check message privacy",
+			"error", err, "sender_id", senderID, "recipient_id", recipientID)
+		return false, err
+	}
+
+	return allowed && !blocked, nil
+}
+
+// SearchUsers finds users by phone or name on behalf of requesterID. The requester
+// and anyone on either side of a block with them are excluded in SQL so that
+// limit and total reflect what the caller actually gets back.
+func (s *Store) SearchUsers(requesterID, queryStr string, offset, limit int) ([]models.User, int, error) {
+	s.logger.Info("Searching users",
+		"requester_id", requesterID, "query", queryStr, "offset", offset, "limit", limit)
+
+	filter := `
+		WHERE (u.phone ILIKE $1 OR u.name ILIKE $1)
+		AND u.id <> $2
+		AND NOT EXISTS (
+			SELECT 1 FROM blocked_users b
+			WHERE (b.user_id = $2 AND b.blocked_id = u.id)
+			OR (b.user_id = u.id AND b.blocked_id = $2)
+		)`
+	pattern := "%" + queryStr + "%"
+
+	var total int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM users u`+filter, pattern, requesterID).Scan(&total)
+	if err != nil {
+		s.logger.Error("Failed to count user search results", "error", err, "query", queryStr)
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT u.id, u.phone, u.name, u.status, u.avatar_url, u.last_seen, u.created_at, u.updated_at
+		FROM users u` + filter + `
+		ORDER BY u.name, u.id
+		LIMIT $3 OFFSET $4`
 
-	rows, err := s.DB.Query(query, "%"+queryStr+"%", limit)
+	rows, err := s.DB.Query(query, pattern, requesterID, limit, offset)
 	if err != nil {
 		s.logger.Error("Failed to search users", "error", err, "query", queryStr)
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -155,7 +219,7 @@ func (s *Store) SearchUsers(queryStr string, limit int) ([]models.User, error) {
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan user row", "error", err)
-			return nil, err
+			return nil, 0, err
 		}
 
 		// Convert NullString to regular string
@@ -167,47 +231,57 @@ func (s *Store) SearchUsers(queryStr string, limit int) ([]models.User, error) {
 	}
 
 	s.logger.Info("User search completed",
-		"query", queryStr, "results", len(users), "limit", limit)
-	return users, nil
+		"query", queryStr, "results", len(users), "total", total, "offset", offset, "limit", limit)
+	return users, total, nil
 }
 
-func (s *Store) CreateUserSession(userID, sessionID, deviceInfo, ipAddress string) error {
+func (s *Store) CreateUserSession(session *models.UserSession) error {
 	s.logger.Info("Creating user session",
-		"user_id", userID, "session_id", sessionID,
-		"device_info", deviceInfo[:min(len(deviceInfo), 50)])
+		"user_id", session.UserID, "session_id", session.SessionID,
+		"device_name", session.DeviceName)
 
 	query := `
-		INSERT INTO user_sessions (user_id, session_id, device_info, ip_address)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO user_sessions (user_id, session_id, device_info, browser, os, device_name, ip_address)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)`
 
-	_, err := s.DB.Exec(query, userID, sessionID, deviceInfo, ipAddress)
+	_, err := s.DB.Exec(query,
+		session.UserID, session.SessionID, session.DeviceInfo,
+		session.Browser, session.OS, session.DeviceName, session.IPAddress,
+	)
 	if err != nil {
 		s.logger.Error("Failed to create user session",
-			"error", err, "user_id", userID, "session_id", sessionID)
+			"error", err, "user_id", session.UserID, "session_id", session.SessionID)
 		return err
 	}
 
 	s.logger.Info("User session created successfully",
-		"user_id", userID, "session_id", sessionID)
+		"user_id", session.UserID, "session_id", session.SessionID)
 	return nil
 }
 
+// sessionColumns is the select list matching scanSession
+const sessionColumns = `user_id, session_id, COALESCE(device_info, ''), COALESCE(browser, ''), COALESCE(os, ''),
+		       COALESCE(device_name, ''), label, COALESCE(HOST(ip_address), ''), last_active, created_at, is_active`
+
+func scanSession(row interface{ Scan(...any) error }, session *models.UserSession) error {
+	return row.Scan(
+		&session.UserID, &session.SessionID, &session.DeviceInfo, &session.Browser, &session.OS,
+		&session.DeviceName, &session.Label, &session.IPAddress, &session.LastActive,
+		&session.CreatedAt, &session.IsActive,
+	)
+}
+
 func (s *Store) GetUserSession(sessionID string) (*models.UserSession, error) {
 	s.logger.Debug("Getting user session", "session_id", sessionID)
 
-	query := `
-		SELECT user_id, session_id, device_info, ip_address, last_active, created_at, is_active
-		FROM user_sessions WHERE session_id = $1`
+	query := `SELECT ` + sessionColumns + ` FROM user_sessions WHERE session_id = $1`
 
 	session := &models.UserSession{}
-	err := s.DB.QueryRow(query, sessionID).Scan(
-		&session.UserID, &session.SessionID, &session.DeviceInfo,
-		&session.IPAddress, &session.LastActive, &session.CreatedAt, &session.IsActive,
-	)
+	err := scanSession(s.DB.QueryRow(query, sessionID), session)
 
 	if err == sql.ErrNoRows {
 		s.logger.Debug("User session not found", "session_id", sessionID)
-		return nil, nil
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		s.logger.Error("Failed to get user session",
@@ -220,6 +294,65 @@ func (s *Store) GetUserSession(sessionID string) (*models.UserSession, error) {
 	return session, nil
 }
 
+// GetUserSessions returns a user's sessions, most recently active first
+func (s *Store) GetUserSessions(userID string) ([]models.UserSession, error) {
+	s.logger.Debug("Getting user sessions", "user_id", userID)
+
+	query := `SELECT ` + sessionColumns + `
+		FROM user_sessions
+		WHERE user_id = $1
+		ORDER BY last_active DESC`
+
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		s.logger.Error("Failed to query user sessions", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.UserSession{}
+	for rows.Next() {
+		var session models.UserSession
+		if err := scanSession(rows, &session); err != nil {
+			s.logger.Error("Failed to scan user session", "error", err, "user_id", userID)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating user sessions", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Debug("User sessions retrieved", "user_id", userID, "count", len(sessions))
+	return sessions, nil
+}
+
+// UpdateSessionLabel sets or clears (with nil or "") the name a user gave one of their
+// sessions. It returns the updated session, or ErrNotFound if the user has no such session.
+func (s *Store) UpdateSessionLabel(userID, sessionID string, label *string) (*models.UserSession, error) {
+	s.logger.Info("Updating session label", "user_id", userID, "session_id", sessionID)
+
+	query := `
+		UPDATE user_sessions SET label = NULLIF($3, '')
+		WHERE session_id = $1 AND user_id = $2
+		RETURNING ` + sessionColumns
+
+	session := &models.UserSession{}
+	err := scanSession(s.DB.QueryRow(query, sessionID, userID, label), session)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Session not found for label update", "user_id", userID, "session_id", sessionID)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to update session label",
+			"error", err, "user_id", userID, "session_id", sessionID)
+		return nil, err
+	}
+
+	return session, nil
+}
+
 func (s *Store) UpdateSessionActivity(sessionID string) error {
 	s.logger.Debug("Updating session activity", "session_id", sessionID)
 
@@ -249,33 +382,418 @@ func (s *Store) DeleteSession(sessionID string) error {
 	return nil
 }
 
-func (s *Store) AddContact(userID, contactID, displayName string) error {
+// GetActiveSessionCount returns how many active sessions a user has
+func (s *Store) GetActiveSessionCount(userID string) (int, error) {
+	var count int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM user_sessions WHERE user_id = $1 AND is_active = TRUE`, userID).Scan(&count)
+	if err != nil {
+		s.logger.Error("Failed to count active sessions", "error", err, "user_id", userID)
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteOldestSession deletes the user's least recently active session along with
+// its refresh tokens, and returns it. It returns ErrNotFound if the user
+// has no active sessions.
+func (s *Store) DeleteOldestSession(userID string) (*models.UserSession, error) {
+	query := `
+		DELETE FROM user_sessions
+		WHERE session_id = (
+			SELECT session_id FROM user_sessions
+			WHERE user_id = $1 AND is_active = TRUE
+			ORDER BY last_active, created_at
+			LIMIT 1
+		)
+		RETURNING ` + sessionColumns
+
+	session := &models.UserSession{}
+	err := scanSession(s.DB.QueryRow(query, userID), session)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.Error("Failed to delete oldest session", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Info("Oldest session deleted", "user_id", userID, "session_id", session.SessionID)
+	return session, nil
+}
+
+// CreateRefreshToken stores the hash of a new refresh token for a session
+func (s *Store) CreateRefreshToken(userID, sessionID, tokenHash string, expiresAt time.Time) error {
+	s.logger.Debug("Creating refresh token", "user_id", userID, "session_id", sessionID)
+
+	query := `
+		INSERT INTO refresh_tokens (session_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`
+
+	if _, err := s.DB.Exec(query, sessionID, userID, tokenHash, expiresAt); err != nil {
+		s.logger.Error("Failed to create refresh token",
+			"error", err, "user_id", userID, "session_id", sessionID)
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same session and
+// returns the session's user and session IDs, recording ipAddress as the session's
+// latest address. The old token is marked used rather than
+// deleted: presenting it again returns ErrRefreshTokenReused, along with the IDs of
+// the session it revoked, since either the legitimate client or an attacker is
+// holding a stale copy.
+func (s *Store) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time, ipAddress string) (string, string, error) {
+	s.logger.Debug("Rotating refresh token")
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for RotateRefreshToken", "error", err)
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var userID, sessionID string
+	var tokenExpiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT user_id, session_id, expires_at, used_at
+		FROM refresh_tokens WHERE token_hash = $1
+		FOR UPDATE`,
+		oldHash,
+	).Scan(&userID, &sessionID, &tokenExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		s.logger.Debug("Refresh token not found")
+		return "", "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		s.logger.Error("Failed to get refresh token", "error", err)
+		return "", "", err
+	}
+
+	if usedAt.Valid {
+		s.logger.Warn("Rotated refresh token reused, revoking session",
+			"user_id", userID, "session_id", sessionID, "used_at", usedAt.Time)
+		// Refresh tokens go with the session via ON DELETE CASCADE
+		if _, err := tx.Exec(`DELETE FROM user_sessions WHERE session_id = $1`, sessionID); err != nil {
+			s.logger.Error("Failed to revoke session after refresh token reuse",
+				"error", err, "session_id", sessionID)
+			return "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			s.logger.Error("Failed to commit session revocation", "error", err, "session_id", sessionID)
+			return "", "", err
+		}
+		return userID, sessionID, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(tokenExpiresAt) {
+		s.logger.Debug("Refresh token expired", "session_id", sessionID, "expires_at", tokenExpiresAt)
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1`, oldHash); err != nil {
+		s.logger.Error("Failed to mark refresh token used", "error", err, "session_id", sessionID)
+		return "", "", err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		sessionID, userID, newHash, expiresAt,
+	); err != nil {
+		s.logger.Error("Failed to store rotated refresh token", "error", err, "session_id", sessionID)
+		return "", "", err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE user_sessions SET last_active = CURRENT_TIMESTAMP, ip_address = $2
+		WHERE session_id = $1`,
+		sessionID, ipAddress,
+	); err != nil {
+		s.logger.Error("Failed to update session activity", "error", err, "session_id", sessionID)
+		return "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for RotateRefreshToken", "error", err)
+		return "", "", err
+	}
+
+	s.logger.Info("Refresh token rotated", "user_id", userID, "session_id", sessionID)
+	return userID, sessionID, nil
+}
+
+// AddContact adds contactID to userID's contacts, updating the display name if the
+// contact already exists. With mutual set, userID is also added to contactID's
+// contacts unless already present. It reports whether userID's contact row was
+// newly created.
+func (s *Store) AddContact(userID, contactID, displayName string, mutual bool) (bool, error) {
 	s.logger.Info("Adding contact",
-		"user_id", userID, "contact_id", contactID, "display_name", displayName)
+		"user_id", userID, "contact_id", contactID, "display_name", displayName, "mutual", mutual)
 
+	tx, err := s.DB.Begin()
+	if err != nil {
+		s.logger.Error("Failed to begin transaction for AddContact", "error", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// xmax is zero only for freshly inserted rows
 	query := `
 		INSERT INTO contacts (user_id, contact_id, display_name)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (user_id, contact_id) DO UPDATE
-		SET display_name = EXCLUDED.display_name`
+		SET display_name = EXCLUDED.display_name
+		RETURNING (xmax = 0)`
 
-	_, err := s.DB.Exec(query, userID, contactID, displayName)
+	var created bool
+	err = tx.QueryRow(query, userID, contactID, displayName).Scan(&created)
 	if err != nil {
 		s.logger.Error("Failed to add contact",
 			"error", err, "user_id", userID, "contact_id", contactID)
-		return err
+		return false, err
+	}
+
+	if mutual {
+		_, err = tx.Exec(`
+			INSERT INTO contacts (user_id, contact_id)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, contact_id) DO NOTHING`,
+			contactID, userID,
+		)
+		if err != nil {
+			s.logger.Error("Failed to add reciprocal contact",
+				"error", err, "user_id", userID, "contact_id", contactID)
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit transaction for AddContact", "error", err)
+		return false, err
 	}
 
 	s.logger.Info("Contact added successfully",
-		"user_id", userID, "contact_id", contactID)
+		"user_id", userID, "contact_id", contactID, "created", created, "mutual", mutual)
+	return created, nil
+}
+
+// AddContacts adds several contacts for userID in a single statement, leaving
+// existing contacts and users on either side of a block with userID untouched. It
+// returns the IDs of the contacts that were added.
+func (s *Store) AddContacts(userID string, contacts []models.Contact) ([]string, error) {
+	s.logger.Info("Adding contacts in bulk", "user_id", userID, "count", len(contacts))
+
+	if len(contacts) == 0 {
+		return []string{}, nil
+	}
+
+	contactIDs := make([]string, len(contacts))
+	displayNames := make([]string, len(contacts))
+	for i, contact := range contacts {
+		contactIDs[i] = contact.ContactID
+		displayNames[i] = contact.DisplayName
+	}
+
+	query := `
+		INSERT INTO contacts (user_id, contact_id, display_name)
+		SELECT $1, c.contact_id, NULLIF(c.display_name, '')
+		FROM unnest($2::uuid[], $3::text[]) AS c(contact_id, display_name)
+		WHERE c.contact_id <> $1
+		AND NOT EXISTS (
+			SELECT 1 FROM blocked_users b
+			WHERE (b.user_id = $1 AND b.blocked_id = c.contact_id)
+			OR (b.user_id = c.contact_id AND b.blocked_id = $1)
+		)
+		ON CONFLICT (user_id, contact_id) DO NOTHING
+		RETURNING contact_id`
+
+	rows, err := s.DB.Query(query, userID, pq.Array(contactIDs), pq.Array(displayNames))
+	if err != nil {
+		s.logger.Error("Failed to add contacts in bulk", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	added := []string{}
+	for rows.Next() {
+		var contactID string
+		if err := rows.Scan(&contactID); err != nil {
+			s.logger.Error("Failed to scan added contact", "error", err, "user_id", userID)
+			return nil, err
+		}
+		added = append(added, contactID)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to add contacts in bulk", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Info("Contacts added in bulk", "user_id", userID, "requested", len(contacts), "added", len(added))
+	return added, nil
+}
+
+// AreContacts reports whether both users have each other in their contacts
+func (s *Store) AreContacts(userA, userB string) (bool, error) {
+	s.logger.Debug("Checking mutual contacts", "user_a", userA, "user_b", userB)
+
+	query := `
+		SELECT COUNT(*) = 2
+		FROM contacts
+		WHERE (user_id = $1 AND contact_id = $2)
+		OR (user_id = $2 AND contact_id = $1)`
+
+	var mutual bool
+	err := s.DB.QueryRow(query, userA, userB).Scan(&mutual)
+	if err != nil {
+		s.logger.Error("Failed to check mutual contacts",
+			"error", err, "user_a", userA, "user_b", userB)
+		return false, err
+	}
+
+	return mutual, nil
+}
+
+// BlockUser records that userID blocked blockedID. Blocking someone already
+// blocked changes nothing.
+func (s *Store) BlockUser(userID, blockedID string) error {
+	s.logger.Info("Blocking user", "user_id", userID, "blocked_id", blockedID)
+
+	query := `
+		INSERT INTO blocked_users (user_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, blocked_id) DO NOTHING`
+
+	if _, err := s.DB.Exec(query, userID, blockedID); err != nil {
+		s.logger.Error("Failed to block user",
+			"error", err, "user_id", userID, "blocked_id", blockedID)
+		return err
+	}
+
+	s.logger.Info("User blocked successfully", "user_id", userID, "blocked_id", blockedID)
+	return nil
+}
+
+// UnblockUser lifts userID's block on blockedID, if there is one. A block placed
+// the other way, by blockedID on userID, stays.
+func (s *Store) UnblockUser(userID, blockedID string) error {
+	s.logger.Info("Unblocking user", "user_id", userID, "blocked_id", blockedID)
+
+	query := `DELETE FROM blocked_users WHERE user_id = $1 AND blocked_id = $2`
+	if _, err := s.DB.Exec(query, userID, blockedID); err != nil {
+		s.logger.Error("Failed to unblock user",
+			"error", err, "user_id", userID, "blocked_id", blockedID)
+		return err
+	}
+
+	s.logger.Info("User unblocked successfully", "user_id", userID, "blocked_id", blockedID)
 	return nil
 }
 
+// GetBlockedUsers returns the users userID has blocked, most recently blocked first
+func (s *Store) GetBlockedUsers(userID string) ([]models.User, error) {
+	s.logger.Debug("Getting blocked users", "user_id", userID)
+
+	query := `
+		SELECT u.id, u.phone, u.name, u.status, u.avatar_url, u.last_seen, u.created_at, u.updated_at
+		FROM blocked_users b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.user_id = $1
+		ORDER BY b.created_at DESC, u.id`
+
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		s.logger.Error("Failed to get blocked users", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID, &user.Phone, &user.Name, &user.Status,
+			&user.AvatarURL, &user.LastSeen, &user.CreatedAt, &user.UpdatedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan blocked user row", "error", err, "user_id", userID)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to get blocked users", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Debug("Blocked users retrieved", "user_id", userID, "count", len(users))
+	return users, nil
+}
+
+// IsBlocked reports whether either user has blocked the other
+func (s *Store) IsBlocked(userA, userB string) (bool, error) {
+	s.logger.Debug("Checking block status", "user_a", userA, "user_b", userB)
+
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM blocked_users
+			WHERE (user_id = $1 AND blocked_id = $2)
+			OR (user_id = $2 AND blocked_id = $1)
+		)`
+
+	var blocked bool
+	err := s.DB.QueryRow(query, userA, userB).Scan(&blocked)
+	if err != nil {
+		s.logger.Error("Failed to check block status",
+			"error", err, "user_a", userA, "user_b", userB)
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// GetContactDisplayNames returns the names userID saved for any of targetIDs in
+// their contacts, keyed by contact ID. Targets without a saved name are omitted.
+func (s *Store) GetContactDisplayNames(userID string, targetIDs []string) (map[string]string, error) {
+	s.logger.Debug("Getting contact display names", "user_id", userID, "target_count", len(targetIDs))
+
+	names := make(map[string]string)
+	if len(targetIDs) == 0 {
+		return names, nil
+	}
+
+	query := `
+		SELECT contact_id, display_name
+		FROM contacts
+		WHERE user_id = $1 AND contact_id = ANY($2)
+		AND display_name IS NOT NULL AND display_name <> ''`
+
+	rows, err := s.DB.Query(query, userID, pq.Array(targetIDs))
+	if err != nil {
+		s.logger.Error("Failed to get contact display names", "error", err, "user_id", userID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contactID, displayName string
+		if err := rows.Scan(&contactID, &displayName); err != nil {
+			s.logger.Error("Failed to scan contact display name", "error", err, "user_id", userID)
+			return nil, err
+		}
+		names[contactID] = displayName
+	}
+
+	return names, rows.Err()
+}
+
 func (s *Store) GetContacts(userID string) ([]models.User, error) {
 	s.logger.Debug("Getting contacts", "user_id", userID)
 
 	query := `
-		SELECT u.id, u.phone, u.name, u.status, u.avatar_url, u.last_seen, u.created_at, u.updated_at
+		SELECT u.id, u.phone, u.name, u.status, u.avatar_url, u.last_seen, u.created_at, u.updated_at,
+		       COALESCE(c.display_name, '')
 		FROM contacts c
 		JOIN users u ON c.contact_id = u.id
 		WHERE c.user_id = $1
@@ -294,6 +812,7 @@ func (s *Store) GetContacts(userID string) ([]models.User, error) {
 		err := rows.Scan(
 			&user.ID, &user.Phone, &user.Name, &user.Status,
 			&user.AvatarURL, &user.LastSeen, &user.CreatedAt, &user.UpdatedAt,
+			&user.DisplayName,
 		)
 		if err != nil {
 			s.logger.Error("Failed to scan contact row", "error", err, "user_id", userID)
@@ -361,26 +880,236 @@ func (s *Store) GetUsersByIDs(userIDs []string) ([]models.User, error) {
 	return users, nil
 }
 
+// GetUsersByPhones returns the registered users among the given phone numbers,
+// leaving out the requester and anyone on either side of a block with them
+func (s *Store) GetUsersByPhones(requesterID string, phones []string) ([]models.User, error) {
+	s.logger.Debug("Getting users by phones", "requester_id", requesterID, "phone_count", len(phones))
+
+	if len(phones) == 0 {
+		return []models.User{}, nil
+	}
+
+	query := `
+		SELECT u.id, u.phone, u.name, u.status, u.avatar_url, u.last_seen, u.created_at, u.updated_at
+		FROM users u
+		WHERE u.phone = ANY($1)
+		AND u.id <> $2
+		AND NOT EXISTS (
+			SELECT 1 FROM blocked_users b
+			WHERE (b.user_id = $2 AND b.blocked_id = u.id)
+			OR (b.user_id = u.id AND b.blocked_id = $2)
+		)
+		ORDER BY u.name`
+
+	rows, err := s.DB.Query(query, pq.Array(phones), requesterID)
+	if err != nil {
+		s.logger.Error("Failed to get users by phones",
+			"error", err, "requester_id", requesterID, "phone_count", len(phones))
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID, &user.Phone, &user.Name, &user.Status,
+			&user.AvatarURL, &user.LastSeen, &user.CreatedAt, &user.UpdatedAt,
+		)
+		if err != nil {
+			s.logger.Error("Failed to scan user row in GetUsersByPhones", "error", err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	s.logger.Debug("Users retrieved by phones", "requested", len(phones), "found", len(users))
+	return users, nil
+}
+
 func (s *Store) GetOnlineUsers() ([]string, error) {
 	s.logger.Debug("Getting online users")
 
-	// Check Redis for online users
-	pattern := "presence:*"
-	keys, err := s.RDB.Keys(s.Ctx, pattern).Result()
+	if !s.RedisAvailable() {
+		return s.local.onlineUsers(), nil
+	}
+
+	members, err := s.RDB.SMembers(s.Ctx, onlineUsersKey).Result()
 	if err != nil {
 		s.logger.Error("Failed to get online users from Redis", "error", err)
 		return nil, err
 	}
 
-	var userIDs []string
-	for _, key := range keys {
-		// Extract user ID from key
-		if len(key) > 9 { // "presence:".length = 9
-			userID := key[9:]
+	if len(members) == 0 {
+		return []string{}, nil
+	}
+
+	// A member whose presence key has expired belongs to an instance that stopped
+	// refreshing it (e.g. crashed), so treat it as offline and clean it up
+	pipe := s.RDB.Pipeline()
+	checks := make([]*redis.IntCmd, len(members))
+	for i, userID := range members {
+		checks[i] = pipe.Exists(s.Ctx, userPresenceKey(userID))
+	}
+	if _, err := pipe.Exec(s.Ctx); err != nil {
+		s.logger.Error("Failed to check presence keys", "error", err, "member_count", len(members))
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(members))
+	var stale []interface{}
+	for i, userID := range members {
+		if checks[i].Val() > 0 {
 			userIDs = append(userIDs, userID)
+		} else {
+			stale = append(stale, userID)
+		}
+	}
+
+	if len(stale) > 0 {
+		if err := s.RDB.SRem(s.Ctx, onlineUsersKey, stale...).Err(); err != nil {
+			s.logger.Warn("Failed to remove stale online users", "error", err, "stale_count", len(stale))
+		} else {
+			s.logger.Debug("Removed stale online users", "stale_count", len(stale))
 		}
 	}
 
 	s.logger.Debug("Online users retrieved", "count", len(userIDs))
 	return userIDs, nil
 }
+
+// GetNotificationSettings returns the user's notification settings, or the
+// defaults if they never changed them
+func (s *Store) GetNotificationSettings(userID string) (*models.NotificationSettings, error) {
+	s.logger.Debug("Getting notification settings", "user_id", userID)
+
+	query := `
+		SELECT mute_all, sound, preview_enabled,
+		       to_char(quiet_hours_start, 'HH24:MI'), to_char(quiet_hours_end, 'HH24:MI'),
+		       timezone, updated_at
+		FROM notification_settings
+		WHERE user_id = $1`
+
+	settings := &models.NotificationSettings{}
+	err := s.DB.QueryRow(query, userID).Scan(
+		&settings.MuteAll, &settings.Sound, &settings.PreviewEnabled,
+		&settings.QuietHoursStart, &settings.QuietHoursEnd,
+		&settings.Timezone, &settings.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return models.DefaultNotificationSettings(), nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get notification settings", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// SaveNotificationSettings stores the user's notification settings, replacing any
+// previous ones, and sets settings.UpdatedAt
+func (s *Store) SaveNotificationSettings(userID string, settings *models.NotificationSettings) error {
+	s.logger.Info("Saving notification settings", "user_id", userID)
+
+	query := `
+		INSERT INTO notification_settings
+			(user_id, mute_all, sound, preview_enabled, quiet_hours_start, quiet_hours_end, timezone, updated_at)
+		VALUES ($1, $2, $3, $4, $5::time, $6::time, $7, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			mute_all = EXCLUDED.mute_all,
+			sound = EXCLUDED.sound,
+			preview_enabled = EXCLUDED.preview_enabled,
+			quiet_hours_start = EXCLUDED.quiet_hours_start,
+			quiet_hours_end = EXCLUDED.quiet_hours_end,
+			timezone = EXCLUDED.timezone,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`
+
+	err := s.DB.QueryRow(query, userID, settings.MuteAll, settings.Sound, settings.PreviewEnabled,
+		settings.QuietHoursStart, settings.QuietHoursEnd, settings.Timezone,
+	).Scan(&settings.UpdatedAt)
+	if err != nil {
+		s.logger.Error("Failed to save notification settings", "error", err, "user_id", userID)
+		return err
+	}
+
+	return nil
+}
+
+// SavePushToken registers a device's push token for the user. Registering a token
+// again refreshes it, and moves it over if another user registered it before.
+func (s *Store) SavePushToken(userID, token string, platform models.PushPlatform) (*models.PushToken, error) {
+	s.logger.Info("Saving push token", "user_id", userID, "platform", platform)
+
+	query := `
+		INSERT INTO push_tokens (token, user_id, platform, created_at, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			platform = EXCLUDED.platform,
+			updated_at = EXCLUDED.updated_at
+		RETURNING token, user_id, platform, created_at, updated_at`
+
+	pushToken := &models.PushToken{}
+	err := s.DB.QueryRow(query, token, userID, platform).Scan(
+		&pushToken.Token, &pushToken.UserID, &pushToken.Platform,
+		&pushToken.CreatedAt, &pushToken.UpdatedAt,
+	)
+	if err != nil {
+		s.logger.Error("Failed to save push token", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	return pushToken, nil
+}
+
+// GetPushTokens returns the push tokens registered by any of the users
+func (s *Store) GetPushTokens(userIDs []string) ([]models.PushToken, error) {
+	s.logger.Debug("Getting push tokens", "user_count", len(userIDs))
+
+	if len(userIDs) == 0 {
+		return []models.PushToken{}, nil
+	}
+
+	query := `
+		SELECT token, user_id, platform, created_at, updated_at
+		FROM push_tokens
+		WHERE user_id = ANY($1)`
+
+	rows, err := s.DB.Query(query, pq.Array(userIDs))
+	if err != nil {
+		s.logger.Error("Failed to get push tokens", "error", err, "user_count", len(userIDs))
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PushToken{}
+	for rows.Next() {
+		var pushToken models.PushToken
+		if err := rows.Scan(&pushToken.Token, &pushToken.UserID, &pushToken.Platform,
+			&pushToken.CreatedAt, &pushToken.UpdatedAt); err != nil {
+			s.logger.Error("Failed to scan push token row", "error", err)
+			return nil, err
+		}
+		tokens = append(tokens, pushToken)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to get push tokens", "error", err, "user_count", len(userIDs))
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeletePushToken forgets a token, e.g. once the push service reports the device
+// unregistered
+func (s *Store) DeletePushToken(token string) error {
+	s.logger.Info("Deleting push token")
+
+	if _, err := s.DB.Exec(`DELETE FROM push_tokens WHERE token = $1`, token); err != nil {
+		s.logger.Error("Failed to delete push token", "error", err)
+		return err
+	}
+	return nil
+}
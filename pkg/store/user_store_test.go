@@ -0,0 +1,60 @@
+package store
+
+import "testing"
+
+func TestBlockAndUnblockUser(t *testing.T) {
+	s := newTestStore(t)
+	alice, bob := createTestUser(t, s), createTestUser(t, s)
+
+	if err := s.BlockUser(alice.ID, bob.ID); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+	// Blocking twice is a no-op
+	if err := s.BlockUser(alice.ID, bob.ID); err != nil {
+		t.Fatalf("BlockUser again: %v", err)
+	}
+
+	// A block stops messages in both directions
+	for _, pair := range [][2]string{{alice.ID, bob.ID}, {bob.ID, alice.ID}} {
+		blocked, err := s.IsBlocked(pair[0], pair[1])
+		if err != nil {
+			t.Fatalf("IsBlocked(%s, %s): %v", pair[0], pair[1], err)
+		}
+		if !blocked {
+			t.Errorf("IsBlocked(%s, %s) = false after block, want true", pair[0], pair[1])
+		}
+		canMessage, err := s.CanMessageUser(pair[0], pair[1])
+		if err != nil {
+			t.Fatalf("CanMessageUser(%s, %s): %v", pair[0], pair[1], err)
+		}
+		if canMessage {
+			t.Errorf("CanMessageUser(%s, %s) = true after block, want false", pair[0], pair[1])
+		}
+	}
+
+	blockedUsers, err := s.GetBlockedUsers(alice.ID)
+	if err != nil {
+		t.Fatalf("GetBlockedUsers: %v", err)
+	}
+	if len(blockedUsers) != 1 || blockedUsers[0].ID != bob.ID {
+		t.Errorf("GetBlockedUsers = %+v, want only %s", blockedUsers, bob.ID)
+	}
+
+	// Only the user who placed the block can lift it
+	if err := s.UnblockUser(bob.ID, alice.ID); err != nil {
+		t.Fatalf("UnblockUser by the blocked user: %v", err)
+	}
+	if blocked, _ := s.IsBlocked(alice.ID, bob.ID); !blocked {
+		t.Error("the blocked user lifted the block")
+	}
+
+	if err := s.UnblockUser(alice.ID, bob.ID); err != nil {
+		t.Fatalf("UnblockUser: %v", err)
+	}
+	if blocked, err := s.IsBlocked(alice.ID, bob.ID); err != nil || blocked {
+		t.Errorf("IsBlocked after unblock = %v, %v; want false", blocked, err)
+	}
+	if canMessage, err := s.CanMessageUser(alice.ID, bob.ID); err != nil || !canMessage {
+		t.Errorf("CanMessageUser after unblock = %v, %v; want true", canMessage, err)
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"github.com/lib/pq"
+
+	"github.com/msniranjan18/chit-chat/pkg/models"
+)
+
+// CreateWebhook registers a webhook for a user
+func (s *Store) CreateWebhook(userID, url, secret string, events []models.WebhookEventType) (*models.Webhook, error) {
+	s.logger.Info("Creating webhook", "user_id", userID, "url", url, "events", events)
+
+	webhook := &models.Webhook{
+		UserID: userID,
+		URL:    url,
+		Secret: secret,
+		Events: events,
+	}
+
+	eventNames := make([]string, len(events))
+	for i, event := range events {
+		eventNames[i] = string(event)
+	}
+
+	query := `
+		INSERT INTO webhooks (user_id, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := s.DB.QueryRow(query, userID, url, secret, pq.Array(eventNames)).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		s.logger.Error("Failed to create webhook", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	s.logger.Info("Webhook created", "webhook_id", webhook.ID, "user_id", userID)
+	return webhook, nil
+}
+
+// DeleteWebhook removes one of a user's webhooks, reporting whether it existed
+func (s *Store) DeleteWebhook(userID, webhookID string) (bool, error) {
+	s.logger.Info("Deleting webhook", "user_id", userID, "webhook_id", webhookID)
+
+	result, err := s.DB.Exec(`DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, webhookID, userID)
+	if err != nil {
+		s.logger.Error("Failed to delete webhook",
+			"error", err, "user_id", userID, "webhook_id", webhookID)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// GetChatWebhooks returns the webhooks subscribed to event whose owners are members
+// of the chat, secrets included for signing deliveries
+func (s *Store) GetChatWebhooks(chatID string, event models.WebhookEventType) ([]models.Webhook, error) {
+	s.logger.Debug("Getting chat webhooks", "chat_id", chatID, "event", event)
+
+	query := `
+		SELECT w.id, w.user_id, w.url, w.secret, w.events, w.created_at
+		FROM webhooks w
+		JOIN chat_members cm ON cm.user_id = w.user_id
+		WHERE cm.chat_id = $1
+		AND cm.is_banned = FALSE
+		AND $2 = ANY(w.events)`
+
+	rows, err := s.DB.Query(query, chatID, event)
+	if err != nil {
+		s.logger.Error("Failed to query chat webhooks", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var webhook models.Webhook
+		var events []string
+		if err := rows.Scan(
+			&webhook.ID, &webhook.UserID, &webhook.URL,
+			&webhook.Secret, pq.Array(&events), &webhook.CreatedAt,
+		); err != nil {
+			s.logger.Error("Failed to scan webhook", "error", err, "chat_id", chatID)
+			return nil, err
+		}
+		for _, e := range events {
+			webhook.Events = append(webhook.Events, models.WebhookEventType(e))
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Error iterating chat webhooks", "error", err, "chat_id", chatID)
+		return nil, err
+	}
+
+	return webhooks, nil
+}
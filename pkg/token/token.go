@@ -0,0 +1,98 @@
+// Package token issues access tokens with a configurable lifetime, the opaque
+// refresh tokens used to renew them, and bot API keys. The shared common/jwt package fixes token
+// lifetime at seven days, so tokens are minted here with the same claims, issuer
+// and secret; validation (including the auth middleware) keeps using common/jwt.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v4"
+	"github.com/msniranjan18/common/jwt"
+)
+
+const issuer = "chitchat"
+
+// apiKeyPrefix marks API keys so they are recognizable in configs and logs
+const apiKeyPrefix = "ccbot_"
+
+var (
+	secret     []byte
+	expiration = 7 * 24 * time.Hour
+)
+
+// InitJWT sets the signing secret and token lifetime, and initializes common/jwt
+// with the same secret so it can validate the tokens issued here. A non-positive
+// expiration keeps the seven day default.
+func InitJWT(jwtSecret string, tokenExpiration time.Duration) {
+	jwt.InitJWT(jwtSecret)
+	secret = []byte(jwtSecret)
+	if tokenExpiration > 0 {
+		expiration = tokenExpiration
+	}
+}
+
+// GenerateJWT issues an access token for a user session that expires after the
+// configured lifetime
+func GenerateJWT(userID, sessionID string) (string, time.Time, error) {
+	if secret == nil {
+		return "", time.Time{}, errors.New("JWT not initialized")
+	}
+
+	now := time.Now()
+	expirationTime := now.Add(expiration)
+	claims := &jwt.Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwtlib.RegisteredClaims{
+			ExpiresAt: jwtlib.NewNumericDate(expirationTime),
+			IssuedAt:  jwtlib.NewNumericDate(now),
+			Issuer:    issuer,
+		},
+	}
+
+	tokenString, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", expirationTime, err
+	}
+	return tokenString, expirationTime, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token and the hash to store for it.
+// Only the hash is persisted, so a leaked database doesn't yield usable tokens.
+func NewRefreshToken() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	return raw, HashRefreshToken(raw), nil
+}
+
+// HashRefreshToken returns the stored form of a refresh token
+func HashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey returns a random bot API key and the hash to store for it. Like refresh
+// tokens, only the hash is persisted.
+func NewAPIKey() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return raw, HashAPIKey(raw), nil
+}
+
+// HashAPIKey returns the stored form of an API key
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
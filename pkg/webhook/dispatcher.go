@@ -0,0 +1,205 @@
+// Package webhook delivers chat events to the HTTP endpoints users register.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/msniranjan18/chit-chat/config"
+	"github.com/msniranjan18/chit-chat/pkg/models"
+	"github.com/msniranjan18/chit-chat/pkg/store"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-ChitChat-Event"
+	HeaderDelivery  = "X-ChitChat-Delivery"
+	HeaderSignature = "X-ChitChat-Signature"
+)
+
+// ErrForbiddenAddress is returned for webhook hosts that are loopback, link-local
+// or private, so webhooks can't be used to reach the server's own network
+var ErrForbiddenAddress = errors.New("webhook host is not a public address")
+
+type Dispatcher struct {
+	store  *store.Store
+	client *http.Client
+	cfg    config.WebhookConfig
+	logger *slog.Logger
+}
+
+func NewDispatcher(store *store.Store, cfg config.WebhookConfig, logger *slog.Logger) *Dispatcher {
+	// Addresses are checked again as each connection is made: a host that was public
+	// when the webhook was registered may resolve elsewhere later, or redirect there
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: checkDialAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would be dialed in the webhook host's place, skipping the check
+	transport.Proxy = nil
+
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		cfg:    cfg,
+		logger: logger.With("component", "webhook"),
+	}
+}
+
+// publicIP reports whether deliveries may connect to ip
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// CheckHost resolves host and returns ErrForbiddenAddress if any of its addresses
+// is not public
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// checkDialAddress is a net.Dialer Control function that refuses connections to
+// addresses that are not public. It sees the resolved IP, not the host name.
+func checkDialAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body: an HMAC-SHA256 keyed with the
+// webhook's secret, hex encoded and prefixed with "sha256="
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch delivers event in the background to every webhook subscribed to it whose
+// owner is a member of the event's chat. It never blocks the caller, and a nil
+// Dispatcher drops events.
+func (d *Dispatcher) Dispatch(event models.WebhookEvent) {
+	if d == nil {
+		return
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	go d.dispatch(event)
+}
+
+func (d *Dispatcher) dispatch(event models.WebhookEvent) {
+	webhooks, err := d.store.GetChatWebhooks(event.ChatID, event.Event)
+	if err != nil {
+		d.logger.Error("Failed to get webhooks for event",
+			"error", err, "event", event.Event, "chat_id", event.ChatID)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("Failed to marshal webhook event",
+			"error", err, "event", event.Event, "event_id", event.ID)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		go d.deliver(webhook, event, body)
+	}
+}
+
+// deliver POSTs body to a webhook, retrying with doubling delays until it gets a 2xx
+// response, attempts run out or the store shuts down
+func (d *Dispatcher) deliver(webhook models.Webhook, event models.WebhookEvent, body []byte) {
+	signature := Sign(webhook.Secret, body)
+	delay := d.cfg.RetryBackoff
+
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		err := d.post(webhook.URL, event, signature, body)
+		if err == nil {
+			d.logger.Debug("Webhook delivered",
+				"webhook_id", webhook.ID, "event", event.Event, "event_id", event.ID, "attempt", attempt)
+			return
+		}
+
+		d.logger.Warn("Webhook delivery failed",
+			"error", err, "webhook_id", webhook.ID, "event", event.Event,
+			"event_id", event.ID, "attempt", attempt)
+
+		if attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-d.store.Ctx.Done():
+			return
+		}
+		delay *= 2
+	}
+
+	d.logger.Error("Webhook delivery abandoned",
+		"webhook_id", webhook.ID, "event", event.Event, "event_id", event.ID, "attempts", d.cfg.MaxAttempts)
+}
+
+func (d *Dispatcher) post(url string, event models.WebhookEvent, signature string, body []byte) error {
+	req, err := http.NewRequestWithContext(d.store.Ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Event))
+	req.Header.Set(HeaderDelivery, event.ID)
+	req.Header.Set(HeaderSignature, signature)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckHostRejectsInternalAddresses(t *testing.T) {
+	for _, host := range []string{
+		"127.0.0.1", "::1", "10.0.0.5", "172.16.3.4", "192.168.1.1",
+		"169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "::ffff:127.0.0.1",
+	} {
+		if err := CheckHost(context.Background(), host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+}
+
+func TestCheckHostAllowsPublicAddresses(t *testing.T) {
+	for _, host := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		if err := CheckHost(context.Background(), host); err != nil {
+			t.Errorf("CheckHost(%q) = %v, want nil", host, err)
+		}
+	}
+}
+
+func TestCheckDialAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:8080", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"169.254.169.254:80", false},
+	}
+
+	for _, tt := range tests {
+		err := checkDialAddress("tcp", tt.address, nil)
+		if tt.allowed && err != nil {
+			t.Errorf("checkDialAddress(%q) = %v, want nil", tt.address, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("checkDialAddress(%q) = %v, want ErrForbiddenAddress", tt.address, err)
+		}
+	}
+}
@@ -153,7 +153,7 @@ class ChatManager {
             
             if (!response.ok) throw new Error('Failed to load users');
             
-            const users = await response.json();
+            const { results: users } = await response.json();
             
             // Check if users is an array
             if (Array.isArray(users)) {
@@ -179,7 +179,7 @@ class ChatManager {
             
             if (!response.ok) throw new Error('Search failed');
             
-            const users = await response.json();
+            const { results: users } = await response.json();
             this.app.uiManager.renderUserList(users);
         } catch (error) {
             console.error('Search error:', error);
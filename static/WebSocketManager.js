@@ -7,7 +7,8 @@ class WebSocketManager {
     connectWebSocket() {
         if (!this.app.token) return;
 
-        const wsUrl = `ws://${window.location.host}/ws?token=${this.app.token}`;
+        // Announce the protocol versions this client understands; see handleWebSocketMessage
+        const wsUrl = `ws://${window.location.host}/ws?token=${this.app.token}&versions=1,2`;
         this.app.ws = new WebSocket(wsUrl);
 
         this.app.ws.onopen = () => {
@@ -53,9 +54,44 @@ class WebSocketManager {
             case 'chat_update':
                 this.app.chatManager.handleChatUpdate(data.payload);
                 break;
+            case 'hello':
+                this.app.wsVersion = data.payload.version;
+                break;
+            case 'error':
+                this.handleError(data.payload);
+                break;
+            case 'presence_snapshot':
+                this.handlePresenceSnapshot(data.payload);
+                break;
+            case 'mention':
+                this.handleMention(data.payload);
+                break;
+            case 'resumed':
+                console.log(`Resumed chat ${data.payload.chat_id}: ${data.payload.count} messages`);
+                break;
         }
     }
 
+    handleError(data) {
+        console.error('WebSocket request failed:', data.code, data.message, data.ref);
+        this.app.uiManager.showError(data.message);
+    }
+
+    handlePresenceSnapshot(data) {
+        // Everyone in the snapshot is online; peers missing from it are offline
+        (data.online_user_ids || []).forEach(userId => {
+            this.handlePresenceUpdate({ user_id: userId, is_online: true });
+        });
+    }
+
+    handleMention(data) {
+        if (data.chat_id === this.app.activeChat) return;
+
+        const chat = this.app.chats.get(data.chat_id);
+        const chatName = chat ? this.app.chatManager.getChatName(chat) : 'a chat';
+        this.app.uiManager.showToast(`You were mentioned in ${chatName}`, 'info');
+    }
+
     handleTypingIndicator(data) {
         if (data.chat_id === this.app.activeChat && data.user_id !== this.app.user?.id) {
             const typingIndicator = document.getElementById('typing-indicator');